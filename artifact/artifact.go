@@ -0,0 +1,178 @@
+// Package artifact reads and writes stored pipeline outputs (transcripts,
+// cached audio and blobs), with optional AES-256-GCM encryption at rest.
+package artifact
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// encryptedMagic prefixes every encrypted artifact so plaintext files written
+// before encryption was enabled can still be read.
+var encryptedMagic = []byte("VSENC1")
+
+// KeyProvider supplies the 32-byte AES-256 data key.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// envKeyProvider reads a base64 key from an env var.
+type envKeyProvider struct{ name string }
+
+func (p envKeyProvider) Key() ([]byte, error) {
+	return decodeKey(os.Getenv(p.name))
+}
+
+// fileKeyProvider reads a base64 key from a file (e.g. a mounted secret).
+type fileKeyProvider struct{ path string }
+
+func (p fileKeyProvider) Key() ([]byte, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return decodeKey(string(data))
+}
+
+// commandKeyProvider runs a command that prints the base64 key on stdout.
+// This is how KMS integrations plug in, e.g.
+// `aws kms decrypt --ciphertext-blob fileb://key.enc --query Plaintext --output text`.
+type commandKeyProvider struct{ command string }
+
+func (p commandKeyProvider) Key() ([]byte, error) {
+	out, err := exec.Command("sh", "-c", p.command).Output()
+	if err != nil {
+		return nil, fmt.Errorf("key command failed: %w", err)
+	}
+	return decodeKey(string(out))
+}
+
+func decodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Encryptor seals artifacts with AES-256-GCM.
+type Encryptor struct {
+	aead cipher.AEAD
+}
+
+// NewEncryptor builds an encryptor from the provider's key.
+func NewEncryptor(p KeyProvider) (*Encryptor, error) {
+	key, err := p.Key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Encryptor{aead: aead}, nil
+}
+
+// Seal returns magic || nonce || ciphertext.
+func (e *Encryptor) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, nonce...)
+	return e.aead.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// Open reverses Seal.
+func (e *Encryptor) Open(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(data, encryptedMagic)
+	ns := e.aead.NonceSize()
+	if len(data) < ns {
+		return nil, fmt.Errorf("encrypted artifact too short")
+	}
+	return e.aead.Open(nil, data[:ns], data[ns:], encryptedMagic)
+}
+
+var (
+	artifactEncryptor     *Encryptor
+	artifactEncryptorErr  error
+	artifactEncryptorOnce sync.Once
+)
+
+// EncryptorFromEnv returns the configured encryptor, or nil when encryption at rest
+// is disabled. Keys come from ENCRYPTION_KEY (base64), ENCRYPTION_KEY_FILE or
+// ENCRYPTION_KEY_COMMAND (for KMS), checked in that order. It covers
+// transcript files, the audio cache and everything in the blob store; the
+// transcript index (INDEX_DB) stays plaintext, since full-text search reads
+// it, and belongs on an encrypted volume.
+func EncryptorFromEnv() (*Encryptor, error) {
+	artifactEncryptorOnce.Do(func() {
+		var p KeyProvider
+		switch {
+		case os.Getenv("ENCRYPTION_KEY") != "":
+			p = envKeyProvider{name: "ENCRYPTION_KEY"}
+		case os.Getenv("ENCRYPTION_KEY_FILE") != "":
+			p = fileKeyProvider{path: os.Getenv("ENCRYPTION_KEY_FILE")}
+		case os.Getenv("ENCRYPTION_KEY_COMMAND") != "":
+			p = commandKeyProvider{command: os.Getenv("ENCRYPTION_KEY_COMMAND")}
+		default:
+			return
+		}
+		artifactEncryptor, artifactEncryptorErr = NewEncryptor(p)
+		if artifactEncryptorErr == nil {
+			log.Printf("Encryption at rest enabled for transcripts, cached audio and blobs; the transcript index is not encrypted")
+		}
+	})
+	return artifactEncryptor, artifactEncryptorErr
+}
+
+// Write writes a stored artifact, encrypting it when a key is configured.
+func Write(path string, data []byte) error {
+	enc, err := EncryptorFromEnv()
+	if err != nil {
+		return fmt.Errorf("encryption unavailable: %w", err)
+	}
+	if enc != nil {
+		if data, err = enc.Seal(data); err != nil {
+			return fmt.Errorf("failed to encrypt artifact: %w", err)
+		}
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Read reads a stored artifact, decrypting it if it was written encrypted.
+func Read(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, nil
+	}
+	enc, err := EncryptorFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("encryption unavailable: %w", err)
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("artifact %s is encrypted but no key is configured", path)
+	}
+	return enc.Open(data)
+}
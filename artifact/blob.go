@@ -0,0 +1,312 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlobStore keeps artifacts by key outside the local disk, so replicas
+// share transcripts, cached audio and clips and can be replaced freely.
+// Keys are slash-separated paths such as "transcripts/abc.json".
+type BlobStore interface {
+	// Get copies the blob at key to the local file path; found is false
+	// when there is no such blob.
+	Get(ctx context.Context, key, path string) (found bool, err error)
+	// Put copies the local file at path to key, replacing any blob there.
+	Put(ctx context.Context, key, path string) error
+	// Delete removes the blob at key; a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is a link to the blob that needs no credentials, valid for ttl.
+	URL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ErrNoURL is returned by BlobStore.URL when the store can't link to blobs.
+var ErrNoURL = errors.New("this blob store can't hand out links")
+
+var (
+	sharedBlobStoreOnce sync.Once
+	sharedBlobStore     BlobStore
+)
+
+// SharedBlobStore is the process's blob store from BLOB_STORE_URI:
+// s3://bucket/prefix (through the AWS CLI, AWS_CLI_PATH), gs://bucket/prefix
+// (through the Google Cloud CLI, GCLOUD_PATH) or a directory, e.g. a shared
+// mount. It is nil when unset, and artifacts stay on local disk only. With
+// encryption at rest (see EncryptorFromEnv) every blob is stored encrypted.
+func SharedBlobStore() BlobStore {
+	sharedBlobStoreOnce.Do(func() {
+		uri := os.Getenv("BLOB_STORE_URI")
+		if uri == "" {
+			return
+		}
+		s, err := OpenBlobStore(uri)
+		if err != nil {
+			log.Printf("blob store disabled: %v", err)
+			return
+		}
+		enc, err := EncryptorFromEnv()
+		if err != nil {
+			log.Printf("blob store disabled: encryption unavailable: %v", err)
+			return
+		}
+		if enc != nil {
+			s = EncryptedBlobStore{BlobStore: s, Encryptor: enc}
+		}
+		log.Printf("Sharing artifacts through the blob store at %s", uri)
+		sharedBlobStore = s
+	})
+	return sharedBlobStore
+}
+
+// EncryptedBlobStore seals files (see Encryptor.SealFile) on their way into
+// BlobStore and opens them on the way out. Files that are already encrypted,
+// like transcripts written by Write, are stored as they are, and blobs put
+// before encryption was turned on are read back as they are. Encrypted blobs
+// are no use to anyone without the key, so it hands out no links.
+type EncryptedBlobStore struct {
+	BlobStore
+	Encryptor *Encryptor
+}
+
+func (s EncryptedBlobStore) Get(ctx context.Context, key, path string) (bool, error) {
+	sealed := path + ".sealed"
+	found, err := s.BlobStore.Get(ctx, key, sealed)
+	if !found || err != nil {
+		_ = os.Remove(sealed)
+		return found, err
+	}
+	defer os.Remove(sealed)
+	if !IsSealedFile(sealed) {
+		return true, os.Rename(sealed, path)
+	}
+	if err := s.Encryptor.OpenFile(sealed, path); err != nil {
+		return false, fmt.Errorf("failed to decrypt blob %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (s EncryptedBlobStore) Put(ctx context.Context, key, path string) error {
+	if IsSealedFile(path) || hasPrefix(path, encryptedMagic) {
+		return s.BlobStore.Put(ctx, key, path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sealed_*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := s.Encryptor.SealFile(path, tmp.Name()); err != nil {
+		return fmt.Errorf("failed to encrypt blob %s: %w", key, err)
+	}
+	return s.BlobStore.Put(ctx, key, tmp.Name())
+}
+
+func (EncryptedBlobStore) URL(context.Context, string, time.Duration) (string, error) {
+	return "", fmt.Errorf("%w: blobs are encrypted at rest", ErrNoURL)
+}
+
+// OpenBlobStore opens the store at uri; see SharedBlobStore.
+func OpenBlobStore(uri string) (BlobStore, error) {
+	switch {
+	case strings.HasPrefix(uri, "s3://"):
+		return &cliBlobStore{prefix: strings.TrimRight(uri, "/"), cli: s3CLI{}}, nil
+	case strings.HasPrefix(uri, "gs://"):
+		return &cliBlobStore{prefix: strings.TrimRight(uri, "/"), cli: gcsCLI{}}, nil
+	case strings.Contains(uri, "://") && !strings.HasPrefix(uri, "file://"):
+		return nil, fmt.Errorf("unsupported blob store %q", uri)
+	}
+	dir := strings.TrimPrefix(uri, "file://")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return DirBlobStore{Dir: dir}, nil
+}
+
+// DirBlobStore keeps blobs as files under Dir.
+type DirBlobStore struct {
+	Dir string
+}
+
+func (s DirBlobStore) path(key string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(key))
+}
+
+func (s DirBlobStore) Get(_ context.Context, key, path string) (bool, error) {
+	err := copyFile(s.path(key), path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put writes through a temporary file so readers never see half a blob.
+func (s DirBlobStore) Put(_ context.Context, key, path string) error {
+	dst := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".part"
+	if err := copyFile(path, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (s DirBlobStore) Delete(_ context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (DirBlobStore) URL(context.Context, string, time.Duration) (string, error) {
+	return "", ErrNoURL
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// objectCLI is the command line tool of an object store.
+type objectCLI interface {
+	name() string
+	copy(src, dst string) []string
+	remove(uri string) []string
+	sign(uri string, ttl time.Duration) []string
+	// missing tells a failed copy of an absent object from other failures
+	missing(output string) bool
+}
+
+// cliBlobStore keeps blobs under prefix in S3 or GCS through their CLIs.
+type cliBlobStore struct {
+	prefix string
+	cli    objectCLI
+}
+
+func (s *cliBlobStore) uri(key string) string {
+	return s.prefix + "/" + strings.TrimLeft(key, "/")
+}
+
+func (s *cliBlobStore) run(ctx context.Context, args []string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, s.cli.name(), args...).CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%s %s failed: %w: %s", filepath.Base(s.cli.name()), args[0], err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+func (s *cliBlobStore) Get(ctx context.Context, key, path string) (bool, error) {
+	out, err := s.run(ctx, s.cli.copy(s.uri(key), path))
+	if err != nil {
+		_ = os.Remove(path)
+		if ctx.Err() == nil && s.cli.missing(string(out)) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *cliBlobStore) Put(ctx context.Context, key, path string) error {
+	_, err := s.run(ctx, s.cli.copy(path, s.uri(key)))
+	return err
+}
+
+func (s *cliBlobStore) Delete(ctx context.Context, key string) error {
+	out, err := s.run(ctx, s.cli.remove(s.uri(key)))
+	if err != nil && ctx.Err() == nil && s.cli.missing(string(out)) {
+		return nil
+	}
+	return err
+}
+
+func (s *cliBlobStore) URL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	out, err := s.run(ctx, s.cli.sign(s.uri(key), ttl))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "https://") {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("no link in %s's output", s.cli.name())
+}
+
+// s3CLI drives the AWS CLI.
+type s3CLI struct{}
+
+func (s3CLI) name() string {
+	if bin := os.Getenv("AWS_CLI_PATH"); bin != "" {
+		return bin
+	}
+	return "aws"
+}
+
+func (s3CLI) copy(src, dst string) []string {
+	return []string{"s3", "cp", "--only-show-errors", src, dst}
+}
+
+func (s3CLI) remove(uri string) []string {
+	return []string{"s3", "rm", "--only-show-errors", uri}
+}
+
+func (s3CLI) sign(uri string, ttl time.Duration) []string {
+	return []string{"s3", "presign", uri, "--expires-in", strconv.Itoa(int(ttl.Seconds()))}
+}
+
+var s3Missing = regexp.MustCompile(`\(404\)|Not Found|NoSuchKey|does not exist`)
+
+func (s3CLI) missing(output string) bool { return s3Missing.MatchString(output) }
+
+// gcsCLI drives the Google Cloud CLI. Signing links needs service account
+// credentials.
+type gcsCLI struct{}
+
+func (gcsCLI) name() string {
+	if bin := os.Getenv("GCLOUD_PATH"); bin != "" {
+		return bin
+	}
+	return "gcloud"
+}
+
+func (gcsCLI) copy(src, dst string) []string {
+	return []string{"storage", "cp", "--no-user-output-enabled", src, dst}
+}
+
+func (gcsCLI) remove(uri string) []string {
+	return []string{"storage", "rm", "--no-user-output-enabled", uri}
+}
+
+func (gcsCLI) sign(uri string, ttl time.Duration) []string {
+	return []string{"storage", "sign-url", uri, "--duration", strconv.Itoa(int(ttl.Seconds())) + "s", "--format", "value(signed_url)"}
+}
+
+var gcsMissing = regexp.MustCompile(`No URLs matched|404|NotFound|not found`)
+
+func (gcsCLI) missing(output string) bool { return gcsMissing.MatchString(output) }
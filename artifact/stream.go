@@ -0,0 +1,155 @@
+package artifact
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// streamMagic prefixes files sealed by SealFile. They are AES-256-GCM sealed
+// in streamChunkSize chunks, so audio and clips never sit in memory whole.
+// Each chunk's nonce is a random per-file prefix and the chunk's number, and
+// the last chunk is marked in its additional data so a truncated file fails
+// to open.
+var streamMagic = []byte("VSENS1")
+
+const streamChunkSize = 1 << 20
+
+// SealFile encrypts the file at src into dst.
+func (e *Encryptor) SealFile(src, dst string) error {
+	return e.convertFile(src, dst, e.sealStream)
+}
+
+// OpenFile decrypts a file SealFile wrote into dst.
+func (e *Encryptor) OpenFile(src, dst string) error {
+	return e.convertFile(src, dst, e.openStream)
+}
+
+func (e *Encryptor) convertFile(src, dst string, convert func(io.Writer, io.Reader) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = convert(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+	}
+	return err
+}
+
+// IsSealedFile reports whether the file at path was written by SealFile.
+func IsSealedFile(path string) bool {
+	return hasPrefix(path, streamMagic)
+}
+
+func hasPrefix(path string, magic []byte) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, magic)
+}
+
+// chunkNonce is the nonce of chunk n of a stream with prefix.
+func (e *Encryptor) chunkNonce(prefix []byte, n uint64) []byte {
+	nonce := make([]byte, e.aead.NonceSize())
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[len(prefix):], n)
+	return nonce
+}
+
+// chunkData is the additional data of a chunk, which tells the last apart.
+func chunkData(last bool) []byte {
+	if last {
+		return append(append([]byte{}, streamMagic...), 1)
+	}
+	return append(append([]byte{}, streamMagic...), 0)
+}
+
+// readChunk reads up to len(buf) bytes and whether they are the last.
+func readChunk(r *bufio.Reader, buf []byte) (int, bool, error) {
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return n, true, nil
+	case err != nil:
+		return n, false, err
+	}
+	if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+		return n, true, nil
+	} else if err != nil {
+		return n, false, err
+	}
+	return n, false, nil
+}
+
+func (e *Encryptor) sealStream(w io.Writer, r io.Reader) error {
+	prefix := make([]byte, e.aead.NonceSize()-8)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(append([]byte{}, streamMagic...), prefix...)); err != nil {
+		return err
+	}
+	br := bufio.NewReader(r)
+	buf := make([]byte, streamChunkSize)
+	var sealed []byte
+	for n := uint64(0); ; n++ {
+		size, last, err := readChunk(br, buf)
+		if err != nil {
+			return err
+		}
+		sealed = e.aead.Seal(sealed[:0], e.chunkNonce(prefix, n), buf[:size], chunkData(last))
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func (e *Encryptor) openStream(w io.Writer, r io.Reader) error {
+	head := make([]byte, len(streamMagic)+e.aead.NonceSize()-8)
+	if _, err := io.ReadFull(r, head); err != nil || !bytes.HasPrefix(head, streamMagic) {
+		return fmt.Errorf("not a sealed file")
+	}
+	prefix := head[len(streamMagic):]
+	br := bufio.NewReader(r)
+	buf := make([]byte, streamChunkSize+e.aead.Overhead())
+	var plain []byte
+	for n := uint64(0); ; n++ {
+		size, last, err := readChunk(br, buf)
+		if err != nil {
+			return err
+		}
+		plain, err = e.aead.Open(plain[:0], e.chunkNonce(prefix, n), buf[:size], chunkData(last))
+		if err != nil {
+			return fmt.Errorf("sealed file is corrupt or truncated: %w", err)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"searchme/internal/config"
+	"searchme/search"
+	"searchme/server"
+)
+
+// CLI exit codes, so cron jobs and scripts can branch on the outcome
+const (
+	exitFound    = 0
+	exitNotFound = 1
+	exitError    = 2
+)
+
+const cliUsage = `Usage:
+  videosearch serve                       start the HTTP API (default)
+  videosearch worker                      run queued jobs for a coordinator (also --worker)
+  videosearch search --url URL --keyword WORD [--lang CODE] [--format text|json]
+  videosearch check-config                validate the configuration and exit
+
+Run "videosearch search -h" for all search flags.
+`
+
+// runCLI dispatches a subcommand and returns the process exit code.
+func runCLI(cfg config.Config, args []string) int {
+	switch args[0] {
+	case "search":
+		return runSearchCommand(cfg, args[1:], os.Stdout, os.Stderr)
+	case "check-config":
+		return runCheckConfig(cfg, os.Stdout, os.Stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+		return exitError
+	}
+}
+
+// runSearchCommand runs one search through the same pipeline as POST /api/search.
+func runSearchCommand(cfg config.Config, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var req server.SearchRequest
+	fs.StringVar(&req.VideoURL, "url", "", "video URL (required)")
+	fs.StringVar(&req.Keyword, "keyword", "", "word or phrase to find (required)")
+	fs.StringVar(&req.Language, "lang", "", "subtitle language code (default en)")
+	langs := fs.String("langs", "", "comma-separated caption languages to search at once, or \"all\"")
+	fs.BoolVar(&req.Translate, "translate", false, "also look for the keyword translated into each searched language")
+	fs.StringVar(&req.KeywordLanguage, "keyword-lang", "", "with --translate, the keyword's own language code")
+	fs.StringVar(&req.CookiesFile, "cookies-file", "", "cookies file name inside YTDLP_COOKIES_DIR")
+	fs.StringVar(&req.Proxy, "proxy", "", "proxy URL for yt-dlp")
+	fs.StringVar(&req.MatchMode, "match", "", "match mode: substring (default), word or phrase")
+	fs.BoolVar(&req.Stem, "stem", false, "match word stems, so deploy also finds deployed")
+	fs.StringVar(&req.Order, "order", "", "chunk order when transcribing: sequential (default) or priority")
+	fs.StringVar(&req.Hint, "hint", "", "where the keyword probably is, e.g. \"near the end\" or 1:02:00 (implies --order priority)")
+	fs.Float64Var(&req.BudgetMinutes, "budget", 0, "max minutes of audio to transcribe (0 = unlimited)")
+	fs.BoolVar(&req.ConfirmCost, "confirm-cost", false, "accept a transcription cost estimate over MAX_COST_PER_REQUEST or DAILY_COST_BUDGET")
+	fs.IntVar(&req.Limit, "limit", 0, "also list the top N occurrences by relevance")
+	fs.IntVar(&req.Offset, "offset", 0, "with --limit, skip that many ranked occurrences")
+	fs.StringVar(&req.AudioTrack, "audio-track", "", "audio track language or yt-dlp format")
+	fs.IntVar(&req.ChunkSeconds, "chunk-seconds", 0, "Whisper chunk length in seconds (default CHUNK_SECONDS or 300)")
+	fs.IntVar(&req.SampleRate, "sample-rate", 0, "audio sample rate in Hz (default AUDIO_SAMPLE_RATE or 16000)")
+	fs.IntVar(&req.Channels, "channels", 0, "audio channels, 1 or 2 (default AUDIO_CHANNELS or 1)")
+	fs.IntVar(&req.BitrateKbps, "bitrate", 0, "audio bitrate in kbit/s (default AUDIO_BITRATE_KBPS or 32)")
+	format := fs.String("format", "text", "output format: text, json or voice (one spoken-style sentence)")
+	verbose := fs.Bool("v", false, "log pipeline progress to stderr")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitError
+	}
+	if req.VideoURL == "" || req.Keyword == "" {
+		fmt.Fprintln(stderr, "--url and --keyword are required")
+		fs.Usage()
+		return exitError
+	}
+	if *format != "text" && *format != "json" && *format != "voice" {
+		fmt.Fprintf(stderr, "unknown --format %q (want text, json or voice)\n", *format)
+		return exitError
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	if *langs != "" {
+		req.Languages = strings.Split(*langs, ",")
+	}
+	req.Voice = *format == "voice"
+	app := server.NewApp(cfg)
+	resp, err := app.Search(context.Background(), req)
+	if err != nil {
+		fmt.Fprintf(stderr, "search failed: %v\n", err)
+		return exitError
+	}
+
+	if *format == "voice" {
+		fmt.Fprintln(stdout, resp.Voice.Text)
+	} else if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(resp)
+	} else if len(resp.Matches) > 0 {
+		for _, m := range resp.Matches {
+			fmt.Fprintf(stdout, "%s  %s  (score %.3f)\n", m.Time, m.URL, m.Score)
+		}
+	} else if resp.Found {
+		fmt.Fprintf(stdout, "%s  %s  (%s, %s)\n", resp.Time, resp.URL, resp.Source, resp.Confidence)
+	} else if cov := resp.Coverage; cov != nil && !cov.Complete {
+		fmt.Fprintf(stdout, "%q not found in the %.0f%% of the audio searched\n", req.Keyword, cov.Fraction*100)
+		for _, r := range cov.Skipped {
+			fmt.Fprintf(stdout, "  not searched (budget): %s-%s\n", search.FormatTime(r.Start), search.FormatTime(r.End))
+		}
+		for _, r := range cov.Failed {
+			fmt.Fprintf(stdout, "  not searched (transcription failed): %s-%s\n", search.FormatTime(r.Start), search.FormatTime(r.End))
+		}
+	} else {
+		fmt.Fprintf(stdout, "%q not found\n", req.Keyword)
+	}
+	if !resp.Found {
+		return exitNotFound
+	}
+	return exitFound
+}
+
+// runCheckConfig validates the configuration the server would start with,
+// e.g. as a container build step.
+func runCheckConfig(cfg config.Config, stdout, stderr io.Writer) int {
+	warnings, err := cfg.Validate()
+	for _, w := range warnings {
+		fmt.Fprintf(stderr, "warning: %s\n", w)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, "configuration OK")
+	return 0
+}
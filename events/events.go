@@ -0,0 +1,145 @@
+// Package events is the pipeline event bus: typed events emitted while
+// downloading, transcribing and searching, fanned out to in-process
+// subscribers and optional NATS/Kafka publishers.
+package events
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"searchme/internal/env"
+)
+
+type Type string
+
+// Pipeline events, also used as the suffix of the NATS subject / Kafka key
+const (
+	JobQueued        Type = "job.queued"
+	DownloadFinished Type = "download.finished"
+	ChunkTranscribed Type = "chunk.transcribed"
+	MatchFound       Type = "match.found"
+	JobFailed        Type = "job.failed"
+	// JobCompleted is emitted when a background job finishes
+	JobCompleted Type = "job.completed"
+	// JobCancelled is emitted when a background job is cancelled
+	JobCancelled Type = "job.cancelled"
+	// VideoIndexed is emitted when background indexing adds a video to a collection
+	VideoIndexed Type = "video.indexed"
+	// ResultsChanged is emitted when re-processing a video moves or drops published matches
+	ResultsChanged Type = "results.changed"
+	// CaptionsChanged is emitted when a resync finds a cached caption track
+	// edited or removed on the platform
+	CaptionsChanged Type = "captions.changed"
+)
+
+// Event is one thing that happened while processing a request or job.
+type Event struct {
+	Type     Type                   `json:"type"`
+	Time     time.Time              `json:"time"`
+	JobID    string                 `json:"job_id,omitempty"`
+	VideoURL string                 `json:"video_url,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// Publisher forwards events to an external system.
+type Publisher interface {
+	Publish(ctx context.Context, ev Event) error
+	Close() error
+}
+
+// eventQueueSize bounds buffered events; emitting never blocks the pipeline.
+const eventQueueSize = 1024
+
+// Bus fans events out to in-process subscribers and external publishers
+// from a single goroutine, so subscribers see events in emission order.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []func(Event)
+	ch   chan Event
+}
+
+func NewBus() *Bus {
+	b := &Bus{ch: make(chan Event, eventQueueSize)}
+	go b.run()
+	return b
+}
+
+// Subscribe registers fn for every later event. fn runs on the bus goroutine
+// and should return quickly.
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+}
+
+// AddPublisher subscribes an external publisher.
+func (b *Bus) AddPublisher(name string, p Publisher) {
+	b.Subscribe(func(ev Event) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := p.Publish(ctx, ev); err != nil {
+			log.Printf("%s: failed to publish %s event: %v", name, ev.Type, err)
+		}
+	})
+}
+
+// Emit queues an event. When the queue is full the event is dropped rather
+// than slowing down the request that produced it.
+func (b *Bus) Emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	select {
+	case b.ch <- ev:
+	default:
+		log.Printf("event queue full, dropping %s event", ev.Type)
+	}
+}
+
+func (b *Bus) run() {
+	for ev := range b.ch {
+		b.mu.RLock()
+		subs := b.subs
+		b.mu.RUnlock()
+		for _, fn := range subs {
+			fn(ev)
+		}
+	}
+}
+
+var (
+	busOnce sync.Once
+	bus     *Bus
+)
+
+// Default returns the process-wide bus, attaching publishers configured by
+// EVENTS_NATS_URL (+ EVENTS_NATS_SUBJECT) and EVENTS_KAFKA_REST_URL, a Kafka
+// REST Proxy (+ EVENTS_KAFKA_TOPIC).
+func Default() *Bus {
+	busOnce.Do(func() {
+		bus = NewBus()
+		if url := os.Getenv("EVENTS_NATS_URL"); url != "" {
+			p, err := NewNATSPublisher(url, env.Or("EVENTS_NATS_SUBJECT", "viedoserach.events"))
+			if err != nil {
+				log.Printf("NATS event publisher disabled: %v", err)
+			} else {
+				bus.AddPublisher("nats", p)
+				log.Printf("Publishing pipeline events to NATS at %s", p.conn.ConnectedUrlRedacted())
+			}
+		}
+		if restURL := os.Getenv("EVENTS_KAFKA_REST_URL"); restURL != "" {
+			topic := env.Or("EVENTS_KAFKA_TOPIC", "viedoserach.events")
+			bus.AddPublisher("kafka", NewKafkaPublisher(restURL, topic))
+			log.Printf("Publishing pipeline events to Kafka topic %s via %s", topic, restURL)
+		}
+	})
+	return bus
+}
+
+// Emit is shorthand for emitting on the process-wide bus.
+func Emit(typ Type, videoURL string, data map[string]interface{}) {
+	Default().Emit(Event{Type: typ, VideoURL: videoURL, Data: data})
+}
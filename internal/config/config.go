@@ -0,0 +1,362 @@
+// Package config loads the settings the server is started with from the
+// environment and an optional YAML file, and checks them before anything
+// listens or shells out.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the startup configuration. Every field has an environment
+// variable, which wins over the YAML file at CONFIG_FILE, which wins over
+// the default. Settings not listed here (limits, budgets, policies) are
+// still read from the environment where they are used, so they can change on
+// SIGHUP; the file's env map sets those.
+type Config struct {
+	Port string `yaml:"port" env:"PORT"`
+	// GRPCPort serves the gRPC API (proto/videosearch.proto) in plaintext
+	// HTTP/2 beside the REST one; empty leaves it off
+	GRPCPort string `yaml:"grpc_port" env:"GRPC_PORT"`
+
+	// TLSMode is off, file or autocert; empty serves TLSCertFile when it
+	// exists and plain HTTP otherwise
+	TLSMode          string   `yaml:"tls_mode" env:"TLS_MODE"`
+	TLSCertFile      string   `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile       string   `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	AutocertDomains  []string `yaml:"autocert_domains" env:"AUTOCERT_DOMAINS"`
+	AutocertEmail    string   `yaml:"autocert_email" env:"AUTOCERT_EMAIL"`
+	AutocertCacheDir string   `yaml:"autocert_cache_dir" env:"AUTOCERT_CACHE_DIR"`
+	AutocertHTTPAddr string   `yaml:"autocert_http_addr" env:"AUTOCERT_HTTP_ADDR"`
+
+	// APIKeys and AdminAPIKeys are "name:key" or bare keys
+	APIKeys      []string `yaml:"api_keys" env:"API_KEYS"`
+	APIKeysFile  string   `yaml:"api_keys_file" env:"API_KEYS_FILE"`
+	AdminAPIKeys []string `yaml:"admin_api_keys" env:"ADMIN_API_KEYS"`
+	OpenAIAPIKey string   `yaml:"openai_api_key" env:"OPENAI_API_KEY"`
+
+	YTDLPPath      string `yaml:"ytdlp_path" env:"YTDLP_PATH"`
+	YTDLPCookies   string `yaml:"ytdlp_cookies" env:"YTDLP_COOKIES"`
+	YTDLPCookieDir string `yaml:"ytdlp_cookies_dir" env:"YTDLP_COOKIES_DIR"`
+	TesseractPath  string `yaml:"tesseract_path" env:"TESSERACT_PATH"`
+	AWSCLIPath     string `yaml:"aws_cli_path" env:"AWS_CLI_PATH"`
+
+	// WorkDir holds downloads, chunks and transcripts while a request runs;
+	// empty is the working directory
+	WorkDir       string `yaml:"work_dir" env:"WORK_DIR"`
+	IndexDB       string `yaml:"index_db" env:"INDEX_DB"`
+	LocalMediaDir string `yaml:"local_media_dir" env:"LOCAL_MEDIA_DIR"`
+	// TrustedProxies are the proxy addresses or CIDR ranges whose
+	// X-Forwarded-For and X-Real-IP headers name the client; none trusts no
+	// proxy, so per-client limits go by the peer address
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	// S3MediaBuckets are the buckets, or bucket/prefix, that s3:// video URLs
+	// may read from; none leaves S3 media off
+	S3MediaBuckets []string `yaml:"s3_media_buckets" env:"S3_MEDIA_BUCKETS"`
+
+	// QueueBackend is memory (default) or redis, which keeps async jobs in
+	// Redis at RedisURL for restarts and other replicas
+	QueueBackend string `yaml:"queue_backend" env:"QUEUE_BACKEND"`
+	RedisURL     string `yaml:"redis_url" env:"REDIS_URL"`
+	// Role is all (default: serve the API and run queued jobs),
+	// coordinator (serve the API, leave searches to workers) or worker
+	// (run queued jobs only); the last two need QueueBackend redis
+	Role string `yaml:"role" env:"ROLE"`
+
+	// Env sets any other environment variable the file doesn't have a field
+	// for, e.g. MAX_COST_PER_REQUEST, unless the environment already has it
+	Env map[string]string `yaml:"env"`
+}
+
+// Default is the configuration with nothing set.
+func Default() Config {
+	return Config{
+		Port:             "8800",
+		TLSCertFile:      "cert.pem",
+		TLSKeyFile:       "key.pem",
+		AutocertCacheDir: "autocert-cache",
+		AutocertHTTPAddr: ":80",
+		YTDLPPath:        "yt-dlp",
+		TesseractPath:    "tesseract",
+		AWSCLIPath:       "aws",
+		RedisURL:         "redis://localhost:6379/0",
+	}
+}
+
+// Load reads the YAML file at CONFIG_FILE, if set, then the environment,
+// and exports the result back to the environment so code reading variables
+// directly sees the file's values too. It does not validate.
+func Load() (Config, error) {
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.each(func(name string, v reflect.Value) {
+		if s := strings.TrimSpace(os.Getenv(name)); s != "" {
+			set(v, s)
+		}
+	})
+	cfg.export()
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("CONFIG_FILE: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("CONFIG_FILE %s: %w", path, err)
+	}
+	return nil
+}
+
+// each calls fn with the environment variable and value of every field.
+func (c *Config) each(fn func(name string, v reflect.Value)) {
+	rv := reflect.ValueOf(c).Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		if name := rt.Field(i).Tag.Get("env"); name != "" {
+			fn(name, rv.Field(i))
+		}
+	}
+}
+
+func set(v reflect.Value, s string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		var list []string
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				list = append(list, p)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	}
+}
+
+func get(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return v.String()
+}
+
+// export sets every unset variable the configuration has a value for.
+func (c *Config) export() {
+	c.each(func(name string, v reflect.Value) {
+		if s := get(v); s != "" && os.Getenv(name) == "" {
+			os.Setenv(name, s)
+		}
+	})
+	for name, value := range c.Env {
+		if os.Getenv(name) == "" {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+// Error lists every problem Validate found.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the configuration for a server start: the port, TLS
+// files, API keys, configured binaries and directories. Warnings are things
+// that still let the server start, e.g. a default binary missing from PATH,
+// which the readiness probe will report.
+func (c Config) Validate() (warnings []string, err error) {
+	var problems []string
+	fail := func(format string, args ...interface{}) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		fail("PORT: %q is not a port number (1-65535)", c.Port)
+	}
+	if c.GRPCPort != "" {
+		if n, err := strconv.Atoi(c.GRPCPort); err != nil || n < 1 || n > 65535 {
+			fail("GRPC_PORT: %q is not a port number (1-65535)", c.GRPCPort)
+		} else if c.GRPCPort == c.Port {
+			fail("GRPC_PORT: %s is already the REST API's PORT", c.GRPCPort)
+		}
+	}
+
+	switch strings.ToLower(c.TLSMode) {
+	case "", "off":
+	case "file":
+		for _, f := range []struct{ name, path string }{{"TLS_CERT_FILE", c.TLSCertFile}, {"TLS_KEY_FILE", c.TLSKeyFile}} {
+			if err := readable(f.path); err != nil {
+				fail("%s: TLS_MODE=file needs a readable file: %v", f.name, err)
+			}
+		}
+	case "autocert":
+		if len(c.AutocertDomains) == 0 {
+			fail("AUTOCERT_DOMAINS: TLS_MODE=autocert needs at least one domain")
+		}
+		if err := writableDir(c.AutocertCacheDir); err != nil {
+			fail("AUTOCERT_CACHE_DIR: %v", err)
+		}
+	default:
+		fail("TLS_MODE: unknown mode %q (want off, file or autocert)", c.TLSMode)
+	}
+
+	for _, k := range []struct {
+		name string
+		keys []string
+	}{{"API_KEYS", c.APIKeys}, {"ADMIN_API_KEYS", c.AdminAPIKeys}} {
+		for i, entry := range k.keys {
+			if key := keyPart(entry); key == "" || strings.ContainsAny(key, " \t") {
+				fail("%s: entry %d has an empty or malformed key", k.name, i+1)
+			}
+		}
+	}
+	if c.APIKeysFile != "" {
+		if err := readable(c.APIKeysFile); err != nil {
+			fail("API_KEYS_FILE: %v", err)
+		}
+	}
+	if c.OpenAIAPIKey == "" {
+		warnings = append(warnings, "OPENAI_API_KEY is not set: Whisper transcription is unavailable unless callers send their own key")
+	} else if strings.ContainsAny(c.OpenAIAPIKey, " \t\r\n") {
+		fail("OPENAI_API_KEY: contains whitespace")
+	}
+
+	defaults := Default()
+	for _, b := range []struct{ name, path, def string }{
+		{"YTDLP_PATH", c.YTDLPPath, defaults.YTDLPPath},
+		{"TESSERACT_PATH", c.TesseractPath, defaults.TesseractPath},
+		{"AWS_CLI_PATH", c.AWSCLIPath, defaults.AWSCLIPath},
+	} {
+		if _, err := exec.LookPath(b.path); err != nil {
+			if b.path != b.def {
+				fail("%s: %q is not an executable: %v", b.name, b.path, err)
+			} else if b.name == "YTDLP_PATH" {
+				warnings = append(warnings, fmt.Sprintf("%s not found on PATH; set YTDLP_PATH", b.path))
+			}
+		}
+	}
+	for _, bin := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			warnings = append(warnings, bin+" not found on PATH")
+		}
+	}
+	if c.YTDLPCookies != "" {
+		if err := readable(c.YTDLPCookies); err != nil {
+			fail("YTDLP_COOKIES: %v", err)
+		}
+	}
+
+	for _, d := range []struct{ name, path string }{{"YTDLP_COOKIES_DIR", c.YTDLPCookieDir}, {"LOCAL_MEDIA_DIR", c.LocalMediaDir}} {
+		if d.path == "" {
+			continue
+		}
+		if fi, err := os.Stat(d.path); err != nil || !fi.IsDir() {
+			fail("%s: %q is not a directory", d.name, d.path)
+		}
+	}
+	if c.WorkDir != "" {
+		if err := writableDir(c.WorkDir); err != nil {
+			fail("WORK_DIR: %v", err)
+		}
+	}
+	switch strings.ToLower(c.QueueBackend) {
+	case "", "memory":
+	case "redis":
+		if u, err := url.Parse(c.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			fail("REDIS_URL: %q is not a redis:// or rediss:// URL", c.RedisURL)
+		}
+	default:
+		fail("QUEUE_BACKEND: unknown backend %q (want memory or redis)", c.QueueBackend)
+	}
+	switch strings.ToLower(c.Role) {
+	case "", "all":
+	case "coordinator", "worker":
+		if !strings.EqualFold(c.QueueBackend, "redis") {
+			fail("ROLE: %s needs QUEUE_BACKEND=redis to share work through", c.Role)
+		}
+	default:
+		fail("ROLE: unknown role %q (want all, coordinator or worker)", c.Role)
+	}
+	if c.IndexDB != "" {
+		if err := writableDir(filepath.Dir(c.IndexDB)); err != nil {
+			fail("INDEX_DB: %v", err)
+		}
+	}
+	for i, p := range c.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				fail("TRUSTED_PROXIES: entry %d, %q, is not an IP address or CIDR range", i+1, p)
+			}
+		}
+	}
+	// limits are read where they are used, but a zero one would let nothing run
+	for _, name := range []string{"MAX_CONCURRENT_REQUESTS", "MAX_TRANSCRIPTIONS"} {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+				fail("%s: %q is not a positive number", name, v)
+			}
+		}
+	}
+	for name := range c.Env {
+		if name == "" || strings.ContainsAny(name, "= \t") {
+			fail("env: %q is not a variable name", name)
+		}
+	}
+
+	sort.Strings(warnings)
+	if len(problems) > 0 {
+		return warnings, &Error{Problems: problems}
+	}
+	return warnings, nil
+}
+
+// keyPart is the key of a "name:key" entry.
+func keyPart(entry string) string {
+	if _, key, ok := strings.Cut(entry, ":"); ok {
+		return strings.TrimSpace(key)
+	}
+	return strings.TrimSpace(entry)
+}
+
+func readable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writableDir creates dir if needed and checks a file can be written in it.
+func writableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".write_check_*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
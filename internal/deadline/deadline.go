@@ -0,0 +1,70 @@
+// Package deadline bounds the pipeline's stages, so a yt-dlp or ffmpeg run
+// or a Whisper request that hangs, e.g. on a bad URL or a throttled
+// connection, fails its request instead of holding it forever.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"searchme/internal/env"
+)
+
+// ErrTimeout is wrapped by the error of every stage that ran out of time.
+var ErrTimeout = errors.New("timed out")
+
+// Stage is a step of the pipeline with a time limit of its own.
+type Stage struct {
+	// Name is what errors call the stage
+	Name string
+	// Env names the variable overriding Default; 0 there is no limit
+	Env     string
+	Default time.Duration
+}
+
+// The pipeline's stages.
+var (
+	// Download is fetching a video's audio or video, retries included
+	Download = Stage{Name: "download", Env: "DOWNLOAD_TIMEOUT", Default: 15 * time.Minute}
+	// Metadata is a yt-dlp run that doesn't download media: captions,
+	// duration, chapters and the like
+	Metadata = Stage{Name: "yt-dlp", Env: "YTDLP_TIMEOUT", Default: 2 * time.Minute}
+	// Segment is ffmpeg cutting audio into chunks
+	Segment = Stage{Name: "segmentation", Env: "SEGMENT_TIMEOUT", Default: 10 * time.Minute}
+	// Chunk is transcribing one chunk with Whisper, retries included
+	Chunk = Stage{Name: "chunk transcription", Env: "CHUNK_TRANSCRIBE_TIMEOUT", Default: 3 * time.Minute}
+)
+
+// Limit is the stage's time limit; 0 is none.
+func (s Stage) Limit() time.Duration {
+	if os.Getenv(s.Env) == "0" {
+		return 0
+	}
+	return env.Duration(s.Env, s.Default)
+}
+
+// Context is ctx ending no later than the stage's limit from now. Pass the
+// stage's error through Err so running out of time reads as a timeout.
+func (s Stage) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	limit := s.Limit()
+	if limit <= 0 {
+		return context.WithCancel(ctx)
+	}
+	cause := fmt.Errorf("%s %w after %v", s.Name, ErrTimeout, limit)
+	return context.WithTimeoutCause(ctx, limit, cause)
+}
+
+// Err is err, failed under ctx, as the timeout of whichever stage's
+// Context ended ctx, or err itself when no stage ran out of time.
+func Err(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTimeout) && !errors.Is(err, ErrTimeout) {
+		return fmt.Errorf("%w (%v)", cause, err)
+	}
+	return err
+}
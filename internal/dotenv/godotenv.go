@@ -0,0 +1,17 @@
+//go:build !lite
+
+// Package dotenv reads .env files into the environment. The lite build
+// leaves it out; see lite.go.
+package dotenv
+
+import "github.com/joho/godotenv"
+
+// Load sets the variables in .env that aren't set already.
+func Load() error {
+	return godotenv.Load()
+}
+
+// Overload sets every variable in .env, replacing current values.
+func Overload() error {
+	return godotenv.Overload()
+}
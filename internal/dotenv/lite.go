@@ -0,0 +1,15 @@
+//go:build lite
+
+// Package dotenv reads .env files into the environment. The lite build
+// leaves godotenv out, so .env files are not read; set variables in the
+// environment or CONFIG_FILE's env map instead.
+package dotenv
+
+import "errors"
+
+// ErrUnsupported is returned in the lite build.
+var ErrUnsupported = errors.New(".env files are not read by the lite build")
+
+func Load() error { return ErrUnsupported }
+
+func Overload() error { return ErrUnsupported }
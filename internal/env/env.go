@@ -0,0 +1,72 @@
+// Package env reads settings from environment variables with defaults.
+package env
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Or returns the variable's value, or def when it is unset or empty.
+func Or(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int reads a positive integer setting, falling back to def.
+func Int(name string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// List splits a comma-separated setting, dropping blanks.
+func List(name string) []string {
+	var out []string
+	for _, p := range strings.Split(os.Getenv(name), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Float reads a positive number setting, falling back to def.
+func Float(name string, def float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && f > 0 {
+		return f
+	}
+	return def
+}
+
+// Duration reads a positive duration setting such as "90s", falling back to def.
+func Duration(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// Bytes reads a positive size setting such as "512MB" or "20GiB" (plain
+// numbers are bytes), falling back to def.
+func Bytes(name string, def int64) int64 {
+	v := strings.ToUpper(strings.TrimSpace(os.Getenv(name)))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10}, {"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3}, {"B", 1}} {
+		if strings.HasSuffix(v, u.suffix) {
+			v, mult = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.mult
+			break
+		}
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+		return int64(f * float64(mult))
+	}
+	return def
+}
@@ -0,0 +1,11 @@
+// Package faults injects failures into the pipeline so retry, cleanup and
+// resume paths can be exercised deterministically. It is compiled in only
+// with the "faults" build tag; production builds get no-op stubs.
+//
+// FAULTS is a comma-separated list of:
+//
+//	fail_chunk=N        transcription of chunk N (0-based) fails; repeatable
+//	delay_download=DUR  media downloads wait DUR (e.g. 2s) before starting
+//	fail_download       media downloads fail
+//	corrupt_srt         fetched captions are truncated mid-cue
+package faults
@@ -0,0 +1,94 @@
+//go:build faults
+
+package faults
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"searchme/internal/env"
+)
+
+// Enabled reports whether this build can inject faults.
+const Enabled = true
+
+type config struct {
+	failChunks    map[int]bool
+	downloadDelay time.Duration
+	failDownload  bool
+	corruptSRT    bool
+}
+
+var (
+	cfgOnce sync.Once
+	cfg     config
+)
+
+// active reads FAULTS on first use, after main has loaded .env.
+func active() *config {
+	cfgOnce.Do(func() { cfg = parse(env.List("FAULTS")) })
+	return &cfg
+}
+
+func parse(entries []string) config {
+	c := config{failChunks: map[int]bool{}}
+	for _, e := range entries {
+		name, value, _ := strings.Cut(e, "=")
+		switch name {
+		case "fail_chunk":
+			if n, err := strconv.Atoi(value); err == nil {
+				c.failChunks[n] = true
+			}
+		case "delay_download":
+			if d, err := time.ParseDuration(value); err == nil {
+				c.downloadDelay = d
+			}
+		case "fail_download":
+			c.failDownload = true
+		case "corrupt_srt":
+			c.corruptSRT = true
+		default:
+			log.Printf("faults: ignoring unknown fault %q", e)
+			continue
+		}
+		log.Printf("faults: injecting %s", e)
+	}
+	return c
+}
+
+// Chunk returns an error when transcription of chunk index should fail.
+func Chunk(index int) error {
+	if active().failChunks[index] {
+		return fmt.Errorf("injected fault: chunk %d transcription failed", index)
+	}
+	return nil
+}
+
+// Download delays and/or fails a media download of url.
+func Download(ctx context.Context, url string) error {
+	cfg := active()
+	if cfg.downloadDelay > 0 {
+		select {
+		case <-time.After(cfg.downloadDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if cfg.failDownload {
+		return fmt.Errorf("injected fault: download of %s failed", url)
+	}
+	return nil
+}
+
+// SRT returns caption content, truncated halfway through when corruption is on.
+func SRT(content []byte) []byte {
+	if active().corruptSRT && len(content) > 1 {
+		return content[:len(content)/2]
+	}
+	return content
+}
@@ -0,0 +1,12 @@
+//go:build !faults
+
+package faults
+
+import "context"
+
+// Enabled reports whether this build can inject faults.
+const Enabled = false
+
+func Chunk(index int) error                          { return nil }
+func Download(ctx context.Context, url string) error { return nil }
+func SRT(content []byte) []byte                      { return content }
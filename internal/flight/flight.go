@@ -0,0 +1,75 @@
+// Package flight coalesces concurrent calls doing the same work, so two
+// requests for the same video share one download or transcription instead
+// of paying for both.
+package flight
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs at most one call per key at a time. It is safe for concurrent
+// use; the zero value is ready.
+type Group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+type call[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+	// waiters counts the callers still interested; the work is cancelled
+	// when the last one gives up
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Do runs fn for key, or waits for the run already in flight and shares
+// its result; shared reports the latter. fn's context keeps the values of
+// the first caller's ctx but is only cancelled once every caller waiting
+// on it has gone, so one client hanging up doesn't fail the others. A
+// caller whose ctx ends gets ctx's error.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (v T, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call[T]{}
+	}
+	c, shared := g.calls[key]
+	if shared {
+		c.waiters++
+	} else {
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call[T]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = c
+		go func() {
+			defer cancel()
+			c.val, c.err = fn(runCtx)
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, shared, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			// a caller arriving now starts afresh rather than joining
+			// work that is being cancelled
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return v, shared, ctx.Err()
+	}
+}
@@ -0,0 +1,96 @@
+package oai
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Client key modes, set by CLIENT_OPENAI_KEYS
+const (
+	// ClientKeysAllow uses a caller's key when one is given, else the server's
+	ClientKeysAllow = "allow"
+	// ClientKeysRequire never spends the server's key on caller requests
+	ClientKeysRequire = "require"
+	// ClientKeysOff refuses caller keys
+	ClientKeysOff = "off"
+)
+
+// ErrClientKeyRequired is returned by ClientFor when CLIENT_OPENAI_KEYS is
+// "require" and the caller sent no key.
+var ErrClientKeyRequired = errors.New("this server requires your own OpenAI API key (X-OpenAI-Key header)")
+
+// ClientKeyMode is CLIENT_OPENAI_KEYS: ClientKeysAllow (default),
+// ClientKeysRequire or ClientKeysOff.
+func ClientKeyMode() string {
+	switch m := strings.ToLower(strings.TrimSpace(os.Getenv("CLIENT_OPENAI_KEYS"))); m {
+	case ClientKeysRequire, ClientKeysOff:
+		return m
+	default:
+		return ClientKeysAllow
+	}
+}
+
+type keyContext struct{}
+
+// WithKey returns a context whose OpenAI calls bill key instead of the
+// server's key. An empty key leaves ctx as is.
+func WithKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, keyContext{}, key)
+}
+
+// Key is the caller's key ctx carries, or "".
+func Key(ctx context.Context) string {
+	key, _ := ctx.Value(keyContext{}).(string)
+	return key
+}
+
+// maxClientKeys bounds the per-key clients kept; past it the cache is
+// dropped and rebuilt as keys come back.
+const maxClientKeys = 256
+
+var (
+	keyMu        sync.Mutex
+	keyClients   = map[[32]byte]*openai.Client{}
+	keyTransport *http.Transport
+)
+
+// ClientFor returns the client for ctx: one billing the caller's key when
+// ctx carries one (see WithKey), otherwise the shared Client. Callers' keys
+// are kept in memory only, keyed by their hash, and never logged.
+func ClientFor(ctx context.Context) (*openai.Client, error) {
+	key := Key(ctx)
+	if key == "" {
+		if ClientKeyMode() == ClientKeysRequire {
+			return nil, ErrClientKeyRequired
+		}
+		return Client()
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if c, ok := keyClients[sum]; ok {
+		return c, nil
+	}
+	if keyTransport == nil {
+		keyTransport = transport()
+	}
+	if len(keyClients) >= maxClientKeys {
+		keyClients = map[[32]byte]*openai.Client{}
+	}
+	cfg := openai.DefaultConfig(key)
+	cfg.HTTPClient = &http.Client{Transport: keyTransport}
+	c := openai.NewClientWithConfig(cfg)
+	keyClients[sum] = c
+	return c, nil
+}
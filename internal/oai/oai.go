@@ -0,0 +1,75 @@
+// Package oai holds the process-wide OpenAI client. Creating it once lets
+// every Whisper and chat call share one pooled HTTP/2 transport instead of
+// dialling fresh connections per request.
+package oai
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/env"
+)
+
+var (
+	mu     sync.Mutex
+	loaded bool
+	client *openai.Client
+	err    error
+)
+
+// Client returns the shared client for OPENAI_API_KEY, creating it on first
+// use. The transport is tuned with OPENAI_MAX_IDLE_CONNS (default 32),
+// OPENAI_IDLE_TIMEOUT and OPENAI_KEEPALIVE (durations, default 90s and 30s).
+func Client() (*openai.Client, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !loaded {
+		client, err = newClient()
+		loaded = true
+	}
+	return client, err
+}
+
+// Reload replaces the shared client with one built from the current
+// environment, e.g. after the key is rotated. Calls already running finish
+// on the old client.
+func Reload() error {
+	mu.Lock()
+	defer mu.Unlock()
+	client, err = newClient()
+	loaded = true
+	return err
+}
+
+func newClient() (*openai.Client, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY not set")
+	}
+	cfg := openai.DefaultConfig(apiKey)
+	cfg.HTTPClient = &http.Client{Transport: transport()}
+	return openai.NewClientWithConfig(cfg), nil
+}
+
+func transport() *http.Transport {
+	idle := env.Int("OPENAI_MAX_IDLE_CONNS", 32)
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: env.Duration("OPENAI_KEEPALIVE", 30*time.Second),
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          idle,
+		MaxIdleConnsPerHost:   idle,
+		IdleConnTimeout:       env.Duration("OPENAI_IDLE_TIMEOUT", 90*time.Second),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
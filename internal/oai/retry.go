@@ -0,0 +1,54 @@
+package oai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/retry"
+)
+
+// RetryPolicy is how OpenAI calls are retried: OPENAI_RETRY_ATTEMPTS (4),
+// OPENAI_RETRY_BASE (1s) and OPENAI_RETRY_MAX (30s).
+func RetryPolicy() retry.Policy {
+	return retry.FromEnv("OPENAI", retry.Policy{Attempts: 4, Base: time.Second, Max: 30 * time.Second})
+}
+
+// Retry runs fn under RetryPolicy, retrying the errors Retryable accepts.
+func Retry(ctx context.Context, what string, fn func() error) error {
+	return RetryPolicy().Do(ctx, what, Retryable, fn)
+}
+
+// Retryable reports whether an OpenAI call may succeed if repeated: rate
+// limits, server errors and dropped connections. An exhausted quota is
+// also a 429 but won't clear up by waiting.
+func Retryable(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == "insufficient_quota" || apiErr.Type == "insufficient_quota" {
+			return false
+		}
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func retryableStatus(code int) bool {
+	return code == 429 || code == 408 || code >= 500
+}
@@ -0,0 +1,69 @@
+// Package retry runs calls that fail transiently again, with exponential
+// backoff and full jitter so concurrent callers don't retry in lockstep.
+package retry
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"searchme/internal/env"
+)
+
+// Policy is how often and how patiently a call is retried.
+type Policy struct {
+	// Attempts is the total number of tries, including the first
+	Attempts int
+	// Base is the longest wait before the first retry; it doubles per retry
+	Base time.Duration
+	// Max caps any one wait
+	Max time.Duration
+}
+
+// FromEnv reads <prefix>_RETRY_ATTEMPTS, <prefix>_RETRY_BASE and
+// <prefix>_RETRY_MAX (durations) over def.
+func FromEnv(prefix string, def Policy) Policy {
+	return Policy{
+		Attempts: env.Int(prefix+"_RETRY_ATTEMPTS", def.Attempts),
+		Base:     env.Duration(prefix+"_RETRY_BASE", def.Base),
+		Max:      env.Duration(prefix+"_RETRY_MAX", def.Max),
+	}
+}
+
+// Delay is a random wait before retry n (1-based), up to Base*2^(n-1)
+// capped at Max.
+func (p Policy) Delay(n int) time.Duration {
+	ceiling := p.Base
+	for i := 1; i < n && ceiling < p.Max; i++ {
+		ceiling *= 2
+	}
+	if p.Max > 0 && ceiling > p.Max {
+		ceiling = p.Max
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// Do calls fn until it succeeds, fails with an error retryable rejects, runs
+// out of attempts or ctx ends, and returns fn's last error. what names the
+// call in logs.
+func (p Policy) Do(ctx context.Context, what string, retryable func(error) bool, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= p.Attempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		wait := p.Delay(attempt)
+		log.Printf("%s failed (attempt %d/%d), retrying in %v: %v", what, attempt, p.Attempts, wait.Round(time.Millisecond), err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Package safehttp makes requests to URLs that API callers supply without
+// letting them reach the server's own network. Addresses are checked after
+// DNS resolution, when connecting, so neither a hostname that resolves to an
+// internal address nor a redirect to one gets past it.
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrInternal is returned for loopback, private, link-local and other
+// addresses that aren't on the public internet.
+var ErrInternal = errors.New("address is not public")
+
+// nonPublic are ranges the netip predicates don't cover: "this network" and
+// carrier-grade NAT.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// Public reports whether ip is a public unicast address.
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL refuses URLs that aren't http(s) or whose host resolves to any
+// address that isn't public.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid URL %q: want an http(s) URL", raw)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", u.Hostname(), err)
+	}
+	for _, a := range addrs {
+		if !Public(a) {
+			return fmt.Errorf("%s resolves to %s: %w", u.Hostname(), a, ErrInternal)
+		}
+	}
+	return nil
+}
+
+// Client returns an http.Client that only connects to public addresses.
+// Redirects are followed, up to 10, to http(s) URLs only; where they lead
+// is checked like the first address. Environment proxies are not used,
+// since the check would then only see the proxy.
+func Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   refuseInternal,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to %q: want an http(s) URL", req.URL)
+			}
+			return nil
+		},
+	}
+}
+
+// refuseInternal is the dialer's Control hook: it sees the resolved address
+// each connection is about to be made to.
+func refuseInternal(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, err)
+	}
+	if !Public(ap.Addr()) {
+		return fmt.Errorf("connecting to %s: %w", ap.Addr(), ErrInternal)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !lite
+
+// Package web is the HTTP layer the server's handlers are written against.
+// By default it is Gin; building with -tags lite swaps in a small net/http
+// implementation of the same surface, so the handlers stay shared and the
+// binary drops Gin and its dependencies.
+package web
+
+import "github.com/gin-gonic/gin"
+
+type (
+	Context     = gin.Context
+	Engine      = gin.Engine
+	HandlerFunc = gin.HandlerFunc
+	H           = gin.H
+)
+
+const MIMEJSON = gin.MIMEJSON
+
+// New returns an engine without Gin's default logger and recovery.
+func New() *Engine {
+	return gin.New()
+}
@@ -0,0 +1,381 @@
+//go:build lite
+
+// Package web is the HTTP layer the server's handlers are written against.
+// By default it is Gin; building with -tags lite swaps in this small
+// net/http implementation of the same surface, so the handlers stay shared
+// and the binary drops Gin and its dependencies.
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const MIMEJSON = "application/json"
+
+// H is a shortcut for JSON objects.
+type H map[string]interface{}
+
+// HandlerFunc handles a request or, as middleware, wraps the rest of the
+// chain with Next.
+type HandlerFunc func(*Context)
+
+const abortIndex = math.MaxInt8 / 2
+
+// Context carries one request through its handler chain.
+type Context struct {
+	Request *http.Request
+	Writer  ResponseWriter
+
+	engine   *Engine
+	params   map[string]string
+	fullPath string
+	handlers []HandlerFunc
+	index    int
+	keys     map[string]interface{}
+}
+
+// Next runs the rest of the chain; only middleware calls it.
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort stops the handlers after the current one from running.
+func (c *Context) Abort() { c.index = abortIndex }
+
+// AbortWithStatusJSON aborts and writes obj as the response.
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// Param is a path parameter, e.g. :id.
+func (c *Context) Param(key string) string { return c.params[key] }
+
+// FullPath is the matched route, e.g. "/api/jobs/:id".
+func (c *Context) FullPath() string { return c.fullPath }
+
+// Query is a URL query parameter.
+func (c *Context) Query(key string) string { return c.Request.URL.Query().Get(key) }
+
+// PostForm is a url-encoded or multipart form field.
+func (c *Context) PostForm(key string) string {
+	if c.Request.MultipartForm == nil {
+		_ = c.Request.ParseMultipartForm(32 << 20)
+	}
+	return c.Request.PostFormValue(key)
+}
+
+// FormFile is the first file uploaded under name.
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if c.Request.MultipartForm == nil {
+		if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+	}
+	f, fh, err := c.Request.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return fh, nil
+}
+
+// GetHeader is a request header.
+func (c *Context) GetHeader(key string) string { return c.Request.Header.Get(key) }
+
+// Header sets a response header; an empty value deletes it.
+func (c *Context) Header(key, value string) {
+	if value == "" {
+		c.Writer.Header().Del(key)
+		return
+	}
+	c.Writer.Header().Set(key, value)
+}
+
+// ClientIP is the peer's address or, when the peer is a trusted proxy (see
+// Engine.SetTrustedProxies), the client it forwards for: the last
+// X-Forwarded-For address not itself a trusted proxy, else X-Real-IP.
+func (c *Context) ClientIP() string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	if c.engine == nil || !c.engine.trusts(host) {
+		return host
+	}
+	if fwd := c.Request.Header.Get("X-Forwarded-For"); fwd != "" {
+		hops := strings.Split(fwd, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(hops[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			if i == 0 || !c.engine.trusts(ip) {
+				return ip
+			}
+		}
+	}
+	if ip := strings.TrimSpace(c.Request.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return host
+}
+
+// Set stores a value for later handlers in the chain.
+func (c *Context) Set(key string, value interface{}) {
+	if c.keys == nil {
+		c.keys = map[string]interface{}{}
+	}
+	c.keys[key] = value
+}
+
+// GetString is a value Set as a string, or "".
+func (c *Context) GetString(key string) string {
+	s, _ := c.keys[key].(string)
+	return s
+}
+
+// ShouldBindJSON decodes the request body into obj.
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	if c.Request == nil || c.Request.Body == nil {
+		return fmt.Errorf("invalid request")
+	}
+	return json.NewDecoder(c.Request.Body).Decode(obj)
+}
+
+// NegotiateFormat picks the first offered type the Accept header lists,
+// or the first offered when there is no Accept header.
+func (c *Context) NegotiateFormat(offered ...string) string {
+	accept := c.Request.Header.Get("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+	for _, part := range strings.Split(accept, ",") {
+		want, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		for _, o := range offered {
+			if want == o || want == "*/*" || (strings.HasSuffix(want, "/*") && strings.HasPrefix(o, strings.TrimSuffix(want, "*"))) {
+				return o
+			}
+		}
+	}
+	return ""
+}
+
+// Status writes the status code alone.
+func (c *Context) Status(code int) { c.Writer.WriteHeader(code) }
+
+// JSON writes obj as a JSON response.
+func (c *Context) JSON(code int, obj interface{}) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.Data(code, "application/json; charset=utf-8", body)
+}
+
+// String writes a formatted plain-text response.
+func (c *Context) String(code int, format string, values ...interface{}) {
+	c.Data(code, "text/plain; charset=utf-8", []byte(fmt.Sprintf(format, values...)))
+}
+
+// Data writes a response with the given content type.
+func (c *Context) Data(code int, contentType string, data []byte) {
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.WriteHeader(code)
+	_, _ = c.Writer.Write(data)
+}
+
+// ResponseWriter remembers the status written.
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status is the status code written, 200 until one is
+	Status() int
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	w.status, w.written = code, true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Status() int { return w.status }
+
+// Flush lets handlers stream, e.g. pprof traces.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// RouterGroup registers routes under a path prefix and its middleware.
+type RouterGroup struct {
+	engine   *Engine
+	prefix   string
+	handlers []HandlerFunc
+}
+
+// Group returns a sub-group whose routes also run middleware.
+func (g *RouterGroup) Group(path string, middleware ...HandlerFunc) *RouterGroup {
+	return &RouterGroup{
+		engine:   g.engine,
+		prefix:   joinPath(g.prefix, path),
+		handlers: append(append([]HandlerFunc(nil), g.handlers...), middleware...),
+	}
+}
+
+func (g *RouterGroup) GET(path string, handlers ...HandlerFunc) {
+	g.handle(http.MethodGet, path, handlers)
+}
+func (g *RouterGroup) POST(path string, handlers ...HandlerFunc) {
+	g.handle(http.MethodPost, path, handlers)
+}
+func (g *RouterGroup) DELETE(path string, handlers ...HandlerFunc) {
+	g.handle(http.MethodDelete, path, handlers)
+}
+
+func (g *RouterGroup) handle(method, path string, handlers []HandlerFunc) {
+	full := joinPath(g.prefix, path)
+	g.engine.routes = append(g.engine.routes, route{
+		method:   method,
+		path:     full,
+		parts:    strings.Split(strings.Trim(full, "/"), "/"),
+		handlers: append(append([]HandlerFunc(nil), g.handlers...), handlers...),
+	})
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// Engine routes requests to handler chains. Paths match segment by
+// segment; :name matches one segment and *name the rest of the path.
+type Engine struct {
+	RouterGroup
+	routes []route
+	// trusted are the proxies whose forwarding headers ClientIP believes
+	trusted []*net.IPNet
+}
+
+type route struct {
+	method   string
+	path     string
+	parts    []string
+	handlers []HandlerFunc
+}
+
+// New returns an empty engine.
+func New() *Engine {
+	e := &Engine{}
+	e.RouterGroup = RouterGroup{engine: e, prefix: "/"}
+	return e
+}
+
+// SetTrustedProxies sets the proxies, as addresses or CIDR ranges, whose
+// X-Forwarded-For and X-Real-IP headers ClientIP believes; nil trusts none.
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	var trusted []*net.IPNet
+	for _, p := range proxies {
+		if ip := net.ParseIP(p); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 128
+			}
+			p = fmt.Sprintf("%s/%d", p, bits)
+		}
+		_, cidr, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("%q is not an IP address or CIDR range", p)
+		}
+		trusted = append(trusted, cidr)
+	}
+	e.trusted = trusted
+	return nil
+}
+
+// trusts reports whether ip is one of the trusted proxies.
+func (e *Engine) trusts(ip string) bool {
+	addr := net.ParseIP(ip)
+	for _, cidr := range e.trusted {
+		if addr != nil && cidr.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for _, rt := range e.routes {
+		if rt.method != r.Method {
+			continue
+		}
+		params, ok := match(rt.parts, segs)
+		if !ok {
+			continue
+		}
+		c := &Context{
+			Request:  r,
+			Writer:   &responseWriter{ResponseWriter: w, status: http.StatusOK},
+			engine:   e,
+			params:   params,
+			fullPath: rt.path,
+			handlers: rt.handlers,
+			index:    -1,
+		}
+		c.Next()
+		return
+	}
+	http.Error(w, "404 page not found", http.StatusNotFound)
+}
+
+func match(parts, segs []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, p := range parts {
+		if strings.HasPrefix(p, "*") {
+			params[p[1:]] = "/" + strings.Join(segs[i:], "/")
+			return params, true
+		}
+		if i >= len(segs) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(p, ":"):
+			if segs[i] == "" {
+				return nil, false
+			}
+			params[p[1:]] = segs[i]
+		case p != segs[i]:
+			return nil, false
+		}
+	}
+	return params, len(parts) == len(segs)
+}
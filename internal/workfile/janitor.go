@@ -0,0 +1,209 @@
+package workfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by CheckQuota while the work directory holds
+// more than its quota.
+var ErrQuotaExceeded = errors.New("work directory is over its disk quota, try again later")
+
+// artifact matches the top-level work files pipelines leave: names made by
+// Path, with whatever extensions tools add to them, and the os.CreateTemp /
+// os.MkdirTemp names of uploads and slide frames. The first group is the
+// stem they are tracked by.
+var artifact = regexp.MustCompile(`^([a-z_]+_[0-9a-f]{12}|(?:upload|slides)_[0-9]+)(\..*)?$`)
+
+// live records when each artifact stem of this process was handed out.
+var live = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: map[string]time.Time{}}
+
+var (
+	quota     atomic.Int64
+	usage     atomic.Int64
+	overQuota atomic.Bool
+)
+
+func track(name string) {
+	live.Lock()
+	live.m[name] = time.Now()
+	live.Unlock()
+}
+
+// Track marks a file or directory made in the work directory other than by
+// Path, e.g. with os.CreateTemp, as in use so the janitor leaves it alone
+// until it is older than the maximum age.
+func Track(path string) {
+	if m := artifact.FindStringSubmatch(filepath.Base(path)); m != nil {
+		track(m[1])
+	}
+}
+
+// RemoveAll deletes base, which may be a directory, and every file named
+// base plus an extension, e.g. a download's partial and finished files.
+func RemoveAll(base string) {
+	_ = os.RemoveAll(base)
+	matches, _ := filepath.Glob(base + ".*")
+	for _, m := range matches {
+		_ = os.RemoveAll(m)
+	}
+	live.Lock()
+	delete(live.m, filepath.Base(base))
+	live.Unlock()
+}
+
+// CheckQuota fails with ErrQuotaExceeded when the last sweep found the work
+// directory over its quota, so new downloads wait for room.
+func CheckQuota() error {
+	if q := quota.Load(); q > 0 && usage.Load() >= q {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// JanitorConfig sets how the work directory is kept clean.
+type JanitorConfig struct {
+	// Quota caps the bytes artifacts may take; 0 is no cap
+	Quota int64
+	// MaxAge is how long an artifact may live before it is presumed leaked
+	// by a request that failed without cleaning up
+	MaxAge time.Duration
+	// Interval is the time between sweeps
+	Interval time.Duration
+}
+
+// SweepStats reports one sweep.
+type SweepStats struct {
+	At           time.Time `json:"at"`
+	Files        int       `json:"files"`
+	Bytes        int64     `json:"bytes"`
+	Quota        int64     `json:"quota,omitempty"`
+	Removed      int       `json:"removed"`
+	RemovedBytes int64     `json:"removed_bytes"`
+}
+
+var lastSweep atomic.Value // SweepStats
+
+// LastSweep is the most recent sweep's report.
+func LastSweep() SweepStats {
+	s, _ := lastSweep.Load().(SweepStats)
+	return s
+}
+
+// StartJanitor removes every artifact left behind by earlier processes,
+// then sweeps the work directory every cfg.Interval for artifacts older
+// than cfg.MaxAge. The work directory must not be shared with another
+// running process.
+func StartJanitor(cfg JanitorConfig) {
+	quota.Store(cfg.Quota)
+	Sweep(cfg.MaxAge, true)
+	go func() {
+		t := time.NewTicker(cfg.Interval)
+		defer t.Stop()
+		for range t.C {
+			Sweep(cfg.MaxAge, false)
+		}
+	}()
+}
+
+// Sweep deletes orphaned artifacts in the work directory and measures what
+// is left. Artifacts this process handed out are orphans once older than
+// maxAge; others, left by a crashed earlier process, are orphans at
+// startup and otherwise once untouched for maxAge.
+func Sweep(maxAge time.Duration, startup bool) SweepStats {
+	now := time.Now()
+	stats := SweepStats{At: now.UTC(), Quota: quota.Load()}
+	dir := Dir()
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("janitor: %v", err)
+		return stats
+	}
+
+	live.Lock()
+	for name, at := range live.m {
+		if now.Sub(at) > maxAge {
+			delete(live.m, name)
+		}
+	}
+	owned := make(map[string]bool, len(live.m))
+	for name := range live.m {
+		owned[name] = true
+	}
+	live.Unlock()
+
+	for _, e := range entries {
+		m := artifact.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		size, modified := measure(path)
+		orphan := false
+		if !owned[m[1]] {
+			orphan = startup || now.Sub(modified) > maxAge
+		}
+		if orphan {
+			if err := os.RemoveAll(path); err != nil {
+				log.Printf("janitor: %v", err)
+			} else {
+				stats.Removed++
+				stats.RemovedBytes += size
+				continue
+			}
+		}
+		stats.Files++
+		stats.Bytes += size
+	}
+
+	usage.Store(stats.Bytes)
+	lastSweep.Store(stats)
+	if stats.Removed > 0 {
+		log.Printf("Janitor removed %d orphaned work files (%s)", stats.Removed, megabytes(stats.RemovedBytes))
+	}
+	over := stats.Quota > 0 && stats.Bytes >= stats.Quota
+	if overQuota.Swap(over) != over {
+		if over {
+			log.Printf("Work directory holds %s, over its %s quota: new downloads are refused", megabytes(stats.Bytes), megabytes(stats.Quota))
+		} else {
+			log.Printf("Work directory is back under its quota (%s of %s)", megabytes(stats.Bytes), megabytes(stats.Quota))
+		}
+	}
+	return stats
+}
+
+func megabytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// measure totals a file's or directory's size and finds its newest
+// modification.
+func measure(path string) (size int64, modified time.Time) {
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+			if info.ModTime().After(modified) {
+				modified = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return size, modified
+}
@@ -0,0 +1,39 @@
+// Package workfile names the temporary files and directories pipelines
+// create, and sweeps up the ones they leave behind.
+package workfile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path/filepath"
+	"sync/atomic"
+)
+
+var dir atomic.Value // string
+
+// SetDir puts every file named by Path in d; empty is the working directory.
+func SetDir(d string) {
+	dir.Store(d)
+}
+
+// Dir is the directory set by SetDir, or "" for the working directory.
+func Dir() string {
+	d, _ := dir.Load().(string)
+	return d
+}
+
+// Name returns prefix plus a random suffix, so concurrent pipelines
+// (foreground searches and background indexing) never share temp files.
+func Name(prefix string) string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return prefix + "_" + hex.EncodeToString(b)
+}
+
+// Path is Name inside the work directory, tracked as in use for the
+// janitor.
+func Path(prefix string) string {
+	name := Name(prefix)
+	track(name)
+	return filepath.Join(Dir(), name)
+}
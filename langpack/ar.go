@@ -0,0 +1,115 @@
+package langpack
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"searchme/internal/env"
+)
+
+// arabicPack folds the spelling variants Arabic captions and queries mix
+// freely, so a keyword typed without diacritics or with a bare alef still
+// matches. Each rule can be switched off with ARABIC_NORMALIZE, a comma list
+// of the rules to apply (default: all of them).
+type arabicPack struct {
+	Base
+}
+
+// Arabic folding rules, named as in ARABIC_NORMALIZE
+const (
+	arabicRuleDiacritics  = "diacritics"   // harakat, tanween, shadda, sukun, dagger alef
+	arabicRuleTatweel     = "tatweel"      // kashida used to stretch words
+	arabicRuleAlef        = "alef"         // أ إ آ ٱ → ا
+	arabicRuleHamza       = "hamza"        // ؤ → و, ئ → ي
+	arabicRuleYeh         = "yeh"          // ى → ي
+	arabicRuleTehMarbuta  = "teh_marbuta"  // ة → ه
+	arabicRuleIndicDigits = "indic_digits" // ٠-٩ → 0-9
+)
+
+// arabicSettings are the rules ARABIC_NORMALIZE enables.
+type arabicSettings struct {
+	replacer *strings.Replacer
+	strip    bool
+}
+
+// arabicLoaded is nil until first use and again after Reload.
+var arabicLoaded atomic.Pointer[arabicSettings]
+
+var arabicStopwords = map[string]bool{}
+
+func init() {
+	for _, w := range []string{
+		"في", "من", "على", "الى", "عن", "مع", "هذا", "هذه", "ذلك", "التي", "الذي", "ان",
+		"او", "ثم", "قد", "لا", "ما", "هو", "هي", "كان", "كل", "و", "يا", "لم", "لن", "بين",
+	} {
+		arabicStopwords[w] = true
+	}
+	Register(arabicPack{Base{code: "ar"}})
+}
+
+// arabicRules builds the replacer for the enabled rules on first use, after
+// main has loaded .env.
+func arabicRules() (*strings.Replacer, bool) {
+	s := arabicLoaded.Load()
+	if s == nil {
+		s = loadArabicRules()
+		arabicLoaded.Store(s)
+	}
+	return s.replacer, s.strip
+}
+
+func loadArabicRules() *arabicSettings {
+	enabled := map[string]bool{}
+	rules := env.List("ARABIC_NORMALIZE")
+	if len(rules) == 0 {
+		rules = []string{arabicRuleDiacritics, arabicRuleTatweel, arabicRuleAlef, arabicRuleHamza,
+			arabicRuleYeh, arabicRuleTehMarbuta, arabicRuleIndicDigits}
+	}
+	for _, r := range rules {
+		enabled[strings.ToLower(r)] = true
+	}
+
+	var pairs []string
+	if enabled[arabicRuleTatweel] {
+		pairs = append(pairs, "\u0640", "")
+	}
+	if enabled[arabicRuleAlef] {
+		pairs = append(pairs, "أ", "ا", "إ", "ا", "آ", "ا", "ٱ", "ا")
+	}
+	if enabled[arabicRuleHamza] {
+		pairs = append(pairs, "ؤ", "و", "ئ", "ي")
+	}
+	if enabled[arabicRuleYeh] {
+		pairs = append(pairs, "ى", "ي")
+	}
+	if enabled[arabicRuleTehMarbuta] {
+		pairs = append(pairs, "ة", "ه")
+	}
+	if enabled[arabicRuleIndicDigits] {
+		for d := '0'; d <= '9'; d++ {
+			pairs = append(pairs, string('٠'+(d-'0')), string(d))
+		}
+	}
+	return &arabicSettings{replacer: strings.NewReplacer(pairs...), strip: enabled[arabicRuleDiacritics]}
+}
+
+func (arabicPack) Fold(s string) string {
+	r, strip := arabicRules()
+	if strip {
+		s = strings.Map(func(c rune) rune {
+			if isArabicDiacritic(c) {
+				return -1
+			}
+			return c
+		}, s)
+	}
+	// Latin words mixed into Arabic text are still lowercased
+	return strings.ToLower(r.Replace(s))
+}
+
+func (arabicPack) IsStopword(word string) bool { return arabicStopwords[word] }
+
+// isArabicDiacritic reports whether c is an Arabic vowel mark or Quranic annotation.
+func isArabicDiacritic(c rune) bool {
+	return (c >= '\u064B' && c <= '\u065F') || c == '\u0670' || (c >= '\u06D6' && c <= '\u06ED')
+}
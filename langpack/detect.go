@@ -0,0 +1,82 @@
+package langpack
+
+import (
+	"unicode"
+)
+
+// scriptLangs maps a writing system to the language it most likely means.
+// Latin is handled separately since many languages share it.
+var scriptLangs = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+}
+
+// latinLangs are languages whose captions are written in Latin script.
+var latinLangs = map[string]bool{
+	"en": true, "fr": true, "es": true, "de": true, "it": true, "pt": true, "nl": true,
+	"sv": true, "no": true, "da": true, "fi": true, "pl": true, "cs": true, "ro": true,
+	"hu": true, "tr": true, "id": true, "ms": true, "vi": true, "sw": true, "tl": true,
+}
+
+// Detect guesses the language of a short text such as one caption segment from
+// the script most of its letters are written in. Latin text keeps the
+// track's language when that language is written in Latin script; inside a
+// non-Latin track (e.g. English terms in an Arabic lecture) it is taken to be
+// English. Text without letters keeps the track's language.
+func Detect(text, trackLang string) string {
+	track := primarySubtag(trackLang)
+	counts := map[string]int{}
+	latin := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scriptLangs {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang == track) {
+			best, bestCount = lang, n
+		}
+	}
+	// Japanese mixes kanji with kana; Chinese has no kana
+	if best == "zh" && counts["ja"] > 0 {
+		best = "ja"
+	}
+
+	switch {
+	case bestCount == 0 && latin == 0:
+		return trackLang
+	case latin > bestCount:
+		if latinLangs[track] {
+			return trackLang
+		}
+		return "en"
+	case best == track:
+		// keep the region subtag the caller gave
+		return trackLang
+	default:
+		return best
+	}
+}
@@ -0,0 +1,26 @@
+package langpack
+
+// englishPack adds English stopwords and Porter2 stemming on top of the
+// default folding.
+type englishPack struct {
+	Base
+}
+
+var englishStopwords = map[string]bool{}
+
+func init() {
+	for _, w := range []string{
+		"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "from", "has", "have",
+		"he", "her", "his", "i", "if", "in", "into", "is", "it", "its", "me", "my", "no", "not",
+		"of", "on", "or", "our", "she", "so", "that", "the", "their", "them", "then", "there",
+		"these", "they", "this", "to", "was", "we", "were", "what", "when", "which", "who",
+		"will", "with", "you", "your", "um", "uh", "yeah", "okay", "just", "like", "do", "did",
+	} {
+		englishStopwords[w] = true
+	}
+	Register(englishPack{Base{code: "en"}})
+}
+
+func (englishPack) IsStopword(word string) bool { return englishStopwords[word] }
+
+func (englishPack) Stem(word string) string { return porter2(word) }
@@ -0,0 +1,161 @@
+// Package langpack holds per-language text normalization rules (folding,
+// transliteration, stemming, stopwords) used for keyword matching.
+package langpack
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"searchme/internal/env"
+)
+
+// Pack bundles the text normalization rules for one language. The matcher
+// only talks to this interface, so supporting a new language means registering a
+// pack, not touching the matcher.
+type Pack interface {
+	// Code is the primary language subtag, e.g. "en" or "ar".
+	Code() string
+	// Transliterate maps text to the pack's canonical script (identity when not needed).
+	Transliterate(s string) string
+	// Fold applies case and character folding to a whole text.
+	Fold(s string) string
+	// Stem reduces a folded word to its stem (identity when unsupported).
+	Stem(word string) string
+	// IsStopword reports whether a folded word carries no search meaning.
+	IsStopword(word string) bool
+}
+
+// Base is the language-neutral default: Unicode lowercasing, no stemming,
+// no stopwords. Packs embed it and override what they need.
+type Base struct {
+	code string
+}
+
+func (p Base) Code() string                  { return p.code }
+func (p Base) Transliterate(s string) string { return s }
+func (p Base) Fold(s string) string          { return strings.ToLower(s) }
+func (p Base) Stem(word string) string       { return word }
+func (p Base) IsStopword(word string) bool   { return false }
+
+var (
+	packsMu     sync.RWMutex
+	packs       = map[string]Pack{}
+	defaultPack = Pack(Base{code: "und"})
+)
+
+// Register makes a pack available for its language code,
+// replacing any pack registered earlier for the same code.
+func Register(p Pack) {
+	packsMu.Lock()
+	defer packsMu.Unlock()
+	packs[strings.ToLower(p.Code())] = p
+}
+
+// For returns the pack for a language tag ("en-US" uses "en"),
+// falling back to the language-neutral default.
+func For(lang string) Pack {
+	code := primarySubtag(lang)
+	packsMu.RLock()
+	defer packsMu.RUnlock()
+	if p, ok := packs[code]; ok {
+		return p
+	}
+	return defaultPack
+}
+
+// primarySubtag lowercases a language tag and strips region/script subtags.
+func primarySubtag(lang string) string {
+	code := strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// NormalizeText brings text to a canonical form before matching: NFKC (which
+// also maps Arabic presentation forms and full-width letters to the plain
+// ones), then the pack's transliteration and folding, then diacritic
+// stripping when enabled for the language (see StripDiacritics).
+func NormalizeText(p Pack, s string) string {
+	s = p.Fold(p.Transliterate(norm.NFKC.String(s)))
+	if stripDiacriticsFor(p.Code()) {
+		s = StripDiacritics(s)
+	}
+	return s
+}
+
+// StripDiacritics removes combining marks: "café" becomes "cafe" and Arabic
+// harakat are dropped. Letters that decompose into a base and a mark, such as
+// hamza on alef, lose the mark too.
+func StripDiacritics(s string) string {
+	s = norm.NFD.String(s)
+	s = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, s)
+	return norm.NFC.String(s)
+}
+
+// stripSettings are the languages STRIP_DIACRITICS selects.
+type stripSettings struct {
+	all   bool
+	langs map[string]bool
+}
+
+// stripLoaded is nil until first use and again after Reload.
+var stripLoaded atomic.Pointer[stripSettings]
+
+// stripDiacriticsFor reads STRIP_DIACRITICS on first use: "all" (default),
+// "none", or a comma list of language codes.
+func stripDiacriticsFor(code string) bool {
+	s := stripLoaded.Load()
+	if s == nil {
+		s = &stripSettings{langs: map[string]bool{}}
+		langs := env.List("STRIP_DIACRITICS")
+		if len(langs) == 0 {
+			s.all = true
+		}
+		for _, l := range langs {
+			switch l = strings.ToLower(l); l {
+			case "all":
+				s.all = true
+			case "none":
+			default:
+				s.langs[primarySubtag(l)] = true
+			}
+		}
+		stripLoaded.Store(s)
+	}
+	return s.all || s.langs[code]
+}
+
+// Reload makes the packs re-read their settings (STRIP_DIACRITICS,
+// ARABIC_NORMALIZE) on next use.
+func Reload() {
+	stripLoaded.Store(nil)
+	arabicLoaded.Store(nil)
+}
+
+// ContentWords returns the folded, stemmed words of text with stopwords removed.
+func ContentWords(p Pack, s string) []string {
+	var out []string
+	for _, w := range strings.FieldsFunc(NormalizeText(p, s), IsWordSeparator) {
+		if p.IsStopword(w) {
+			continue
+		}
+		out = append(out, p.Stem(w))
+	}
+	return out
+}
+
+// IsWordSeparator reports whether r splits words: anything but letters, digits,
+// combining marks and apostrophes.
+func IsWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.Is(unicode.Mn, r) && r != '\''
+}
@@ -0,0 +1,302 @@
+package langpack
+
+// English stemming with the Snowball "Porter2" algorithm
+// (https://snowballstem.org/algorithms/english/stemmer.html).
+
+var porterExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli",
+	"singly": "singl", "sky": "sky", "news": "news", "howe": "howe",
+	"atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// words left alone after step 1a
+var porterInvariants = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+type porterRule struct {
+	suffix, replacement string
+}
+
+var porterStep2 = []porterRule{
+	{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"},
+	{"iveness", "ive"}, {"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"},
+	{"entli", "ent"}, {"ation", "ate"}, {"alism", "al"}, {"aliti", "al"},
+	{"ousli", "ous"}, {"iviti", "ive"}, {"fulli", "ful"}, {"enci", "ence"},
+	{"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"},
+	{"alli", "al"}, {"bli", "ble"}, {"ogi", "og"}, {"li", ""},
+}
+
+var porterStep3 = []porterRule{
+	{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"},
+	{"iciti", "ic"}, {"ative", ""}, {"ical", "ic"}, {"ness", ""}, {"ful", ""},
+}
+
+var porterStep4 = []string{
+	"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent", "ism",
+	"ate", "iti", "ous", "ive", "ize", "ion", "al", "er", "ic",
+}
+
+// porter2 stems a lowercase English word. Words with non-ASCII letters are
+// returned unchanged.
+func porter2(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] >= 0x80 {
+			return word
+		}
+	}
+	if word[0] == '\'' {
+		word = word[1:]
+	}
+	if x, ok := porterExceptions[word]; ok {
+		return x
+	}
+
+	w := &porterWord{b: []byte(word)}
+	// consonant y: initial, or after a vowel
+	if w.b[0] == 'y' {
+		w.b[0] = 'Y'
+	}
+	for i := 1; i < len(w.b); i++ {
+		if w.b[i] == 'y' && isPorterVowel(w.b[i-1]) {
+			w.b[i] = 'Y'
+		}
+	}
+	w.regions()
+
+	w.step0()
+	w.step1a()
+	if porterInvariants[string(w.b)] {
+		return string(w.b)
+	}
+	w.step1b()
+	w.step1c()
+	w.step2()
+	w.step3()
+	w.step4()
+	w.step5()
+
+	for i, c := range w.b {
+		if c == 'Y' {
+			w.b[i] = 'y'
+		}
+	}
+	return string(w.b)
+}
+
+type porterWord struct {
+	b      []byte
+	r1, r2 int
+}
+
+func isPorterVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// regions finds R1 (after the first non-vowel following a vowel) and R2 (the same, within R1).
+func (w *porterWord) regions() {
+	next := func(from int) int {
+		for i := from; i < len(w.b); i++ {
+			if i > 0 && !isPorterVowel(w.b[i]) && isPorterVowel(w.b[i-1]) {
+				return i + 1
+			}
+		}
+		return len(w.b)
+	}
+	w.r1 = -1
+	for _, p := range []string{"gener", "commun", "arsen"} {
+		if len(w.b) >= len(p) && string(w.b[:len(p)]) == p {
+			w.r1 = len(p)
+		}
+	}
+	if w.r1 < 0 {
+		w.r1 = next(1)
+	}
+	w.r2 = next(w.r1 + 1)
+	if w.r2 > len(w.b) {
+		w.r2 = len(w.b)
+	}
+}
+
+func (w *porterWord) hasSuffix(s string) bool {
+	return len(w.b) >= len(s) && string(w.b[len(w.b)-len(s):]) == s
+}
+
+// longest returns the longest of suffixes the word ends with, or "".
+func (w *porterWord) longest(suffixes ...string) string {
+	best := ""
+	for _, s := range suffixes {
+		if len(s) > len(best) && w.hasSuffix(s) {
+			best = s
+		}
+	}
+	return best
+}
+
+func (w *porterWord) inR1(suffix string) bool { return len(w.b)-len(suffix) >= w.r1 }
+func (w *porterWord) inR2(suffix string) bool { return len(w.b)-len(suffix) >= w.r2 }
+
+func (w *porterWord) replace(suffix, with string) {
+	w.b = append(w.b[:len(w.b)-len(suffix)], with...)
+}
+
+func (w *porterWord) vowelBefore(end int) bool {
+	for i := 0; i < end; i++ {
+		if isPorterVowel(w.b[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsShortSyllable: non-vowel, vowel, non-vowel other than w, x or Y; or a
+// vowel then a non-vowel at the very start.
+func endsShortSyllable(b []byte) bool {
+	n := len(b)
+	switch {
+	case n == 2:
+		return isPorterVowel(b[0]) && !isPorterVowel(b[1])
+	case n > 2:
+		last := b[n-1]
+		return !isPorterVowel(b[n-3]) && isPorterVowel(b[n-2]) && !isPorterVowel(last) &&
+			last != 'w' && last != 'x' && last != 'Y'
+	}
+	return false
+}
+
+func (w *porterWord) isShort() bool {
+	return w.r1 >= len(w.b) && endsShortSyllable(w.b)
+}
+
+func (w *porterWord) step0() {
+	if s := w.longest("'s'", "'s", "'"); s != "" {
+		w.replace(s, "")
+	}
+}
+
+func (w *porterWord) step1a() {
+	switch s := w.longest("sses", "ied", "ies", "us", "ss", "s"); s {
+	case "sses":
+		w.replace(s, "ss")
+	case "ied", "ies":
+		if len(w.b) > 4 {
+			w.replace(s, "i")
+		} else {
+			w.replace(s, "ie")
+		}
+	case "s":
+		if w.vowelBefore(len(w.b) - 2) {
+			w.replace(s, "")
+		}
+	}
+}
+
+func (w *porterWord) step1b() {
+	switch s := w.longest("eedly", "ingly", "edly", "eed", "ing", "ed"); s {
+	case "":
+	case "eed", "eedly":
+		if w.inR1(s) {
+			w.replace(s, "ee")
+		}
+	default:
+		if !w.vowelBefore(len(w.b) - len(s)) {
+			return
+		}
+		w.replace(s, "")
+		switch {
+		case w.hasSuffix("at") || w.hasSuffix("bl") || w.hasSuffix("iz"):
+			w.b = append(w.b, 'e')
+		case w.longest("bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt") != "":
+			w.b = w.b[:len(w.b)-1]
+		case w.isShort():
+			w.b = append(w.b, 'e')
+		}
+	}
+}
+
+func (w *porterWord) step1c() {
+	n := len(w.b)
+	if n > 2 && (w.b[n-1] == 'y' || w.b[n-1] == 'Y') && !isPorterVowel(w.b[n-2]) {
+		w.b[n-1] = 'i'
+	}
+}
+
+func (w *porterWord) applyRules(rules []porterRule, cond func(r porterRule) bool) {
+	var match *porterRule
+	for i := range rules {
+		if w.hasSuffix(rules[i].suffix) && (match == nil || len(rules[i].suffix) > len(match.suffix)) {
+			match = &rules[i]
+		}
+	}
+	if match != nil && cond(*match) {
+		w.replace(match.suffix, match.replacement)
+	}
+}
+
+func (w *porterWord) step2() {
+	w.applyRules(porterStep2, func(r porterRule) bool {
+		if !w.inR1(r.suffix) {
+			return false
+		}
+		before := len(w.b) - len(r.suffix) - 1
+		switch r.suffix {
+		case "ogi":
+			return before >= 0 && w.b[before] == 'l'
+		case "li":
+			if before < 0 {
+				return false
+			}
+			switch w.b[before] {
+			case 'c', 'd', 'e', 'g', 'h', 'k', 'm', 'n', 'r', 't':
+				return true
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func (w *porterWord) step3() {
+	w.applyRules(porterStep3, func(r porterRule) bool {
+		if r.suffix == "ative" {
+			return w.inR2(r.suffix)
+		}
+		return w.inR1(r.suffix)
+	})
+}
+
+func (w *porterWord) step4() {
+	s := w.longest(porterStep4...)
+	if s == "" || !w.inR2(s) {
+		return
+	}
+	if s == "ion" {
+		before := len(w.b) - len(s) - 1
+		if before < 0 || (w.b[before] != 's' && w.b[before] != 't') {
+			return
+		}
+	}
+	w.replace(s, "")
+}
+
+func (w *porterWord) step5() {
+	switch {
+	case w.hasSuffix("e"):
+		if w.inR2("e") || (w.inR1("e") && !endsShortSyllable(w.b[:len(w.b)-1])) {
+			w.replace("e", "")
+		}
+	case w.hasSuffix("l"):
+		if w.inR2("l") && len(w.b) > 1 && w.b[len(w.b)-2] == 'l' {
+			w.replace("l", "")
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package media
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"searchme/internal/env"
+)
+
+// Audio defaults. 16 kHz is what Whisper resamples to internally, so
+// anything lower throws away accuracy and anything higher only costs upload.
+const (
+	DefaultChunkSeconds = 300
+	DefaultSampleRate   = 16000
+	DefaultChannels     = 1
+	DefaultBitrateKbps  = 32
+)
+
+// whisperUploadLimit is the OpenAI transcription API's per-file cap; chunks
+// are kept a little under it to leave room for container overhead.
+const whisperUploadLimit = 25 << 20
+
+// whisperFormats are the file types the transcription API takes as they are.
+var whisperFormats = map[string]bool{".flac": true, ".m4a": true, ".mp3": true, ".mp4": true, ".mpeg": true, ".mpga": true, ".oga": true, ".ogg": true, ".wav": true, ".webm": true}
+
+// FitsWhisper reports whether the file at path can go to Whisper without
+// re-encoding: a type the API takes, comfortably under its upload limit.
+func FitsWhisper(path string) bool {
+	if !whisperFormats[strings.ToLower(filepath.Ext(path))] {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Size() <= whisperUploadLimit*9/10
+}
+
+// sampleRates are the rates ffmpeg's mp3 encoder accepts.
+var sampleRates = map[int]bool{8000: true, 11025: true, 12000: true, 16000: true, 22050: true, 24000: true, 32000: true, 44100: true, 48000: true}
+
+// AudioSettings controls how audio is encoded for transcription and how long
+// each Whisper chunk is. Zero fields mean the server's default.
+type AudioSettings struct {
+	ChunkSeconds int `json:"chunk_seconds,omitempty"`
+	SampleRate   int `json:"sample_rate,omitempty"`
+	Channels     int `json:"channels,omitempty"`
+	BitrateKbps  int `json:"bitrate_kbps,omitempty"`
+}
+
+// AudioSettingsFromEnv reads CHUNK_SECONDS (300), AUDIO_SAMPLE_RATE (16000),
+// AUDIO_CHANNELS (1) and AUDIO_BITRATE_KBPS (32).
+func AudioSettingsFromEnv() AudioSettings {
+	return AudioSettings{
+		ChunkSeconds: env.Int("CHUNK_SECONDS", DefaultChunkSeconds),
+		SampleRate:   env.Int("AUDIO_SAMPLE_RATE", DefaultSampleRate),
+		Channels:     env.Int("AUDIO_CHANNELS", DefaultChannels),
+		BitrateKbps:  env.Int("AUDIO_BITRATE_KBPS", DefaultBitrateKbps),
+	}
+}
+
+// Merge returns s with the non-zero fields of override applied.
+func (s AudioSettings) Merge(override AudioSettings) AudioSettings {
+	if override.ChunkSeconds != 0 {
+		s.ChunkSeconds = override.ChunkSeconds
+	}
+	if override.SampleRate != 0 {
+		s.SampleRate = override.SampleRate
+	}
+	if override.Channels != 0 {
+		s.Channels = override.Channels
+	}
+	if override.BitrateKbps != 0 {
+		s.BitrateKbps = override.BitrateKbps
+	}
+	return s
+}
+
+// WithDefaults fills the zero fields from AudioSettingsFromEnv.
+func (s AudioSettings) WithDefaults() AudioSettings {
+	return AudioSettingsFromEnv().Merge(s)
+}
+
+// Validate checks the settings are ones ffmpeg can encode and that a chunk
+// fits Whisper's upload limit.
+func (s AudioSettings) Validate() error {
+	switch {
+	case s.ChunkSeconds < 10 || s.ChunkSeconds > 1800:
+		return fmt.Errorf("chunk_seconds must be between 10 and 1800, got %d", s.ChunkSeconds)
+	case !sampleRates[s.SampleRate]:
+		return fmt.Errorf("unsupported sample_rate %d (want 8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100 or 48000)", s.SampleRate)
+	case s.Channels != 1 && s.Channels != 2:
+		return fmt.Errorf("channels must be 1 or 2, got %d", s.Channels)
+	case s.BitrateKbps < 8 || s.BitrateKbps > 320:
+		return fmt.Errorf("bitrate_kbps must be between 8 and 320, got %d", s.BitrateKbps)
+	}
+	if bytes := s.BitrateKbps * 1000 / 8 * s.ChunkSeconds; bytes > whisperUploadLimit*9/10 {
+		return fmt.Errorf("%ds chunks at %d kbit/s would be about %d MB, over Whisper's 25 MB upload limit; lower chunk_seconds or bitrate_kbps",
+			s.ChunkSeconds, s.BitrateKbps, bytes>>20)
+	}
+	return nil
+}
+
+// FFmpegArgs are the ffmpeg output options that apply the settings.
+func (s AudioSettings) FFmpegArgs() []string {
+	return []string{
+		"-ar", strconv.Itoa(s.SampleRate),
+		"-ac", strconv.Itoa(s.Channels),
+		"-b:a", strconv.Itoa(s.BitrateKbps) + "k",
+	}
+}
@@ -0,0 +1,393 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"searchme/artifact"
+	"searchme/internal/env"
+	"searchme/internal/workfile"
+)
+
+// AudioCache keeps downloaded audio on disk, a directory per video and
+// encoding, so searching a video without captions for a second keyword
+// doesn't download it again. The directory also holds files derived from
+// the audio, such as its chunks (see AudioFile.CacheDir). Past its size
+// limit the least recently used entries are evicted, never one in use. It
+// is safe for concurrent use.
+//
+// With an Encryptor the audio is stored sealed and each AudioFile handed out
+// is a decrypted temporary copy; nothing is derived from it in the cache, so
+// no audio is kept in plaintext.
+type AudioCache struct {
+	dir   string
+	limit int64
+	enc   *artifact.Encryptor
+
+	mu      sync.Mutex
+	entries map[string]*audioEntry
+}
+
+type audioEntry struct {
+	name string
+	// key is what the entry was cached under, "" for entries cached before
+	// keys were recorded
+	key  string
+	size int64
+	used time.Time
+	// refs counts the AudioFiles handed out and not yet removed
+	refs int
+	// purged entries are removed once the last reference is released
+	purged bool
+}
+
+// cachedAudioName is an entry's audio file; everything else in the entry
+// is derived from it, but for cachedKeyName, which records its key.
+const (
+	cachedAudioName = "audio.mp3"
+	cachedKeyName   = "key"
+)
+
+// AudioCacheEntry describes a cached audio file and what was derived from it.
+type AudioCacheEntry struct {
+	// Video is the YouTube ID or URL of the video; "" when unknown
+	Video    string    `json:"video,omitempty"`
+	Bytes    int64     `json:"size_bytes"`
+	LastUsed time.Time `json:"last_used"`
+	// InUse entries are being searched and outlive a purge until released
+	InUse bool `json:"in_use,omitempty"`
+}
+
+var (
+	sharedAudioCacheOnce sync.Once
+	sharedAudioCache     *AudioCache
+)
+
+// SharedAudioCache is the process's audio cache: AUDIO_CACHE_DIR (default
+// audio_cache in the work directory) holding up to AUDIO_CACHE_SIZE (1GB;
+// "0" turns caching off), encrypted when encryption at rest is on (see
+// artifact.EncryptorFromEnv). It is nil when off or its directory can't be
+// made.
+func SharedAudioCache() *AudioCache {
+	sharedAudioCacheOnce.Do(func() {
+		if os.Getenv("AUDIO_CACHE_SIZE") == "0" {
+			return
+		}
+		enc, err := artifact.EncryptorFromEnv()
+		if err != nil {
+			log.Printf("audio cache disabled: encryption unavailable: %v", err)
+			return
+		}
+		dir := env.Or("AUDIO_CACHE_DIR", filepath.Join(workfile.Dir(), "audio_cache"))
+		c, err := NewAudioCache(dir, env.Bytes("AUDIO_CACHE_SIZE", 1<<30))
+		if err != nil {
+			log.Printf("audio cache disabled: %v", err)
+			return
+		}
+		c.enc = enc
+		sharedAudioCache = c
+	})
+	return sharedAudioCache
+}
+
+// NewAudioCache opens the cache in dir, creating it if needed and picking
+// up entries left by earlier processes.
+func NewAudioCache(dir string, limit int64) (*AudioCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &AudioCache{dir: dir, limit: limit, entries: map[string]*audioEntry{}}
+	found, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range found {
+		path := filepath.Join(dir, e.Name())
+		if !e.IsDir() {
+			if strings.HasPrefix(e.Name(), ".sealing_") {
+				// audio being encrypted into the cache when a process died
+				_ = os.Remove(path)
+			}
+			continue
+		}
+		info, err := os.Stat(filepath.Join(path, cachedAudioName))
+		if err != nil {
+			// a download that never finished
+			_ = os.RemoveAll(path)
+			continue
+		}
+		key, _ := os.ReadFile(filepath.Join(path, cachedKeyName))
+		c.entries[e.Name()] = &audioEntry{name: e.Name(), key: string(key), size: dirSize(path), used: info.ModTime()}
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// audioCacheName is the entry directory for key.
+func audioCacheName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:10])
+}
+
+// Get returns the cached audio for key, held in the cache until the
+// AudioFile is removed.
+func (c *AudioCache) Get(key string, settings AudioSettings) (*AudioFile, bool) {
+	if c == nil {
+		return nil, false
+	}
+	held, ok := c.get(key, settings)
+	if !ok || c.enc == nil {
+		return held, ok
+	}
+	plain := workfile.Path("audio") + ".mp3"
+	if err := c.enc.OpenFile(held.Path, plain); err != nil {
+		// corrupt, or cached before encryption was turned on
+		log.Printf("audio cache: %v", err)
+		held.Remove()
+		c.discard(audioCacheName(key))
+		return nil, false
+	}
+	held.Path, held.Temp = plain, true
+	return held, true
+}
+
+// discard drops the entry name, once released if it is in use.
+func (c *AudioCache) discard(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	switch {
+	case !ok:
+	case e.refs > 0:
+		e.purged = true
+	default:
+		c.remove(e)
+	}
+}
+
+func (c *AudioCache) get(key string, settings AudioSettings) (*AudioFile, bool) {
+	name := audioCacheName(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	if !ok || e.purged {
+		return nil, false
+	}
+	path := filepath.Join(c.dir, name)
+	if _, err := os.Stat(filepath.Join(path, cachedAudioName)); err != nil {
+		if e.refs == 0 {
+			delete(c.entries, name)
+			_ = os.RemoveAll(path)
+		}
+		return nil, false
+	}
+	e.used = time.Now()
+	_ = os.Chtimes(filepath.Join(path, cachedAudioName), e.used, e.used)
+	return c.hold(e, settings), true
+}
+
+// Put moves a freshly downloaded audio file into the cache under key and
+// returns it held as Get does. When key is already cached, or the file
+// can't be moved, audio comes back as it was.
+func (c *AudioCache) Put(key string, audio *AudioFile) *AudioFile {
+	if c == nil || !audio.Temp {
+		return audio
+	}
+	src := audio.Path
+	if c.enc != nil {
+		// sealed before taking the lock; the plaintext stays the caller's
+		sealed, err := c.seal(audio.Path)
+		if err != nil {
+			log.Printf("audio cache: %v", err)
+			return audio
+		}
+		defer os.Remove(sealed)
+		src = sealed
+	}
+	name := audioCacheName(key)
+	path := filepath.Join(c.dir, name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[name]; ok {
+		return audio
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		log.Printf("audio cache: %v", err)
+		return audio
+	}
+	if err := os.Rename(src, filepath.Join(path, cachedAudioName)); err != nil {
+		log.Printf("audio cache: %v", err)
+		_ = os.RemoveAll(path)
+		return audio
+	}
+	if err := os.WriteFile(filepath.Join(path, cachedKeyName), []byte(key), 0644); err != nil {
+		log.Printf("audio cache: %v", err)
+	}
+	e := &audioEntry{name: name, key: key, size: dirSize(path), used: time.Now()}
+	c.entries[name] = e
+	held := c.hold(e, audio.Settings)
+	if c.enc != nil {
+		held.Path, held.Temp = audio.Path, true
+	}
+	c.evict()
+	return held
+}
+
+// seal encrypts the audio at path into a file in the cache directory, ready
+// to be renamed into an entry.
+func (c *AudioCache) seal(path string) (string, error) {
+	f, err := os.CreateTemp(c.dir, ".sealing_*")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	if err := c.enc.SealFile(path, f.Name()); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// hold hands out e's audio, counting it in use until removed. c.mu is held.
+func (c *AudioCache) hold(e *audioEntry, settings AudioSettings) *AudioFile {
+	e.refs++
+	path := filepath.Join(c.dir, e.name)
+	var once sync.Once
+	cacheDir := path
+	if c.enc != nil {
+		cacheDir = ""
+	}
+	return &AudioFile{
+		Path:     filepath.Join(path, cachedAudioName),
+		Settings: settings,
+		CacheDir: cacheDir,
+		release: func() {
+			once.Do(func() {
+				// derived files may have been added while it was held
+				size := dirSize(path)
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				e.refs--
+				e.size = size
+				if e.purged && e.refs == 0 {
+					c.remove(e)
+				}
+				c.evict()
+			})
+		},
+	}
+}
+
+// evict drops the least recently used entries not in use until the cache
+// fits its limit. c.mu is held.
+func (c *AudioCache) evict() {
+	var total int64
+	for _, e := range c.entries {
+		total += e.size
+	}
+	if c.limit <= 0 || total <= c.limit {
+		return
+	}
+	byAge := make([]*audioEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		byAge = append(byAge, e)
+	}
+	sort.Slice(byAge, func(a, b int) bool { return byAge[a].used.Before(byAge[b].used) })
+	for _, e := range byAge {
+		if total <= c.limit {
+			break
+		}
+		if e.refs > 0 {
+			continue
+		}
+		if !c.remove(e) {
+			continue
+		}
+		total -= e.size
+	}
+}
+
+// remove deletes e from disk and the cache, reporting whether it could.
+// c.mu is held.
+func (c *AudioCache) remove(e *audioEntry) bool {
+	if err := os.RemoveAll(filepath.Join(c.dir, e.name)); err != nil {
+		log.Printf("audio cache: %v", err)
+		return false
+	}
+	if c.entries[e.name] == e {
+		delete(c.entries, e.name)
+	}
+	return true
+}
+
+// Entries lists what the cache holds, most recently used first.
+func (c *AudioCache) Entries() []AudioCacheEntry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]AudioCacheEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		if e.purged {
+			continue
+		}
+		out = append(out, AudioCacheEntry{Video: cachedVideo(e.key), Bytes: e.size, LastUsed: e.used, InUse: e.refs > 0})
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].LastUsed.After(out[b].LastUsed) })
+	return out
+}
+
+// Purge drops the entries whose video match accepts and returns how many
+// there were and their size. Entries in use are hidden from Get at once
+// and deleted when released.
+func (c *AudioCache) Purge(match func(video string) bool) (n int, bytes int64) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries {
+		if e.purged || !match(cachedVideo(e.key)) {
+			continue
+		}
+		n++
+		bytes += e.size
+		if e.refs > 0 {
+			e.purged = true
+			continue
+		}
+		c.remove(e)
+	}
+	return n, bytes
+}
+
+// cachedVideo is the video part of a cache key (see ytdlpSource.cacheKey).
+func cachedVideo(key string) string {
+	video, _, _ := strings.Cut(key, "\x00")
+	return video
+}
+
+// dirSize totals the sizes of the files under path.
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,56 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// AudioTrack describes one audio rendition yt-dlp can download.
+type AudioTrack struct {
+	FormatID  string  `json:"format_id"`
+	Language  string  `json:"language,omitempty"`
+	Note      string  `json:"note,omitempty"`
+	Codec     string  `json:"codec,omitempty"`
+	Bitrate   float64 `json:"bitrate,omitempty"`
+	AudioOnly bool    `json:"audio_only"`
+}
+
+// ListAudioTracks returns the audio-bearing formats of a video.
+func (d *Downloader) ListAudioTracks(videoURL string) ([]AudioTrack, error) {
+	out, err := d.Output(context.Background(), "-J", "--no-playlist", "--", videoURL)
+	if err != nil {
+		log.Printf("yt-dlp metadata error: %v", err)
+		return nil, fmt.Errorf("failed to read video metadata: %w", err)
+	}
+	var info struct {
+		Formats []struct {
+			FormatID   string  `json:"format_id"`
+			Language   string  `json:"language"`
+			FormatNote string  `json:"format_note"`
+			ACodec     string  `json:"acodec"`
+			VCodec     string  `json:"vcodec"`
+			ABR        float64 `json:"abr"`
+		} `json:"formats"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse video metadata: %w", err)
+	}
+
+	var tracks []AudioTrack
+	for _, f := range info.Formats {
+		if f.ACodec == "" || f.ACodec == "none" {
+			continue
+		}
+		tracks = append(tracks, AudioTrack{
+			FormatID:  f.FormatID,
+			Language:  f.Language,
+			Note:      f.FormatNote,
+			Codec:     f.ACodec,
+			Bitrate:   f.ABR,
+			AudioOnly: f.VCodec == "none",
+		})
+	}
+	return tracks, nil
+}
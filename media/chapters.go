@@ -0,0 +1,33 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Chapter is a titled section of a video, as declared by the uploader.
+type Chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start_time"`
+	End   float64 `json:"end_time"`
+}
+
+// Chapters asks yt-dlp for a video's chapters without downloading it. Videos
+// without chapters return none and no error.
+func (d *Downloader) Chapters(videoURL string) ([]Chapter, error) {
+	out, err := d.Output(context.Background(), "--skip-download", "--print", "%(chapters)j", "--", videoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chapters: %w", err)
+	}
+	text := strings.TrimSpace(string(out))
+	if text == "" || text == "NA" || text == "null" {
+		return nil, nil
+	}
+	var chapters []Chapter
+	if err := json.Unmarshal([]byte(text), &chapters); err != nil {
+		return nil, fmt.Errorf("unexpected chapters output: %w", err)
+	}
+	return chapters, nil
+}
@@ -0,0 +1,137 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"searchme/events"
+	"searchme/internal/faults"
+	"searchme/internal/workfile"
+)
+
+// SectionDownloader is implemented by sources that can fetch part of a video
+// without the rest of it.
+type SectionDownloader interface {
+	DownloadSection(ctx context.Context, start, end float64, audioOnly bool) (*AudioFile, error)
+}
+
+// Clip cuts start..end seconds out of the source into a temp file: mp4 with
+// the picture, or mp3 when audioOnly. Sources that can't fetch a section are
+// downloaded whole and cut with ffmpeg.
+func Clip(ctx context.Context, src VideoSource, start, end float64, audioOnly bool) (*AudioFile, error) {
+	if end <= start {
+		return nil, fmt.Errorf("clip end must be after its start")
+	}
+	if sd, ok := src.(SectionDownloader); ok {
+		return sd.DownloadSection(ctx, start, end, audioOnly)
+	}
+	var full *AudioFile
+	var err error
+	if audioOnly {
+		full, err = src.DownloadAudio(ctx)
+	} else {
+		full, err = DownloadMedia(ctx, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer full.Remove()
+	return cutClip(ctx, full.Path, start, end, audioOnly)
+}
+
+// cutClip re-encodes start..end of in, so the clip starts exactly at start
+// rather than at the keyframe before it.
+func cutClip(ctx context.Context, in string, start, end float64, audioOnly bool) (*AudioFile, error) {
+	args := []string{"-hide_banner", "-loglevel", "error",
+		"-ss", seconds(start), "-i", in, "-t", seconds(end - start)}
+	dest := workfile.Path("clip")
+	if audioOnly {
+		dest += ".mp3"
+		args = append(args, "-vn", "-c:a", "libmp3lame", "-q:a", "4")
+	} else {
+		dest += ".mp4"
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-c:a", "aac", "-movflags", "+faststart")
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, "-y", dest)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(dest)
+		log.Printf("ffmpeg clip error: %s", string(out))
+		return nil, fmt.Errorf("failed to cut clip: %w", err)
+	}
+	return &AudioFile{Path: dest, Temp: true}, nil
+}
+
+// DownloadSection has yt-dlp fetch only start..end, cutting at exact
+// timestamps rather than keyframes.
+func (s *ytdlpSource) DownloadSection(ctx context.Context, start, end float64, audioOnly bool) (*AudioFile, error) {
+	if err := faults.Download(ctx, s.url); err != nil {
+		return nil, err
+	}
+	base := workfile.Path("clip")
+	args := []string{
+		"--download-sections", "*" + seconds(start) + "-" + seconds(end),
+		"--force-keyframes-at-cuts",
+	}
+	if audioOnly {
+		args = append(args, "-f", s.dl.AudioFormat(), "--extract-audio", "--audio-format", "mp3")
+	} else {
+		args = append(args, "-f", "bestvideo[height<=720][ext=mp4]+bestaudio/best[height<=720]/best", "--merge-output-format", "mp4")
+	}
+	out, err := s.dl.download(ctx, append(args, "-o", base+".%(ext)s", s.url)...)
+	if err != nil {
+		log.Printf("yt-dlp clip download error: %s", string(out))
+		return nil, fmt.Errorf("clip download failed: %w", err)
+	}
+	matches, _ := filepath.Glob(base + ".*")
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("clip download produced no file")
+	}
+	events.Emit(events.DownloadFinished, s.url, map[string]interface{}{"kind": "clip"})
+	return &AudioFile{Path: matches[0], Temp: true}, nil
+}
+
+func seconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
+
+// Preview kinds
+const (
+	PreviewThumbnail = "thumbnail"
+	PreviewGIF       = "gif"
+)
+
+// previewGIFSeconds is how long a GIF preview runs
+const previewGIFSeconds = 3
+
+// Preview renders a still frame (jpg) or a short looping GIF of the video at
+// the given second into dest, fetching just that moment where the source
+// allows.
+func Preview(ctx context.Context, src VideoSource, at float64, kind, dest string) error {
+	length := 1.0
+	if kind == PreviewGIF {
+		length = previewGIFSeconds
+	}
+	clip, err := Clip(ctx, src, at, at+length, false)
+	if err != nil {
+		return err
+	}
+	defer clip.Remove()
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", clip.Path}
+	if kind == PreviewGIF {
+		args = append(args, "-t", seconds(previewGIFSeconds), "-vf", "fps=10,scale=320:-2:flags=lanczos", "-loop", "0")
+	} else {
+		args = append(args, "-frames:v", "1", "-vf", "scale=480:-2", "-q:v", "4")
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, "-y", dest)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(dest)
+		log.Printf("ffmpeg preview error: %s", string(out))
+		return fmt.Errorf("failed to render preview: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Comment is a viewer comment on a video, as extracted by yt-dlp.
+type Comment struct {
+	ID     string `json:"id"`
+	Text   string `json:"text"`
+	Author string `json:"author"`
+	Likes  int    `json:"like_count"`
+}
+
+// Comments asks yt-dlp for up to max of a video's top comments without
+// downloading it. Platforms without comments return none and no error.
+func (d *Downloader) Comments(ctx context.Context, videoURL string, max int) ([]Comment, error) {
+	out, err := d.Output(ctx, "--skip-download", "--write-comments",
+		"--extractor-args", fmt.Sprintf("youtube:comment_sort=top;max_comments=%d", max),
+		"--print", "%(comments)j", "--", videoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comments: %w", err)
+	}
+	text := strings.TrimSpace(string(out))
+	if text == "" || text == "NA" || text == "null" {
+		return nil, nil
+	}
+	var comments []Comment
+	if err := json.Unmarshal([]byte(text), &comments); err != nil {
+		return nil, fmt.Errorf("unexpected comments output: %w", err)
+	}
+	return comments, nil
+}
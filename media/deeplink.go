@@ -0,0 +1,57 @@
+package media
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// YouTubeVideoID extracts the video ID from the common YouTube URL shapes
+// (watch?v=, youtu.be/, /shorts/, /embed/, /live/). Returns "" if none found.
+func YouTubeVideoID(videoURL string) string {
+	u, err := url.Parse(strings.TrimSpace(videoURL))
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	host = strings.TrimPrefix(host, "m.")
+
+	switch host {
+	case "youtu.be":
+		return strings.Trim(u.Path, "/")
+	case "youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		if v := u.Query().Get("v"); v != "" {
+			return v
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) == 2 {
+			switch parts[0] {
+			case "shorts", "embed", "live", "v":
+				return parts[1]
+			}
+		}
+	}
+	return ""
+}
+
+// DeepLink builds a URL that opens the video at the given offset.
+// YouTube links are normalized to youtu.be; Vimeo uses the #t= fragment;
+// anything else gets a media fragment (#t=) which browsers honor for direct media.
+func DeepLink(videoURL string, seconds float64) string {
+	t := int(seconds + 0.5)
+	if id := YouTubeVideoID(videoURL); id != "" {
+		return fmt.Sprintf("https://youtu.be/%s?t=%d", id, t)
+	}
+
+	u, err := url.Parse(strings.TrimSpace(videoURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	if host == "vimeo.com" || host == "player.vimeo.com" {
+		u.Fragment = fmt.Sprintf("t=%ds", t)
+		return u.String()
+	}
+	u.Fragment = fmt.Sprintf("t=%d", t)
+	return u.String()
+}
@@ -0,0 +1,112 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"searchme/internal/env"
+)
+
+// DependencyCheck is the state of one external tool the pipeline shells out to.
+type DependencyCheck struct {
+	Name       string `json:"name"`
+	Path       string `json:"path,omitempty"`
+	Version    string `json:"version,omitempty"`
+	MinVersion string `json:"min_version,omitempty"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+}
+
+// depCheckTimeout bounds each --version call so a wedged binary can't hang a probe.
+const depCheckTimeout = 5 * time.Second
+
+var ffmpegVersionRegex = regexp.MustCompile(`version n?(\d+(?:\.\d+)*)`)
+
+// CheckYTDLP verifies the configured yt-dlp runs and is at least
+// YTDLP_MIN_VERSION (default 2023.03.04, yt-dlp's date versioning).
+func (d *Downloader) CheckYTDLP(ctx context.Context) DependencyCheck {
+	return checkTool(ctx, "yt-dlp", d.config().Binary, []string{"--version"},
+		env.Or("YTDLP_MIN_VERSION", "2023.03.04"),
+		func(out string) string { return strings.TrimSpace(out) })
+}
+
+// CheckFFmpeg verifies ffmpeg and ffprobe run and are at least
+// FFMPEG_MIN_VERSION (default 4.0). Git builds report no release number and
+// pass on presence alone.
+func CheckFFmpeg(ctx context.Context) []DependencyCheck {
+	minVersion := env.Or("FFMPEG_MIN_VERSION", "4.0")
+	var checks []DependencyCheck
+	for _, name := range []string{"ffmpeg", "ffprobe"} {
+		checks = append(checks, checkTool(ctx, name, name, []string{"-version"}, minVersion, func(out string) string {
+			first, _, _ := strings.Cut(out, "\n")
+			if m := ffmpegVersionRegex.FindStringSubmatch(first); m != nil {
+				return m[1]
+			}
+			return ""
+		}))
+	}
+	return checks
+}
+
+// checkTool runs bin with args and compares the version parse finds in its
+// output against minVersion.
+func checkTool(ctx context.Context, name, bin string, args []string, minVersion string, parse func(string) string) DependencyCheck {
+	check := DependencyCheck{Name: name, MinVersion: minVersion}
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		check.Error = fmt.Sprintf("%s not found on PATH", bin)
+		return check
+	}
+	check.Path = path
+
+	ctx, cancel := context.WithTimeout(ctx, depCheckTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).Output()
+	if err != nil {
+		check.Error = fmt.Sprintf("%s %s failed: %v", name, strings.Join(args, " "), err)
+		return check
+	}
+	check.Version = parse(string(out))
+	if check.Version != "" && minVersion != "" && compareVersions(check.Version, minVersion) < 0 {
+		check.Error = fmt.Sprintf("%s %s is older than the required %s", name, check.Version, minVersion)
+		return check
+	}
+	check.OK = true
+	return check
+}
+
+// compareVersions compares dotted numeric versions like "6.1.1" or
+// "2024.08.06"; missing parts count as zero.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	var parts []int
+	for _, f := range strings.FieldsFunc(v, func(r rune) bool { return r < '0' || r > '9' }) {
+		n, _ := strconv.Atoi(f)
+		parts = append(parts, n)
+	}
+	return parts
+}
@@ -0,0 +1,183 @@
+// Package media resolves video URLs to sources and downloads their audio or
+// video, with yt-dlp for platform pages and direct fetches for plain media.
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloaderConfig holds the yt-dlp settings shared by every invocation.
+type DownloaderConfig struct {
+	Binary        string // yt-dlp executable (name on PATH or absolute path)
+	Cookies       string // Netscape cookies file passed as --cookies
+	CookiesDir    string // directory per-request cookie files are resolved against
+	Proxy         string // --proxy URL
+	RateLimit     string // --limit-rate, e.g. "2M"
+	SleepRequests string // --sleep-requests seconds between metadata requests
+	AudioTrack    string // per-request audio track: language code or yt-dlp format selector
+	Audio         AudioSettings
+}
+
+// DownloadOptions are the per-request overrides a caller may set.
+type DownloadOptions struct {
+	CookiesFile string `json:"cookies_file,omitempty"`
+	Proxy       string `json:"proxy,omitempty"`
+	RateLimit   string `json:"rate_limit,omitempty"`
+	// AudioTrack picks the audio track on videos with several (dubs, commentary):
+	// a language code like "es" or a yt-dlp format ID/selector like "251".
+	AudioTrack string `json:"audio_track,omitempty"`
+	// AudioSettings override the server's chunk length and audio encoding
+	AudioSettings
+}
+
+// Downloader is the single place yt-dlp commands are built, so binary path,
+// cookies, proxy and rate limits apply to subtitles and audio alike.
+type Downloader struct {
+	mu  sync.RWMutex
+	cfg DownloaderConfig
+}
+
+// NewDownloaderFromEnv reads YTDLP_PATH, YTDLP_COOKIES, YTDLP_COOKIES_DIR,
+// YTDLP_PROXY, YTDLP_RATE_LIMIT and YTDLP_SLEEP_REQUESTS, plus the audio
+// settings (see AudioSettingsFromEnv).
+func NewDownloaderFromEnv() (*Downloader, error) {
+	cfg := downloaderConfigFromEnv()
+	if err := cfg.Audio.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid audio settings: %w", err)
+	}
+	return &Downloader{cfg: cfg}, nil
+}
+
+// ReloadFromEnv re-reads the settings NewDownloaderFromEnv uses. Downloaders
+// already derived with With keep the settings they were made with, so
+// downloads in flight are unaffected. Invalid audio settings leave the
+// current ones in place.
+func (d *Downloader) ReloadFromEnv() error {
+	cfg := downloaderConfigFromEnv()
+	if err := cfg.Audio.Validate(); err != nil {
+		return fmt.Errorf("invalid audio settings: %w", err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cfg.AudioTrack = d.cfg.AudioTrack
+	d.cfg = cfg
+	return nil
+}
+
+// AudioSettings returns the audio encoding and chunk length in effect.
+func (d *Downloader) AudioSettings() AudioSettings {
+	return d.config().Audio
+}
+
+func (d *Downloader) config() DownloaderConfig {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.cfg
+}
+
+func downloaderConfigFromEnv() DownloaderConfig {
+	cfg := DownloaderConfig{
+		Binary:        os.Getenv("YTDLP_PATH"),
+		Cookies:       os.Getenv("YTDLP_COOKIES"),
+		CookiesDir:    os.Getenv("YTDLP_COOKIES_DIR"),
+		Proxy:         os.Getenv("YTDLP_PROXY"),
+		RateLimit:     os.Getenv("YTDLP_RATE_LIMIT"),
+		SleepRequests: os.Getenv("YTDLP_SLEEP_REQUESTS"),
+		Audio:         AudioSettingsFromEnv(),
+	}
+	if cfg.Binary == "" {
+		cfg.Binary = "yt-dlp"
+	}
+	return cfg
+}
+
+// With returns a copy of the downloader with per-request overrides applied.
+// Cookie files must live inside YTDLP_COOKIES_DIR so callers can't point
+// yt-dlp at arbitrary files on the server.
+func (d *Downloader) With(opts DownloadOptions) (*Downloader, error) {
+	cfg := d.config()
+	if opts.Proxy != "" {
+		cfg.Proxy = opts.Proxy
+	}
+	if opts.RateLimit != "" {
+		cfg.RateLimit = opts.RateLimit
+	}
+	if opts.AudioTrack != "" {
+		cfg.AudioTrack = strings.TrimSpace(opts.AudioTrack)
+	}
+	if opts.CookiesFile != "" {
+		if cfg.CookiesDir == "" {
+			return nil, fmt.Errorf("per-request cookies are disabled (YTDLP_COOKIES_DIR not set)")
+		}
+		name := filepath.Base(opts.CookiesFile)
+		if name != opts.CookiesFile || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("invalid cookies file name %q", opts.CookiesFile)
+		}
+		path := filepath.Join(cfg.CookiesDir, name)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("cookies file %q not found", opts.CookiesFile)
+		}
+		cfg.Cookies = path
+	}
+	cfg.Audio = cfg.Audio.Merge(opts.AudioSettings)
+	if err := cfg.Audio.Validate(); err != nil {
+		return nil, err
+	}
+	return &Downloader{cfg: cfg}, nil
+}
+
+// Command builds a yt-dlp command with the configured global flags followed by args.
+func (d *Downloader) Command(args ...string) *exec.Cmd {
+	return d.CommandContext(context.Background(), args...)
+}
+
+// CommandContext is Command killed when ctx is done. Output still held open
+// by yt-dlp's children (ffmpeg) is abandoned shortly after.
+func (d *Downloader) CommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	cfg := d.config()
+	cmd := exec.CommandContext(ctx, cfg.Binary, append(cfg.globalArgs(), args...)...)
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
+
+// languageTagRegex matches BCP-47-ish language codes such as "es" or "pt-BR".
+var languageTagRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// AudioFormat is the -f selector for audio downloads, honoring the requested track.
+func (d *Downloader) AudioFormat() string {
+	track := d.config().AudioTrack
+	switch {
+	case track == "":
+		return "bestaudio"
+	case languageTagRegex.MatchString(track):
+		// prefix match so "en" also picks "en-US"; fall back to the default track
+		return fmt.Sprintf("bestaudio[language^=%s]/bestaudio", track)
+	default:
+		return track
+	}
+}
+
+func (cfg DownloaderConfig) globalArgs() []string {
+	var args []string
+	if cfg.Cookies != "" {
+		args = append(args, "--cookies", cfg.Cookies)
+	}
+	if cfg.Proxy != "" {
+		args = append(args, "--proxy", cfg.Proxy)
+	}
+	if cfg.RateLimit != "" {
+		args = append(args, "--limit-rate", cfg.RateLimit)
+	}
+	if cfg.SleepRequests != "" {
+		args = append(args, "--sleep-requests", cfg.SleepRequests)
+	}
+	return args
+}
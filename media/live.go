@@ -0,0 +1,111 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LiveSegment is a finished stretch of a live stream recorded by TailLive.
+type LiveSegment struct {
+	Index int
+	Path  string
+	// Offset is where the segment starts, in seconds since recording began
+	Offset   float64
+	Duration float64
+	// Started is when the segment's audio was live, by the server's clock
+	Started time.Time
+}
+
+// isManifest reports whether videoURL is an HLS or DASH manifest ffmpeg can
+// read without yt-dlp's help.
+func isManifest(videoURL string) bool {
+	u, err := url.Parse(videoURL)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(u.Path))
+	return ext == ".m3u8" || ext == ".mpd"
+}
+
+// LiveStreamURL is the media URL ffmpeg records a live stream from:
+// manifests as they are, anything else as yt-dlp resolves it.
+func (d *Downloader) LiveStreamURL(ctx context.Context, videoURL string) (string, error) {
+	if isManifest(videoURL) {
+		return videoURL, nil
+	}
+	out, err := d.Output(ctx, "-g", "-f", d.AudioFormat()+"/best", "--no-playlist", "--", videoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the live stream: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("yt-dlp found no stream for %s", videoURL)
+}
+
+// TailLive records the live stream at streamURL into dir, window by window,
+// sending each segment as ffmpeg finishes it. It returns, closing segments,
+// when the stream ends, ffmpeg fails or ctx is done. Segments are the
+// receiver's to remove.
+func TailLive(ctx context.Context, streamURL, dir string, window time.Duration, settings AudioSettings, segments chan<- LiveSegment) error {
+	defer close(segments)
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", streamURL, "-vn"}
+	args = append(args, settings.FFmpegArgs()...)
+	args = append(args,
+		"-f", "segment", "-segment_format", "mp3",
+		"-segment_time", strconv.FormatFloat(window.Seconds(), 'f', 3, 64),
+		"-reset_timestamps", "1",
+		// finished segments are listed on stdout as they are closed
+		"-segment_list", "pipe:1", "-segment_list_type", "flat",
+		"-y", filepath.Join(dir, "live_%05d.mp3"))
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.WaitDelay = 5 * time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	var offset float64
+	lines := bufio.NewScanner(stdout)
+	for i := 0; lines.Scan(); i++ {
+		name := strings.TrimSpace(lines.Text())
+		if name == "" {
+			continue
+		}
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, filepath.Base(name))
+		}
+		duration, err := ProbeDuration(path)
+		if err != nil || duration <= 0 {
+			duration = window.Seconds()
+		}
+		seg := LiveSegment{Index: i, Path: path, Offset: offset, Duration: duration, Started: started.Add(time.Duration(offset * float64(time.Second)))}
+		offset += duration
+		select {
+		case segments <- seg:
+		case <-ctx.Done():
+		}
+	}
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		log.Printf("ffmpeg live recording error: %s", stderr.String())
+		return fmt.Errorf("live recording failed: %w", err)
+	}
+	return nil
+}
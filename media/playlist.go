@@ -0,0 +1,62 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PlaylistEntry is one video enumerated from a playlist or channel.
+type PlaylistEntry struct {
+	URL   string
+	Title string
+}
+
+// ListPlaylist enumerates a playlist or channel without downloading anything.
+func (d *Downloader) ListPlaylist(playlistURL string) ([]PlaylistEntry, error) {
+	out, err := d.Output(context.Background(), "--flat-playlist", "-j", "--", playlistURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playlist: %w", err)
+	}
+
+	var entries []PlaylistEntry
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for sc.Scan() {
+		var e struct {
+			ID    string `json:"id"`
+			URL   string `json:"url"`
+			Title string `json:"title"`
+			IEKey string `json:"ie_key"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		url := e.URL
+		if e.IEKey == "Youtube" && e.ID != "" {
+			url = "https://www.youtube.com/watch?v=" + e.ID
+		}
+		if url == "" {
+			continue
+		}
+		entries = append(entries, PlaylistEntry{URL: url, Title: e.Title})
+	}
+	return entries, sc.Err()
+}
+
+// Duration asks yt-dlp for a video's length in seconds without downloading it.
+func (d *Downloader) Duration(videoURL string) (float64, error) {
+	out, err := d.Output(context.Background(), "--skip-download", "--print", "duration", "--", videoURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read duration: %w", err)
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected duration %q", strings.TrimSpace(string(out)))
+	}
+	return secs, nil
+}
@@ -0,0 +1,116 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"searchme/internal/deadline"
+	"searchme/internal/retry"
+)
+
+// transientYTDLP matches yt-dlp messages for failures worth running again:
+// rate limiting, server errors and network trouble. Anything else, such as
+// an unavailable or private video, fails straight away.
+var transientYTDLP = regexp.MustCompile(`(?i)HTTP Error (429|5\d\d)|too many requests|timed out|connection (reset|refused|aborted)|remote end closed connection|temporary failure in name resolution|incompleteread|unable to download (webpage|api page)`)
+
+// unavailableYTDLP matches yt-dlp messages for videos that can't be had at
+// all: removed, private, region or age locked, members only, not yet
+// streamed, or no video at that URL.
+var unavailableYTDLP = regexp.MustCompile(`(?i)video unavailable|private video|has been removed|been terminated|no longer available|not available in your country|geo.?restricted|sign in to confirm your age|members.only|requested content is not available|unable to download (webpage|api page): HTTP Error 404|unsupported url|is not a valid url|premieres in|live event will begin`)
+
+// ErrVideoUnavailable is wrapped by yt-dlp failures unavailableYTDLP
+// matched. Such failures are never retried.
+var ErrVideoUnavailable = errors.New("video is unavailable")
+
+// ytdlpRetryPolicy reads YTDLP_RETRY_ATTEMPTS (3), YTDLP_RETRY_BASE (2s)
+// and YTDLP_RETRY_MAX (30s).
+func ytdlpRetryPolicy() retry.Policy {
+	return retry.FromEnv("YTDLP", retry.Policy{Attempts: 3, Base: 2 * time.Second, Max: 30 * time.Second})
+}
+
+// Output runs yt-dlp with args and returns its stdout. A run that fails with
+// a transient error (see transientYTDLP) is repeated with backoff per
+// YTDLP_RETRY_*. All runs together are cut off after YTDLP_TIMEOUT (see
+// deadline.Metadata).
+func (d *Downloader) Output(ctx context.Context, args ...string) ([]byte, error) {
+	return d.runStage(ctx, deadline.Metadata, false, args)
+}
+
+// CombinedOutput is Output with stderr interleaved, for callers that log
+// what yt-dlp said.
+func (d *Downloader) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return d.runStage(ctx, deadline.Metadata, true, args)
+}
+
+// download is CombinedOutput for runs that fetch media, cut off after
+// DOWNLOAD_TIMEOUT instead.
+func (d *Downloader) download(ctx context.Context, args ...string) ([]byte, error) {
+	return d.runStage(ctx, deadline.Download, true, args)
+}
+
+func (d *Downloader) runStage(ctx context.Context, stage deadline.Stage, combined bool, args []string) ([]byte, error) {
+	ctx, cancel := stage.Context(ctx)
+	defer cancel()
+	out, err := d.run(ctx, combined, args)
+	return out, deadline.Err(ctx, err)
+}
+
+func (d *Downloader) run(ctx context.Context, combined bool, args []string) ([]byte, error) {
+	var out []byte
+	transient := func(err error) bool {
+		var te transientError
+		return errors.As(err, &te)
+	}
+	err := ytdlpRetryPolicy().Do(ctx, "yt-dlp", transient, func() (err error) {
+		cmd := d.CommandContext(ctx, args...)
+		var diag []byte
+		if combined {
+			out, err = cmd.CombinedOutput()
+			diag = out
+		} else {
+			out, err = cmd.Output()
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				diag = exitErr.Stderr
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		if m := unavailableYTDLP.Find(diag); m != nil {
+			return unavailableError{err: err, msg: string(m)}
+		}
+		if m := transientYTDLP.Find(diag); m != nil {
+			return transientError{err: err, msg: string(m)}
+		}
+		return err
+	})
+	return out, err
+}
+
+// transientError is a yt-dlp failure transientYTDLP matched, with the
+// matching message.
+type transientError struct {
+	err error
+	msg string
+}
+
+func (e transientError) Error() string { return fmt.Sprintf("%v (%s)", e.err, e.msg) }
+func (e transientError) Unwrap() error { return e.err }
+
+// unavailableError is a yt-dlp failure unavailableYTDLP matched, with the
+// matching message.
+type unavailableError struct {
+	err error
+	msg string
+}
+
+func (e unavailableError) Error() string {
+	return fmt.Sprintf("%v: %s (%v)", ErrVideoUnavailable, e.msg, e.err)
+}
+func (e unavailableError) Unwrap() error        { return e.err }
+func (e unavailableError) Is(target error) bool { return target == ErrVideoUnavailable }
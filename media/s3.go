@@ -0,0 +1,36 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// awsCLI is the AWS CLI binary, AWS_CLI_PATH or "aws" on PATH.
+func awsCLI() string {
+	if bin := os.Getenv("AWS_CLI_PATH"); bin != "" {
+		return bin
+	}
+	return "aws"
+}
+
+// UploadS3 copies a local file to an s3:// URI and returns a presigned GET
+// URL for it valid for ttl, so callers without AWS credentials can fetch it.
+func UploadS3(ctx context.Context, local, uri string, ttl time.Duration) (string, error) {
+	cmd := exec.CommandContext(ctx, awsCLI(), "s3", "cp", "--only-show-errors", local, uri)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("aws s3 cp error: %s", string(out))
+		return "", fmt.Errorf("S3 upload failed: %w", err)
+	}
+	out, err := exec.CommandContext(ctx, awsCLI(), "s3", "presign", uri,
+		"--expires-in", strconv.Itoa(int(ttl.Seconds()))).Output()
+	if err != nil {
+		return "", fmt.Errorf("S3 presign failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,445 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"searchme/artifact"
+	"searchme/events"
+	"searchme/internal/deadline"
+	"searchme/internal/env"
+	"searchme/internal/faults"
+	"searchme/internal/flight"
+	"searchme/internal/safehttp"
+	"searchme/internal/workfile"
+)
+
+// AudioFile is a local media file ffmpeg can read.
+// Temp files are ours to delete; user-provided local files are not.
+type AudioFile struct {
+	Path string
+	Temp bool
+	// Settings are the encoding and chunk length to transcribe it with; zero
+	// fields use AudioSettingsFromEnv
+	Settings AudioSettings
+	// CacheDir is set when the file is in the AudioCache: a directory that
+	// lives as long as it, for files derived from it such as its chunks
+	CacheDir string
+	// release lets the AudioCache evict the file again
+	release func()
+}
+
+// Remove deletes the file if it was created for this request, or releases
+// it to the AudioCache it came from.
+func (a *AudioFile) Remove() {
+	if a == nil {
+		return
+	}
+	if a.Temp {
+		_ = os.Remove(a.Path)
+	}
+	if a.release != nil {
+		a.release()
+	}
+}
+
+// VideoSource fetches media for one video from wherever it lives.
+type VideoSource interface {
+	// DownloadAudio makes the audio available as a local file.
+	DownloadAudio(ctx context.Context) (*AudioFile, error)
+	// SupportsSubtitles reports whether platform captions can be fetched for this source.
+	SupportsSubtitles() bool
+}
+
+// SourceDuration is the source's length in seconds where it can be read
+// without downloading the media: yt-dlp's metadata for platform pages,
+// ffprobe for local files. Other sources report 0, unknown.
+func SourceDuration(src VideoSource) (float64, error) {
+	switch s := src.(type) {
+	case *ytdlpSource:
+		return s.dl.Duration(s.url)
+	case *localSource:
+		return ProbeDuration(s.path)
+	}
+	return 0, nil
+}
+
+// VideoDownloader is implemented by sources whose DownloadAudio strips the picture,
+// for callers that need frames (e.g. slide OCR). Other sources already hand back
+// the original media file from DownloadAudio.
+type VideoDownloader interface {
+	DownloadVideo(ctx context.Context) (*AudioFile, error)
+}
+
+// DownloadMedia returns a local file that includes the video stream when the source has one.
+func DownloadMedia(ctx context.Context, src VideoSource) (*AudioFile, error) {
+	if vd, ok := src.(VideoDownloader); ok {
+		return vd.DownloadVideo(ctx)
+	}
+	return src.DownloadAudio(ctx)
+}
+
+// mediaExtensions are direct media files we fetch over plain HTTP instead of via yt-dlp.
+var mediaExtensions = map[string]bool{
+	".mp3": true, ".mp4": true, ".m4a": true, ".wav": true, ".webm": true,
+	".ogg": true, ".oga": true, ".flac": true, ".mkv": true, ".mov": true, ".aac": true,
+}
+
+// ResolveSource picks the VideoSource implementation for a URL:
+// s3:// URIs, file:// or absolute paths, direct media links, and yt-dlp for everything else.
+func ResolveSource(dl *Downloader, videoURL string) (VideoSource, error) {
+	videoURL = strings.TrimSpace(videoURL)
+	audio := dl.AudioSettings()
+	if strings.HasPrefix(videoURL, "/") {
+		return newLocalSource(videoURL, audio)
+	}
+
+	u, err := url.Parse(videoURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid video URL: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "s3":
+		return newS3Source(videoURL, u, audio)
+	case "file":
+		return newLocalSource(u.Path, audio)
+	case "http", "https":
+		if ext := strings.ToLower(path.Ext(u.Path)); mediaExtensions[ext] {
+			return &httpSource{url: videoURL, ext: ext, audio: audio}, nil
+		}
+		return &ytdlpSource{dl: dl, url: videoURL}, nil
+	default:
+		return nil, fmt.Errorf("unsupported video URL scheme %q", u.Scheme)
+	}
+}
+
+// ResolveWebSource is ResolveSource limited to http(s) URLs, for URLs that
+// are handed straight to yt-dlp rather than downloaded through the source.
+func ResolveWebSource(dl *Downloader, videoURL string) (VideoSource, error) {
+	if err := CheckWebURL(videoURL); err != nil {
+		return nil, err
+	}
+	return ResolveSource(dl, videoURL)
+}
+
+// CheckWebURL fails unless rawURL is an absolute http(s) URL.
+func CheckWebURL(rawURL string) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: want an http(s) URL", rawURL)
+	}
+	return nil
+}
+
+// ytdlpSource handles platform pages (YouTube and everything else yt-dlp supports).
+type ytdlpSource struct {
+	dl  *Downloader
+	url string
+}
+
+func (s *ytdlpSource) SupportsSubtitles() bool { return true }
+
+func (s *ytdlpSource) DownloadAudio(ctx context.Context) (*AudioFile, error) {
+	if err := faults.Download(ctx, s.url); err != nil {
+		return nil, err
+	}
+	audio := s.dl.AudioSettings()
+	cache, key := SharedAudioCache(), s.cacheKey(audio)
+	if cached, ok := cache.Get(key, audio); ok {
+		log.Printf("audio for %s served from the cache", s.url)
+		return cached, nil
+	}
+	if cache != nil {
+		// one download per video at a time; the others find it cached
+		_, shared, err := audioDownloads.Do(ctx, key, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, s.fetchShared(ctx, cache, key, audio)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if cached, ok := cache.Get(key, audio); ok {
+			if shared {
+				log.Printf("audio for %s shared with a concurrent download", s.url)
+			}
+			return cached, nil
+		}
+	}
+	file, err := s.fetchAudio(ctx, audio)
+	if err != nil {
+		return nil, err
+	}
+	return cache.Put(key, file), nil
+}
+
+// audioDownloads coalesces concurrent downloads of the same audio.
+var audioDownloads flight.Group[struct{}]
+
+// fetchShared puts the audio in the cache: from the blob store, when there
+// is one and another replica put it there, or downloaded and then shared
+// there in the background.
+func (s *ytdlpSource) fetchShared(ctx context.Context, cache *AudioCache, key string, audio AudioSettings) error {
+	blobs, blobKey := artifact.SharedBlobStore(), "audio/"+audioCacheName(key)+".mp3"
+	if blobs != nil {
+		path := workfile.Path("audio") + ".mp3"
+		found, err := blobs.Get(ctx, blobKey, path)
+		if found {
+			log.Printf("audio for %s fetched from the blob store", s.url)
+			cache.Put(key, &AudioFile{Path: path, Temp: true, Settings: audio}).Remove()
+			return nil
+		}
+		if err != nil {
+			log.Printf("blob store: %v", err)
+		}
+	}
+	file, err := s.fetchAudio(ctx, audio)
+	if err != nil {
+		return err
+	}
+	held := cache.Put(key, file)
+	if blobs == nil {
+		held.Remove()
+		return nil
+	}
+	go func() {
+		defer held.Remove()
+		ctx, cancel := deadline.Download.Context(context.Background())
+		defer cancel()
+		if err := blobs.Put(ctx, blobKey, held.Path); err != nil {
+			log.Printf("blob store: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *ytdlpSource) fetchAudio(ctx context.Context, audio AudioSettings) (*AudioFile, error) {
+	if err := workfile.CheckQuota(); err != nil {
+		return nil, err
+	}
+	base := workfile.Path("audio")
+	out, err := s.dl.download(ctx,
+		"-f", s.dl.AudioFormat(),
+		"--extract-audio",
+		"--audio-format", "mp3",
+		// encode once at the transcription settings so chunking doesn't
+		// upsample a degraded download
+		"--audio-quality", fmt.Sprintf("%dK", audio.BitrateKbps),
+		"--postprocessor-args", fmt.Sprintf("ffmpeg:-ac %d -ar %d", audio.Channels, audio.SampleRate),
+		"-o", base+".%(ext)s",
+		s.url,
+	)
+	if err != nil {
+		log.Printf("yt-dlp audio download error: %s", string(out))
+		workfile.RemoveAll(base)
+		return nil, fmt.Errorf("audio download failed: %w", err)
+	}
+	events.Emit(events.DownloadFinished, s.url, map[string]interface{}{"kind": "audio"})
+	return &AudioFile{Path: base + ".mp3", Temp: true, Settings: audio}, nil
+}
+
+// cacheKey names the audio DownloadAudio fetches: the video, the track and
+// the encoding, but not the chunk length, which only matters later.
+func (s *ytdlpSource) cacheKey(audio AudioSettings) string {
+	video := s.url
+	if id := YouTubeVideoID(video); id != "" {
+		video = id
+	}
+	return fmt.Sprintf("%s\x00%s\x00%d/%d/%d", video, s.dl.AudioFormat(), audio.SampleRate, audio.Channels, audio.BitrateKbps)
+}
+
+// DownloadVideo fetches a small mp4 rendition, enough for reading slides.
+func (s *ytdlpSource) DownloadVideo(ctx context.Context) (*AudioFile, error) {
+	if err := faults.Download(ctx, s.url); err != nil {
+		return nil, err
+	}
+	if err := workfile.CheckQuota(); err != nil {
+		return nil, err
+	}
+	base := workfile.Path("video")
+	out, err := s.dl.download(ctx,
+		"-f", "bestvideo[height<=720][ext=mp4]/best[height<=720]/best",
+		"--merge-output-format", "mp4",
+		"-o", base+".%(ext)s",
+		s.url,
+	)
+	if err != nil {
+		log.Printf("yt-dlp video download error: %s", string(out))
+		workfile.RemoveAll(base)
+		return nil, fmt.Errorf("video download failed: %w", err)
+	}
+	matches, _ := filepath.Glob(base + ".*")
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("video download produced no file")
+	}
+	events.Emit(events.DownloadFinished, s.url, map[string]interface{}{"kind": "video"})
+	return &AudioFile{Path: matches[0], Temp: true}, nil
+}
+
+// httpSource downloads a direct media URL as-is, up to MAX_MEDIA_DOWNLOAD; ffmpeg
+// extracts the audio later.
+type httpSource struct {
+	url   string
+	ext   string
+	audio AudioSettings
+}
+
+func (s *httpSource) SupportsSubtitles() bool { return false }
+
+func (s *httpSource) DownloadAudio(ctx context.Context) (*AudioFile, error) {
+	if err := faults.Download(ctx, s.url); err != nil {
+		return nil, err
+	}
+	ctx, cancel := deadline.Download.Context(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := mediaClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("media download failed: %w", deadline.Err(ctx, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("media download failed: HTTP %d", resp.StatusCode)
+	}
+	limit := maxMediaDownload()
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("media download failed: %d bytes is over the %d byte limit (MAX_MEDIA_DOWNLOAD)", resp.ContentLength, limit)
+	}
+	if err := workfile.CheckQuota(); err != nil {
+		return nil, err
+	}
+
+	dest := workfile.Path("audio_src") + s.ext
+	f, err := os.Create(dest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media file: %w", err)
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, limit+1))
+	if err == nil && n > limit {
+		err = fmt.Errorf("over the %d byte limit (MAX_MEDIA_DOWNLOAD)", limit)
+	}
+	if err != nil {
+		f.Close()
+		_ = os.Remove(dest)
+		return nil, fmt.Errorf("media download failed: %w", deadline.Err(ctx, err))
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(dest)
+		return nil, err
+	}
+	events.Emit(events.DownloadFinished, s.url, map[string]interface{}{"kind": "media"})
+	return &AudioFile{Path: dest, Temp: true, Settings: s.audio}, nil
+}
+
+// mediaClient fetches direct media URLs, which callers choose, so it only
+// connects to public addresses, redirects included.
+var mediaClient = safehttp.Client(0)
+
+// maxMediaDownload caps direct media downloads, MAX_MEDIA_DOWNLOAD (default
+// 2GiB).
+func maxMediaDownload() int64 {
+	return env.Bytes("MAX_MEDIA_DOWNLOAD", 2<<30)
+}
+
+// localSource reads files already on the server. Only paths inside
+// LOCAL_MEDIA_DIR are allowed; the feature is off when it is unset.
+type localSource struct {
+	path  string
+	audio AudioSettings
+}
+
+func newLocalSource(p string, audio AudioSettings) (*localSource, error) {
+	root := os.Getenv("LOCAL_MEDIA_DIR")
+	if root == "" {
+		return nil, fmt.Errorf("local files are disabled (LOCAL_MEDIA_DIR not set)")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	clean := filepath.Clean(p)
+	if rel, err := filepath.Rel(root, clean); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("path %q is outside LOCAL_MEDIA_DIR", p)
+	}
+	if _, err := os.Stat(clean); err != nil {
+		return nil, fmt.Errorf("local media not found: %w", err)
+	}
+	return &localSource{path: clean, audio: audio}, nil
+}
+
+func (s *localSource) SupportsSubtitles() bool { return false }
+
+func (s *localSource) DownloadAudio(ctx context.Context) (*AudioFile, error) {
+	return &AudioFile{Path: s.path, Temp: false, Settings: s.audio}, nil
+}
+
+// s3Source copies an object down with the AWS CLI, which picks up the usual
+// credential chain (env, profile, instance role). AWS_CLI_PATH overrides the binary.
+type s3Source struct {
+	uri   string
+	ext   string
+	audio AudioSettings
+}
+
+// newS3Source allows objects in the buckets, or under the bucket/prefix
+// entries, of S3_MEDIA_BUCKETS, but never the blob store's (see
+// artifact.SharedBlobStore); the feature is off when it is unset.
+func newS3Source(uri string, u *url.URL, audio AudioSettings) (*s3Source, error) {
+	allowed := env.List("S3_MEDIA_BUCKETS")
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("S3 media is disabled (S3_MEDIA_BUCKETS not set)")
+	}
+	key := strings.TrimLeft(u.Path, "/")
+	if u.Host == "" || key == "" || strings.Contains("/"+key+"/", "/../") {
+		return nil, fmt.Errorf("invalid S3 URI %q", uri)
+	}
+	object := u.Host + "/" + key
+	if blobs := os.Getenv("BLOB_STORE_URI"); strings.HasPrefix(blobs, "s3://") && underPrefix(object, strings.TrimPrefix(blobs, "s3://")) {
+		return nil, fmt.Errorf("S3 URI %q is not in an allowed bucket", uri)
+	}
+	for _, prefix := range allowed {
+		if underPrefix(object, strings.TrimPrefix(prefix, "s3://")) {
+			return &s3Source{uri: uri, ext: path.Ext(u.Path), audio: audio}, nil
+		}
+	}
+	return nil, fmt.Errorf("S3 URI %q is not in an allowed bucket", uri)
+}
+
+// underPrefix reports whether the bucket/key object is prefix, a bucket or
+// bucket/path, or lies under it.
+func underPrefix(object, prefix string) bool {
+	prefix = strings.Trim(prefix, "/")
+	return prefix != "" && (object == prefix || strings.HasPrefix(object, prefix+"/"))
+}
+
+func (s *s3Source) SupportsSubtitles() bool { return false }
+
+func (s *s3Source) DownloadAudio(ctx context.Context) (*AudioFile, error) {
+	if err := faults.Download(ctx, s.uri); err != nil {
+		return nil, err
+	}
+	if err := workfile.CheckQuota(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := deadline.Download.Context(ctx)
+	defer cancel()
+	dest := workfile.Path("audio_src") + s.ext
+	cmd := exec.CommandContext(ctx, awsCLI(), "s3", "cp", "--only-show-errors", s.uri, dest)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("aws s3 cp error: %s", string(out))
+		_ = os.Remove(dest)
+		return nil, fmt.Errorf("S3 download failed: %w", deadline.Err(ctx, err))
+	}
+	events.Emit(events.DownloadFinished, s.uri, map[string]interface{}{"kind": "media"})
+	return &AudioFile{Path: dest, Temp: true, Settings: s.audio}, nil
+}
@@ -0,0 +1,5 @@
+// Package videosearchpb holds the Go types and gRPC service of
+// proto/videosearch.proto. Regenerate them after changing the .proto.
+package videosearchpb
+
+//go:generate protoc -I .. --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative videosearch.proto
@@ -0,0 +1,1187 @@
+// The gRPC API served on GRPC_PORT alongside the REST API. Messages carry
+// the fields typed clients need most; SearchResponse.json and Job.result_json
+// hold the full REST JSON for everything else.
+//
+// Authenticate with the same keys as REST, in the x-api-key metadata entry.
+// The caller's own OpenAI key, when the server accepts one, goes in
+// x-openai-key.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        (unknown)
+// source: videosearch.proto
+
+package videosearchpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SearchRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	VideoUrl  string                 `protobuf:"bytes,1,opt,name=video_url,json=videoUrl,proto3" json:"video_url,omitempty"`
+	Keyword   string                 `protobuf:"bytes,2,opt,name=keyword,proto3" json:"keyword,omitempty"`
+	Language  string                 `protobuf:"bytes,3,opt,name=language,proto3" json:"language,omitempty"`
+	Languages []string               `protobuf:"bytes,4,rep,name=languages,proto3" json:"languages,omitempty"`
+	Limit     int32                  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	// substring (default), word or phrase
+	Match         string  `protobuf:"bytes,6,opt,name=match,proto3" json:"match,omitempty"`
+	Stem          bool    `protobuf:"varint,7,opt,name=stem,proto3" json:"stem,omitempty"`
+	BudgetMinutes float64 `protobuf:"fixed64,8,opt,name=budget_minutes,json=budgetMinutes,proto3" json:"budget_minutes,omitempty"`
+	ConfirmCost   bool    `protobuf:"varint,9,opt,name=confirm_cost,json=confirmCost,proto3" json:"confirm_cost,omitempty"`
+	Chapter       string  `protobuf:"bytes,10,opt,name=chapter,proto3" json:"chapter,omitempty"`
+	Translate     bool    `protobuf:"varint,11,opt,name=translate,proto3" json:"translate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_videosearch_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SearchRequest) GetVideoUrl() string {
+	if x != nil {
+		return x.VideoUrl
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetKeyword() string {
+	if x != nil {
+		return x.Keyword
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetMatch() string {
+	if x != nil {
+		return x.Match
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetStem() bool {
+	if x != nil {
+		return x.Stem
+	}
+	return false
+}
+
+func (x *SearchRequest) GetBudgetMinutes() float64 {
+	if x != nil {
+		return x.BudgetMinutes
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetConfirmCost() bool {
+	if x != nil {
+		return x.ConfirmCost
+	}
+	return false
+}
+
+func (x *SearchRequest) GetChapter() string {
+	if x != nil {
+		return x.Chapter
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetTranslate() bool {
+	if x != nil {
+		return x.Translate
+	}
+	return false
+}
+
+type Match struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seconds       float64                `protobuf:"fixed64,1,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	EndSeconds    float64                `protobuf:"fixed64,2,opt,name=end_seconds,json=endSeconds,proto3" json:"end_seconds,omitempty"`
+	Time          string                 `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	Url           string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	Text          string                 `protobuf:"bytes,5,opt,name=text,proto3" json:"text,omitempty"`
+	Score         float64                `protobuf:"fixed64,6,opt,name=score,proto3" json:"score,omitempty"`
+	Language      string                 `protobuf:"bytes,7,opt,name=language,proto3" json:"language,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Match) Reset() {
+	*x = Match{}
+	mi := &file_videosearch_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Match) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Match) ProtoMessage() {}
+
+func (x *Match) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Match.ProtoReflect.Descriptor instead.
+func (*Match) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Match) GetSeconds() float64 {
+	if x != nil {
+		return x.Seconds
+	}
+	return 0
+}
+
+func (x *Match) GetEndSeconds() float64 {
+	if x != nil {
+		return x.EndSeconds
+	}
+	return 0
+}
+
+func (x *Match) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *Match) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Match) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Match) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Match) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+type SearchResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Found      bool                   `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`
+	Seconds    float64                `protobuf:"fixed64,2,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	EndSeconds float64                `protobuf:"fixed64,3,opt,name=end_seconds,json=endSeconds,proto3" json:"end_seconds,omitempty"`
+	Time       string                 `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	Url        string                 `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	Source     string                 `protobuf:"bytes,6,opt,name=source,proto3" json:"source,omitempty"`
+	Confidence string                 `protobuf:"bytes,7,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Language   string                 `protobuf:"bytes,8,opt,name=language,proto3" json:"language,omitempty"`
+	Chapter    string                 `protobuf:"bytes,9,opt,name=chapter,proto3" json:"chapter,omitempty"`
+	Score      float64                `protobuf:"fixed64,10,opt,name=score,proto3" json:"score,omitempty"`
+	Matches    []*Match               `protobuf:"bytes,11,rep,name=matches,proto3" json:"matches,omitempty"`
+	// json is the REST response in full
+	Json          string `protobuf:"bytes,12,opt,name=json,proto3" json:"json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_videosearch_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SearchResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *SearchResponse) GetSeconds() float64 {
+	if x != nil {
+		return x.Seconds
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetEndSeconds() float64 {
+	if x != nil {
+		return x.EndSeconds
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetConfidence() string {
+	if x != nil {
+		return x.Confidence
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetChapter() string {
+	if x != nil {
+		return x.Chapter
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetMatches() []*Match {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetJson() string {
+	if x != nil {
+		return x.Json
+	}
+	return ""
+}
+
+type Progress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChunksDone    int32                  `protobuf:"varint,1,opt,name=chunks_done,json=chunksDone,proto3" json:"chunks_done,omitempty"`
+	ChunksTotal   int32                  `protobuf:"varint,2,opt,name=chunks_total,json=chunksTotal,proto3" json:"chunks_total,omitempty"`
+	AudioDone     float64                `protobuf:"fixed64,3,opt,name=audio_done,json=audioDone,proto3" json:"audio_done,omitempty"`
+	AudioTotal    float64                `protobuf:"fixed64,4,opt,name=audio_total,json=audioTotal,proto3" json:"audio_total,omitempty"`
+	Elapsed       float64                `protobuf:"fixed64,5,opt,name=elapsed,proto3" json:"elapsed,omitempty"`
+	Eta           float64                `protobuf:"fixed64,6,opt,name=eta,proto3" json:"eta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_videosearch_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Progress) GetChunksDone() int32 {
+	if x != nil {
+		return x.ChunksDone
+	}
+	return 0
+}
+
+func (x *Progress) GetChunksTotal() int32 {
+	if x != nil {
+		return x.ChunksTotal
+	}
+	return 0
+}
+
+func (x *Progress) GetAudioDone() float64 {
+	if x != nil {
+		return x.AudioDone
+	}
+	return 0
+}
+
+func (x *Progress) GetAudioTotal() float64 {
+	if x != nil {
+		return x.AudioTotal
+	}
+	return 0
+}
+
+func (x *Progress) GetElapsed() float64 {
+	if x != nil {
+		return x.Elapsed
+	}
+	return 0
+}
+
+func (x *Progress) GetEta() float64 {
+	if x != nil {
+		return x.Eta
+	}
+	return 0
+}
+
+type SearchEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*SearchEvent_Progress
+	//	*SearchEvent_Result
+	Event         isSearchEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchEvent) Reset() {
+	*x = SearchEvent{}
+	mi := &file_videosearch_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchEvent) ProtoMessage() {}
+
+func (x *SearchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchEvent.ProtoReflect.Descriptor instead.
+func (*SearchEvent) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SearchEvent) GetEvent() isSearchEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *SearchEvent) GetProgress() *Progress {
+	if x != nil {
+		if x, ok := x.Event.(*SearchEvent_Progress); ok {
+			return x.Progress
+		}
+	}
+	return nil
+}
+
+func (x *SearchEvent) GetResult() *SearchResponse {
+	if x != nil {
+		if x, ok := x.Event.(*SearchEvent_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isSearchEvent_Event interface {
+	isSearchEvent_Event()
+}
+
+type SearchEvent_Progress struct {
+	Progress *Progress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type SearchEvent_Result struct {
+	Result *SearchResponse `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*SearchEvent_Progress) isSearchEvent_Event() {}
+
+func (*SearchEvent_Result) isSearchEvent_Event() {}
+
+type TranscriptRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// video_id is the index key, e.g. a YouTube video ID
+	VideoId       string `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscriptRequest) Reset() {
+	*x = TranscriptRequest{}
+	mi := &file_videosearch_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscriptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscriptRequest) ProtoMessage() {}
+
+func (x *TranscriptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscriptRequest.ProtoReflect.Descriptor instead.
+func (*TranscriptRequest) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TranscriptRequest) GetVideoId() string {
+	if x != nil {
+		return x.VideoId
+	}
+	return ""
+}
+
+type Segment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         float64                `protobuf:"fixed64,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           float64                `protobuf:"fixed64,2,opt,name=end,proto3" json:"end,omitempty"`
+	Text          string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Segment) Reset() {
+	*x = Segment{}
+	mi := &file_videosearch_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Segment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Segment) ProtoMessage() {}
+
+func (x *Segment) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Segment.ProtoReflect.Descriptor instead.
+func (*Segment) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Segment) GetStart() float64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Segment) GetEnd() float64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Segment) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type Transcript struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VideoId       string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	VideoUrl      string                 `protobuf:"bytes,2,opt,name=video_url,json=videoUrl,proto3" json:"video_url,omitempty"`
+	Language      string                 `protobuf:"bytes,3,opt,name=language,proto3" json:"language,omitempty"`
+	Source        string                 `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	Segments      []*Segment             `protobuf:"bytes,5,rep,name=segments,proto3" json:"segments,omitempty"`
+	Partial       bool                   `protobuf:"varint,6,opt,name=partial,proto3" json:"partial,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transcript) Reset() {
+	*x = Transcript{}
+	mi := &file_videosearch_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transcript) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transcript) ProtoMessage() {}
+
+func (x *Transcript) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transcript.ProtoReflect.Descriptor instead.
+func (*Transcript) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Transcript) GetVideoId() string {
+	if x != nil {
+		return x.VideoId
+	}
+	return ""
+}
+
+func (x *Transcript) GetVideoUrl() string {
+	if x != nil {
+		return x.VideoUrl
+	}
+	return ""
+}
+
+func (x *Transcript) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *Transcript) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Transcript) GetSegments() []*Segment {
+	if x != nil {
+		return x.Segments
+	}
+	return nil
+}
+
+func (x *Transcript) GetPartial() bool {
+	if x != nil {
+		return x.Partial
+	}
+	return false
+}
+
+type JobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRequest) Reset() {
+	*x = JobRequest{}
+	mi := &file_videosearch_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRequest) ProtoMessage() {}
+
+func (x *JobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRequest.ProtoReflect.Descriptor instead.
+func (*JobRequest) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *JobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CancelJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CancelToken   string                 `protobuf:"bytes,2,opt,name=cancel_token,json=cancelToken,proto3" json:"cancel_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelJobRequest) Reset() {
+	*x = CancelJobRequest{}
+	mi := &file_videosearch_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelJobRequest) ProtoMessage() {}
+
+func (x *CancelJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelJobRequest.ProtoReflect.Descriptor instead.
+func (*CancelJobRequest) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CancelJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CancelJobRequest) GetCancelToken() string {
+	if x != nil {
+		return x.CancelToken
+	}
+	return ""
+}
+
+type JobAccepted struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	CancelToken   string                 `protobuf:"bytes,2,opt,name=cancel_token,json=cancelToken,proto3" json:"cancel_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobAccepted) Reset() {
+	*x = JobAccepted{}
+	mi := &file_videosearch_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobAccepted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobAccepted) ProtoMessage() {}
+
+func (x *JobAccepted) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobAccepted.ProtoReflect.Descriptor instead.
+func (*JobAccepted) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *JobAccepted) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *JobAccepted) GetCancelToken() string {
+	if x != nil {
+		return x.CancelToken
+	}
+	return ""
+}
+
+type Job struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Kind  string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	// queued, running, completed, failed or cancelled
+	Status   string  `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Error    string  `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Total    int32   `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	Done     int32   `protobuf:"varint,6,opt,name=done,proto3" json:"done,omitempty"`
+	Failed   int32   `protobuf:"varint,7,opt,name=failed,proto3" json:"failed,omitempty"`
+	Progress float64 `protobuf:"fixed64,8,opt,name=progress,proto3" json:"progress,omitempty"`
+	// result_json is the job's result as the REST API returns it
+	ResultJson string `protobuf:"bytes,9,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	// transcription is the first item's Whisper progress while it runs
+	Transcription *Progress `protobuf:"bytes,10,opt,name=transcription,proto3" json:"transcription,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_videosearch_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_videosearch_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_videosearch_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Job) GetDone() int32 {
+	if x != nil {
+		return x.Done
+	}
+	return 0
+}
+
+func (x *Job) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *Job) GetProgress() float64 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *Job) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+func (x *Job) GetTranscription() *Progress {
+	if x != nil {
+		return x.Transcription
+	}
+	return nil
+}
+
+var File_videosearch_proto protoreflect.FileDescriptor
+
+const file_videosearch_proto_rawDesc = "" +
+	"\n" +
+	"\x11videosearch.proto\x12\vsearchme.v1\"\xc2\x02\n" +
+	"\rSearchRequest\x12\x1b\n" +
+	"\tvideo_url\x18\x01 \x01(\tR\bvideoUrl\x12\x18\n" +
+	"\akeyword\x18\x02 \x01(\tR\akeyword\x12\x1a\n" +
+	"\blanguage\x18\x03 \x01(\tR\blanguage\x12\x1c\n" +
+	"\tlanguages\x18\x04 \x03(\tR\tlanguages\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05match\x18\x06 \x01(\tR\x05match\x12\x12\n" +
+	"\x04stem\x18\a \x01(\bR\x04stem\x12%\n" +
+	"\x0ebudget_minutes\x18\b \x01(\x01R\rbudgetMinutes\x12!\n" +
+	"\fconfirm_cost\x18\t \x01(\bR\vconfirmCost\x12\x18\n" +
+	"\achapter\x18\n" +
+	" \x01(\tR\achapter\x12\x1c\n" +
+	"\ttranslate\x18\v \x01(\bR\ttranslate\"\xae\x01\n" +
+	"\x05Match\x12\x18\n" +
+	"\aseconds\x18\x01 \x01(\x01R\aseconds\x12\x1f\n" +
+	"\vend_seconds\x18\x02 \x01(\x01R\n" +
+	"endSeconds\x12\x12\n" +
+	"\x04time\x18\x03 \x01(\tR\x04time\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\x12\x12\n" +
+	"\x04text\x18\x05 \x01(\tR\x04text\x12\x14\n" +
+	"\x05score\x18\x06 \x01(\x01R\x05score\x12\x1a\n" +
+	"\blanguage\x18\a \x01(\tR\blanguage\"\xcd\x02\n" +
+	"\x0eSearchResponse\x12\x14\n" +
+	"\x05found\x18\x01 \x01(\bR\x05found\x12\x18\n" +
+	"\aseconds\x18\x02 \x01(\x01R\aseconds\x12\x1f\n" +
+	"\vend_seconds\x18\x03 \x01(\x01R\n" +
+	"endSeconds\x12\x12\n" +
+	"\x04time\x18\x04 \x01(\tR\x04time\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\x12\x16\n" +
+	"\x06source\x18\x06 \x01(\tR\x06source\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\a \x01(\tR\n" +
+	"confidence\x12\x1a\n" +
+	"\blanguage\x18\b \x01(\tR\blanguage\x12\x18\n" +
+	"\achapter\x18\t \x01(\tR\achapter\x12\x14\n" +
+	"\x05score\x18\n" +
+	" \x01(\x01R\x05score\x12,\n" +
+	"\amatches\x18\v \x03(\v2\x12.searchme.v1.MatchR\amatches\x12\x12\n" +
+	"\x04json\x18\f \x01(\tR\x04json\"\xba\x01\n" +
+	"\bProgress\x12\x1f\n" +
+	"\vchunks_done\x18\x01 \x01(\x05R\n" +
+	"chunksDone\x12!\n" +
+	"\fchunks_total\x18\x02 \x01(\x05R\vchunksTotal\x12\x1d\n" +
+	"\n" +
+	"audio_done\x18\x03 \x01(\x01R\taudioDone\x12\x1f\n" +
+	"\vaudio_total\x18\x04 \x01(\x01R\n" +
+	"audioTotal\x12\x18\n" +
+	"\aelapsed\x18\x05 \x01(\x01R\aelapsed\x12\x10\n" +
+	"\x03eta\x18\x06 \x01(\x01R\x03eta\"\x82\x01\n" +
+	"\vSearchEvent\x123\n" +
+	"\bprogress\x18\x01 \x01(\v2\x15.searchme.v1.ProgressH\x00R\bprogress\x125\n" +
+	"\x06result\x18\x02 \x01(\v2\x1b.searchme.v1.SearchResponseH\x00R\x06resultB\a\n" +
+	"\x05event\".\n" +
+	"\x11TranscriptRequest\x12\x19\n" +
+	"\bvideo_id\x18\x01 \x01(\tR\avideoId\"E\n" +
+	"\aSegment\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x01R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x01R\x03end\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\"\xc4\x01\n" +
+	"\n" +
+	"Transcript\x12\x19\n" +
+	"\bvideo_id\x18\x01 \x01(\tR\avideoId\x12\x1b\n" +
+	"\tvideo_url\x18\x02 \x01(\tR\bvideoUrl\x12\x1a\n" +
+	"\blanguage\x18\x03 \x01(\tR\blanguage\x12\x16\n" +
+	"\x06source\x18\x04 \x01(\tR\x06source\x120\n" +
+	"\bsegments\x18\x05 \x03(\v2\x14.searchme.v1.SegmentR\bsegments\x12\x18\n" +
+	"\apartial\x18\x06 \x01(\bR\apartial\"\x1c\n" +
+	"\n" +
+	"JobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"E\n" +
+	"\x10CancelJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12!\n" +
+	"\fcancel_token\x18\x02 \x01(\tR\vcancelToken\"G\n" +
+	"\vJobAccepted\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12!\n" +
+	"\fcancel_token\x18\x02 \x01(\tR\vcancelToken\"\x93\x02\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x14\n" +
+	"\x05total\x18\x05 \x01(\x05R\x05total\x12\x12\n" +
+	"\x04done\x18\x06 \x01(\x05R\x04done\x12\x16\n" +
+	"\x06failed\x18\a \x01(\x05R\x06failed\x12\x1a\n" +
+	"\bprogress\x18\b \x01(\x01R\bprogress\x12\x1f\n" +
+	"\vresult_json\x18\t \x01(\tR\n" +
+	"resultJson\x12;\n" +
+	"\rtranscription\x18\n" +
+	" \x01(\v2\x15.searchme.v1.ProgressR\rtranscription2\xd6\x03\n" +
+	"\vVideoSearch\x12A\n" +
+	"\x06Search\x12\x1a.searchme.v1.SearchRequest\x1a\x1b.searchme.v1.SearchResponse\x12F\n" +
+	"\fSearchStream\x12\x1a.searchme.v1.SearchRequest\x1a\x18.searchme.v1.SearchEvent0\x01\x12H\n" +
+	"\rGetTranscript\x12\x1e.searchme.v1.TranscriptRequest\x1a\x17.searchme.v1.Transcript\x12F\n" +
+	"\x0eStartSearchJob\x12\x1a.searchme.v1.SearchRequest\x1a\x18.searchme.v1.JobAccepted\x123\n" +
+	"\x06GetJob\x12\x17.searchme.v1.JobRequest\x1a\x10.searchme.v1.Job\x127\n" +
+	"\bWatchJob\x12\x17.searchme.v1.JobRequest\x1a\x10.searchme.v1.Job0\x01\x12<\n" +
+	"\tCancelJob\x12\x1d.searchme.v1.CancelJobRequest\x1a\x10.searchme.v1.JobB\x1eZ\x1csearchme/proto/videosearchpbb\x06proto3"
+
+var (
+	file_videosearch_proto_rawDescOnce sync.Once
+	file_videosearch_proto_rawDescData []byte
+)
+
+func file_videosearch_proto_rawDescGZIP() []byte {
+	file_videosearch_proto_rawDescOnce.Do(func() {
+		file_videosearch_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_videosearch_proto_rawDesc), len(file_videosearch_proto_rawDesc)))
+	})
+	return file_videosearch_proto_rawDescData
+}
+
+var file_videosearch_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_videosearch_proto_goTypes = []any{
+	(*SearchRequest)(nil),     // 0: searchme.v1.SearchRequest
+	(*Match)(nil),             // 1: searchme.v1.Match
+	(*SearchResponse)(nil),    // 2: searchme.v1.SearchResponse
+	(*Progress)(nil),          // 3: searchme.v1.Progress
+	(*SearchEvent)(nil),       // 4: searchme.v1.SearchEvent
+	(*TranscriptRequest)(nil), // 5: searchme.v1.TranscriptRequest
+	(*Segment)(nil),           // 6: searchme.v1.Segment
+	(*Transcript)(nil),        // 7: searchme.v1.Transcript
+	(*JobRequest)(nil),        // 8: searchme.v1.JobRequest
+	(*CancelJobRequest)(nil),  // 9: searchme.v1.CancelJobRequest
+	(*JobAccepted)(nil),       // 10: searchme.v1.JobAccepted
+	(*Job)(nil),               // 11: searchme.v1.Job
+}
+var file_videosearch_proto_depIdxs = []int32{
+	1,  // 0: searchme.v1.SearchResponse.matches:type_name -> searchme.v1.Match
+	3,  // 1: searchme.v1.SearchEvent.progress:type_name -> searchme.v1.Progress
+	2,  // 2: searchme.v1.SearchEvent.result:type_name -> searchme.v1.SearchResponse
+	6,  // 3: searchme.v1.Transcript.segments:type_name -> searchme.v1.Segment
+	3,  // 4: searchme.v1.Job.transcription:type_name -> searchme.v1.Progress
+	0,  // 5: searchme.v1.VideoSearch.Search:input_type -> searchme.v1.SearchRequest
+	0,  // 6: searchme.v1.VideoSearch.SearchStream:input_type -> searchme.v1.SearchRequest
+	5,  // 7: searchme.v1.VideoSearch.GetTranscript:input_type -> searchme.v1.TranscriptRequest
+	0,  // 8: searchme.v1.VideoSearch.StartSearchJob:input_type -> searchme.v1.SearchRequest
+	8,  // 9: searchme.v1.VideoSearch.GetJob:input_type -> searchme.v1.JobRequest
+	8,  // 10: searchme.v1.VideoSearch.WatchJob:input_type -> searchme.v1.JobRequest
+	9,  // 11: searchme.v1.VideoSearch.CancelJob:input_type -> searchme.v1.CancelJobRequest
+	2,  // 12: searchme.v1.VideoSearch.Search:output_type -> searchme.v1.SearchResponse
+	4,  // 13: searchme.v1.VideoSearch.SearchStream:output_type -> searchme.v1.SearchEvent
+	7,  // 14: searchme.v1.VideoSearch.GetTranscript:output_type -> searchme.v1.Transcript
+	10, // 15: searchme.v1.VideoSearch.StartSearchJob:output_type -> searchme.v1.JobAccepted
+	11, // 16: searchme.v1.VideoSearch.GetJob:output_type -> searchme.v1.Job
+	11, // 17: searchme.v1.VideoSearch.WatchJob:output_type -> searchme.v1.Job
+	11, // 18: searchme.v1.VideoSearch.CancelJob:output_type -> searchme.v1.Job
+	12, // [12:19] is the sub-list for method output_type
+	5,  // [5:12] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_videosearch_proto_init() }
+func file_videosearch_proto_init() {
+	if File_videosearch_proto != nil {
+		return
+	}
+	file_videosearch_proto_msgTypes[4].OneofWrappers = []any{
+		(*SearchEvent_Progress)(nil),
+		(*SearchEvent_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_videosearch_proto_rawDesc), len(file_videosearch_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_videosearch_proto_goTypes,
+		DependencyIndexes: file_videosearch_proto_depIdxs,
+		MessageInfos:      file_videosearch_proto_msgTypes,
+	}.Build()
+	File_videosearch_proto = out.File
+	file_videosearch_proto_goTypes = nil
+	file_videosearch_proto_depIdxs = nil
+}
@@ -0,0 +1,380 @@
+// The gRPC API served on GRPC_PORT alongside the REST API. Messages carry
+// the fields typed clients need most; SearchResponse.json and Job.result_json
+// hold the full REST JSON for everything else.
+//
+// Authenticate with the same keys as REST, in the x-api-key metadata entry.
+// The caller's own OpenAI key, when the server accepts one, goes in
+// x-openai-key.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: videosearch.proto
+
+package videosearchpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	VideoSearch_Search_FullMethodName         = "/searchme.v1.VideoSearch/Search"
+	VideoSearch_SearchStream_FullMethodName   = "/searchme.v1.VideoSearch/SearchStream"
+	VideoSearch_GetTranscript_FullMethodName  = "/searchme.v1.VideoSearch/GetTranscript"
+	VideoSearch_StartSearchJob_FullMethodName = "/searchme.v1.VideoSearch/StartSearchJob"
+	VideoSearch_GetJob_FullMethodName         = "/searchme.v1.VideoSearch/GetJob"
+	VideoSearch_WatchJob_FullMethodName       = "/searchme.v1.VideoSearch/WatchJob"
+	VideoSearch_CancelJob_FullMethodName      = "/searchme.v1.VideoSearch/CancelJob"
+)
+
+// VideoSearchClient is the client API for VideoSearch service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VideoSearchClient interface {
+	// Search finds the keyword in the video like POST /api/search.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// SearchStream runs the same search, streaming transcription progress
+	// while Whisper runs and then the result.
+	SearchStream(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SearchEvent], error)
+	// GetTranscript returns an indexed transcript like GET /api/transcripts/:id.
+	GetTranscript(ctx context.Context, in *TranscriptRequest, opts ...grpc.CallOption) (*Transcript, error)
+	// StartSearchJob queues the search as a background job.
+	StartSearchJob(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*JobAccepted, error)
+	// GetJob reports a job like GET /api/jobs/:id.
+	GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	// WatchJob streams the job each time it changes until it finishes.
+	WatchJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error)
+	// CancelJob stops a job like POST /api/jobs/:id/cancel.
+	CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type videoSearchClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVideoSearchClient(cc grpc.ClientConnInterface) VideoSearchClient {
+	return &videoSearchClient{cc}
+}
+
+func (c *videoSearchClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, VideoSearch_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoSearchClient) SearchStream(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SearchEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VideoSearch_ServiceDesc.Streams[0], VideoSearch_SearchStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SearchRequest, SearchEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoSearch_SearchStreamClient = grpc.ServerStreamingClient[SearchEvent]
+
+func (c *videoSearchClient) GetTranscript(ctx context.Context, in *TranscriptRequest, opts ...grpc.CallOption) (*Transcript, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transcript)
+	err := c.cc.Invoke(ctx, VideoSearch_GetTranscript_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoSearchClient) StartSearchJob(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*JobAccepted, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobAccepted)
+	err := c.cc.Invoke(ctx, VideoSearch_StartSearchJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoSearchClient) GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, VideoSearch_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoSearchClient) WatchJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VideoSearch_ServiceDesc.Streams[1], VideoSearch_WatchJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[JobRequest, Job]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoSearch_WatchJobClient = grpc.ServerStreamingClient[Job]
+
+func (c *videoSearchClient) CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, VideoSearch_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VideoSearchServer is the server API for VideoSearch service.
+// All implementations must embed UnimplementedVideoSearchServer
+// for forward compatibility.
+type VideoSearchServer interface {
+	// Search finds the keyword in the video like POST /api/search.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// SearchStream runs the same search, streaming transcription progress
+	// while Whisper runs and then the result.
+	SearchStream(*SearchRequest, grpc.ServerStreamingServer[SearchEvent]) error
+	// GetTranscript returns an indexed transcript like GET /api/transcripts/:id.
+	GetTranscript(context.Context, *TranscriptRequest) (*Transcript, error)
+	// StartSearchJob queues the search as a background job.
+	StartSearchJob(context.Context, *SearchRequest) (*JobAccepted, error)
+	// GetJob reports a job like GET /api/jobs/:id.
+	GetJob(context.Context, *JobRequest) (*Job, error)
+	// WatchJob streams the job each time it changes until it finishes.
+	WatchJob(*JobRequest, grpc.ServerStreamingServer[Job]) error
+	// CancelJob stops a job like POST /api/jobs/:id/cancel.
+	CancelJob(context.Context, *CancelJobRequest) (*Job, error)
+	mustEmbedUnimplementedVideoSearchServer()
+}
+
+// UnimplementedVideoSearchServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVideoSearchServer struct{}
+
+func (UnimplementedVideoSearchServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedVideoSearchServer) SearchStream(*SearchRequest, grpc.ServerStreamingServer[SearchEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method SearchStream not implemented")
+}
+func (UnimplementedVideoSearchServer) GetTranscript(context.Context, *TranscriptRequest) (*Transcript, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTranscript not implemented")
+}
+func (UnimplementedVideoSearchServer) StartSearchJob(context.Context, *SearchRequest) (*JobAccepted, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartSearchJob not implemented")
+}
+func (UnimplementedVideoSearchServer) GetJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedVideoSearchServer) WatchJob(*JobRequest, grpc.ServerStreamingServer[Job]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchJob not implemented")
+}
+func (UnimplementedVideoSearchServer) CancelJob(context.Context, *CancelJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedVideoSearchServer) mustEmbedUnimplementedVideoSearchServer() {}
+func (UnimplementedVideoSearchServer) testEmbeddedByValue()                     {}
+
+// UnsafeVideoSearchServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VideoSearchServer will
+// result in compilation errors.
+type UnsafeVideoSearchServer interface {
+	mustEmbedUnimplementedVideoSearchServer()
+}
+
+func RegisterVideoSearchServer(s grpc.ServiceRegistrar, srv VideoSearchServer) {
+	// If the following call pancis, it indicates UnimplementedVideoSearchServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&VideoSearch_ServiceDesc, srv)
+}
+
+func _VideoSearch_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoSearchServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoSearch_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoSearchServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoSearch_SearchStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VideoSearchServer).SearchStream(m, &grpc.GenericServerStream[SearchRequest, SearchEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoSearch_SearchStreamServer = grpc.ServerStreamingServer[SearchEvent]
+
+func _VideoSearch_GetTranscript_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranscriptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoSearchServer).GetTranscript(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoSearch_GetTranscript_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoSearchServer).GetTranscript(ctx, req.(*TranscriptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoSearch_StartSearchJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoSearchServer).StartSearchJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoSearch_StartSearchJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoSearchServer).StartSearchJob(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoSearch_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoSearchServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoSearch_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoSearchServer).GetJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoSearch_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(JobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VideoSearchServer).WatchJob(m, &grpc.GenericServerStream[JobRequest, Job]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoSearch_WatchJobServer = grpc.ServerStreamingServer[Job]
+
+func _VideoSearch_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoSearchServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoSearch_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoSearchServer).CancelJob(ctx, req.(*CancelJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// VideoSearch_ServiceDesc is the grpc.ServiceDesc for VideoSearch service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VideoSearch_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "searchme.v1.VideoSearch",
+	HandlerType: (*VideoSearchServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _VideoSearch_Search_Handler,
+		},
+		{
+			MethodName: "GetTranscript",
+			Handler:    _VideoSearch_GetTranscript_Handler,
+		},
+		{
+			MethodName: "StartSearchJob",
+			Handler:    _VideoSearch_StartSearchJob_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _VideoSearch_GetJob_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _VideoSearch_CancelJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SearchStream",
+			Handler:       _VideoSearch_SearchStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchJob",
+			Handler:       _VideoSearch_WatchJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "videosearch.proto",
+}
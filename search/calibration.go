@@ -0,0 +1,143 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"searchme/internal/env"
+)
+
+// CalibrationConfig tunes how much feedback it takes to move scores.
+type CalibrationConfig struct {
+	// Prior is how many verdicts the raw score is worth; with fewer real
+	// verdicts than this the raw score still dominates
+	Prior float64
+	// MinSamples is the feedback needed on partial-word matches before
+	// MinPartialPrecision is enforced
+	MinSamples int
+	// MinPartialPrecision switches substring searches to whole-word matching
+	// once partial-word matches ("cat" in "category") are confirmed less often
+	// than this
+	MinPartialPrecision float64
+}
+
+// CalibrationStats is the feedback collected on one kind of match.
+type CalibrationStats struct {
+	Source    string  `json:"source"`
+	Partial   bool    `json:"partial_word"`
+	Confirmed int     `json:"confirmed"`
+	Rejected  int     `json:"rejected"`
+	Precision float64 `json:"precision"`
+}
+
+type calibrationKey struct {
+	source  string
+	partial bool
+}
+
+// Calibrator turns raw match confidence into the precision users actually
+// observed for matches of the same source and kind. It is safe for concurrent use.
+type Calibrator struct {
+	config CalibrationConfig
+
+	mu      sync.Mutex
+	buckets map[calibrationKey]*CalibrationStats
+}
+
+// NewCalibrator returns a calibrator with no feedback yet.
+func NewCalibrator(cfg CalibrationConfig) *Calibrator {
+	return &Calibrator{config: cfg, buckets: map[calibrationKey]*CalibrationStats{}}
+}
+
+// NewCalibratorFromEnv reads CALIBRATION_PRIOR (default 10),
+// CALIBRATION_MIN_SAMPLES (default 20) and CALIBRATION_MIN_PARTIAL_PRECISION
+// (default 0.5).
+func NewCalibratorFromEnv() *Calibrator {
+	return NewCalibrator(CalibrationConfig{
+		Prior:               env.Float("CALIBRATION_PRIOR", 10),
+		MinSamples:          env.Int("CALIBRATION_MIN_SAMPLES", 20),
+		MinPartialPrecision: env.Float("CALIBRATION_MIN_PARTIAL_PRECISION", 0.5),
+	})
+}
+
+// Record adds verdicts on matches of one source and kind.
+func (c *Calibrator) Record(source string, partial, correct bool, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := calibrationKey{source, partial}
+	st := c.buckets[k]
+	if st == nil {
+		st = &CalibrationStats{Source: source, Partial: partial}
+		c.buckets[k] = st
+	}
+	if correct {
+		st.Confirmed += n
+	} else {
+		st.Rejected += n
+	}
+	st.Precision = float64(st.Confirmed) / float64(st.Confirmed+st.Rejected)
+}
+
+// Calibrate sets resp.Score, the estimated probability that the match is
+// right: the raw confidence blended with the feedback on similar matches.
+func (c *Calibrator) Calibrate(resp *Response) {
+	if !resp.Found {
+		resp.Score = 0
+		return
+	}
+	raw := 1.0
+	if resp.Quality != nil {
+		raw = resp.Quality.Score
+	} else if resp.Confidence == ConfidenceEstimated {
+		raw = TranscriptQuality(nil, SourceEstimate).Score
+	}
+	if resp.Partial {
+		raw *= partialWordWeight
+	}
+
+	c.mu.Lock()
+	var confirmed, total float64
+	if st := c.buckets[calibrationKey{resp.Source, resp.Partial}]; st != nil {
+		confirmed, total = float64(st.Confirmed), float64(st.Confirmed+st.Rejected)
+	}
+	c.mu.Unlock()
+
+	score := (confirmed + raw*c.config.Prior) / (total + c.config.Prior)
+	resp.Score = math.Round(score*100) / 100
+}
+
+// StrictWords reports whether feedback has shown partial-word matches to be
+// wrong too often, so substring searches should only match whole words.
+func (c *Calibrator) StrictWords() bool {
+	if c.config.MinPartialPrecision <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var confirmed, total int
+	for k, st := range c.buckets {
+		if k.partial {
+			confirmed += st.Confirmed
+			total += st.Confirmed + st.Rejected
+		}
+	}
+	return total >= c.config.MinSamples && float64(confirmed)/float64(total) < c.config.MinPartialPrecision
+}
+
+// Stats returns the feedback collected so far, by source.
+func (c *Calibrator) Stats() []CalibrationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]CalibrationStats, 0, len(c.buckets))
+	for _, st := range c.buckets {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Source != out[j].Source {
+			return out[i].Source < out[j].Source
+		}
+		return !out[i].Partial && out[j].Partial
+	})
+	return out
+}
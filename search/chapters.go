@@ -0,0 +1,59 @@
+package search
+
+import (
+	"strings"
+
+	"searchme/langpack"
+	"searchme/media"
+	"searchme/subtitle"
+)
+
+// ChapterAt returns the chapter containing a timestamp.
+func ChapterAt(chapters []media.Chapter, seconds float64) (media.Chapter, bool) {
+	for _, ch := range chapters {
+		if seconds >= ch.Start && seconds < ch.End {
+			return ch, true
+		}
+	}
+	return media.Chapter{}, false
+}
+
+// FindChapter looks a chapter up by title: an exact match ignoring case and
+// diacritics wins, otherwise the first title containing name.
+func FindChapter(chapters []media.Chapter, name string) (media.Chapter, bool) {
+	p := langpack.For("")
+	want := strings.TrimSpace(langpack.NormalizeText(p, name))
+	if want == "" {
+		return media.Chapter{}, false
+	}
+	for _, ch := range chapters {
+		if strings.TrimSpace(langpack.NormalizeText(p, ch.Title)) == want {
+			return ch, true
+		}
+	}
+	for _, ch := range chapters {
+		if strings.Contains(langpack.NormalizeText(p, ch.Title), want) {
+			return ch, true
+		}
+	}
+	return media.Chapter{}, false
+}
+
+// InChapter keeps the segments that start inside a chapter.
+func InChapter(subs []subtitle.Entry, ch media.Chapter) []subtitle.Entry {
+	return InWindow(subs, &TimeRange{Start: ch.Start, End: ch.End})
+}
+
+// LabelChapters sets the chapter title on a response and its ranked matches.
+func LabelChapters(resp *Response, chapters []media.Chapter) {
+	if resp.Found {
+		if ch, ok := ChapterAt(chapters, resp.Seconds); ok {
+			resp.Chapter = ch.Title
+		}
+	}
+	for i := range resp.Matches {
+		if ch, ok := ChapterAt(chapters, resp.Matches[i].Seconds); ok {
+			resp.Matches[i].Chapter = ch.Title
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package search
+
+import (
+	"container/list"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// ChunkCache keeps transcribed audio chunks in memory, least recently used
+// out first, so a chunk transcribed once, by a search or a prefetch, isn't
+// sent to Whisper again. It is safe for concurrent use.
+type ChunkCache struct {
+	mu    sync.Mutex
+	max   int
+	lru   *list.List // of *cachedChunk, most recent first
+	items map[chunkKey]*list.Element
+}
+
+type chunkKey struct {
+	video string
+	// chunk position in milliseconds; chunk length is part of the key since
+	// requests may chunk differently
+	offset, duration int64
+}
+
+type cachedChunk struct {
+	key     chunkKey
+	entries []subtitle.Entry
+}
+
+// NewChunkCache holds up to max chunks.
+func NewChunkCache(max int) *ChunkCache {
+	return &ChunkCache{max: max, lru: list.New(), items: map[chunkKey]*list.Element{}}
+}
+
+// chunkCacheKey identifies the audio a request transcribes and how: the
+// video, on videos with several, the audio track, and any Whisper options.
+func chunkCacheKey(req Request) string {
+	video := req.VideoURL
+	if id := media.YouTubeVideoID(video); id != "" {
+		video = id
+	}
+	return video + "\x00" + req.AudioTrack + "\x00" + req.WhisperOptions.CacheKey()
+}
+
+func keyFor(video string, c transcribe.Chunk) chunkKey {
+	return chunkKey{video, int64(math.Round(c.Offset * 1000)), int64(math.Round(c.Duration * 1000))}
+}
+
+// Get returns the chunk's entries if it was transcribed before.
+func (c *ChunkCache) Get(video string, chunk transcribe.Chunk) ([]subtitle.Entry, bool) {
+	if c == nil || video == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[keyFor(video, chunk)]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cachedChunk).entries, true
+}
+
+// Put stores a transcribed chunk.
+func (c *ChunkCache) Put(video string, chunk transcribe.Chunk, entries []subtitle.Entry) {
+	if c == nil || video == "" || c.max <= 0 {
+		return
+	}
+	k := keyFor(video, chunk)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[k]; ok {
+		el.Value.(*cachedChunk).entries = entries
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[k] = c.lru.PushFront(&cachedChunk{key: k, entries: entries})
+	for c.lru.Len() > c.max {
+		old := c.lru.Remove(c.lru.Back()).(*cachedChunk)
+		delete(c.items, old.key)
+	}
+}
+
+// Video returns the chunks cached for a video, in time order.
+func (c *ChunkCache) Video(video string) []transcribe.Chunk {
+	if c == nil || video == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var chunks []transcribe.Chunk
+	for k := range c.items {
+		if k.video == video {
+			chunks = append(chunks, transcribe.Chunk{Offset: float64(k.offset) / 1000, Duration: float64(k.duration) / 1000})
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Offset < chunks[j].Offset })
+	return chunks
+}
+
+// Len is how many chunks are cached.
+func (c *ChunkCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// CachedChunks sums up the chunks cached for one video.
+type CachedChunks struct {
+	// Video is the YouTube ID or URL of the video
+	Video  string `json:"video"`
+	Chunks int    `json:"chunks"`
+	// Seconds is how much audio they cover and Bytes roughly the memory
+	// their text takes
+	Seconds float64 `json:"seconds"`
+	Bytes   int64   `json:"size_bytes"`
+}
+
+// Videos sums up the cache by video, audio tracks together.
+func (c *ChunkCache) Videos() []CachedChunks {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byVideo := map[string]*CachedChunks{}
+	for k, el := range c.items {
+		video := cachedChunkVideo(k.video)
+		v, ok := byVideo[video]
+		if !ok {
+			v = &CachedChunks{Video: video}
+			byVideo[video] = v
+		}
+		v.Chunks++
+		v.Seconds += float64(k.duration) / 1000
+		for _, e := range el.Value.(*cachedChunk).entries {
+			v.Bytes += int64(len(e.Text))
+		}
+	}
+	out := make([]CachedChunks, 0, len(byVideo))
+	for _, v := range byVideo {
+		out = append(out, *v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Video < out[j].Video })
+	return out
+}
+
+// Purge drops the chunks of the videos match accepts and returns how many
+// there were.
+func (c *ChunkCache) Purge(match func(video string) bool) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k, el := range c.items {
+		if match(cachedChunkVideo(k.video)) {
+			c.lru.Remove(el)
+			delete(c.items, k)
+			n++
+		}
+	}
+	return n
+}
+
+// cachedChunkVideo is the video part of a chunkCacheKey.
+func cachedChunkVideo(key string) string {
+	video, _, _ := strings.Cut(key, "\x00")
+	return video
+}
+
+// cachedMatch looks for the keyword in the chunks cached for video without
+// downloading anything. In sequential order a match only counts when the
+// cached chunks run unbroken from the start of the video to it, so it is
+// the earliest; in priority order any cached match will do.
+func (c *ChunkCache) cachedMatch(video string, matcher *Matcher, searcher Searcher, anyOrder bool) (subtitle.Entry, bool) {
+	var end float64
+	for _, chunk := range c.Video(video) {
+		if !anyOrder && chunk.Offset > end+0.5 {
+			return subtitle.Entry{}, false
+		}
+		entries, ok := c.Get(video, chunk)
+		if !ok {
+			return subtitle.Entry{}, false
+		}
+		if sub, found := searcher.Find(entries, matcher); found {
+			return sub, true
+		}
+		end = math.Max(end, chunk.Offset+chunk.Duration)
+	}
+	return subtitle.Entry{}, false
+}
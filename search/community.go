@@ -0,0 +1,59 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CommunityHint is a moment viewers pointed at in a comment whose text
+// matches the keyword. It is a lead, not a transcript match.
+type CommunityHint struct {
+	Time    string  `json:"time"`
+	Seconds float64 `json:"seconds"`
+	URL     string  `json:"url,omitempty"`
+	// Text is what the comment says about the moment
+	Text  string `json:"text"`
+	Likes int    `json:"likes"`
+}
+
+// CommentTimestamp is one timestamp in a comment with the words that go
+// with it.
+type CommentTimestamp struct {
+	Seconds float64
+	Label   string
+}
+
+// commentTimestampRegex matches "1:02:03" or "12:34" not inside a longer
+// run of digits and colons.
+var commentTimestampRegex = regexp.MustCompile(`(?:^|[^\d:])((?:\d{1,2}:)?\d{1,2}:[0-5]\d)(?:$|[^\d:])`)
+
+// CommentTimestamps finds the timestamps in a comment, line by line. A lone
+// timestamp is labelled with the rest of its line ("the drop at 12:34 is
+// insane"); several on one line ("0:00 intro / 2:15 setup") each get the
+// text up to the next.
+func CommentTimestamps(text string) []CommentTimestamp {
+	var out []CommentTimestamp
+	for _, line := range strings.Split(text, "\n") {
+		locs := commentTimestampRegex.FindAllStringSubmatchIndex(line, -1)
+		for i, loc := range locs {
+			seconds, ok := parseClock(line[loc[2]:loc[3]])
+			if !ok {
+				continue
+			}
+			end := len(line)
+			if i+1 < len(locs) {
+				end = locs[i+1][2]
+			}
+			label := trimLabel(line[loc[3]:end])
+			if len(locs) == 1 {
+				label = strings.TrimSpace(trimLabel(line[:loc[2]]) + " " + label)
+			}
+			out = append(out, CommentTimestamp{Seconds: seconds, Label: label})
+		}
+	}
+	return out
+}
+
+func trimLabel(s string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(s), "-–—:|/•,()[]"))
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"sort"
+
+	"searchme/transcribe"
+)
+
+// TimeRange is a span of the video in seconds.
+type TimeRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Coverage reports which parts of the audio a transcription search actually
+// checked, so "not found" can be told apart from "not looked at".
+type Coverage struct {
+	// Duration is the length of the audio, as far as it is known
+	Duration float64     `json:"duration"`
+	Scanned  []TimeRange `json:"scanned"`
+	// Skipped are ranges left out to stay within the budget
+	Skipped []TimeRange `json:"skipped,omitempty"`
+	// Failed are ranges whose chunks could not be transcribed
+	Failed []TimeRange `json:"failed,omitempty"`
+	// Fraction is the share of Duration that was scanned
+	Fraction float64 `json:"fraction"`
+	// Complete is set when the whole audio was scanned
+	Complete bool `json:"complete"`
+}
+
+// Chunk outcomes for chunkCoverage
+const (
+	chunkScanned = iota
+	chunkFailed
+	chunkSkipped
+)
+
+// chunkCoverage builds the coverage of chunks, whose outcomes are indexed
+// alongside them; chunks may be in any order. Audio between chunks is
+// silence that was never sent (see transcribe.VADConfig) and counts as
+// scanned; audio before the first, e.g. outside a search window, doesn't.
+func chunkCoverage(chunks []transcribe.Chunk, outcomes []int) *Coverage {
+	var scanned, failed, skipped []TimeRange
+	var total, missed float64
+	first := -1.0
+	for i, c := range chunks {
+		r := TimeRange{Start: c.Offset, End: c.Offset + c.Duration}
+		total = max(total, r.End)
+		if first < 0 || r.Start < first {
+			first = r.Start
+		}
+		switch outcomes[i] {
+		case chunkScanned:
+			scanned = append(scanned, r)
+		case chunkFailed:
+			failed = append(failed, r)
+			missed += c.Duration
+		default:
+			skipped = append(skipped, r)
+			missed += c.Duration
+		}
+	}
+	cov := &Coverage{
+		Duration: total,
+		Scanned:  mergeRanges(scanned),
+		Skipped:  mergeRanges(skipped),
+		Failed:   mergeRanges(failed),
+		Complete: len(failed) == 0 && len(skipped) == 0,
+	}
+	if total > 0 {
+		cov.Fraction = (total - first - missed) / total
+	}
+	return cov
+}
+
+// fullCoverage is the coverage of a transcript of the whole audio.
+func fullCoverage(duration float64) *Coverage {
+	return &Coverage{
+		Duration: duration,
+		Scanned:  []TimeRange{{Start: 0, End: duration}},
+		Fraction: 1,
+		Complete: true,
+	}
+}
+
+// transcriptCoverage is the coverage of a full transcript: all of it but
+// the gaps left by chunks that failed.
+func transcriptCoverage(t transcribe.Transcript) *Coverage {
+	if len(t.Gaps) == 0 {
+		return fullCoverage(t.Duration)
+	}
+	var failed []TimeRange
+	for _, g := range t.Gaps {
+		failed = append(failed, TimeRange{Start: g.Start, End: min(g.End, t.Duration)})
+	}
+	failed = mergeRanges(failed)
+	var scanned []TimeRange
+	var seen, at float64
+	for _, f := range failed {
+		if f.Start > at {
+			scanned = append(scanned, TimeRange{Start: at, End: f.Start})
+			seen += f.Start - at
+		}
+		at = f.End
+	}
+	if at < t.Duration {
+		scanned = append(scanned, TimeRange{Start: at, End: t.Duration})
+		seen += t.Duration - at
+	}
+	cov := &Coverage{Duration: t.Duration, Scanned: scanned, Failed: failed}
+	if t.Duration > 0 {
+		cov.Fraction = seen / t.Duration
+	}
+	return cov
+}
+
+// mergeRanges sorts ranges and joins the ones that touch.
+func mergeRanges(ranges []TimeRange) []TimeRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	out := []TimeRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &out[len(out)-1]
+		// chunk boundaries come from float offsets, so allow rounding slack
+		if r.Start <= last.End+0.5 {
+			last.End = max(last.End, r.End)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// ErrFullyCovered is returned by Extend when nothing asked for is left to
+// transcribe.
+var ErrFullyCovered = errors.New("the requested ranges are already transcribed")
+
+// ExtendResult is what one Pipeline.Extend added to a partial transcript.
+type ExtendResult struct {
+	// Entries are the new segments, none inside what was covered before
+	Entries []subtitle.Entry
+	// Transcribed are the spans this call transcribed
+	Transcribed []TimeRange
+	// Coverage is everything transcribed so far, this call included
+	Coverage *Coverage
+}
+
+// Extend transcribes more of req's video, building a transcript up over
+// several cheap calls instead of one long job. covered is what earlier
+// calls transcribed. Chunks not yet covered are transcribed in time order,
+// up to maxSeconds of audio: those overlapping want, or the earliest ones
+// when want is empty. Chunks are shared with the ChunkCache, so parts a
+// search or prefetch already transcribed cost nothing.
+func (p *Pipeline) Extend(ctx context.Context, req Request, covered, want []TimeRange, maxSeconds float64) (ExtendResult, error) {
+	langCode := NormalizeLang(req.Language)
+
+	done, err := p.acquireRun(ctx)
+	if err != nil {
+		return ExtendResult{}, err
+	}
+	defer done()
+
+	dl, err := p.Downloader.With(req.DownloadOptions)
+	if err != nil {
+		return ExtendResult{}, err
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		return ExtendResult{}, err
+	}
+
+	refund, err := p.approveCostSeconds(req, maxSeconds)
+	if err != nil {
+		return ExtendResult{}, err
+	}
+	defer refund()
+	release, err := p.acquireTranscription(ctx)
+	if err != nil {
+		return ExtendResult{}, err
+	}
+	defer release()
+
+	covered = mergeRanges(append([]TimeRange(nil), covered...))
+	var duration, shortest float64
+	var picked []transcribe.Chunk
+	choose := func(chunks []transcribe.Chunk) []transcribe.Chunk {
+		for _, c := range chunks {
+			duration = max(duration, c.Offset+c.Duration)
+		}
+		var spent float64
+		for _, c := range chunks {
+			if rangesContain(covered, c.Offset, c.Offset+c.Duration) || !wanted(want, c) {
+				continue
+			}
+			if shortest == 0 {
+				shortest = c.Duration
+			}
+			if spent+c.Duration > maxSeconds {
+				break
+			}
+			spent += c.Duration
+			picked = append(picked, c)
+		}
+		return picked
+	}
+	chunks, _, err := p.flow().WarmChunks(req.transcribeContext(ctx), src, chunkCacheKey(req), choose, req.Progress)
+	if err != nil {
+		return ExtendResult{}, err
+	}
+
+	var res ExtendResult
+	all := append([]TimeRange(nil), covered...)
+	for _, c := range chunks {
+		r := TimeRange{Start: c.Offset, End: c.Offset + c.Duration}
+		res.Transcribed = append(res.Transcribed, r)
+		all = append(all, r)
+		for _, e := range c.Entries {
+			// chunks cut differently from earlier calls may overlap them
+			if !rangesContain(covered, (e.Start+e.End)/2, (e.Start+e.End)/2) {
+				res.Entries = append(res.Entries, e)
+			}
+		}
+	}
+	switch {
+	case len(picked) == 0 && shortest > 0:
+		return res, fmt.Errorf("the budget of %.0fs is shorter than the next chunk (%.0fs)", maxSeconds, shortest)
+	case len(picked) == 0:
+		return res, ErrFullyCovered
+	case len(chunks) == 0:
+		return res, fmt.Errorf("none of the %d chunks could be transcribed", len(picked))
+	}
+	sort.Slice(res.Entries, func(i, j int) bool { return res.Entries[i].Start < res.Entries[j].Start })
+	TagLanguages(res.Entries, langCode)
+	res.Coverage = rangeCoverage(mergeRanges(all), duration)
+	return res, nil
+}
+
+func wanted(want []TimeRange, c transcribe.Chunk) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, r := range want {
+		if overlaps(r.Start, r.End, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangesContain reports whether [start, end] lies within one of the merged
+// ranges, give or take chunk boundary rounding.
+func rangesContain(ranges []TimeRange, start, end float64) bool {
+	for _, r := range ranges {
+		if start >= r.Start-0.5 && end <= r.End+0.5 {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeCoverage is the coverage of merged scanned ranges of audio lasting
+// duration.
+func rangeCoverage(scanned []TimeRange, duration float64) *Coverage {
+	var seen float64
+	for _, r := range scanned {
+		seen += min(r.End, duration) - r.Start
+	}
+	cov := &Coverage{Duration: duration, Scanned: scanned}
+	if duration > 0 {
+		cov.Fraction = min(seen/duration, 1)
+	}
+	cov.Complete = rangesContain(scanned, 0, duration)
+	return cov
+}
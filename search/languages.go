@@ -0,0 +1,250 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"searchme/internal/env"
+	"searchme/media"
+	"searchme/subtitle"
+)
+
+// AllLanguages in Request.Languages searches every caption track the video
+// has: the uploader's, and the platform's auto captions in the original
+// language. Auto translations are left out.
+const AllLanguages = "all"
+
+// LanguageHit is what one caption track had in a multi-language search.
+type LanguageHit struct {
+	Language string  `json:"language"`
+	Found    bool    `json:"found"`
+	Time     string  `json:"time,omitempty"`
+	Seconds  float64 `json:"seconds,omitempty"`
+	URL      string  `json:"url,omitempty"`
+	Text     string  `json:"text,omitempty"`
+	Source   string  `json:"source,omitempty"`
+	// Keyword is the translation looked for in this track, when the
+	// keyword was translated
+	Keyword string `json:"keyword,omitempty"`
+	// Count is how many segments of the track contain the keyword
+	Count int `json:"count"`
+	// Error is why the track couldn't be searched, e.g. it doesn't exist
+	Error string `json:"error,omitempty"`
+}
+
+// languageResult is one track's search before merging.
+type languageResult struct {
+	hit    LanguageHit
+	first  subtitle.Entry
+	match  Match
+	ranked []RankedMatch
+}
+
+// SearchLanguages searches the caption tracks in req.Languages concurrently
+// and merges them: the answer is the earliest match in any track, Languages
+// reports every track's hits, and with a limit Matches pages through the
+// occurrences of all of them, ranked together. When none of the tracks exist it falls back to Search
+// in the first language, which may transcribe.
+func (p *Pipeline) SearchLanguages(ctx context.Context, req Request) (Response, error) {
+	resp, searched, err := p.searchCaptionTracks(ctx, req)
+	if err != nil || searched {
+		return resp, err
+	}
+	fallback := req
+	fallback.Languages = nil
+	for _, l := range req.Languages {
+		if !strings.EqualFold(l, AllLanguages) {
+			fallback.Language = l
+			break
+		}
+	}
+	match, found, usedLang, err := p.Search(ctx, fallback)
+	if err != nil {
+		return Response{}, err
+	}
+	return NewResponse(req.VideoURL, match, found, usedLang), nil
+}
+
+// searchCaptionTracks is SearchLanguages' caption search. searched is false
+// when no requested track exists.
+func (p *Pipeline) searchCaptionTracks(ctx context.Context, req Request) (Response, bool, error) {
+	done, err := p.acquireRun(ctx)
+	if err != nil {
+		return Response{}, false, err
+	}
+	defer done()
+
+	dl, err := p.Downloader.With(req.DownloadOptions)
+	if err != nil {
+		return Response{}, false, err
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		return Response{}, false, err
+	}
+	if !src.SupportsSubtitles() {
+		return Response{}, false, nil
+	}
+	langs, err := requestedLanguages(dl, src, req)
+	if err != nil {
+		return Response{}, false, err
+	}
+
+	results := make([]languageResult, len(langs))
+	sem := make(chan struct{}, env.Int("LANGUAGE_SEARCH_CONCURRENCY", 4))
+	var wg sync.WaitGroup
+	for i, lang := range langs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = p.searchLanguageTrack(dl, src, req, lang)
+		}()
+	}
+	wg.Wait()
+
+	var best *languageResult
+	var hits []LanguageHit
+	var ranked []RankedMatch
+	for i := range results {
+		r := &results[i]
+		hits = append(hits, r.hit)
+		ranked = append(ranked, r.ranked...)
+		if r.hit.Found && (best == nil || r.first.Start < best.first.Start) {
+			best = r
+		}
+	}
+	var resp Response
+	if best != nil {
+		resp = NewResponse(req.VideoURL, best.match, true, best.hit.Language)
+	} else {
+		// nothing found: answer for the first track that exists, so the
+		// caller still learns its quality
+		exists := false
+		for _, r := range results {
+			if r.hit.Error == "" {
+				resp = NewResponse(req.VideoURL, r.match, false, r.hit.Language)
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			log.Printf("multi-language search: none of %s has captions for %s", strings.Join(langs, ", "), req.VideoURL)
+			return Response{}, false, nil
+		}
+	}
+	resp.Languages = hits
+	if req.Limit > 0 && len(ranked) > 0 {
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+		resp.Matches, resp.Page = req.paginate(ranked)
+	}
+	return resp, true, nil
+}
+
+// requestedLanguages expands req.Languages into the normalized, de-duplicated
+// tracks to search, at most MAX_LANGUAGE_TRACKS (default 10) of them.
+func requestedLanguages(dl *media.Downloader, src media.VideoSource, req Request) ([]string, error) {
+	var langs []string
+	seen := map[string]bool{}
+	add := func(l string) {
+		l = NormalizeLang(l)
+		if !seen[l] {
+			seen[l] = true
+			langs = append(langs, l)
+		}
+	}
+	for _, l := range req.Languages {
+		if !strings.EqualFold(strings.TrimSpace(l), AllLanguages) {
+			add(l)
+			continue
+		}
+		manual, auto, err := subtitle.Languages(dl, src, req.VideoURL)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range manual {
+			add(m)
+		}
+		for _, a := range auto {
+			if base, ok := strings.CutSuffix(a, "-orig"); ok {
+				add(base)
+			}
+		}
+	}
+	if max := env.Int("MAX_LANGUAGE_TRACKS", 10); max > 0 && len(langs) > max {
+		log.Printf("multi-language search: searching %d of %d caption tracks", max, len(langs))
+		langs = langs[:max]
+	}
+	return langs, nil
+}
+
+// searchLanguageTrack fetches and searches one language's captions.
+func (p *Pipeline) searchLanguageTrack(dl *media.Downloader, src media.VideoSource, req Request, lang string) languageResult {
+	res := languageResult{hit: LanguageHit{Language: lang}}
+	track, ok, err := subtitle.FetchTrack(dl, src, req.VideoURL, lang)
+	if !ok {
+		res.hit.Error = "no captions in this language"
+		if err != nil {
+			res.hit.Error = err.Error()
+		}
+		return res
+	}
+	subs, err := track.Entries()
+	if err == nil {
+		err = p.checkSegments(len(subs))
+	}
+	if err != nil {
+		res.hit.Error = fmt.Sprintf("failed to read %s captions: %v", lang, err)
+		return res
+	}
+	trackLang := trackLanguage(track, lang)
+	TagLanguages(subs, trackLang)
+	if trackLang == NormalizeLang(req.Language) {
+		// the index keeps one track per video: the primary language's
+		p.onTranscript(req.VideoURL, trackLang, track.Source, subs)
+	}
+
+	matcher := req.Matcher(trackLang)
+	quality := TranscriptQuality(subs, track.Source)
+	res.match = Match{Quality: &quality, CaptionVariant: track.Variant}
+	res.hit.Language = trackLang
+	if kw := req.KeywordFor(trackLang); kw != req.Keyword {
+		res.hit.Keyword = kw
+	}
+	for i, e := range subs {
+		partial := matcher.PartialWord(e)
+		if !matcher.MatchEntry(e) {
+			span, whole, ok := matcher.spanning(subs[i:])
+			if !ok {
+				continue
+			}
+			e, partial = span, !whole
+		}
+		res.hit.Count++
+		if res.hit.Count > 1 {
+			continue
+		}
+		res.first = e
+		res.match = Match{Start: e.Start, End: e.End, Text: e.Text, Source: track.Source, Quality: &quality,
+			Partial: partial, CaptionVariant: track.Variant}
+		res.match.Previous, res.match.Next = SegmentNeighbors(subs, e)
+		res.hit.Found = true
+		res.hit.Time = FormatTime(e.Start)
+		res.hit.Seconds = e.Start
+		res.hit.URL = media.DeepLink(req.VideoURL, e.Start)
+		res.hit.Text = e.Text
+		res.hit.Source = track.Source
+	}
+	if req.Limit > 0 && res.hit.Found {
+		res.ranked = Rank(subs, matcher, req.VideoURL, 0)
+		for i := range res.ranked {
+			res.ranked[i].Language = trackLang
+		}
+	}
+	return res
+}
@@ -0,0 +1,190 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"searchme/events"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// LiveOptions sets how WatchLive tails a stream.
+type LiveOptions struct {
+	// Window is how much of the stream is transcribed at a time: shorter
+	// windows report matches sooner but send Whisper more requests
+	Window time.Duration
+	// MaxDuration ends the watch; 0 watches until the stream does
+	MaxDuration time.Duration
+	// OnMatch receives each match as soon as its window is transcribed
+	OnMatch func(LiveMatch)
+	// OnWindow, when set, is told about every window searched
+	OnWindow func(LiveWindow)
+}
+
+// LiveMatch is the keyword said on a live stream.
+type LiveMatch struct {
+	// Start and End are seconds since the watch began
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Time  string  `json:"time"`
+	Text  string  `json:"text"`
+	// At is when it was said, by the server's clock
+	At time.Time `json:"at"`
+	// SpeechConfidence is how sure Whisper was of the segment
+	SpeechConfidence *SpeechConfidence `json:"speech_confidence,omitempty"`
+}
+
+// LiveWindow is one stretch of a live stream searched by WatchLive.
+type LiveWindow struct {
+	Index   int     `json:"index"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Matches int     `json:"matches"`
+	// Error is why the window couldn't be transcribed; the watch goes on
+	Error string `json:"error,omitempty"`
+}
+
+// liveFailLimit is how many windows may fail before one has been
+// transcribed, e.g. when there is no usable OpenAI key.
+const liveFailLimit = 3
+
+// WatchLive records a live stream, a yt-dlp live URL or an HLS or DASH
+// manifest, and transcribes it window by window as it plays, reporting
+// each match of req's keyword through opts.OnMatch. It returns nil when
+// the stream or opts.MaxDuration ends and ctx's error when it is
+// cancelled. Each window is transcribed as one chunk; a window that fails
+// is skipped, but when the first liveFailLimit all fail the watch stops
+// with transcribe.ErrTranscriptionFailed.
+func (p *Pipeline) WatchLive(ctx context.Context, req Request, opts LiveOptions) error {
+	dl, err := p.Downloader.With(req.DownloadOptions)
+	if err != nil {
+		return err
+	}
+	refund, err := p.approveCostSeconds(req, opts.MaxDuration.Seconds())
+	if err != nil {
+		return err
+	}
+	defer refund()
+	streamURL, err := dl.LiveStreamURL(ctx, req.VideoURL)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(workfile.Dir(), "live_")
+	if err != nil {
+		return fmt.Errorf("failed to create live dir: %w", err)
+	}
+	workfile.Track(dir)
+	defer os.RemoveAll(dir)
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.MaxDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxDuration)
+		defer cancel()
+	}
+	segments := make(chan media.LiveSegment, 16)
+	tailed := make(chan error, 1)
+	go func() {
+		tailed <- media.TailLive(ctx, streamURL, dir, opts.Window, dl.AudioSettings(), segments)
+	}()
+
+	f := p.flow()
+	matcher := req.Matcher(NormalizeLang(req.Language))
+	wctx := req.transcribeContext(ctx)
+	var tail []subtitle.Entry
+	transcribed, failed := false, 0
+	var failure error
+	for seg := range segments {
+		window := LiveWindow{Index: seg.Index, Start: seg.Offset, End: seg.Offset + seg.Duration}
+		entries, err := p.transcribeLive(wctx, f, seg)
+		os.Remove(seg.Path)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			window.Error = err.Error()
+			tail = nil
+			if failed++; !transcribed && failed >= liveFailLimit {
+				failure = fmt.Errorf("%w: %w", transcribe.ErrTranscriptionFailed, err)
+				if opts.OnWindow != nil {
+					opts.OnWindow(window)
+				}
+				break
+			}
+		} else {
+			transcribed = true
+		}
+		for _, m := range liveMatches(entries, tail, matcher) {
+			window.Matches++
+			match := LiveMatch{Start: m.Start, End: m.End, Time: FormatTime(m.Start), Text: m.Text,
+				At: seg.Started.Add(time.Duration((m.Start - seg.Offset) * float64(time.Second))), SpeechConfidence: EntryConfidence(m)}
+			events.Emit(events.MatchFound, req.VideoURL, map[string]interface{}{
+				"keyword": req.Keyword, "seconds": match.Start, "source": SourceLiveTranscription, "live": true,
+			})
+			if opts.OnMatch != nil {
+				opts.OnMatch(match)
+			}
+		}
+		if n := len(entries); n > 0 {
+			tail = entries
+		}
+		if opts.OnWindow != nil {
+			opts.OnWindow(window)
+		}
+	}
+	if failure != nil {
+		cancel()
+		<-tailed
+		return failure
+	}
+	err = <-tailed
+	if parent.Err() != nil {
+		return parent.Err()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// MaxDuration is up
+		return nil
+	}
+	return err
+}
+
+// transcribeLive transcribes one recorded window, holding a transcription
+// slot only while it does.
+func (p *Pipeline) transcribeLive(ctx context.Context, f *Flow, seg media.LiveSegment) ([]subtitle.Entry, error) {
+	release, err := p.acquireTranscription(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return f.Transcriber.Transcribe(ctx, transcribe.Chunk{Index: seg.Index, Path: seg.Path, Offset: seg.Offset, Duration: seg.Duration})
+}
+
+// liveMatches are the entries of a window that match and the keyword split
+// across its entries, including across the cut from the previous window's
+// entries, tail. A phrase starting in an entry that matches on its own is
+// reported with that entry only.
+func liveMatches(entries, tail []subtitle.Entry, m *Matcher) []subtitle.Entry {
+	all := append(append([]subtitle.Entry(nil), tail...), entries...)
+	var out []subtitle.Entry
+	matched := -1
+	for _, h := range m.Hits(all) {
+		if !h.Spans {
+			matched = h.Index
+		}
+		switch {
+		case h.Spans && h.Index == matched:
+		case h.Index < len(tail) && (!h.Spans || h.End <= tail[len(tail)-1].End):
+			// reported with the previous window
+		default:
+			out = append(out, h.Entry)
+		}
+	}
+	return out
+}
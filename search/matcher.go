@@ -0,0 +1,250 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"searchme/langpack"
+	"searchme/subtitle"
+)
+
+// MatchMode controls what counts as finding the keyword in a text.
+type MatchMode string
+
+const (
+	// MatchSubstring finds the keyword anywhere, so "cat" matches "category"
+	MatchSubstring MatchMode = "substring"
+	// MatchWord only finds the keyword as whole words
+	MatchWord MatchMode = "word"
+	// MatchPhrase finds the keyword's words in sequence, ignoring punctuation
+	// and spacing between them
+	MatchPhrase MatchMode = "phrase"
+)
+
+// ParseMatchMode validates a match option; empty means MatchSubstring.
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch mode := MatchMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return MatchSubstring, nil
+	case MatchSubstring, MatchWord, MatchPhrase:
+		return mode, nil
+	default:
+		return MatchSubstring, fmt.Errorf("unknown match mode %q (want word, substring or phrase)", s)
+	}
+}
+
+// MatchOptions tune how a Matcher compares the keyword with text.
+type MatchOptions struct {
+	Mode MatchMode
+	// Stem compares word stems, so "deploy" also finds "deploying" and
+	// "deployed". Stemmed matching is always whole-word.
+	Stem bool
+}
+
+// Matcher decides whether a text contains a keyword under one language pack's rules.
+// Text and keyword are normalized the same way before comparing. A Matcher is
+// not safe for concurrent use.
+type Matcher struct {
+	pack    langpack.Pack
+	raw     string
+	keyword string
+	mode    MatchMode
+	stem    bool
+	// words is the keyword split into words (stemmed when stem is set), for
+	// phrase and stemmed matching
+	words []string
+	// other keeps matchers for segments in other languages, built on first use
+	other map[string]*Matcher
+}
+
+// NewMatcher prepares a keyword for substring matching in the given language.
+func NewMatcher(lang, keyword string) *Matcher {
+	return NewModeMatcher(lang, keyword, MatchSubstring)
+}
+
+// NewModeMatcher prepares a keyword for matching in the given language and mode.
+func NewModeMatcher(lang, keyword string, mode MatchMode) *Matcher {
+	return NewMatcherWithOptions(lang, keyword, MatchOptions{Mode: mode})
+}
+
+// NewMatcherWithOptions prepares a keyword for matching in the given language.
+func NewMatcherWithOptions(lang, keyword string, opts MatchOptions) *Matcher {
+	pack := langpack.For(lang)
+	normalized := strings.TrimSpace(langpack.NormalizeText(pack, keyword))
+	m := &Matcher{
+		pack:    pack,
+		raw:     keyword,
+		keyword: normalized,
+		mode:    opts.Mode,
+		stem:    opts.Stem,
+		words:   strings.FieldsFunc(normalized, langpack.IsWordSeparator),
+	}
+	if m.stem {
+		m.words = m.stemWords(m.words)
+	}
+	return m
+}
+
+// stemWords stems words in place with the matcher's language pack.
+func (m *Matcher) stemWords(words []string) []string {
+	for i, w := range words {
+		words[i] = m.pack.Stem(w)
+	}
+	return words
+}
+
+// textWords splits normalized text into words, stemmed when the matcher stems.
+func (m *Matcher) textWords(text string) []string {
+	words := strings.FieldsFunc(text, langpack.IsWordSeparator)
+	if m.stem {
+		words = m.stemWords(words)
+	}
+	return words
+}
+
+// Keyword is the keyword as the caller typed it.
+func (m *Matcher) Keyword() string { return m.raw }
+
+// Normalize applies the matcher's language rules to text.
+func (m *Matcher) Normalize(text string) string {
+	return langpack.NormalizeText(m.pack, text)
+}
+
+// Match reports whether text contains the keyword.
+func (m *Matcher) Match(text string) bool {
+	if m.keyword == "" {
+		return false
+	}
+	switch {
+	case m.stem, m.mode == MatchWord, m.mode == MatchPhrase:
+		count, _ := m.occurrences(m.Normalize(text))
+		return count > 0
+	default:
+		return strings.Contains(m.Normalize(text), m.keyword)
+	}
+}
+
+// MatchEntry reports whether a segment contains the keyword, normalizing both
+// with the segment's own language rules when it was tagged with one.
+func (m *Matcher) MatchEntry(e subtitle.Entry) bool {
+	return m.forLang(e.Lang).Match(e.Text)
+}
+
+// Occurrences counts the keyword in a segment and reports whether any
+// occurrence is a whole word rather than part of a longer one. In word and
+// phrase mode, and when stemming, only whole-word occurrences are counted.
+func (m *Matcher) Occurrences(e subtitle.Entry) (count int, whole bool) {
+	mm := m.forLang(e.Lang)
+	if mm.keyword == "" {
+		return 0, false
+	}
+	return mm.occurrences(mm.Normalize(e.Text))
+}
+
+// PartialWord reports whether a segment contains the keyword only inside
+// longer words.
+func (m *Matcher) PartialWord(e subtitle.Entry) bool {
+	count, whole := m.Occurrences(e)
+	return count > 0 && !whole
+}
+
+func (m *Matcher) occurrences(text string) (count int, whole bool) {
+	if m.stem || m.mode == MatchPhrase {
+		n := phraseCount(m.textWords(text), m.words)
+		return n, n > 0
+	}
+	for i := 0; ; {
+		j := strings.Index(text[i:], m.keyword)
+		if j < 0 {
+			return count, whole
+		}
+		start, end := i+j, i+j+len(m.keyword)
+		isWhole := isBoundary(text, start, true) && isBoundary(text, end, false)
+		if isWhole || m.mode != MatchWord {
+			count++
+		}
+		whole = whole || isWhole
+		i = end
+	}
+}
+
+// phraseCount counts where phrase occurs as a run of consecutive words.
+func phraseCount(words, phrase []string) int {
+	if len(phrase) == 0 {
+		return 0
+	}
+	count := 0
+outer:
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		for k, w := range phrase {
+			if words[i+k] != w {
+				continue outer
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// isBoundary reports whether position i of text starts (before=true) or ends a word.
+func isBoundary(text string, i int, before bool) bool {
+	var r rune
+	if before {
+		if i == 0 {
+			return true
+		}
+		r, _ = utf8.DecodeLastRuneInString(text[:i])
+	} else {
+		if i >= len(text) {
+			return true
+		}
+		r, _ = utf8.DecodeRuneInString(text[i:])
+	}
+	return langpack.IsWordSeparator(r)
+}
+
+func (m *Matcher) forLang(lang string) *Matcher {
+	if lang == "" || langpack.For(lang).Code() == m.pack.Code() {
+		return m
+	}
+	if mm, ok := m.other[lang]; ok {
+		return mm
+	}
+	if m.other == nil {
+		m.other = map[string]*Matcher{}
+	}
+	mm := NewMatcherWithOptions(lang, m.raw, MatchOptions{Mode: m.mode, Stem: m.stem})
+	m.other[lang] = mm
+	return mm
+}
+
+// WordsBefore counts words in text before the first occurrence of the keyword.
+func (m *Matcher) WordsBefore(text string) int {
+	if m.stem {
+		words := m.textWords(m.Normalize(text))
+		for i := 0; i+len(m.words) <= len(words); i++ {
+			if phraseCount(words[i:i+len(m.words)], m.words) > 0 {
+				return i
+			}
+		}
+		return 0
+	}
+	return countWordsBeforeKeyword(m.Normalize(text), m.keyword)
+}
+
+// countWordsBeforeKeyword counts words before the first occurrence of the keyword
+func countWordsBeforeKeyword(text, keyword string) int {
+	lowerText := strings.ToLower(text)
+	lowerKeyword := strings.ToLower(keyword)
+
+	keywordIndex := strings.Index(lowerText, lowerKeyword)
+	if keywordIndex == -1 {
+		return 0
+	}
+
+	// Count words in the text before the keyword
+	textBeforeKeyword := text[:keywordIndex]
+	words := strings.Fields(textBeforeKeyword)
+	return len(words)
+}
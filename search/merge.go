@@ -0,0 +1,258 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"searchme/langpack"
+	"searchme/media"
+	"searchme/subtitle"
+)
+
+// Where a merged segment's timing came from
+const (
+	TimingCaptions = "captions"
+	TimingWhisper  = "whisper"
+)
+
+// ErrNoCaptions is returned by MergedTranscript for videos without captions
+// to merge with. It wraps subtitle.ErrNoSubtitles.
+var ErrNoCaptions = fmt.Errorf("%w to merge with a transcription", subtitle.ErrNoSubtitles)
+
+// mergeWindow is how far apart in seconds a caption cue and the Whisper
+// segment saying the same words may be
+const mergeWindow = 10
+
+// MergedSegment is one segment of a timeline merged from captions and a
+// Whisper transcript.
+type MergedSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+	// Timing is the source whose timestamps won
+	Timing string `json:"timing"`
+	// Confidence is the winning source's weight, 0-1
+	Confidence float64 `json:"confidence"`
+	// Rolling marks a caption cue that overlaps or repeats its neighbours,
+	// as auto-generated captions do
+	Rolling bool `json:"rolling,omitempty"`
+	// Caption and Whisper are each source's own timing for the segment
+	Caption *TimeRange `json:"caption,omitempty"`
+	Whisper *TimeRange `json:"whisper,omitempty"`
+}
+
+// MergedTranscript is a video's captions and Whisper transcript as one
+// timeline.
+type MergedTranscript struct {
+	Language      string          `json:"language"`
+	CaptionSource string          `json:"caption_source"`
+	Segments      []MergedSegment `json:"segments"`
+	// CaptionTimed and WhisperTimed count the segments each source won
+	CaptionTimed int `json:"caption_timed"`
+	WhisperTimed int `json:"whisper_timed"`
+}
+
+// MergedTranscript fetches the video's captions and transcribes it with
+// Whisper, then merges the two with MergeTimelines. It costs a full
+// transcription, so it is approved like one.
+func (p *Pipeline) MergedTranscript(ctx context.Context, req Request) (MergedTranscript, error) {
+	langCode := NormalizeLang(req.Language)
+	done, err := p.acquireRun(ctx)
+	if err != nil {
+		return MergedTranscript{}, err
+	}
+	defer done()
+
+	dl, err := p.Downloader.With(req.DownloadOptions)
+	if err != nil {
+		return MergedTranscript{}, err
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		return MergedTranscript{}, err
+	}
+	track, ok, err := subtitle.FetchTrack(dl, src, req.VideoURL, langCode)
+	if subtitle.Fatal(err) {
+		return MergedTranscript{}, err
+	}
+	if !ok {
+		return MergedTranscript{}, ErrNoCaptions
+	}
+	captions, err := track.Entries()
+	if err != nil {
+		return MergedTranscript{}, fmt.Errorf("failed to parse %s subtitles: %w", strings.ToUpper(track.Format), err)
+	}
+	if err := p.checkSegments(len(captions)); err != nil {
+		return MergedTranscript{}, err
+	}
+	lang := trackLanguage(track, langCode)
+	whisper, _, err := p.wholeTranscript(ctx, dl, src, req, lang)
+	if err != nil {
+		return MergedTranscript{}, err
+	}
+
+	out := MergedTranscript{Language: lang, CaptionSource: track.Source, Segments: MergeTimelines(captions, track.Source, whisper, lang)}
+	for _, s := range out.Segments {
+		if s.Timing == TimingCaptions {
+			out.CaptionTimed++
+		} else {
+			out.WhisperTimed++
+		}
+	}
+	return out, nil
+}
+
+// MergeTimelines builds one timeline from captions and a Whisper transcript
+// of the same video. Each caption cue is weighed against the part of the
+// Whisper segment saying the same words: human captions weigh 1 and
+// auto-generated ones 0.8, halved for rolling cues; Whisper weighs its mean
+// token probability times how well the words agree. The heavier side's
+// timing wins. The cue's text is kept either way. Whisper segments no cue
+// overlaps fill the gaps captions leave.
+func MergeTimelines(captions []subtitle.Entry, captionSource string, whisper []subtitle.Entry, lang string) []MergedSegment {
+	pack := langpack.For(lang)
+	words := func(text string) []string {
+		return strings.FieldsFunc(langpack.NormalizeText(pack, text), langpack.IsWordSeparator)
+	}
+	whisperWords := make([][]string, len(whisper))
+	for i, w := range whisper {
+		whisperWords[i] = words(w.Text)
+	}
+	base := 1.0
+	if captionSource == SourceAutoSubtitles {
+		base = 0.8
+	}
+
+	var out []MergedSegment
+	used := make([]bool, len(whisper))
+	for i, c := range captions {
+		rolling := rollingCue(captions, i)
+		weight := base
+		if rolling {
+			weight /= 2
+		}
+		seg := MergedSegment{
+			Start: c.Start, End: c.End, Text: c.Text,
+			Timing: TimingCaptions, Confidence: weight, Rolling: rolling,
+			Caption: &TimeRange{Start: c.Start, End: c.End},
+		}
+		cue := words(c.Text)
+		mid := (c.Start + c.End) / 2
+		bestJ, bestSim := -1, 0.0
+		var bestRange TimeRange
+		for j, w := range whisper {
+			if mid < w.Start-mergeWindow || mid > w.End+mergeWindow {
+				continue
+			}
+			from, to, sim := locateWords(cue, whisperWords[j])
+			if sim > bestSim {
+				bestJ, bestSim = j, sim
+				// spread the segment evenly over its words
+				per := (w.End - w.Start) / float64(max(len(whisperWords[j]), 1))
+				bestRange = TimeRange{Start: roundMillis(w.Start + float64(from)*per), End: roundMillis(w.Start + float64(to)*per)}
+			}
+		}
+		if bestJ >= 0 && bestSim >= 0.5 {
+			used[bestJ] = true
+			seg.Whisper = &bestRange
+			if ww := whisperWeight(whisper[bestJ]) * bestSim; ww > weight {
+				seg.Start, seg.End = bestRange.Start, bestRange.End
+				seg.Timing, seg.Confidence = TimingWhisper, ww
+			}
+		}
+		seg.Confidence = math.Round(seg.Confidence*100) / 100
+		out = append(out, seg)
+	}
+
+	for j, w := range whisper {
+		if used[j] || overlapsCaptions(captions, w) {
+			continue
+		}
+		out = append(out, MergedSegment{
+			Start: w.Start, End: w.End, Text: strings.TrimSpace(w.Text),
+			Timing: TimingWhisper, Confidence: math.Round(whisperWeight(w)*100) / 100,
+			Whisper: &TimeRange{Start: w.Start, End: w.End},
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// rollingCue reports whether a cue looks like rolling captions: it runs into
+// the next cue, repeats the end of the previous one, or flashes by.
+func rollingCue(cues []subtitle.Entry, i int) bool {
+	c := cues[i]
+	if c.End-c.Start < 0.3 {
+		return true
+	}
+	if i+1 < len(cues) && cues[i+1].Start < c.End-0.1 {
+		return true
+	}
+	if i > 0 {
+		if prev := strings.Fields(cues[i-1].Text); len(prev) >= 2 {
+			tail := strings.Join(prev[len(prev)/2:], " ")
+			return strings.HasPrefix(strings.TrimSpace(c.Text), tail)
+		}
+	}
+	return false
+}
+
+// locateWords finds the window of seg that best matches cue, as word
+// indexes from..to, and the share of cue's words found in it.
+func locateWords(cue, seg []string) (from, to int, sim float64) {
+	if len(cue) == 0 || len(seg) == 0 {
+		return 0, 0, 0
+	}
+	want := map[string]int{}
+	for _, w := range cue {
+		want[w]++
+	}
+	n := min(len(cue), len(seg))
+	best := -1
+	for start := 0; start+n <= len(seg); start++ {
+		left := map[string]int{}
+		for k, v := range want {
+			left[k] = v
+		}
+		hits := 0
+		for _, w := range seg[start : start+n] {
+			if left[w] > 0 {
+				left[w]--
+				hits++
+			}
+		}
+		if hits > best {
+			best, from = hits, start
+		}
+	}
+	return from, from + n, float64(best) / float64(len(cue))
+}
+
+// whisperWeight is the segment's mean token probability, halved when
+// Whisper thought it probably wasn't speech.
+func whisperWeight(e subtitle.Entry) float64 {
+	w := 0.7
+	if e.AvgLogprob != 0 {
+		w = math.Exp(e.AvgLogprob)
+	}
+	if e.NoSpeechProb > hallucinationNoSpeechProb {
+		w /= 2
+	}
+	return w
+}
+
+func overlapsCaptions(cues []subtitle.Entry, e subtitle.Entry) bool {
+	for _, c := range cues {
+		if c.Start < e.End && e.Start < c.End {
+			return true
+		}
+	}
+	return false
+}
+
+func roundMillis(s float64) float64 {
+	return math.Round(s*1000) / 1000
+}
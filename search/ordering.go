@@ -0,0 +1,174 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// Chunk orders for the audio search
+const (
+	// OrderSequential transcribes chunks front to back and returns the
+	// earliest match in the video
+	OrderSequential = "sequential"
+	// OrderPriority transcribes the chunks most likely to contain the keyword
+	// first and returns the first match found, which may not be the earliest
+	OrderPriority = "priority"
+)
+
+// ParseOrder validates an order option; empty means OrderSequential, or
+// OrderPriority when the request carries a hint.
+func ParseOrder(order, hint string) (string, error) {
+	switch o := strings.ToLower(strings.TrimSpace(order)); o {
+	case "":
+		if strings.TrimSpace(hint) != "" {
+			return OrderPriority, nil
+		}
+		return OrderSequential, nil
+	case OrderSequential, OrderPriority:
+		return o, nil
+	default:
+		return "", fmt.Errorf("unknown order %q (want sequential or priority)", order)
+	}
+}
+
+// ChunkSignals are the cheap clues about where the keyword is, gathered
+// before any audio is transcribed. Every field is optional.
+type ChunkSignals struct {
+	// Chapters whose title contains the keyword pull their chunks forward
+	Chapters []media.Chapter
+	// Captions, e.g. auto captions the policy chose not to trust, pull
+	// forward chunks where they mention the keyword or where speech is dense
+	Captions []subtitle.Entry
+	// Hint is the caller's guess: "start", "middle", "end", a timestamp
+	// ("1:02:00", "3720") or a fraction ("75%"), possibly inside a phrase
+	// like "probably near the end"
+	Hint string
+}
+
+// Signal weights: a keyword seen in captions beats a matching chapter title,
+// which beats the caller's positional hint, which beats speech density.
+const (
+	captionHitWeight     = 100
+	chapterMatchWeight   = 50
+	hintWeight           = 20
+	captionDensityWeight = 5
+)
+
+// PrioritizeChunks orders chunks by how likely they are to contain the
+// keyword. Chunks no signal speaks for keep their original order, after the
+// ones that score.
+func PrioritizeChunks(chunks []transcribe.Chunk, sig ChunkSignals, m *Matcher) []transcribe.Chunk {
+	scores := chunkScores(chunks, sig, m)
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	out := make([]transcribe.Chunk, len(chunks))
+	for i, j := range order {
+		out[i] = chunks[j]
+	}
+	return out
+}
+
+// chunkScores weighs the signals for each chunk; 0 is no evidence.
+func chunkScores(chunks []transcribe.Chunk, sig ChunkSignals, m *Matcher) []float64 {
+	var total float64
+	for _, c := range chunks {
+		total = math.Max(total, c.Offset+c.Duration)
+	}
+	target, hasTarget := parseHint(sig.Hint, total)
+
+	var maxDensity float64
+	densities := make([]float64, len(chunks))
+	for i, c := range chunks {
+		if c.Duration <= 0 {
+			continue
+		}
+		words := 0
+		for _, e := range sig.Captions {
+			if overlaps(e.Start, e.End, c) {
+				words += len(strings.Fields(e.Text))
+			}
+		}
+		densities[i] = float64(words) / c.Duration
+		maxDensity = math.Max(maxDensity, densities[i])
+	}
+
+	scores := make([]float64, len(chunks))
+	for i, c := range chunks {
+		for _, e := range sig.Captions {
+			if overlaps(e.Start, e.End, c) && m.MatchEntry(e) {
+				scores[i] += captionHitWeight
+			}
+		}
+		for _, ch := range sig.Chapters {
+			if overlaps(ch.Start, ch.End, c) && m.Match(ch.Title) {
+				scores[i] += chapterMatchWeight
+			}
+		}
+		if hasTarget && total > 0 {
+			mid := c.Offset + c.Duration/2
+			scores[i] += hintWeight * (1 - math.Abs(mid-target)/total)
+		}
+		if maxDensity > 0 {
+			scores[i] += captionDensityWeight * densities[i] / maxDensity
+		}
+	}
+	return scores
+}
+
+func overlaps(start, end float64, c transcribe.Chunk) bool {
+	return start < c.Offset+c.Duration && end > c.Offset
+}
+
+// parseHint turns a hint into a position in seconds.
+func parseHint(hint string, total float64) (float64, bool) {
+	hint = strings.ToLower(strings.TrimSpace(hint))
+	if hint == "" {
+		return 0, false
+	}
+	if strings.HasSuffix(hint, "%") {
+		if pct, err := strconv.ParseFloat(strings.TrimSuffix(hint, "%"), 64); err == nil && pct >= 0 && pct <= 100 {
+			return total * pct / 100, true
+		}
+	}
+	if secs, ok := parseClock(hint); ok {
+		return secs, true
+	}
+	for _, w := range strings.FieldsFunc(hint, func(r rune) bool { return r == ' ' || r == ',' || r == '-' }) {
+		switch w {
+		case "start", "beginning", "early", "intro", "first":
+			return 0, true
+		case "middle", "halfway", "mid":
+			return total / 2, true
+		case "end", "ending", "late", "last", "outro":
+			return total, true
+		}
+	}
+	return 0, false
+}
+
+// parseClock reads "3720", "62:00" or "1:02:00" as seconds.
+func parseClock(s string) (float64, bool) {
+	var secs float64
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	for _, p := range parts {
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		secs = secs*60 + n
+	}
+	return secs, true
+}
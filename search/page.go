@@ -0,0 +1,84 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Page is where one page of matches sits in the full list. Send NextCursor
+// back as the request's cursor, or Offset+Limit as its offset, for the next
+// page; NextCursor is empty on the last page.
+type Page struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	// Total counts the matches across all pages, when known
+	Total      int    `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ErrBadCursor is returned for a cursor that is malformed or was issued for
+// a different query.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// EncodeCursor makes an opaque cursor for offset within the results of the
+// query scope identifies.
+func EncodeCursor(scope string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", offset, cursorScope(scope))))
+}
+
+// DecodeCursor returns the offset in cursor, failing with ErrBadCursor when
+// it wasn't issued for scope.
+func DecodeCursor(scope, cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrBadCursor
+	}
+	offset, sc, ok := strings.Cut(string(raw), ":")
+	n, err := strconv.Atoi(offset)
+	if !ok || err != nil || n < 0 || sc != cursorScope(scope) {
+		return 0, ErrBadCursor
+	}
+	return n, nil
+}
+
+func cursorScope(scope string) string {
+	sum := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(sum[:6])
+}
+
+// PageOffset is where the request's page starts: its cursor when set,
+// otherwise its offset.
+func (r Request) PageOffset() (int, error) {
+	if r.Cursor != "" {
+		return DecodeCursor(r.cursorScope(), r.Cursor)
+	}
+	if r.Offset < 0 {
+		return 0, fmt.Errorf("offset must not be negative")
+	}
+	return r.Offset, nil
+}
+
+func (r Request) cursorScope() string {
+	return r.VideoURL + "\x00" + strings.ToLower(strings.TrimSpace(r.Keyword))
+}
+
+// paginate cuts the request's page out of every ranked match.
+func (r Request) paginate(ranked []RankedMatch) ([]RankedMatch, *Page) {
+	offset, _ := r.PageOffset()
+	page := &Page{Offset: offset, Limit: r.Limit, Total: len(ranked)}
+	if offset >= len(ranked) {
+		return []RankedMatch{}, page
+	}
+	end := offset + r.Limit
+	if end < len(ranked) {
+		page.NextCursor = EncodeCursor(r.cursorScope(), end)
+	} else {
+		end = len(ranked)
+	}
+	return ranked[offset:end], page
+}
@@ -0,0 +1,610 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"searchme/artifact"
+	"searchme/internal/oai"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// Request is one keyword search against a video.
+type Request struct {
+	VideoURL string `json:"video_url"`
+	Keyword  string `json:"keyword"`
+	Language string `json:"language,omitempty"`
+	// Translations, keyed by language code, stand in for Keyword when
+	// matching a track in that language; see KeywordFor
+	Translations map[string]string `json:"-"`
+	// Languages, when set, searches these caption tracks at once instead of
+	// Language; see AllLanguages and Pipeline.SearchLanguages
+	Languages []string `json:"languages,omitempty"`
+	// Limit, when positive, also returns up to Limit occurrences ranked by relevance
+	Limit int `json:"limit,omitempty"`
+	// Offset skips that many ranked occurrences; Cursor, a Page's
+	// NextCursor, does the same and takes precedence
+	Offset int    `json:"offset,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	// BudgetMinutes caps how much audio may be transcribed; 0 is unlimited
+	BudgetMinutes float64 `json:"budget_minutes,omitempty"`
+	// MatchMode is "substring" (default), "word" or "phrase"; see ParseMatchMode
+	MatchMode string `json:"match,omitempty"`
+	// Stem matches word stems, so "deploy" also finds "deployed"
+	Stem bool `json:"stem,omitempty"`
+	// Order is "sequential" (default) or "priority"; see ParseOrder
+	Order string `json:"order,omitempty"`
+	// Hint guesses where the keyword is, e.g. "near the end"; see ChunkSignals
+	Hint string `json:"hint,omitempty"`
+	// ConfirmCost accepts a Whisper cost estimate over the server's budget
+	ConfirmCost bool `json:"confirm_cost,omitempty"`
+	// Window, when set, limits the search and any transcription to that part
+	// of the video, e.g. one chapter. A miss's Coverage is then the window's.
+	// Multi-language searches ignore it.
+	Window *TimeRange `json:"window,omitempty"`
+	// OpenAIKey, when set, pays for the request's Whisper calls instead of
+	// the server's key. It is never serialized.
+	OpenAIKey string `json:"-"`
+	media.DownloadOptions
+	// WhisperOptions override the server's transcription model, language
+	// hint, temperature and prompt
+	transcribe.WhisperOptions
+	// Progress, when set, receives transcription progress if the search
+	// falls back to Whisper
+	Progress transcribe.ProgressFunc `json:"-"`
+}
+
+// Matcher prepares the request's keyword for matching in lang. An invalid
+// match mode falls back to substring matching; validate it with ParseMatchMode.
+func (r Request) Matcher(lang string) *Matcher {
+	mode, _ := ParseMatchMode(r.MatchMode)
+	return NewMatcherWithOptions(lang, r.KeywordFor(lang), MatchOptions{Mode: mode, Stem: r.Stem})
+}
+
+// KeywordFor is the keyword to look for in a track in lang: its translation
+// for lang, or for lang's primary subtag, else Keyword itself.
+func (r Request) KeywordFor(lang string) string {
+	lang = strings.ToLower(lang)
+	if t, ok := r.Translations[lang]; ok {
+		return t
+	}
+	if base, _, ok := strings.Cut(lang, "-"); ok {
+		if t, ok := r.Translations[base]; ok {
+			return t
+		}
+	}
+	return r.Keyword
+}
+
+// transcribeContext bills the request's transcriptions to its OpenAI key,
+// if any, and applies its WhisperOptions. Without a whisper_language the
+// request's Language is Whisper's hint; with neither, Whisper detects the
+// language. The hint only steers detection, so it isn't part of cache keys.
+func (r Request) transcribeContext(ctx context.Context) context.Context {
+	opts := r.WhisperOptions
+	if opts.Language == "" {
+		opts.Language = transcribe.LanguageHint(r.Language)
+	}
+	return transcribe.WithOptions(oai.WithKey(ctx, r.OpenAIKey), opts)
+}
+
+// Pipeline runs searches against videos: platform captions first, then Whisper.
+type Pipeline struct {
+	Downloader *media.Downloader
+	// AcquireTranscription, when set, is called before any Whisper work and
+	// returns the func that releases the slot; use it to bound concurrency.
+	AcquireTranscription func(ctx context.Context) (release func(), err error)
+	// OnTranscript, when set, receives every full caption track or transcript
+	// the pipeline loads, e.g. to index it.
+	OnTranscript func(videoURL, lang, source string, entries []subtitle.Entry)
+	// Flow holds the stages of the early-exit audio search; nil uses NewFlow's defaults
+	Flow *Flow
+	// Policy chooses between captions, partial and full transcription; nil
+	// uses DefaultPolicyConfig
+	Policy *Policy
+	// AcquireRun, when set, is called before each Search or LoadSegments and
+	// returns the func that releases the slot; use it to shed load.
+	AcquireRun func(ctx context.Context) (release func(), err error)
+	// MaxSegments caps how many segments one caption track or transcript may
+	// hold in memory; 0 is no limit.
+	MaxSegments int
+	// ApproveCost, when set, is called before any Whisper work with the most
+	// audio, in seconds, the request may transcribe (0 when the video's length
+	// is unknown); an error refuses the request. Otherwise the returned func
+	// is called once the request is over, e.g. to release a reservation.
+	ApproveCost func(req Request, audioSeconds float64) (refund func(), err error)
+	// ChunkCache, when set, keeps the chunks the default Flow transcribes so
+	// later searches and Prefetch share them
+	ChunkCache *ChunkCache
+}
+
+// ErrNoStrategy is returned when the policy leaves no way to answer a search,
+// e.g. no captions and only full transcription allowed, which the video's
+// length puts over the caller's budget.
+var ErrNoStrategy = errors.New("no search strategy fits this request")
+
+// ErrKeywordNotFound reports a search that ran to the end without finding
+// the keyword. Search itself answers that with found false; it is for
+// callers that treat it as a failure.
+var ErrKeywordNotFound = errors.New("keyword not found in the video")
+
+// ErrTooManySegments is returned when a caption track or transcript is larger
+// than the pipeline's MaxSegments.
+var ErrTooManySegments = errors.New("transcript has too many segments")
+
+var (
+	defaultPolicyOnce sync.Once
+	defaultPolicy     *Policy
+)
+
+// NewPipeline returns a pipeline downloading with dl.
+func NewPipeline(dl *media.Downloader) *Pipeline {
+	return &Pipeline{Downloader: dl}
+}
+
+func (p *Pipeline) flow() *Flow {
+	if p.Flow != nil {
+		return p.Flow
+	}
+	f := NewFlow(p.Downloader)
+	f.Cache = p.ChunkCache
+	return f
+}
+
+func (p *Pipeline) policy() *Policy {
+	if p.Policy != nil {
+		return p.Policy
+	}
+	defaultPolicyOnce.Do(func() { defaultPolicy, _ = NewPolicy(DefaultPolicyConfig()) })
+	return defaultPolicy
+}
+
+func (p *Pipeline) acquireTranscription(ctx context.Context) (func(), error) {
+	if p.AcquireTranscription == nil {
+		return func() {}, nil
+	}
+	return p.AcquireTranscription(ctx)
+}
+
+func (p *Pipeline) acquireRun(ctx context.Context) (func(), error) {
+	if p.AcquireRun == nil {
+		return func() {}, nil
+	}
+	return p.AcquireRun(ctx)
+}
+
+// checkSegments enforces MaxSegments.
+func (p *Pipeline) checkSegments(n int) error {
+	if p.MaxSegments > 0 && n > p.MaxSegments {
+		return fmt.Errorf("%w (%d, limit %d)", ErrTooManySegments, n, p.MaxSegments)
+	}
+	return nil
+}
+
+// approveCost fails fast when the server only spends callers' own OpenAI
+// keys and the request has none. Otherwise it estimates the audio the
+// strategies will transcribe, worst case, and asks ApproveCost. duration is
+// the video's length, or 0 to look it up. refund is never nil.
+func (p *Pipeline) approveCost(src media.VideoSource, req Request, duration float64, strategies []Strategy) (refund func(), err error) {
+	if p.ApproveCost == nil {
+		return p.approveCostSeconds(req, 0)
+	}
+	if duration <= 0 {
+		d, err := media.SourceDuration(src)
+		if err != nil {
+			log.Printf("cost estimate: %v", err)
+		}
+		duration = d
+	}
+	var seconds float64
+	for _, s := range strategies {
+		switch s {
+		case StrategyPartial:
+			if budget := req.BudgetMinutes * 60; budget > 0 && budget < duration {
+				seconds += budget
+			} else {
+				seconds += duration
+			}
+		case StrategyFull:
+			seconds += duration
+		}
+	}
+	return p.approveCostSeconds(req, seconds)
+}
+
+// approveCostSeconds is approveCost for a known amount of audio.
+func (p *Pipeline) approveCostSeconds(req Request, seconds float64) (refund func(), err error) {
+	if req.OpenAIKey == "" && oai.ClientKeyMode() == oai.ClientKeysRequire {
+		return func() {}, oai.ErrClientKeyRequired
+	}
+	if p.ApproveCost == nil {
+		return func() {}, nil
+	}
+	refund, err = p.ApproveCost(req, seconds)
+	if refund == nil {
+		refund = func() {}
+	}
+	return refund, err
+}
+
+func (p *Pipeline) onTranscript(videoURL, lang, source string, entries []subtitle.Entry) {
+	if p.OnTranscript != nil {
+		p.OnTranscript(videoURL, lang, source, entries)
+	}
+}
+
+// Search finds the first occurrence of the keyword. It reports the language
+// code used alongside the match: the caption track's, or the one Whisper
+// heard when the video was transcribed. Which of captions, partial and full
+// transcription are tried, and in what order, is up to the pipeline's Policy.
+// Cancelling ctx stops a download or transcription in progress.
+func (p *Pipeline) Search(ctx context.Context, req Request) (Match, bool, string, error) {
+	videoURL := req.VideoURL
+
+	langCode := NormalizeLang(req.Language)
+	matcher := req.Matcher(langCode)
+
+	done, err := p.acquireRun(ctx)
+	if err != nil {
+		return Match{}, false, langCode, err
+	}
+	defer done()
+
+	dl, err := p.Downloader.With(req.DownloadOptions)
+	if err != nil {
+		return Match{}, false, langCode, err
+	}
+	src, err := media.ResolveSource(dl, videoURL)
+	if err != nil {
+		return Match{}, false, langCode, err
+	}
+
+	track, hasSubs, subsErr := subtitle.FetchTrack(dl, src, videoURL, langCode)
+	if subtitle.Fatal(subsErr) {
+		return Match{}, false, langCode, subsErr
+	}
+
+	policy := p.policy()
+	facts := Facts{Captions: hasSubs, BudgetMinutes: req.BudgetMinutes}
+	if policy.NeedsDuration(facts) {
+		if d, err := media.SourceDuration(src); err == nil {
+			facts.Duration = d
+		} else {
+			log.Printf("policy: %v", err)
+		}
+	}
+	plan := windowPlan(policy.Plan(facts), req.Window)
+	if len(plan) == 0 {
+		err := fmt.Errorf("%w (captions: %t, duration: %.0fs, budget: %g min)", ErrNoStrategy, facts.Captions, facts.Duration, facts.BudgetMinutes)
+		if !hasSubs {
+			err = fmt.Errorf("%w: %w", subtitle.ErrNoSubtitles, err)
+		}
+		return Match{}, false, langCode, err
+	}
+
+	release := func() {}
+	defer func() { release() }()
+	acquired := false
+
+	var last Match
+	for i, strategy := range plan {
+		if strategy != StrategyCaptions && !acquired {
+			duration := facts.Duration
+			if req.Window != nil {
+				duration = req.Window.End - req.Window.Start
+			}
+			refund, err := p.approveCost(src, req, duration, plan[i:])
+			if err != nil {
+				return Match{}, false, langCode, err
+			}
+			defer refund()
+			r, err := p.acquireTranscription(ctx)
+			if err != nil {
+				return Match{}, false, langCode, err
+			}
+			release, acquired = r, true
+		}
+
+		var m Match
+		var found bool
+		switch strategy {
+		case StrategyCaptions:
+			if subsErr != nil {
+				return Match{}, false, langCode, fmt.Errorf("failed to read SRT file: %w", subsErr)
+			}
+			m, found, err = p.searchCaptions(videoURL, langCode, track, matcher, req.Window)
+		case StrategyPartial:
+			// Fast path: transcribe chunk by chunk and return early on first match
+			opts := AudioSearchOptions{Progress: req.Progress, BudgetSeconds: req.BudgetMinutes * 60, CacheKey: chunkCacheKey(req), Window: req.Window}
+			if order, _ := ParseOrder(req.Order, req.Hint); order == OrderPriority {
+				opts.Signals = p.chunkSignals(dl, src, req, track, hasSubs)
+			}
+			m, found, err = p.flow().SearchAudio(req.transcribeContext(ctx), src, matcher, opts)
+			if err != nil && ctx.Err() != nil {
+				return Match{}, false, langCode, ctx.Err()
+			}
+			if err != nil {
+				log.Printf("early chunked transcription failed: %v", err)
+				continue
+			}
+		case StrategyFull:
+			m, found, err = p.searchFullTranscript(ctx, videoURL, langCode, src, matcher, req)
+		}
+		if err != nil {
+			return Match{}, false, langCode, err
+		}
+		policy.Record(strategy, found)
+		if m.Language != "" {
+			langCode = m.Language
+		}
+		if found {
+			m.Partial = matcher.PartialWord(subtitle.Entry{Text: m.Text})
+			return m, true, langCode, nil
+		}
+		last = m
+	}
+	return last, false, langCode, nil
+}
+
+// chunkSignals gathers what is known cheaply about where the keyword is:
+// the caller's hint, the video's chapters and any captions fetched.
+func (p *Pipeline) chunkSignals(dl *media.Downloader, src media.VideoSource, req Request, track subtitle.Track, hasSubs bool) *ChunkSignals {
+	sig := &ChunkSignals{Hint: req.Hint}
+	if src.SupportsSubtitles() {
+		chapters, err := dl.Chapters(req.VideoURL)
+		if err != nil {
+			log.Printf("chunk order: %v", err)
+		}
+		sig.Chapters = chapters
+	}
+	if hasSubs {
+		if subs, err := track.Entries(); err == nil {
+			TagLanguages(subs, track.Lang)
+			sig.Captions = subs
+		}
+	}
+	return sig
+}
+
+// trackLanguage is the language a caption track is indexed under: regional
+// tracks count as the requested language, the original-language track as
+// what it is.
+func trackLanguage(track subtitle.Track, langCode string) string {
+	if track.Variant == subtitle.VariantAutoOriginal {
+		return NormalizeLang(strings.TrimSuffix(track.Lang, "-orig"))
+	}
+	return langCode
+}
+
+// searchCaptions searches a fetched SRT caption track, inside window when
+// one is set. The whole track is still indexed.
+func (p *Pipeline) searchCaptions(videoURL, langCode string, track subtitle.Track, matcher *Matcher, window *TimeRange) (Match, bool, error) {
+	subs, err := track.Entries()
+	if err != nil {
+		return Match{}, false, fmt.Errorf("failed to parse %s subtitles: %w", strings.ToUpper(track.Format), err)
+	}
+	if err := p.checkSegments(len(subs)); err != nil {
+		return Match{}, false, err
+	}
+	lang := trackLanguage(track, langCode)
+	TagLanguages(subs, lang)
+	p.onTranscript(videoURL, lang, track.Source, subs)
+
+	quality := TranscriptQuality(subs, track.Source)
+	if sub, ok := FindInSubtitles(InWindow(subs, window), matcher); ok {
+		m := Match{Start: sub.Start, End: sub.End, Text: sub.Text, Source: track.Source, Quality: &quality, CaptionVariant: track.Variant}
+		m.Previous, m.Next = SegmentNeighbors(subs, sub)
+		return m, true, nil
+	}
+	return Match{Quality: &quality, CaptionVariant: track.Variant}, false, nil
+}
+
+// searchFullTranscript transcribes the whole video and searches the transcript.
+func (p *Pipeline) searchFullTranscript(ctx context.Context, videoURL, langCode string, src media.VideoSource, matcher *Matcher, req Request) (Match, bool, error) {
+	transcriptFile, err := p.transcriptFile(ctx, src, req)
+	if err != nil {
+		return Match{}, false, fmt.Errorf("%w: %w", transcribe.ErrTranscriptionFailed, err)
+	}
+	// Clean up transcript file
+	defer os.Remove(transcriptFile)
+
+	transcriptContent, err := artifact.Read(transcriptFile)
+	if err != nil {
+		return Match{}, false, fmt.Errorf("failed to read transcript file: %w", err)
+	}
+	var quality *Quality
+	var coverage *Coverage
+	var heard string
+	if t, err := transcribe.ReadFile(transcriptFile); err == nil {
+		coverage = transcriptCoverage(t)
+		if t.Language != "" {
+			langCode, heard = t.Language, t.Language
+		}
+		entries := transcribe.Entries(t)
+		if err := p.checkSegments(len(entries)); err != nil {
+			return Match{}, false, err
+		}
+		TagLanguages(entries, langCode)
+		p.onTranscript(videoURL, langCode, SourceTranscriptJSON, entries)
+		q := TranscriptQuality(entries, SourceTranscriptJSON)
+		quality = &q
+	}
+
+	// Check if it's a JSON file
+	if strings.HasSuffix(transcriptFile, ".json") {
+		if m, ok, err := InTranscriptFile(transcriptFile, matcher); err == nil && ok {
+			if m.Estimated {
+				q := TranscriptQuality(nil, SourceEstimate)
+				quality = &q
+			}
+			m.Quality = quality
+			m.Language = heard
+			return m, true, nil
+		} else if err != nil {
+			return Match{}, false, fmt.Errorf("failed to parse JSON transcript: %w", err)
+		}
+	} else {
+		// Search in plain text transcript
+		transcriptText := string(transcriptContent)
+		if matcher.Match(transcriptText) {
+			wordsBeforeKeyword := matcher.WordsBefore(transcriptText)
+			estimatedTime := float64(wordsBeforeKeyword) / 150.0 * 60.0 // Convert to seconds
+			quality := TranscriptQuality(nil, SourceEstimate)
+			return Match{Start: estimatedTime, End: estimatedTime, Source: SourceEstimate, Estimated: true, Quality: &quality}, true, nil
+		}
+	}
+	return Match{Quality: quality, Coverage: coverage, Language: heard}, false, nil
+}
+
+// LoadSegments returns every timed segment for a video: platform captions when
+// available, otherwise a full Whisper transcript. It also reports which source
+// was used and the language code, for transcripts the one Whisper heard. With req.Window only the segments inside it
+// are returned, and only the chunks overlapping it are transcribed.
+// Cancelling ctx stops a transcription in progress.
+func (p *Pipeline) LoadSegments(ctx context.Context, req Request) ([]subtitle.Entry, string, string, error) {
+	langCode := NormalizeLang(req.Language)
+
+	done, err := p.acquireRun(ctx)
+	if err != nil {
+		return nil, "", langCode, err
+	}
+	defer done()
+
+	dl, err := p.Downloader.With(req.DownloadOptions)
+	if err != nil {
+		return nil, "", langCode, err
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		return nil, "", langCode, err
+	}
+
+	track, ok, err := subtitle.FetchTrack(dl, src, req.VideoURL, langCode)
+	if subtitle.Fatal(err) {
+		return nil, "", langCode, err
+	}
+	if ok {
+		subs, err := track.Entries()
+		if err != nil {
+			return nil, "", langCode, fmt.Errorf("failed to parse %s subtitles: %w", strings.ToUpper(track.Format), err)
+		}
+		if err := p.checkSegments(len(subs)); err != nil {
+			return nil, "", langCode, err
+		}
+		lang := trackLanguage(track, langCode)
+		TagLanguages(subs, lang)
+		p.onTranscript(req.VideoURL, lang, track.Source, subs)
+		return InWindow(subs, req.Window), track.Source, lang, nil
+	}
+	if req.Window != nil {
+		entries, err := p.windowTranscript(ctx, src, req)
+		if err != nil {
+			return nil, "", langCode, err
+		}
+		if heard := heardLanguage(entries); heard != "" {
+			langCode = heard
+		}
+		TagLanguages(entries, langCode)
+		return entries, SourceChunkedTranscription, langCode, nil
+	}
+
+	entries, langCode, err := p.wholeTranscript(ctx, dl, src, req, langCode)
+	if err != nil {
+		return nil, "", langCode, err
+	}
+	p.onTranscript(req.VideoURL, langCode, SourceTranscriptJSON, entries)
+	return entries, SourceTranscriptJSON, langCode, nil
+}
+
+// wholeTranscript transcribes all of the video with Whisper, within the
+// cost and concurrency limits. It also returns the language Whisper heard,
+// or langCode when it didn't say.
+func (p *Pipeline) wholeTranscript(ctx context.Context, dl *media.Downloader, src media.VideoSource, req Request, langCode string) ([]subtitle.Entry, string, error) {
+	refund, err := p.approveCost(src, req, 0, []Strategy{StrategyFull})
+	if err != nil {
+		return nil, langCode, err
+	}
+	defer refund()
+	release, err := p.acquireTranscription(ctx)
+	if err != nil {
+		return nil, langCode, err
+	}
+	defer release()
+
+	transcriptFile, err := p.transcriptFile(ctx, src, req)
+	if err != nil {
+		return nil, langCode, fmt.Errorf("%w: %w", transcribe.ErrTranscriptionFailed, err)
+	}
+	defer os.Remove(transcriptFile)
+
+	transcript, err := transcribe.ReadFile(transcriptFile)
+	if err != nil {
+		return nil, langCode, err
+	}
+	if transcript.Language != "" {
+		langCode = transcript.Language
+	}
+	entries := transcribe.Entries(transcript)
+	if err := p.checkSegments(len(entries)); err != nil {
+		return nil, langCode, err
+	}
+	TagLanguages(entries, langCode)
+	return entries, langCode, nil
+}
+
+// transcriptFile transcribes the whole video into a transcript file the
+// caller removes. With a blob store (see artifact.SharedBlobStore) a
+// transcript another replica made of the same audio is used instead, and a
+// new one is shared there.
+func (p *Pipeline) transcriptFile(ctx context.Context, src media.VideoSource, req Request) (string, error) {
+	blobs := artifact.SharedBlobStore()
+	if blobs == nil {
+		return transcribe.ToFile(req.transcribeContext(ctx), src, req.Progress)
+	}
+	key := transcriptBlobKey(req)
+	path := workfile.Path("transcript_segments") + ".json"
+	found, err := blobs.Get(ctx, key, path)
+	if found {
+		log.Printf("transcript of %s fetched from the blob store", req.VideoURL)
+		return path, nil
+	}
+	if err != nil {
+		log.Printf("blob store: %v", err)
+	}
+	path, err = transcribe.ToFile(req.transcribeContext(ctx), src, req.Progress)
+	if err != nil {
+		return "", err
+	}
+	if err := blobs.Put(ctx, key, path); err != nil {
+		log.Printf("blob store: %v", err)
+	}
+	return path, nil
+}
+
+// transcriptBlobKey names a video's full transcript in the blob store: the
+// video, on videos with several, the audio track, and any Whisper options.
+func transcriptBlobKey(req Request) string {
+	video := media.YouTubeVideoID(req.VideoURL)
+	if video == "" {
+		sum := sha256.Sum256([]byte(strings.TrimSpace(req.VideoURL)))
+		video = hex.EncodeToString(sum[:10])
+	}
+	if req.AudioTrack != "" {
+		video += "." + url.PathEscape(req.AudioTrack)
+	}
+	if k := req.WhisperOptions.CacheKey(); k != "" {
+		video += ".w" + k
+	}
+	return "transcripts/" + video + ".json"
+}
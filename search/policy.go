@@ -0,0 +1,210 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Strategy is one way of answering a search, from cheapest to most expensive.
+type Strategy string
+
+const (
+	// StrategyCaptions searches platform captions; only viable when they exist
+	StrategyCaptions Strategy = "captions"
+	// StrategyPartial transcribes chunk by chunk and stops at the first match
+	StrategyPartial Strategy = "partial"
+	// StrategyFull transcribes the whole video
+	StrategyFull Strategy = "full"
+)
+
+// PolicyRule picks the strategies to try, in order, for videos matching its
+// conditions. Unset conditions match anything; duration conditions never
+// match a video whose length is unknown.
+type PolicyRule struct {
+	Name        string     `json:"name,omitempty"`
+	Captions    *bool      `json:"captions,omitempty"`
+	MinDuration float64    `json:"min_duration,omitempty"` // seconds
+	MaxDuration float64    `json:"max_duration,omitempty"` // seconds
+	Use         []Strategy `json:"use"`
+}
+
+// PolicyConfig is the policy as written in POLICY_FILE.
+type PolicyConfig struct {
+	// Rules are checked in order; the first match decides
+	Rules []PolicyRule `json:"rules"`
+	// MinHitRate drops a transcription strategy whose observed hit rate is
+	// below it, once it has been tried MinSamples times
+	MinHitRate float64 `json:"min_hit_rate,omitempty"`
+	MinSamples int     `json:"min_samples,omitempty"`
+}
+
+// DefaultPolicyConfig is the behaviour before policies existed: captions when
+// there are any, otherwise a partial transcription falling back to a full one.
+func DefaultPolicyConfig() PolicyConfig {
+	yes := true
+	return PolicyConfig{
+		Rules: []PolicyRule{
+			{Name: "captions", Captions: &yes, Use: []Strategy{StrategyCaptions}},
+			{Name: "transcribe", Use: []Strategy{StrategyPartial, StrategyFull}},
+		},
+		MinSamples: 20,
+	}
+}
+
+// Facts are what the policy knows about a request when it decides.
+type Facts struct {
+	Captions bool
+	// Duration is the video length in seconds, 0 when unknown
+	Duration float64
+	// BudgetMinutes caps the audio the caller lets us transcribe; 0 is unlimited
+	BudgetMinutes float64
+}
+
+// StrategyStats counts how often a strategy found the keyword.
+type StrategyStats struct {
+	Attempts int     `json:"attempts"`
+	Hits     int     `json:"hits"`
+	HitRate  float64 `json:"hit_rate"`
+}
+
+// Policy chooses strategies from its config and the hit rates it has observed.
+type Policy struct {
+	config PolicyConfig
+
+	mu    sync.Mutex
+	stats map[Strategy]*StrategyStats
+}
+
+// NewPolicy validates a config.
+func NewPolicy(cfg PolicyConfig) (*Policy, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("policy has no rules")
+	}
+	for i, r := range cfg.Rules {
+		if len(r.Use) == 0 {
+			return nil, fmt.Errorf("policy rule %d has no strategies", i)
+		}
+		for _, s := range r.Use {
+			switch s {
+			case StrategyCaptions, StrategyPartial, StrategyFull:
+			default:
+				return nil, fmt.Errorf("policy rule %d: unknown strategy %q", i, s)
+			}
+		}
+	}
+	return &Policy{config: cfg, stats: map[Strategy]*StrategyStats{}}, nil
+}
+
+// NewPolicyFromEnv loads POLICY_FILE (JSON PolicyConfig), or the default policy when unset.
+func NewPolicyFromEnv() (*Policy, error) {
+	path := os.Getenv("POLICY_FILE")
+	if path == "" {
+		return NewPolicy(DefaultPolicyConfig())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read POLICY_FILE: %w", err)
+	}
+	var cfg PolicyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse POLICY_FILE: %w", err)
+	}
+	return NewPolicy(cfg)
+}
+
+// Config returns the policy's rules.
+func (p *Policy) Config() PolicyConfig { return p.config }
+
+// NeedsDuration reports whether deciding for these facts requires the video length.
+func (p *Policy) NeedsDuration(f Facts) bool {
+	if f.BudgetMinutes > 0 {
+		return true
+	}
+	for _, r := range p.config.Rules {
+		if r.MinDuration > 0 || r.MaxDuration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan returns the strategies to try in order. It is empty when nothing viable
+// fits: no captions and full transcription over budget, or ruled out by hit rates.
+func (p *Policy) Plan(f Facts) []Strategy {
+	for _, r := range p.config.Rules {
+		if !r.matches(f) {
+			continue
+		}
+		var plan []Strategy
+		for _, s := range r.Use {
+			if p.viable(s, f) {
+				plan = append(plan, s)
+			}
+		}
+		return plan
+	}
+	return nil
+}
+
+func (r PolicyRule) matches(f Facts) bool {
+	if r.Captions != nil && *r.Captions != f.Captions {
+		return false
+	}
+	if (r.MinDuration > 0 || r.MaxDuration > 0) && f.Duration <= 0 {
+		return false
+	}
+	if r.MinDuration > 0 && f.Duration < r.MinDuration {
+		return false
+	}
+	if r.MaxDuration > 0 && f.Duration > r.MaxDuration {
+		return false
+	}
+	return true
+}
+
+func (p *Policy) viable(s Strategy, f Facts) bool {
+	if s == StrategyCaptions {
+		return f.Captions
+	}
+	// a full transcription can't stop early, so it must fit the budget; a
+	// partial run stops at the budget and reports what it covered
+	if s == StrategyFull && f.BudgetMinutes > 0 && f.Duration > 0 && f.Duration/60 > f.BudgetMinutes {
+		return false
+	}
+	if p.config.MinHitRate > 0 {
+		st := p.Stats()[s]
+		if st.Attempts >= p.config.MinSamples && st.HitRate < p.config.MinHitRate {
+			return false
+		}
+	}
+	return true
+}
+
+// Record notes whether a strategy found the keyword.
+func (p *Policy) Record(s Strategy, found bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.stats[s]
+	if st == nil {
+		st = &StrategyStats{}
+		p.stats[s] = st
+	}
+	st.Attempts++
+	if found {
+		st.Hits++
+	}
+	st.HitRate = float64(st.Hits) / float64(st.Attempts)
+}
+
+// Stats returns a copy of the observed hit rates.
+func (p *Policy) Stats() map[Strategy]StrategyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[Strategy]StrategyStats, len(p.stats))
+	for s, st := range p.stats {
+		out[s] = *st
+	}
+	return out
+}
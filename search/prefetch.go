@@ -0,0 +1,239 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"searchme/internal/env"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// PrefetchHints are what a client expects to ask about a video next, e.g.
+// from a UI's autocomplete. Every field is optional.
+type PrefetchHints struct {
+	// Keywords warm the chunks whose chapter titles mention them
+	Keywords []string `json:"keywords,omitempty"`
+	// Ranges warm the chunks overlapping them
+	Ranges []TimeRange `json:"ranges,omitempty"`
+}
+
+// PrefetchResult reports what a prefetch warmed.
+type PrefetchResult struct {
+	// Source is the captions indexed, or SourceChunkedTranscription when
+	// chunks were transcribed
+	Source string `json:"source"`
+	// Chunks are the chunks now cached; Transcribed of them were new
+	Chunks      []TimeRange `json:"chunks,omitempty"`
+	Transcribed int         `json:"transcribed"`
+}
+
+// Prefetch warms what a later Search for req's video will need, so it
+// returns without waiting. Captions, when the video has them, are loaded
+// and passed to OnTranscript for indexing; otherwise the audio is fetched
+// once and the chunks the hints point at, or the first few without hints,
+// are transcribed into the ChunkCache. PREFETCH_MAX_CHUNKS (default 4) caps
+// the chunks one prefetch transcribes. Cost is approved as for a search.
+func (p *Pipeline) Prefetch(ctx context.Context, req Request, hints PrefetchHints) (PrefetchResult, error) {
+	langCode := NormalizeLang(req.Language)
+
+	done, err := p.acquireRun(ctx)
+	if err != nil {
+		return PrefetchResult{}, err
+	}
+	defer done()
+
+	dl, err := p.Downloader.With(req.DownloadOptions)
+	if err != nil {
+		return PrefetchResult{}, err
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		return PrefetchResult{}, err
+	}
+
+	if track, ok, _ := subtitle.FetchTrack(dl, src, req.VideoURL, langCode); ok {
+		subs, err := track.Entries()
+		if err != nil {
+			return PrefetchResult{}, fmt.Errorf("failed to parse %s subtitles: %w", strings.ToUpper(track.Format), err)
+		}
+		if err := p.checkSegments(len(subs)); err != nil {
+			return PrefetchResult{}, err
+		}
+		lang := trackLanguage(track, langCode)
+		TagLanguages(subs, lang)
+		p.onTranscript(req.VideoURL, lang, track.Source, subs)
+		return PrefetchResult{Source: track.Source}, nil
+	}
+
+	f := p.flow()
+	if f.Cache == nil {
+		return PrefetchResult{}, fmt.Errorf("prefetch needs a chunk cache for videos without captions")
+	}
+	sig := ChunkSignals{}
+	if src.SupportsSubtitles() && len(hints.Keywords) > 0 {
+		chapters, err := dl.Chapters(req.VideoURL)
+		if err != nil {
+			log.Printf("prefetch: %v", err)
+		}
+		sig.Chapters = chapters
+	}
+	max := env.Int("PREFETCH_MAX_CHUNKS", 4)
+	choose := func(chunks []transcribe.Chunk) []transcribe.Chunk {
+		return prefetchChunks(chunks, hints, sig, langCode, max)
+	}
+
+	// the cost is approved for the most a prefetch may transcribe
+	var seconds float64
+	if max > 0 {
+		seconds = float64(max) * float64(dl.AudioSettings().ChunkSeconds)
+	}
+	refund, err := p.approveCostSeconds(req, seconds)
+	if err != nil {
+		return PrefetchResult{}, err
+	}
+	defer refund()
+	release, err := p.acquireTranscription(ctx)
+	if err != nil {
+		return PrefetchResult{}, err
+	}
+	defer release()
+
+	warmed, transcribed, err := f.WarmChunks(req.transcribeContext(ctx), src, chunkCacheKey(req), choose, req.Progress)
+	if err != nil {
+		return PrefetchResult{}, err
+	}
+	res := PrefetchResult{Source: SourceChunkedTranscription, Transcribed: transcribed}
+	for _, c := range warmed {
+		res.Chunks = append(res.Chunks, TimeRange{Start: c.Offset, End: c.Offset + c.Duration})
+	}
+	return res, nil
+}
+
+// prefetchChunks picks up to max chunks, in time order: those overlapping
+// the ranges, then for each keyword the chunk the signals favour most. With
+// neither, the first chunks are picked, where a sequential search starts.
+func prefetchChunks(chunks []transcribe.Chunk, hints PrefetchHints, sig ChunkSignals, lang string, max int) []transcribe.Chunk {
+	picked := map[int]bool{}
+	var order []int
+	pick := func(i int) {
+		if !picked[i] && len(order) < max {
+			picked[i] = true
+			order = append(order, i)
+		}
+	}
+	for _, r := range hints.Ranges {
+		for i, c := range chunks {
+			if overlaps(r.Start, r.End, c) {
+				pick(i)
+			}
+		}
+	}
+	for _, kw := range hints.Keywords {
+		if strings.TrimSpace(kw) == "" {
+			continue
+		}
+		scores := chunkScores(chunks, sig, NewMatcher(lang, kw))
+		best := -1
+		for i, s := range scores {
+			if s > 0 && (best < 0 || s > scores[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			pick(best)
+		}
+	}
+	if len(order) == 0 {
+		for i := range chunks {
+			pick(i)
+		}
+	}
+	sort.Ints(order)
+	out := make([]transcribe.Chunk, len(order))
+	for i, j := range order {
+		out[i] = chunks[j]
+	}
+	return out
+}
+
+// ChunkTranscript is one transcribed chunk.
+type ChunkTranscript struct {
+	transcribe.Chunk
+	Entries []subtitle.Entry
+}
+
+// WarmChunks downloads src's audio, lets choose pick among its chunks and
+// transcribes those the Cache doesn't hold yet under key. It returns the
+// chosen chunks, in time order, and how many were transcribed; chunks that
+// fail are logged and left out. It works without a Cache too, it just
+// keeps nothing.
+func (f *Flow) WarmChunks(ctx context.Context, src media.VideoSource, key string, choose func([]transcribe.Chunk) []transcribe.Chunk, progress transcribe.ProgressFunc) ([]ChunkTranscript, int, error) {
+	audio, err := f.Audio.DownloadAudio(ctx, src)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer audio.Remove()
+
+	chunksDir := workfile.Path("chunks_warm")
+	_ = os.RemoveAll(chunksDir)
+	if err := os.MkdirAll(chunksDir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create chunks dir: %w", err)
+	}
+	defer os.RemoveAll(chunksDir)
+
+	chunks, err := f.Segmenter.Segment(ctx, audio, chunksDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var todo []transcribe.Chunk
+	var warm []ChunkTranscript
+	for _, c := range choose(chunks) {
+		if entries, ok := f.Cache.Get(key, c); ok {
+			warm = append(warm, ChunkTranscript{c, entries})
+		} else {
+			todo = append(todo, c)
+		}
+	}
+	tracker := transcribe.NewProgressTracker("chunk transcription "+audio.Path, todo, progress)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, f.concurrency())
+	transcribed := 0
+	for _, c := range todo {
+		wg.Add(1)
+		go func(c transcribe.Chunk) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			entries, err := f.transcribeCached(ctx, key, c)
+			if err != nil {
+				log.Printf("transcription error on chunk %d: %v", c.Index, err)
+				return
+			}
+			tracker.ChunkDone(c)
+			mu.Lock()
+			warm = append(warm, ChunkTranscript{c, entries})
+			transcribed++
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(warm, func(i, j int) bool { return warm[i].Offset < warm[j].Offset })
+	return warm, transcribed, nil
+}
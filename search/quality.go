@@ -0,0 +1,132 @@
+package search
+
+import (
+	"math"
+	"strings"
+
+	"searchme/subtitle"
+)
+
+// Quality flags: signs that a transcript contains hallucinated or unreliable text
+const (
+	FlagRepetition       = "repetition"
+	FlagHighCompression  = "high_compression_ratio"
+	FlagNoSpeech         = "no_speech"
+	FlagEstimatedTimings = "estimated_timings"
+)
+
+// Thresholds after which Whisper output is usually hallucinated (the same
+// ones Whisper itself uses to retry a window at a higher temperature)
+const (
+	hallucinationCompressionRatio = 2.4
+	hallucinationNoSpeechProb     = 0.6
+	hallucinationLogprob          = -1.0
+	// identical consecutive segments from this many on are treated as a loop
+	hallucinationRepeats = 3
+)
+
+// Quality tells consumers how far to trust a transcript, and so the timestamps
+// derived from it. Score is between 0 and 1.
+type Quality struct {
+	Score float64  `json:"score"`
+	Flags []string `json:"flags,omitempty"`
+}
+
+// SpeechConfidence is how sure Whisper was of one transcribed segment.
+// Score is between 0 and 1: the mean token probability (exp of avg_logprob)
+// times the probability that the segment is speech at all, scaled down
+// further when the text compresses like a hallucinated loop. Matches under
+// about 0.3 are seldom really there.
+type SpeechConfidence struct {
+	Score            float64 `json:"score"`
+	AvgLogprob       float64 `json:"avg_logprob"`
+	NoSpeechProb     float64 `json:"no_speech_prob"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+}
+
+// EntryConfidence rates a Whisper segment; it is nil for segments without
+// decoder statistics, such as captions.
+func EntryConfidence(e subtitle.Entry) *SpeechConfidence {
+	if e.AvgLogprob == 0 && e.NoSpeechProb == 0 {
+		return nil
+	}
+	score := math.Exp(min(e.AvgLogprob, 0)) * (1 - e.NoSpeechProb)
+	if e.CompressionRatio > hallucinationCompressionRatio {
+		score *= hallucinationCompressionRatio / e.CompressionRatio
+	}
+	return &SpeechConfidence{
+		Score:            math.Round(score*1000) / 1000,
+		AvgLogprob:       e.AvgLogprob,
+		NoSpeechProb:     e.NoSpeechProb,
+		CompressionRatio: e.CompressionRatio,
+	}
+}
+
+// TranscriptQuality scores a transcript. Human captions start at 1 and
+// auto-generated ones lower; Whisper transcripts start at their mean token
+// probability (exp of avg_logprob). The share of segments flagged as likely
+// hallucinations is then taken off.
+func TranscriptQuality(entries []subtitle.Entry, source string) Quality {
+	var q Quality
+	switch source {
+	case SourceManualSubtitles, SourceUploadedSubtitles:
+		q.Score = 1
+	case SourceAutoSubtitles:
+		q.Score = 0.8
+	case SourceEstimate:
+		return Quality{Score: 0.3, Flags: []string{FlagEstimatedTimings}}
+	default:
+		var sum float64
+		var n int
+		for _, e := range entries {
+			if e.AvgLogprob != 0 {
+				sum += e.AvgLogprob
+				n++
+			}
+		}
+		if n == 0 {
+			// no decoder statistics, e.g. an uploaded transcript
+			q.Score = 0.7
+		} else {
+			q.Score = math.Exp(sum / float64(n))
+		}
+	}
+
+	addFlag := func(flag string) {
+		for _, f := range q.Flags {
+			if f == flag {
+				return
+			}
+		}
+		q.Flags = append(q.Flags, flag)
+	}
+	flagged, repeats := 0, 1
+	for i, e := range entries {
+		bad := false
+		if e.CompressionRatio > hallucinationCompressionRatio {
+			addFlag(FlagHighCompression)
+			bad = true
+		}
+		if e.NoSpeechProb > hallucinationNoSpeechProb && e.AvgLogprob < hallucinationLogprob {
+			addFlag(FlagNoSpeech)
+			bad = true
+		}
+		if i > 0 && strings.EqualFold(strings.TrimSpace(e.Text), strings.TrimSpace(entries[i-1].Text)) {
+			repeats++
+			if repeats >= hallucinationRepeats {
+				addFlag(FlagRepetition)
+				bad = true
+			}
+		} else {
+			repeats = 1
+		}
+		if bad {
+			flagged++
+		}
+	}
+	if len(entries) > 0 {
+		q.Score *= 1 - float64(flagged)/float64(len(entries))
+	}
+	q.Score = math.Round(q.Score*100) / 100
+	return q
+}
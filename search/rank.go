@@ -0,0 +1,109 @@
+package search
+
+import (
+	"math"
+	"sort"
+
+	"searchme/media"
+	"searchme/subtitle"
+)
+
+// partialWordWeight scales the score of a keyword found inside a longer word ("cat" in "category")
+const partialWordWeight = 0.5
+
+// RankedMatch is one occurrence in a ranked result list.
+type RankedMatch struct {
+	Time       string  `json:"time"`
+	Seconds    float64 `json:"seconds"`
+	EndSeconds float64 `json:"end_seconds"`
+	URL        string  `json:"url,omitempty"`
+	Text       string  `json:"text"`
+	Chapter    string  `json:"chapter,omitempty"`
+	// Language is the caption track the match is in, in a multi-language search
+	Language string `json:"language,omitempty"`
+	// Score is the relevance; higher is better
+	Score float64 `json:"score"`
+	// SpeechConfidence rates transcribed segments; see Response
+	SpeechConfidence *SpeechConfidence `json:"speech_confidence,omitempty"`
+	// PreviousSegment and NextSegment bound the neighbouring segments
+	PreviousSegment *TimeRange `json:"previous_segment,omitempty"`
+	NextSegment     *TimeRange `json:"next_segment,omitempty"`
+	// PreviewURL is a thumbnail or GIF of the moment, when requested
+	PreviewURL string `json:"preview_url,omitempty"`
+}
+
+// Score rates how relevant a segment is for the matcher's keyword, 0 when it
+// doesn't contain it. More occurrences score higher (logarithmically), partial
+// word matches score lower, and segments Whisper thinks are not speech are
+// discounted by their no_speech_prob.
+func Score(e subtitle.Entry, m *Matcher) float64 {
+	count, whole := m.Occurrences(e)
+	return occurrenceScore(count, whole, e.NoSpeechProb)
+}
+
+// occurrenceScore is Score for count occurrences in a segment or span.
+func occurrenceScore(count int, whole bool, noSpeechProb float64) float64 {
+	if count == 0 {
+		return 0
+	}
+	score := 1 + math.Log(float64(count))
+	if !whole {
+		score *= partialWordWeight
+	}
+	score *= 1 - noSpeechProb
+	return math.Round(score*1000) / 1000
+}
+
+// Rank returns up to limit segments containing the keyword, best first,
+// along with any occurrences split across segments (see FindInSubtitles).
+// Equal scores keep time order.
+func Rank(subs []subtitle.Entry, m *Matcher, videoURL string, limit int) []RankedMatch {
+	var ranked []RankedMatch
+	add := func(e subtitle.Entry, score float64, prev, next *TimeRange) {
+		ranked = append(ranked, RankedMatch{
+			Time:             FormatTime(e.Start),
+			Seconds:          e.Start,
+			EndSeconds:       e.End,
+			URL:              media.DeepLink(videoURL, e.Start),
+			Text:             e.Text,
+			Score:            score,
+			SpeechConfidence: EntryConfidence(e),
+			PreviousSegment:  prev,
+			NextSegment:      next,
+		})
+	}
+	for _, h := range m.Hits(subs) {
+		if !h.Spans {
+			prev, next := neighborsAt(subs, h.Index, h.Index)
+			add(h.Entry, Score(h.Entry, m), prev, next)
+			continue
+		}
+		prev, next := SegmentNeighbors(subs, h.Entry)
+		// one occurrence, scored on its segments' merged statistics; their
+		// own occurrences are ranked with them
+		add(h.Entry, occurrenceScore(1, h.Whole, h.NoSpeechProb), prev, next)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// InSegments answers a request from a full set of segments: the first
+// occurrence, plus a page of req.Limit ranked occurrences when a limit is set.
+func InSegments(req Request, subs []subtitle.Entry, source, lang string) Response {
+	subs = InWindow(subs, req.Window)
+	matcher := req.Matcher(lang)
+	sub, found := FindInSubtitles(subs, matcher)
+	quality := TranscriptQuality(subs, source)
+	match := Match{Start: sub.Start, End: sub.End, Text: sub.Text, Source: source, Quality: &quality, Partial: found && matcher.PartialWord(sub), Speech: EntryConfidence(sub)}
+	if found {
+		match.Previous, match.Next = SegmentNeighbors(subs, sub)
+	}
+	resp := NewResponse(req.VideoURL, match, found, lang)
+	if req.Limit > 0 && found {
+		resp.Matches, resp.Page = req.paginate(Rank(subs, matcher, req.VideoURL, 0))
+	}
+	return resp
+}
@@ -0,0 +1,227 @@
+// Package search finds keywords in videos: it matches against platform
+// captions when they exist and falls back to Whisper transcription.
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"searchme/langpack"
+	"searchme/media"
+	"searchme/subtitle"
+)
+
+// Where a match came from
+const (
+	SourceManualSubtitles      = subtitle.SourceManual
+	SourceAutoSubtitles        = subtitle.SourceAuto
+	SourceChunkedTranscription = "chunked_transcription"
+	SourceTranscriptJSON       = "transcript_json"
+	SourceEstimate             = "word_count_estimate"
+	SourceUploadedSubtitles    = "uploaded_subtitles"
+	SourceUploadedTranscript   = "uploaded_transcript"
+	SourceUploadedMedia        = "uploaded_media"
+	SourceIndex                = "index"
+	SourceLiveTranscription    = "live_transcription"
+)
+
+// Confidence indicators for match timestamps
+const (
+	ConfidenceExact     = "exact"
+	ConfidenceEstimated = "estimated"
+)
+
+// Match is a single keyword occurrence with the span of the segment it was found in.
+type Match struct {
+	Start  float64
+	End    float64
+	Text   string
+	Source string
+	// Estimated is set when the timestamp was derived from word counts rather than segment timing
+	Estimated bool
+	// Quality rates the transcript the match came from, when known
+	Quality *Quality
+	// Partial is set when the keyword was only found inside a longer word
+	Partial bool
+	// CaptionVariant names the caption variant the match came from, if any
+	CaptionVariant string
+	// Coverage is what a transcription search checked, when it found nothing
+	Coverage *Coverage
+	// Language is the language Whisper heard, when the answer came from
+	// transcription
+	Language string
+	// Speech rates the matching segment, when Whisper transcribed it
+	Speech *SpeechConfidence
+	// Previous and Next are the segments around the match, when known, so
+	// players and clips can extend the span to whole sentences
+	Previous, Next *TimeRange
+}
+
+// Confidence reports how trustworthy the match timestamp is.
+func (m Match) Confidence() string {
+	if m.Estimated {
+		return ConfidenceEstimated
+	}
+	return ConfidenceExact
+}
+
+// Response is a match rendered for API clients.
+type Response struct {
+	Found      bool    `json:"found"`
+	Time       string  `json:"time"`
+	Seconds    float64 `json:"seconds"`
+	EndSeconds float64 `json:"end_seconds"`
+	URL        string  `json:"url,omitempty"`
+	// PreviewURL is a thumbnail or GIF of the match, when requested
+	PreviewURL string `json:"preview_url,omitempty"`
+	Source     string `json:"source"`
+	Confidence string `json:"confidence,omitempty"`
+	Language   string `json:"language,omitempty"`
+	// Keyword and TranslatedKeyword are the keyword asked for and the
+	// translation matched in Language, when the keyword was translated
+	Keyword           string `json:"keyword,omitempty"`
+	TranslatedKeyword string `json:"translated_keyword,omitempty"`
+	// ServedBy is "cache" or "upstream" when the local pipeline did not run
+	ServedBy string `json:"served_by,omitempty"`
+	// ResultID is set when the result was published at /public/results/:id
+	ResultID string `json:"result_id,omitempty"`
+	// Matches are the top occurrences by relevance when the request set a
+	// limit, one page of them at a time
+	Matches []RankedMatch `json:"matches,omitempty"`
+	// Page places Matches in the full ranked list
+	Page *Page `json:"page,omitempty"`
+	// Quality rates the transcript behind the answer, so consumers know how
+	// far to trust an "exact" timestamp
+	Quality *Quality `json:"quality,omitempty"`
+	// Partial is set when the keyword was only found inside a longer word
+	Partial bool `json:"partial_word,omitempty"`
+	// CaptionVariant is the caption variant that answered, e.g. "auto_region"
+	CaptionVariant string `json:"caption_variant,omitempty"`
+	// Chapter is the title of the video chapter containing the match
+	Chapter string `json:"chapter,omitempty"`
+	// Score estimates the probability that the match is right, calibrated
+	// with user feedback on similar matches
+	Score float64 `json:"score,omitempty"`
+	// SpeechConfidence is how sure Whisper was of the matching segment, when
+	// it was transcribed; low scores are often hallucinations
+	SpeechConfidence *SpeechConfidence `json:"speech_confidence,omitempty"`
+	// PreviousSegment and NextSegment bound the segments before and after
+	// the match (Seconds to EndSeconds), when known
+	PreviousSegment *TimeRange `json:"previous_segment,omitempty"`
+	NextSegment     *TimeRange `json:"next_segment,omitempty"`
+	// Coverage lists the time ranges transcribed when Whisper found nothing;
+	// a not-found with incomplete coverage doesn't mean the keyword is absent
+	Coverage *Coverage `json:"coverage,omitempty"`
+	// Voice is the answer phrased for voice assistants, when requested
+	Voice *VoiceAnswer `json:"voice,omitempty"`
+	// Languages reports each caption track's hits in a multi-language search
+	Languages []LanguageHit `json:"languages,omitempty"`
+	// CommunityHints are moments commenters mention that match the keyword,
+	// when the transcript has no match and the request asked for them
+	CommunityHints []CommunityHint `json:"community_hints,omitempty"`
+}
+
+// NewResponse renders a match for API clients.
+func NewResponse(videoURL string, match Match, found bool, usedLang string) Response {
+	resp := Response{
+		Found:    found,
+		Time:     "",
+		Language: usedLang,
+		Quality:  match.Quality,
+	}
+	if found {
+		resp.Source = match.Source
+		resp.Confidence = match.Confidence()
+		resp.Partial = match.Partial
+		resp.CaptionVariant = match.CaptionVariant
+		resp.Time = FormatTime(match.Start)
+		resp.Seconds = match.Start
+		resp.EndSeconds = match.End
+		resp.URL = media.DeepLink(videoURL, match.Start)
+		resp.SpeechConfidence = match.Speech
+		resp.PreviousSegment, resp.NextSegment = match.Previous, match.Next
+	} else {
+		resp.Coverage = match.Coverage
+	}
+	return resp
+}
+
+// Helper to format seconds as HH:MM:SS
+func FormatTime(seconds float64) string {
+	totalSeconds := int(seconds + 0.5) // round to nearest second
+	h := totalSeconds / 3600
+	m := (totalSeconds % 3600) / 60
+	s := totalSeconds % 60
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
+
+// FindInSubtitles returns the first entry containing the matcher's keyword.
+// A keyword of several words split across segments is found too: the entry
+// returned then spans those segments and starts at the keyword's first word
+// (see Matcher.Tokens).
+func FindInSubtitles(subtitles []subtitle.Entry, m *Matcher) (subtitle.Entry, bool) {
+	for i, sub := range subtitles {
+		if m.MatchEntry(sub) {
+			return sub, true
+		}
+		if span, _, ok := m.spanning(subtitles[i:]); ok {
+			return span, true
+		}
+	}
+	return subtitle.Entry{}, false
+}
+
+// SegmentNeighbors are the spans of the segments before and after sub in
+// entries; either is nil when sub is first or last, both when sub isn't
+// one of entries. For an entry spanning several segments, as
+// FindInSubtitles may return, they are the segments around all of them.
+func SegmentNeighbors(entries []subtitle.Entry, sub subtitle.Entry) (prev, next *TimeRange) {
+	for i, e := range entries {
+		if e.Start == sub.Start && e.Text == sub.Text {
+			return neighborsAt(entries, i, i)
+		}
+	}
+	for i, e := range entries {
+		if e.Start > sub.Start || sub.Start >= e.End {
+			continue
+		}
+		for j := i; j < len(entries); j++ {
+			if entries[j].End == sub.End {
+				return neighborsAt(entries, i, j)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// neighborsAt are the spans of the segments before entries[i] and after
+// entries[j].
+func neighborsAt(entries []subtitle.Entry, i, j int) (prev, next *TimeRange) {
+	if i > 0 {
+		prev = &TimeRange{Start: entries[i-1].Start, End: entries[i-1].End}
+	}
+	if j+1 < len(entries) {
+		next = &TimeRange{Start: entries[j+1].Start, End: entries[j+1].End}
+	}
+	return prev, next
+}
+
+// TagLanguages detects each segment's language (see langpack.Detect) and
+// records it on segments that differ from the track language, so matching
+// applies the right normalization rules segment by segment.
+func TagLanguages(entries []subtitle.Entry, trackLang string) {
+	for i := range entries {
+		if lang := langpack.Detect(entries[i].Text, trackLang); lang != trackLang {
+			entries[i].Lang = lang
+		}
+	}
+}
+
+// NormalizeLang lowercases the requested language; default to en
+func NormalizeLang(lang string) string {
+	langCode := strings.ToLower(strings.TrimSpace(lang))
+	if langCode == "" {
+		langCode = "en"
+	}
+	return langCode
+}
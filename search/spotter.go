@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"log"
+	"sync"
+	"unicode/utf8"
+
+	"searchme/internal/env"
+	"searchme/transcribe"
+)
+
+// DraftSpotter spots the keyword in rough local transcripts of the chunks
+// (see transcribe.WhisperCpp). Small models mishear, so a chunk is
+// promising when its draft says the keyword or words close to each of its
+// words.
+type DraftSpotter struct {
+	Drafter *transcribe.WhisperCpp
+	// Concurrency is how many chunks are drafted at once; 0 reads
+	// SPOTTER_CONCURRENCY (default 2)
+	Concurrency int
+}
+
+// spotterFromEnv is a DraftSpotter when WHISPER_CPP_MODEL is set, else nil.
+func spotterFromEnv() Spotter {
+	if d := transcribe.WhisperCppFromEnv(); d != nil {
+		return DraftSpotter{Drafter: d}
+	}
+	return nil
+}
+
+func (s DraftSpotter) Spot(ctx context.Context, chunks []transcribe.Chunk, m *Matcher) ([]bool, error) {
+	drafts := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = env.Int("SPOTTER_CONCURRENCY", 2)
+	}
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, c := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			drafts[i], errs[i] = s.Drafter.Draft(ctx, c, "")
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	promising := make([]bool, len(chunks))
+	for i, draft := range drafts {
+		if errs[i] != nil {
+			// nothing is known about it, so it isn't passed over
+			log.Printf("keyword spotting: %v", errs[i])
+			promising[i] = true
+			continue
+		}
+		promising[i] = m.Match(draft) || nearMatch(m, draft)
+	}
+	return promising, nil
+}
+
+// nearMatch reports whether every word of the keyword is within a typo of
+// some word of text: one edit for words of five letters or more, none for
+// shorter ones.
+func nearMatch(m *Matcher, text string) bool {
+	if len(m.words) == 0 {
+		return false
+	}
+	words := m.textWords(m.Normalize(text))
+	for _, k := range m.words {
+		allowed := 0
+		if utf8.RuneCountInString(k) >= 5 {
+			allowed = 1
+		}
+		found := false
+		for _, w := range words {
+			if editDistance(k, w, allowed) <= allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// editDistance is the Levenshtein distance between a and b, or limit+1 as
+// soon as it must be more than limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
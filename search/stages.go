@@ -0,0 +1,520 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"searchme/events"
+	"searchme/internal/env"
+	"searchme/internal/flight"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// The pipeline's stages. A Flow strings them together; library users can
+// swap any of them, e.g. inject their own audio or transcription backend.
+
+// SubtitleFetcher loads platform captions. ok is false when the video has
+// none in the language.
+type SubtitleFetcher interface {
+	FetchSubtitles(ctx context.Context, src media.VideoSource, videoURL, lang string) (entries []subtitle.Entry, source string, ok bool, err error)
+}
+
+// AudioDownloader makes a source's audio available as a local file.
+type AudioDownloader interface {
+	DownloadAudio(ctx context.Context, src media.VideoSource) (*media.AudioFile, error)
+}
+
+// Segmenter splits audio into chunks inside dir.
+type Segmenter interface {
+	Segment(ctx context.Context, audio *media.AudioFile, dir string) ([]transcribe.Chunk, error)
+}
+
+// Transcriber turns one chunk into entries with absolute timestamps.
+type Transcriber interface {
+	Transcribe(ctx context.Context, chunk transcribe.Chunk) ([]subtitle.Entry, error)
+}
+
+// Searcher picks the matching entry, if any.
+type Searcher interface {
+	Find(entries []subtitle.Entry, m *Matcher) (subtitle.Entry, bool)
+}
+
+// Spotter cheaply guesses which chunks say the keyword, before any are
+// sent to the transcription API. promising is indexed alongside chunks.
+type Spotter interface {
+	Spot(ctx context.Context, chunks []transcribe.Chunk, m *Matcher) (promising []bool, err error)
+}
+
+// CaptionFetcher fetches captions with yt-dlp.
+type CaptionFetcher struct {
+	Downloader *media.Downloader
+}
+
+func (f CaptionFetcher) FetchSubtitles(ctx context.Context, src media.VideoSource, videoURL, lang string) ([]subtitle.Entry, string, bool, error) {
+	track, ok, err := subtitle.FetchTrack(f.Downloader, src, videoURL, lang)
+	if !ok {
+		return nil, "", false, err
+	}
+	subs, err := track.Entries()
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to parse %s subtitles: %w", strings.ToUpper(track.Format), err)
+	}
+	TagLanguages(subs, lang)
+	return subs, track.Source, true, nil
+}
+
+// SourceAudio downloads audio from the video source.
+type SourceAudio struct{}
+
+func (SourceAudio) DownloadAudio(ctx context.Context, src media.VideoSource) (*media.AudioFile, error) {
+	return src.DownloadAudio(ctx)
+}
+
+// LocalAudio skips downloading and uses a file the caller already has.
+// The file is never deleted.
+type LocalAudio struct {
+	Path string
+}
+
+func (a LocalAudio) DownloadAudio(ctx context.Context, src media.VideoSource) (*media.AudioFile, error) {
+	if _, err := os.Stat(a.Path); err != nil {
+		return nil, err
+	}
+	return &media.AudioFile{Path: a.Path}, nil
+}
+
+// FFmpegSegmenter cuts audio into fixed-length chunks with ffmpeg.
+type FFmpegSegmenter struct {
+	// ChunkSeconds defaults to the audio's chunk length (see media.AudioSettings)
+	ChunkSeconds int
+}
+
+func (s FFmpegSegmenter) Segment(ctx context.Context, audio *media.AudioFile, dir string) ([]transcribe.Chunk, error) {
+	return transcribe.Split(ctx, audio, dir, s.ChunkSeconds)
+}
+
+// WhisperTranscriber transcribes chunks with the OpenAI Whisper API, billing
+// the caller's key in ctx if there is one and using the options ctx carries
+// (see transcribe.WithOptions). Entries are tagged with the language heard
+// and redacted like whole transcripts (see transcribe.NewRedactorFromEnv).
+type WhisperTranscriber struct{}
+
+func (WhisperTranscriber) Transcribe(ctx context.Context, chunk transcribe.Chunk) ([]subtitle.Entry, error) {
+	w, err := transcribe.NewWhisper(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := w.Chunk(ctx, chunk, false)
+	if err != nil {
+		return nil, err
+	}
+	if redactor := w.Redactor(); redactor != nil {
+		redactor.RedactTranscript(ctx, &t)
+	}
+	entries := transcribe.Entries(t)
+	for i := range entries {
+		entries[i].Lang = t.Language
+	}
+	return entries, nil
+}
+
+// FirstMatch returns the earliest matching entry.
+type FirstMatch struct{}
+
+func (FirstMatch) Find(entries []subtitle.Entry, m *Matcher) (subtitle.Entry, bool) {
+	return FindInSubtitles(entries, m)
+}
+
+// Flow runs a search through its stages: captions first when the source has
+// them, then audio transcribed chunk by chunk, stopping at the first chunk
+// with a match.
+type Flow struct {
+	// Subtitles may be nil to always transcribe
+	Subtitles   SubtitleFetcher
+	Audio       AudioDownloader
+	Segmenter   Segmenter
+	Transcriber Transcriber
+	Searcher    Searcher
+	// Concurrency is how many chunks the audio search transcribes at once;
+	// 0 reads EARLY_TRANSCRIBE_CONCURRENCY (default 3)
+	Concurrency int
+	// Cache, which may be nil, keeps transcribed chunks for searches with a
+	// CacheKey
+	Cache *ChunkCache
+	// Spotter, which may be nil, picks the chunks the audio search sends
+	// first; the rest are only sent when none of those match
+	Spotter Spotter
+}
+
+// NewFlow returns the default stages: yt-dlp captions, the source's audio,
+// 5-minute ffmpeg chunks and Whisper, spotting the keyword first with
+// whisper.cpp when WHISPER_CPP_MODEL is set.
+func NewFlow(dl *media.Downloader) *Flow {
+	return &Flow{
+		Subtitles:   CaptionFetcher{Downloader: dl},
+		Audio:       SourceAudio{},
+		Segmenter:   FFmpegSegmenter{},
+		Transcriber: WhisperTranscriber{},
+		Searcher:    FirstMatch{},
+		Spotter:     spotterFromEnv(),
+	}
+}
+
+// Run searches src for req.Keyword.
+func (f *Flow) Run(ctx context.Context, src media.VideoSource, req Request) (Match, bool, error) {
+	langCode := NormalizeLang(req.Language)
+	matcher := req.Matcher(langCode)
+
+	if f.Subtitles != nil && src.SupportsSubtitles() {
+		subs, source, ok, err := f.Subtitles.FetchSubtitles(ctx, src, req.VideoURL, langCode)
+		if ok {
+			quality := TranscriptQuality(subs, source)
+			if sub, found := f.Searcher.Find(subs, matcher); found {
+				m := Match{Start: sub.Start, End: sub.End, Text: sub.Text, Source: source, Quality: &quality}
+				m.Previous, m.Next = SegmentNeighbors(subs, sub)
+				return m, true, nil
+			}
+			return Match{Quality: &quality}, false, nil
+		}
+		if subtitle.Fatal(err) {
+			return Match{}, false, err
+		}
+		if err != nil {
+			log.Printf("subtitle stage failed, transcribing instead: %v", err)
+		}
+	}
+	opts := AudioSearchOptions{Progress: req.Progress, BudgetSeconds: req.BudgetMinutes * 60}
+	if order, _ := ParseOrder(req.Order, req.Hint); order == OrderPriority {
+		opts.Signals = &ChunkSignals{Hint: req.Hint}
+	}
+	return f.SearchAudio(ctx, src, matcher, opts)
+}
+
+// AudioSearchOptions tune Flow.SearchAudio.
+type AudioSearchOptions struct {
+	// Progress, which may be nil, is costed for the whole audio, so its ETA
+	// is the worst case
+	Progress transcribe.ProgressFunc
+	// Signals, when set, reorders the chunks with PrioritizeChunks
+	Signals *ChunkSignals
+	// BudgetSeconds, when positive, caps the audio transcribed; chunks past
+	// it (in search order) are skipped and reported in the match's Coverage
+	BudgetSeconds float64
+	// CacheKey names the audio in the Flow's Cache; empty doesn't cache
+	CacheKey string
+	// Window, when set, limits the search to the chunks overlapping it and
+	// the segments inside it
+	Window *TimeRange
+}
+
+// SearchAudio runs only the audio stages. Up to Concurrency chunks are
+// transcribed at once, but results are checked in chunk order: the first
+// chunk containing the keyword wins once every earlier chunk is known not to,
+// and the requests still in flight are cancelled. With opts.Signals that
+// order is the priority order rather than the video's; a Flow's Spotter
+// moves the chunks it finds promising to the front and holds back the rest
+// until those have all been searched. A chunk that fails to transcribe is
+// logged and skipped; when every chunk fails the search fails with
+// transcribe.ErrTranscriptionFailed. When nothing is found the match
+// carries the Coverage of what was transcribed. Chunks in the Flow's Cache are not
+// transcribed again, and a match among them that is known to be the winner
+// is returned before anything is downloaded.
+func (f *Flow) SearchAudio(ctx context.Context, src media.VideoSource, matcher *Matcher, opts AudioSearchOptions) (Match, bool, error) {
+	if opts.Window == nil {
+		if sub, ok := f.Cache.cachedMatch(opts.CacheKey, matcher, f.Searcher, opts.Signals != nil); ok {
+			quality := TranscriptQuality([]subtitle.Entry{sub}, SourceChunkedTranscription)
+			return Match{Start: sub.Start, End: sub.End, Text: sub.Text, Source: SourceChunkedTranscription, Quality: &quality, Language: sub.Lang, Speech: EntryConfidence(sub)}, true, nil
+		}
+	}
+	audio, err := f.Audio.DownloadAudio(ctx, src)
+	if err != nil {
+		return Match{}, false, err
+	}
+	defer audio.Remove()
+
+	chunksDir := workfile.Path("chunks_early")
+	_ = os.RemoveAll(chunksDir)
+	if err := os.MkdirAll(chunksDir, 0755); err != nil {
+		return Match{}, false, fmt.Errorf("failed to create chunks dir: %w", err)
+	}
+	defer os.RemoveAll(chunksDir)
+
+	chunks, err := f.Segmenter.Segment(ctx, audio, chunksDir)
+	if err != nil {
+		return Match{}, false, err
+	}
+	chunks = chunksInWindow(chunks, opts.Window)
+	if opts.Signals != nil {
+		chunks = PrioritizeChunks(chunks, *opts.Signals, matcher)
+	}
+	// with a Spotter, the promising chunks go first and alone (see gate)
+	gate := -1
+	if f.Spotter != nil && len(chunks) > 1 {
+		var promising int
+		chunks, promising = f.spot(ctx, chunks, matcher)
+		if promising > 0 && promising < len(chunks) {
+			gate = promising
+		}
+	}
+	// chunks past the budget are never sent
+	budgeted := len(chunks)
+	if opts.BudgetSeconds > 0 {
+		var spent float64
+		for i, c := range chunks {
+			if spent+c.Duration > opts.BudgetSeconds {
+				budgeted = i
+				break
+			}
+			spent += c.Duration
+		}
+		if budgeted < len(chunks) {
+			log.Printf("early transcription: budget of %.0fs covers %d of %d chunks", opts.BudgetSeconds, budgeted, len(chunks))
+		}
+	}
+	tracker := transcribe.NewProgressTracker("early transcription "+audio.Path, chunks[:budgeted], opts.Progress)
+
+	type outcome struct {
+		index   int
+		entries []subtitle.Entry
+		err     error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	// workers must be gone before the chunk files are removed
+	defer wg.Wait()
+	defer cancel()
+
+	indexes := make(chan int)
+	outcomes := make(chan outcome)
+	// exhaustive is closed once the promising chunks came up empty
+	exhaustive := make(chan struct{})
+	go func() {
+		defer close(indexes)
+		for i := range chunks[:budgeted] {
+			if i == gate {
+				select {
+				case <-exhaustive:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < f.concurrency(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				entries, err := f.transcribeCached(ctx, opts.CacheKey, chunks[i])
+				select {
+				case outcomes <- outcome{i, entries, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	done := make([]*outcome, len(chunks))
+	next := 0
+	widened := false
+	for next < budgeted {
+		var o outcome
+		select {
+		case o = <-outcomes:
+		case <-ctx.Done():
+			return Match{}, false, ctx.Err()
+		}
+		done[o.index] = &o
+		chunk := chunks[o.index]
+		if o.err != nil {
+			log.Printf("transcription error on chunk %d: %v", chunk.Index, o.err)
+		} else {
+			tracker.ChunkDone(chunk)
+			events.Emit(events.ChunkTranscribed, "", map[string]interface{}{"chunk": chunk.Index, "chunks": len(chunks), "offset": chunk.Offset})
+		}
+
+		// settle chunks in order so a later chunk can't win over an earlier one
+		// (or a lower priority one over a higher)
+		for ; next < budgeted && done[next] != nil; next++ {
+			if done[next].err != nil {
+				continue
+			}
+			entries := InWindow(done[next].entries, opts.Window)
+			// a phrase cut by the chunk boundary is found once both chunks
+			// are settled; the one before starts earlier than anything here
+			var (
+				sub   subtitle.Entry
+				ok    bool
+				known []subtitle.Entry
+			)
+			if p := adjacentChunk(chunks, next, -1); p >= 0 && p < next && done[p].err == nil {
+				before := InWindow(done[p].entries, opts.Window)
+				sub, ok = acrossCut(before, entries, matcher)
+				known = append(append([]subtitle.Entry(nil), before...), entries...)
+			}
+			if !ok {
+				sub, ok = f.Searcher.Find(entries, matcher)
+				known = entries
+			}
+			if q := adjacentChunk(chunks, next, 1); !ok && q >= 0 && q < next && done[q].err == nil {
+				after := InWindow(done[q].entries, opts.Window)
+				sub, ok = acrossCut(entries, after, matcher)
+				known = append(append([]subtitle.Entry(nil), entries...), after...)
+			}
+			if ok {
+				// only the matching segment is known, so rate just that one
+				quality := TranscriptQuality([]subtitle.Entry{sub}, SourceChunkedTranscription)
+				m := Match{Start: sub.Start, End: sub.End, Text: sub.Text, Source: SourceChunkedTranscription, Quality: &quality, Language: sub.Lang, Speech: EntryConfidence(sub)}
+				// neighbours in chunks not settled yet aren't known
+				m.Previous, m.Next = SegmentNeighbors(known, sub)
+				return m, true, nil
+			}
+		}
+		if next == gate && !widened {
+			log.Printf("keyword spotting: none of the %d promising chunks matched, transcribing the rest", gate)
+			close(exhaustive)
+			widened = true
+		}
+	}
+
+	states := make([]int, len(chunks))
+	failed := 0
+	var lastErr error
+	var heard []subtitle.Entry
+	for i := range chunks {
+		switch {
+		case i >= budgeted:
+			states[i] = chunkSkipped
+		case done[i].err != nil:
+			states[i] = chunkFailed
+			failed, lastErr = failed+1, done[i].err
+		default:
+			heard = append(heard, done[i].entries...)
+		}
+	}
+	if budgeted > 0 && failed == budgeted {
+		// nothing was heard, so not finding the keyword would be a guess
+		return Match{}, false, fmt.Errorf("%w: all %d chunks failed, last: %w", transcribe.ErrTranscriptionFailed, failed, lastErr)
+	}
+	return Match{Coverage: chunkCoverage(chunks, states), Language: heardLanguage(heard)}, false, nil
+}
+
+// adjacentChunk is the index of the chunk right before (dir -1) or after
+// (dir 1) chunks[i] in time, -1 when there is none. Prioritizing and
+// keyword spotting reorder chunks, so it isn't simply i+dir.
+func adjacentChunk(chunks []transcribe.Chunk, i, dir int) int {
+	for j, c := range chunks {
+		var gap float64
+		if dir < 0 {
+			gap = chunks[i].Offset - (c.Offset + c.Duration)
+		} else {
+			gap = c.Offset - (chunks[i].Offset + chunks[i].Duration)
+		}
+		if j != i && math.Abs(gap) < 0.01 {
+			return j
+		}
+	}
+	return -1
+}
+
+// acrossCut looks for the keyword said across the cut between two chunks
+// following each other, from the end of before into after.
+func acrossCut(before, after []subtitle.Entry, m *Matcher) (subtitle.Entry, bool) {
+	if len(before) == 0 || len(after) == 0 {
+		return subtitle.Entry{}, false
+	}
+	// a phrase of n words starts at most n-1 segments before the cut
+	last := before[len(before)-1]
+	from := max(0, len(before)-len(m.forLang(last.Lang).words)+1)
+	run := append(append([]subtitle.Entry(nil), before[from:]...), after...)
+	for i := range before[from:] {
+		if span, _, ok := m.spanning(run[i:]); ok && span.End > last.End {
+			return span, true
+		}
+	}
+	return subtitle.Entry{}, false
+}
+
+// heardLanguage is the language tagged on most of the entries' time, or ""
+// when none is tagged.
+func heardLanguage(entries []subtitle.Entry) string {
+	seconds := map[string]float64{}
+	best := ""
+	for _, e := range entries {
+		if e.Lang == "" {
+			continue
+		}
+		seconds[e.Lang] += e.End - e.Start
+		if best == "" || seconds[e.Lang] > seconds[best] {
+			best = e.Lang
+		}
+	}
+	return best
+}
+
+// spot puts the chunks the Spotter finds promising first, each group in
+// its order, and counts them. A Spotter that fails leaves the order alone.
+func (f *Flow) spot(ctx context.Context, chunks []transcribe.Chunk, m *Matcher) ([]transcribe.Chunk, int) {
+	promising, err := f.Spotter.Spot(ctx, chunks, m)
+	if err != nil || len(promising) != len(chunks) {
+		log.Printf("keyword spotting skipped: %v", err)
+		return chunks, 0
+	}
+	first := make([]transcribe.Chunk, 0, len(chunks))
+	var rest []transcribe.Chunk
+	for i, c := range chunks {
+		if promising[i] {
+			first = append(first, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	log.Printf("keyword spotting: %d of %d chunks look promising", len(first), len(chunks))
+	return append(first, rest...), len(first)
+}
+
+// transcribeCached transcribes a chunk unless the Cache has it under key.
+// Searches transcribing the same chunk at the same time share one request.
+func (f *Flow) transcribeCached(ctx context.Context, key string, chunk transcribe.Chunk) ([]subtitle.Entry, error) {
+	if entries, ok := f.Cache.Get(key, chunk); ok {
+		return entries, nil
+	}
+	if f.Cache == nil || key == "" {
+		return f.Transcriber.Transcribe(ctx, chunk)
+	}
+	k := keyFor(key, chunk)
+	entries, _, err := chunkTranscriptions.Do(ctx, fmt.Sprintf("%s\x00%d\x00%d", k.video, k.offset, k.duration), func(ctx context.Context) ([]subtitle.Entry, error) {
+		entries, err := f.Transcriber.Transcribe(ctx, chunk)
+		if err == nil {
+			f.Cache.Put(key, chunk, entries)
+		}
+		return entries, err
+	})
+	// the entries are shared, and callers tag them in place
+	return append([]subtitle.Entry(nil), entries...), err
+}
+
+// chunkTranscriptions coalesces concurrent transcriptions of the same chunk.
+var chunkTranscriptions flight.Group[[]subtitle.Entry]
+
+// concurrency is how many chunks SearchAudio transcribes at once.
+func (f *Flow) concurrency() int {
+	if f.Concurrency > 0 {
+		return f.Concurrency
+	}
+	return env.Int("EARLY_TRANSCRIBE_CONCURRENCY", 3)
+}
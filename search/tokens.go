@@ -0,0 +1,173 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"searchme/langpack"
+	"searchme/subtitle"
+)
+
+// maxPhraseGap is the longest pause, in seconds, between two segments a
+// phrase may run across; past it they are taken as separate sentences.
+const maxPhraseGap = 3.0
+
+// Token is one word of a transcript. Start and End are interpolated within
+// its segment by where the word sits in the segment's text, since segments
+// carry no word timings.
+type Token struct {
+	Word  string
+	Start float64
+	End   float64
+	// Segment is the index of the token's segment
+	Segment int
+}
+
+// Tokens splits entries into one stream of words, normalized (and stemmed)
+// like the matcher's keyword, so phrases can be matched across segments.
+func (m *Matcher) Tokens(entries []subtitle.Entry) []Token {
+	var tokens []Token
+	for i, e := range entries {
+		text := m.Normalize(e.Text)
+		if text == "" {
+			continue
+		}
+		perByte := (e.End - e.Start) / float64(len(text))
+		start := -1
+		for j := 0; j <= len(text); {
+			r, size := utf8.RuneError, 1
+			if j < len(text) {
+				r, size = utf8.DecodeRuneInString(text[j:])
+			}
+			if j == len(text) || langpack.IsWordSeparator(r) {
+				if start >= 0 {
+					word := text[start:j]
+					if m.stem {
+						word = m.pack.Stem(word)
+					}
+					tokens = append(tokens, Token{Word: word, Start: e.Start + float64(start)*perByte, End: e.Start + float64(j)*perByte, Segment: i})
+					start = -1
+				}
+			} else if start < 0 {
+				start = j
+			}
+			j += size
+		}
+	}
+	return tokens
+}
+
+// spanning looks for the keyword running from entries[0] into the segments
+// after it, e.g. "machine" ending one cue and "learning" starting the next.
+// The match starts at the keyword's first token and ends with the segment
+// holding its last one; its text is that of every segment it spans, and its
+// decoder statistics theirs merged (see mergeStats). Words must match whole,
+// except in substring mode, where the first may end a longer word and the
+// last may start one, as in a single segment; whole reports whether they do.
+func (m *Matcher) spanning(entries []subtitle.Entry) (span subtitle.Entry, whole, ok bool) {
+	if len(entries) < 2 {
+		return subtitle.Entry{}, false, false
+	}
+	mm := m.forLang(entries[0].Lang)
+	words := mm.words
+	if len(words) < 2 {
+		return subtitle.Entry{}, false, false
+	}
+	// a phrase of n words spans at most n segments, with no long pause
+	n := 1
+	for n < len(entries) && n < len(words) && entries[n].Start-entries[n-1].End <= maxPhraseGap {
+		n++
+	}
+	if n < 2 {
+		return subtitle.Entry{}, false, false
+	}
+	tokens := mm.Tokens(entries[:n])
+	first := 0
+	for first < len(tokens) && tokens[first].Segment == 0 {
+		first++
+	}
+	loose := mm.mode == MatchSubstring && !mm.stem
+	for s := max(0, first-len(words)+1); s < first && s+len(words) <= len(tokens); s++ {
+		run := tokens[s : s+len(words)]
+		if !mm.tokensMatch(run, words, loose) {
+			continue
+		}
+		last := run[len(run)-1].Segment
+		texts := make([]string, 0, last+1)
+		for _, e := range entries[:last+1] {
+			texts = append(texts, strings.TrimSpace(e.Text))
+		}
+		span = subtitle.Entry{
+			Start: run[0].Start,
+			End:   entries[last].End,
+			Text:  strings.Join(texts, " "),
+			Lang:  entries[0].Lang,
+		}
+		mergeStats(&span, entries[:last+1])
+		whole = run[0].Word == words[0] && run[len(run)-1].Word == words[len(words)-1]
+		return span, whole, true
+	}
+	return subtitle.Entry{}, false, false
+}
+
+// mergeStats gives a span the decoder statistics of the segments it covers:
+// their no_speech_prob and avg_logprob weighted by duration, and the worst
+// compression ratio. Captions have none, and neither has the span.
+func mergeStats(span *subtitle.Entry, segments []subtitle.Entry) {
+	var total, logprob, noSpeech float64
+	for _, e := range segments {
+		d := max(e.End-e.Start, 0.01)
+		total += d
+		logprob += e.AvgLogprob * d
+		noSpeech += e.NoSpeechProb * d
+		span.CompressionRatio = max(span.CompressionRatio, e.CompressionRatio)
+	}
+	if total > 0 {
+		span.AvgLogprob, span.NoSpeechProb = logprob/total, noSpeech/total
+	}
+}
+
+// Hit is a segment containing the keyword, or an occurrence split across
+// the segments from Index on (see spanning).
+type Hit struct {
+	subtitle.Entry
+	// Index is the segment the hit is, or starts in
+	Index int
+	// Spans is set for an occurrence split across segments
+	Spans bool
+	// Whole is false when the keyword was only found inside longer words
+	Whole bool
+}
+
+// Hits lists the segments of entries containing the keyword and the
+// occurrences split across them, in time order; a segment's own hit comes
+// before one starting in it. Matching paths that report every occurrence
+// use it so a phrase cut by a segment boundary isn't missed.
+func (m *Matcher) Hits(entries []subtitle.Entry) []Hit {
+	var hits []Hit
+	for i, e := range entries {
+		if count, whole := m.Occurrences(e); count > 0 {
+			hits = append(hits, Hit{Entry: e, Index: i, Whole: whole})
+		}
+		if span, whole, ok := m.spanning(entries[i:]); ok {
+			hits = append(hits, Hit{Entry: span, Index: i, Spans: true, Whole: whole})
+		}
+	}
+	return hits
+}
+
+// tokensMatch compares a run of tokens with the keyword's words; loose lets
+// the first token end with its word and the last start with its word.
+func (m *Matcher) tokensMatch(tokens []Token, words []string, loose bool) bool {
+	for k, w := range words {
+		t := tokens[k].Word
+		switch {
+		case t == w:
+		case loose && k == 0 && strings.HasSuffix(t, w):
+		case loose && k == len(words)-1 && strings.HasPrefix(t, w):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"searchme/langpack"
+	"searchme/subtitle"
+)
+
+// topicWindowSeconds splits a transcript into the stretches keyphrases are
+// weighed across: a phrase said often in few of them is a topic, one said
+// all along is background
+const topicWindowSeconds = 60
+
+// topicGapSeconds is how far apart two mentions of a topic may be and still
+// belong to one range
+const topicGapSeconds = 60
+
+// topicMaxWords bounds candidate keyphrases
+const topicMaxWords = 3
+
+// Topic is a keyphrase of a transcript and where it is discussed.
+type Topic struct {
+	Phrase string  `json:"phrase"`
+	Score  float64 `json:"score"`
+	// Count is how many segments mention it
+	Count  int         `json:"count"`
+	Ranges []TimeRange `json:"ranges"`
+}
+
+// topicCandidate gathers one keyphrase's mentions.
+type topicCandidate struct {
+	phrase  string
+	words   []string
+	windows map[int]bool
+	spans   []TimeRange
+}
+
+// ExtractTopics returns up to limit keyphrases of the transcript, best
+// first. Candidates are runs of up to three content words between stopwords,
+// as in RAKE, compared by their stems. Each is scored by TF-IDF over
+// minute-long windows, favouring longer phrases; phrases repeating a
+// better one are dropped. Each topic's mentions are merged into time ranges.
+func ExtractTopics(segments []subtitle.Entry, lang string, limit int) []Topic {
+	pack := langpack.For(lang)
+	candidates := map[string]*topicCandidate{}
+	windows := map[int]bool{}
+	for _, s := range segments {
+		window := int(s.Start / topicWindowSeconds)
+		windows[window] = true
+		seen := map[string]bool{}
+		var run []string
+		flush := func() {
+			for n := 1; n <= topicMaxWords; n++ {
+				for i := 0; i+n <= len(run); i++ {
+					words := run[i : i+n]
+					stems := make([]string, n)
+					for j, w := range words {
+						stems[j] = pack.Stem(w)
+					}
+					key := strings.Join(stems, " ")
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					c := candidates[key]
+					if c == nil {
+						c = &topicCandidate{phrase: strings.Join(words, " "), words: stems, windows: map[int]bool{}}
+						candidates[key] = c
+					}
+					c.windows[window] = true
+					c.spans = append(c.spans, TimeRange{Start: s.Start, End: s.End})
+				}
+			}
+			run = run[:0]
+		}
+		for _, w := range strings.FieldsFunc(langpack.NormalizeText(pack, s.Text), langpack.IsWordSeparator) {
+			if pack.IsStopword(w) || len([]rune(w)) < 3 || isNumber(w) {
+				flush()
+				continue
+			}
+			run = append(run, w)
+		}
+		flush()
+	}
+
+	type scored struct {
+		c     *topicCandidate
+		score float64
+	}
+	var ranked []scored
+	for _, c := range candidates {
+		if len(c.spans) < 2 {
+			continue
+		}
+		idf := math.Log(1 + float64(len(windows))/float64(len(c.windows)))
+		score := float64(len(c.spans)) * idf * (1 + 0.5*float64(len(c.words)-1))
+		ranked = append(ranked, scored{c, score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].c.phrase < ranked[j].c.phrase
+	})
+
+	var out []Topic
+	var kept []*topicCandidate
+	for _, r := range ranked {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		if overlapsTopic(r.c, kept) {
+			continue
+		}
+		kept = append(kept, r.c)
+		out = append(out, Topic{
+			Phrase: r.c.phrase,
+			Score:  math.Round(r.score*100) / 100,
+			Count:  len(r.c.spans),
+			Ranges: TopicRanges(r.c.spans),
+		})
+	}
+	return out
+}
+
+// TopicRanges merges mentions less than a minute apart into ranges, in time
+// order.
+func TopicRanges(spans []TimeRange) []TimeRange {
+	spans = append([]TimeRange(nil), spans...)
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	var out []TimeRange
+	for _, s := range spans {
+		if n := len(out); n > 0 && s.Start-out[n-1].End <= topicGapSeconds {
+			out[n-1].End = max(out[n-1].End, s.End)
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// overlapsTopic reports whether c repeats a kept topic: one phrase is
+// contained in the other, or they share a word and c is only ever said
+// where the kept one is, as "learning models" is within "machine learning
+// models".
+func overlapsTopic(c *topicCandidate, kept []*topicCandidate) bool {
+	for _, k := range kept {
+		if phraseCount(k.words, c.words) > 0 || phraseCount(c.words, k.words) > 0 {
+			return true
+		}
+		if sharesWord(c.words, k.words) && withinSpans(c.spans, k.spans) {
+			return true
+		}
+	}
+	return false
+}
+
+func sharesWord(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withinSpans reports whether every span of a is also one of b.
+func withinSpans(a, b []TimeRange) bool {
+	in := map[TimeRange]bool{}
+	for _, s := range b {
+		in[s] = true
+	}
+	for _, s := range a {
+		if !in[s] {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumber(w string) bool {
+	for _, r := range w {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"searchme/artifact"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// InTranscriptFile searches a stored (possibly encrypted) transcript artifact.
+func InTranscriptFile(filePath string, matcher *Matcher) (Match, bool, error) {
+	data, err := artifact.Read(filePath)
+	if err != nil {
+		return Match{}, false, err
+	}
+	return InTranscriptJSON(data, matcher)
+}
+
+// InTranscriptJSON streams a Whisper verbose JSON transcript and returns the first matching segment.
+func InTranscriptJSON(data []byte, matcher *Matcher) (Match, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var fullText string
+
+	// Expect a JSON object at the top level
+	tok, err := dec.Token()
+	if err != nil {
+		return Match{}, false, err
+	}
+	if _, ok := tok.(json.Delim); !ok {
+		return Match{}, false, fmt.Errorf("invalid JSON transcript format")
+	}
+
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return Match{}, false, err
+		}
+		key, _ := keyTok.(string)
+		switch key {
+		case "segments":
+			// Start of segments array
+			if _, err := dec.Token(); err != nil { // should be '['
+				return Match{}, false, err
+			}
+			// the last segments read, enough for a phrase split across
+			// them and the segment before it
+			var recent []subtitle.Entry
+			for dec.More() {
+				var seg transcribe.Segment
+				if err := dec.Decode(&seg); err != nil {
+					return Match{}, false, err
+				}
+				cur := subtitle.Entry{Start: seg.Start, End: seg.End, Text: seg.Text,
+					AvgLogprob: seg.AvgLogprob, NoSpeechProb: seg.NoSpeechProb, CompressionRatio: seg.CompressionRatio}
+				recent = append(recent, cur)
+				if len(recent) > len(matcher.words)+1 {
+					recent = recent[1:]
+				}
+				found, from := subtitle.Entry{}, -1
+				for j := 0; j < len(recent)-1 && from < 0; j++ {
+					if span, _, ok := matcher.spanning(recent[j:]); ok {
+						found, from = span, j
+					}
+				}
+				if from < 0 && matcher.Match(seg.Text) {
+					found, from = cur, len(recent)-1
+				}
+				if from < 0 {
+					continue
+				}
+				m := Match{Start: found.Start, End: found.End, Text: found.Text, Source: SourceTranscriptJSON, Speech: EntryConfidence(found)}
+				if from > 0 {
+					m.Previous = &TimeRange{Start: recent[from-1].Start, End: recent[from-1].End}
+				}
+				var next transcribe.Segment
+				if dec.More() && dec.Decode(&next) == nil {
+					m.Next = &TimeRange{Start: next.Start, End: next.End}
+				}
+				return m, true, nil
+			}
+			// consume closing ']'
+			if _, err := dec.Token(); err != nil {
+				return Match{}, false, err
+			}
+		default:
+			// For other fields, we may want the top-level "text" for fallback
+			if key == "text" {
+				var v string
+				if err := dec.Decode(&v); err != nil {
+					return Match{}, false, err
+				}
+				fullText = v
+				continue
+			}
+			// Skip value for keys we're not using
+			var skip interface{}
+			if err := dec.Decode(&skip); err != nil {
+				return Match{}, false, err
+			}
+		}
+	}
+
+	// Fallback: search in full text if available
+	if fullText != "" && matcher.Match(fullText) {
+		wordsBeforeKeyword := matcher.WordsBefore(fullText)
+		estimatedTime := float64(wordsBeforeKeyword) / 150.0 * 60.0
+		return Match{Start: estimatedTime, End: estimatedTime, Source: SourceEstimate, Estimated: true}, true, nil
+	}
+
+	return Match{}, false, nil
+}
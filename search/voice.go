@@ -0,0 +1,122 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// VoiceAnswer is a result phrased for voice assistants: one spoken-style
+// sentence and the same sentence as SSML. Answers are in English.
+type VoiceAnswer struct {
+	Text string `json:"text"`
+	SSML string `json:"ssml"`
+}
+
+// NewVoiceAnswer phrases resp as a single sentence about keyword, e.g.
+// "The word "deploy" appears at twelve minutes and four seconds."
+func NewVoiceAnswer(keyword string, resp Response) VoiceAnswer {
+	keyword = strings.TrimSpace(keyword)
+	noun := "The word"
+	if strings.IndexFunc(keyword, unicode.IsSpace) >= 0 {
+		noun = "The phrase"
+	}
+
+	var tail string
+	switch {
+	case resp.Found:
+		at := "at " + SpokenDuration(resp.Seconds)
+		if resp.Seconds < 1 {
+			at = "at the very beginning"
+		}
+		if resp.Confidence == ConfidenceEstimated {
+			at = "at about " + SpokenDuration(resp.Seconds)
+		}
+		tail = "appears " + at
+		if resp.Chapter != "" {
+			tail += fmt.Sprintf(", in the chapter %q", resp.Chapter)
+		}
+	case resp.Coverage != nil && !resp.Coverage.Complete:
+		tail = fmt.Sprintf("wasn't found in the %s percent of the video I listened to", spokenNumber(int(math.Round(resp.Coverage.Fraction*100))))
+	default:
+		tail = "doesn't appear in this video"
+	}
+
+	text := fmt.Sprintf("%s %q %s.", noun, keyword, tail)
+	var ssml strings.Builder
+	ssml.WriteString("<speak>")
+	ssml.WriteString(noun)
+	ssml.WriteString(` <emphasis level="moderate">`)
+	ssml.WriteString(escapeSSML(keyword))
+	ssml.WriteString("</emphasis> ")
+	ssml.WriteString(escapeSSML(strings.ReplaceAll(tail, `"`, "")))
+	ssml.WriteString(".</speak>")
+	return VoiceAnswer{Text: text, SSML: ssml.String()}
+}
+
+// SpokenDuration reads seconds the way a person would say them:
+// 724 is "twelve minutes and four seconds".
+func SpokenDuration(seconds float64) string {
+	total := int(math.Round(seconds))
+	h, m, s := total/3600, total%3600/60, total%60
+	var parts []string
+	for _, p := range []struct {
+		n    int
+		unit string
+	}{{h, "hour"}, {m, "minute"}, {s, "second"}} {
+		if p.n == 0 {
+			continue
+		}
+		part := spokenNumber(p.n) + " " + p.unit
+		if p.n != 1 {
+			part += "s"
+		}
+		parts = append(parts, part)
+	}
+	switch len(parts) {
+	case 0:
+		return "zero seconds"
+	case 1:
+		return parts[0]
+	default:
+		return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+}
+
+var (
+	numberOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	numberTens = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+)
+
+// spokenNumber spells out a non-negative integer below a million.
+func spokenNumber(n int) string {
+	switch {
+	case n < 0 || n >= 1000000:
+		return fmt.Sprint(n)
+	case n < 20:
+		return numberOnes[n]
+	case n < 100:
+		if n%10 == 0 {
+			return numberTens[n/10]
+		}
+		return numberTens[n/10] + "-" + numberOnes[n%10]
+	case n < 1000:
+		if n%100 == 0 {
+			return numberOnes[n/100] + " hundred"
+		}
+		return numberOnes[n/100] + " hundred and " + spokenNumber(n%100)
+	default:
+		if n%1000 == 0 {
+			return spokenNumber(n/1000) + " thousand"
+		}
+		return spokenNumber(n/1000) + " thousand " + spokenNumber(n%1000)
+	}
+}
+
+// ssmlEscaper escapes element text; SSML is only ever built with text here,
+// never attribute values
+var ssmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeSSML(s string) string { return ssmlEscaper.Replace(s) }
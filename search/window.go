@@ -0,0 +1,88 @@
+package search
+
+import (
+	"context"
+
+	"searchme/media"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// InWindow keeps the segments that start inside w; a nil w keeps them all.
+func InWindow(subs []subtitle.Entry, w *TimeRange) []subtitle.Entry {
+	if w == nil {
+		return subs
+	}
+	var out []subtitle.Entry
+	for _, e := range subs {
+		if e.Start >= w.Start && e.Start < w.End {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// chunksInWindow keeps the chunks overlapping w; a nil w keeps them all.
+func chunksInWindow(chunks []transcribe.Chunk, w *TimeRange) []transcribe.Chunk {
+	if w == nil {
+		return chunks
+	}
+	var out []transcribe.Chunk
+	for _, c := range chunks {
+		if c.Offset < w.End && c.Offset+c.Duration > w.Start {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// windowPlan swaps a full transcription for a chunked one when the search
+// is limited to a window: chunked transcription can skip the rest of the
+// video, a full one can't.
+func windowPlan(plan []Strategy, w *TimeRange) []Strategy {
+	if w == nil {
+		return plan
+	}
+	var out []Strategy
+	seen := map[Strategy]bool{}
+	for _, s := range plan {
+		if s == StrategyFull {
+			s = StrategyPartial
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// windowTranscript transcribes the chunks overlapping req.Window, sharing
+// them with the ChunkCache, and returns the segments inside it.
+func (p *Pipeline) windowTranscript(ctx context.Context, src media.VideoSource, req Request) ([]subtitle.Entry, error) {
+	refund, err := p.approveCostSeconds(req, req.Window.End-req.Window.Start)
+	if err != nil {
+		return nil, err
+	}
+	defer refund()
+	release, err := p.acquireTranscription(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	choose := func(chunks []transcribe.Chunk) []transcribe.Chunk { return chunksInWindow(chunks, req.Window) }
+	warm, _, err := p.flow().WarmChunks(req.transcribeContext(ctx), src, chunkCacheKey(req), choose, req.Progress)
+	if err != nil {
+		return nil, err
+	}
+	var entries []subtitle.Entry
+	for _, ct := range warm {
+		entries = append(entries, ct.Entries...)
+	}
+	entries = InWindow(entries, req.Window)
+	if err := p.checkSegments(len(entries)); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"crypto/sha256"
+	"net/http/pprof"
+	"strings"
+	"sync"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+)
+
+// AdminAuth guards operator-only routes with X-Admin-Key. Admin keys come
+// from ADMIN_API_KEYS (comma separated) and are separate from client API
+// keys; with none configured the admin routes answer 404.
+type AdminAuth struct {
+	mu   sync.RWMutex
+	keys map[[32]byte]bool
+}
+
+func NewAdminAuthFromEnv() *AdminAuth {
+	a := &AdminAuth{}
+	a.ReloadFromEnv()
+	return a
+}
+
+// ReloadFromEnv re-reads ADMIN_API_KEYS.
+func (a *AdminAuth) ReloadFromEnv() {
+	keys := map[[32]byte]bool{}
+	for _, k := range env.List("ADMIN_API_KEYS") {
+		keys[sha256.Sum256([]byte(k))] = true
+	}
+	a.mu.Lock()
+	a.keys = keys
+	a.mu.Unlock()
+}
+
+// Middleware rejects requests without a valid X-Admin-Key.
+func (a *AdminAuth) Middleware() web.HandlerFunc {
+	return func(c *web.Context) {
+		a.mu.RLock()
+		keys := a.keys
+		a.mu.RUnlock()
+		if len(keys) == 0 {
+			c.AbortWithStatusJSON(404, ErrorResponse{Error: "admin endpoints are disabled (set ADMIN_API_KEYS)"})
+			return
+		}
+		key := c.GetHeader("X-Admin-Key")
+		if key == "" || !keys[sha256.Sum256([]byte(key))] {
+			c.AbortWithStatusJSON(401, ErrorResponse{Error: "missing or invalid admin key"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// pprofHandler serves net/http/pprof under /debug/pprof/.
+func pprofHandler(c *web.Context) {
+	switch strings.TrimPrefix(c.Param("path"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// the index also serves named profiles such as heap and goroutine
+		pprof.Index(c.Writer, c.Request)
+	}
+}
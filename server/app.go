@@ -0,0 +1,194 @@
+// Package server exposes the search pipeline and its companion features
+// (library, meetings, lectures, exports) as a Gin HTTP API.
+package server
+
+import (
+	"log"
+
+	"searchme/internal/config"
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/transcribe"
+)
+
+// App
+type App struct {
+	cfg        config.Config
+	downloader *media.Downloader
+	pipeline   *search.Pipeline
+	store      store.TranscriptStore
+	jobs       *JobManager
+	limiter    *Limiter
+	auth       *APIKeyAuth
+	admin      *AdminAuth
+	guard      *Guard
+	costs      *CostGuard
+	health     *healthChecker
+	upstream   *Upstream
+	results    ResultStore
+	calibrator *search.Calibrator
+	chapters   *chapterCache
+	warmup     *warmup
+	live       *liveWatches
+}
+
+// NewApp wires the application from cfg and the environment settings it
+// doesn't cover.
+func NewApp(cfg config.Config) *App {
+	workfile.SetDir(cfg.WorkDir)
+	st := openStore(cfg.IndexDB)
+	policy, err := search.NewPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("invalid search policy: %v", err)
+	}
+	downloader, err := media.NewDownloaderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := transcribe.Warm(); err != nil {
+		log.Printf("Whisper transcription unavailable: %v", err)
+	}
+	app := &App{
+		cfg:        cfg,
+		downloader: downloader,
+		store:      st,
+		jobs:       NewJobManager(openJobBackend(cfg)),
+		limiter:    NewLimiter(limitConfigFromEnv()),
+		auth:       NewAPIKeyAuthFromEnv(),
+		admin:      NewAdminAuthFromEnv(),
+		guard:      NewGuard(guardConfigFromEnv()),
+		costs:      NewCostGuard(costConfigFromEnv()),
+		upstream:   NewUpstreamFromEnv(),
+		results:    newResultStore(st),
+		calibrator: loadCalibrator(st),
+		chapters:   newChapterCache(),
+		warmup:     newWarmup(),
+		live:       newLiveWatches(liveConfigFromEnv()),
+	}
+	app.health = newHealthChecker(app.downloader)
+	app.pipeline = &search.Pipeline{
+		Downloader:           app.downloader,
+		AcquireTranscription: app.limiter.AcquireTranscription,
+		OnTranscript:         app.indexTranscript,
+		Policy:               policy,
+		AcquireRun:           app.guard.AcquirePipeline,
+		MaxSegments:          app.guard.cfg.MaxSegments,
+		ApproveCost:          app.costs.approve,
+		ChunkCache:           search.NewChunkCache(env.Int("CHUNK_CACHE_SIZE", 64)),
+	}
+	return app
+}
+
+// newEngine is an engine that takes client addresses from forwarding
+// headers only when the peer is one of TRUSTED_PROXIES.
+func newEngine() *web.Engine {
+	r := web.New()
+	if err := r.SetTrustedProxies(env.List("TRUSTED_PROXIES")); err != nil {
+		log.Printf("TRUSTED_PROXIES: %v; trusting no proxy", err)
+		_ = r.SetTrustedProxies(nil)
+	}
+	return r
+}
+
+// Router builds the HTTP routes.
+func (app *App) Router() *web.Engine {
+	r := newEngine()
+	r.GET("/", func(ctx *web.Context) {
+		ctx.String(200, "Hello World!")
+	})
+	r.GET("/public/results/:id", app.publicResultHandler)
+	r.GET("/public/transcripts/:videoID", app.signedTranscriptHandler)
+	r.GET("/public/previews/:name", app.previewHandler)
+	r.GET("/healthz", app.healthzHandler)
+	r.GET("/readyz", app.readyzHandler)
+	r.GET("/openapi.json", openAPIHandler)
+	r.GET("/docs", docsHandler)
+
+	// Operator-only diagnostics, behind ADMIN_API_KEYS
+	debug := r.Group("/debug", app.admin.Middleware())
+	debug.GET("/pprof/*path", pprofHandler)
+	debug.POST("/pprof/*path", pprofHandler)
+	debug.GET("/guard", app.guardHandler)
+	debug.GET("/costs", app.costHandler)
+	debug.GET("/workdir", workDirHandler)
+	debug.POST("/reload", app.reloadHandler)
+	debug.POST("/captions/resync", app.resyncHandler)
+
+	// Cache inspection and invalidation, also behind ADMIN_API_KEYS
+	cache := r.Group("/api/admin/cache", app.admin.Middleware())
+	cache.GET("", app.cacheHandler)
+	cache.DELETE("/:videoID", app.purgeCacheHandler)
+
+	api := r.Group("/api", app.auth.Middleware(), app.warmup.Middleware())
+	api.GET("/usage", app.usageHandler)
+	api.GET("/index/search", app.indexSearchHandler)
+	api.GET("/index/videos", app.indexVideosHandler)
+	api.POST("/library/search", app.librarySearchHandler)
+	api.GET("/jobs/:id", app.jobStatusHandler)
+	api.POST("/jobs/:id/cancel", app.jobCancelHandler)
+	api.GET("/transcripts/:videoID", app.transcriptHandler)
+	api.GET("/comments/:videoID", app.commentMarkersHandler)
+	api.GET("/policy", app.policyHandler)
+	api.POST("/feedback", app.feedbackHandler)
+	api.GET("/feedback", app.listFeedbackHandler)
+	api.GET("/calibration", app.calibrationHandler)
+	api.POST("/collections/:name/webhooks", app.addWebhookHandler)
+	api.GET("/collections/:name/webhooks", app.listWebhooksHandler)
+	api.DELETE("/collections/:name/webhooks/:id", app.deleteWebhookHandler)
+	api.GET("/live/:id/events", app.liveEventsHandler)
+
+	// Routes that spawn yt-dlp/ffmpeg/Whisper work are concurrency limited
+	work := api.Group("", app.limiter.Middleware(), clientKeyMiddleware())
+	work.POST("/search", app.searchHandler)
+	work.GET("/search", app.searchQueryHandler)
+	work.POST("/search/chapter", app.chapterSearchHandler)
+	work.POST("/prefetch", app.prefetchHandler)
+	work.POST("/transcripts/:videoID/extend", app.extendTranscriptHandler)
+	work.POST("/transcripts/merged", app.mergedTranscriptHandler)
+	work.POST("/search/upload", app.uploadSearchHandler)
+	work.POST("/search/media", app.mediaSearchHandler)
+	work.POST("/meetings", app.meetingHandler)
+	work.POST("/lecture/search", app.lectureSearchHandler)
+	work.POST("/timeline", app.timelineHandler)
+	work.GET("/audio-tracks", app.audioTracksHandler)
+	work.POST("/export/karaoke", app.karaokeExportHandler)
+	work.POST("/export/markers", app.markerExportHandler)
+	work.POST("/clip", app.clipHandler)
+	work.POST("/comments/index", app.commentIndexHandler)
+	work.POST("/summarize", app.summarizeHandler)
+	work.POST("/ask", app.askHandler)
+	work.GET("/topics", app.topicsHandler)
+	work.POST("/index/playlist", app.indexPlaylistHandler)
+	work.POST("/live", app.liveHandler)
+
+	return r
+}
+
+// Run serves the API on the configured port, and over gRPC on GRPC_PORT when
+// set, until the server fails, or works the job queue in the worker role.
+func (app *App) Run() error {
+	if app.role() == RoleWorker {
+		return app.RunWorker()
+	}
+	app.reloadOnSIGHUP()
+	workfile.StartJanitor(janitorConfigFromEnv())
+	app.startCaptionResync()
+	go app.warm()
+	if app.coordinating() {
+		app.startJobWorkers(0)
+	} else {
+		app.startJobWorkers(queueWorkers())
+	}
+	handler := app.Router()
+	if app.cfg.GRPCPort != "" {
+		go func() {
+			log.Fatalf("gRPC server stopped: %v", serveGRPC(app.cfg.GRPCPort, app, handler))
+		}()
+	}
+	log.Printf("Server running on port %s...", app.cfg.Port)
+	return serve(app.cfg, versionedAPI{next: handler})
+}
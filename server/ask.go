@@ -0,0 +1,349 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/env"
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/langpack"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+)
+
+// AskRequest is a question about one video; of the search fields only
+// video_url, language and the download options are used.
+type AskRequest struct {
+	search.Request
+	Question string `json:"question"`
+	// Passages is how many transcript passages the answer is drawn from;
+	// default ASK_PASSAGES (6)
+	Passages int `json:"passages,omitempty"`
+}
+
+// AskPassage is a stretch of the transcript retrieved for the question.
+type AskPassage struct {
+	Seconds    float64 `json:"seconds"`
+	EndSeconds float64 `json:"end_seconds"`
+	Time       string  `json:"time"`
+	URL        string  `json:"url,omitempty"`
+	Text       string  `json:"text"`
+	Score      float64 `json:"score"`
+}
+
+type AskResponse struct {
+	VideoID  string `json:"video_id"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	// Citations are the passages the answer cites, in time order
+	Citations []AskPassage `json:"citations"`
+	// Passages are all passages retrieved, best first
+	Passages []AskPassage `json:"passages"`
+	// Retrieval is "hybrid" (keywords and embeddings) or "keyword" when
+	// embeddings were unavailable
+	Retrieval string `json:"retrieval"`
+	Source    string `json:"source"`
+	Language  string `json:"language,omitempty"`
+	Model     string `json:"model"`
+}
+
+// askModel is the chat model answers are written with, ASK_MODEL.
+func askModel() string {
+	if m := os.Getenv("ASK_MODEL"); m != "" {
+		return m
+	}
+	return openai.GPT4oMini
+}
+
+// askEmbeddingModel is the model passages are embedded with,
+// ASK_EMBEDDING_MODEL.
+func askEmbeddingModel() openai.EmbeddingModel {
+	if m := os.Getenv("ASK_EMBEDDING_MODEL"); m != "" {
+		return openai.EmbeddingModel(m)
+	}
+	return openai.SmallEmbedding3
+}
+
+// askHandler answers POST /api/ask: it retrieves the transcript passages most
+// relevant to the question, by keyword overlap and embedding similarity, and
+// has the model answer from those alone, citing their timestamps.
+func (app *App) askHandler(c *web.Context) {
+	var req AskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.VideoURL == "" || req.Question == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url and question are required"})
+		return
+	}
+	if len(req.Question) > 1000 {
+		c.JSON(400, ErrorResponse{Error: "question must be at most 1000 characters"})
+		return
+	}
+	if req.Passages <= 0 {
+		req.Passages = env.Int("ASK_PASSAGES", 6)
+	}
+	req.Passages = min(req.Passages, 20)
+	req.OpenAIKey = callerKey(c)
+	req.Window = nil
+	ctx := c.Request.Context()
+	segments, source, lang, err := app.videoTranscript(ctx, req.Request)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	passages := askPassages(segments)
+	if len(passages) == 0 {
+		c.JSON(422, ErrorResponse{Error: "the transcript is empty"})
+		return
+	}
+
+	ctx = oai.WithKey(ctx, req.OpenAIKey)
+	client, err := oai.ClientFor(ctx)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	videoID := store.VideoKey(req.VideoURL)
+	resp := AskResponse{
+		VideoID:   videoID,
+		Question:  req.Question,
+		Retrieval: "hybrid",
+		Source:    source,
+		Language:  lang,
+		Model:     askModel(),
+		Citations: []AskPassage{},
+	}
+	keyword := keywordScores(passages, req.Question, lang)
+	semantic, err := embeddingScores(ctx, client, videoID+"|"+lang, passages, req.Question)
+	if err != nil {
+		log.Printf("ask: embeddings unavailable, using keywords only: %v", err)
+		resp.Retrieval = "keyword"
+	}
+	best, fused := fuseRanks(keyword, semantic, req.Passages)
+	for _, i := range best {
+		p := passages[i]
+		p.URL = media.DeepLink(req.VideoURL, p.Seconds)
+		p.Score = math.Round(fused[i]*10000) / 10000
+		resp.Passages = append(resp.Passages, p)
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Question: %s\n\nTranscript passages:\n", req.Question)
+	for i, p := range resp.Passages {
+		fmt.Fprintf(&prompt, "%d. [%.0f] %s\n", i+1, p.Seconds, p.Text)
+	}
+	var out struct {
+		Answer    string `json:"answer"`
+		Citations []int  `json:"citations"`
+	}
+	err = chatJSON(ctx, client, askModel(),
+		`Answer the question about a video using only the numbered transcript passages, whose lines start with the time in seconds. `+
+			`Reply with a JSON object {"answer": string, "citations": [number]} where citations are the numbers of the passages the answer rests on. `+
+			`If the passages don't answer the question, say so in answer and reply with no citations.`,
+		prompt.String(), &out)
+	if err != nil {
+		c.JSON(502, ErrorResponse{Error: fmt.Sprintf("answering failed: %v", err)})
+		return
+	}
+	resp.Answer = out.Answer
+	seen := map[int]bool{}
+	for _, n := range out.Citations {
+		if n < 1 || n > len(resp.Passages) || seen[n] {
+			continue
+		}
+		seen[n] = true
+		resp.Citations = append(resp.Citations, resp.Passages[n-1])
+	}
+	sort.SliceStable(resp.Citations, func(i, j int) bool { return resp.Citations[i].Seconds < resp.Citations[j].Seconds })
+	c.JSON(200, resp)
+}
+
+// askPassageSeconds and askPassageChars bound a passage: segments are joined
+// until either is reached
+const (
+	askPassageSeconds = 30
+	askPassageChars   = 1000
+)
+
+// askPassages joins consecutive segments into passages long enough to carry
+// a thought, since single caption cues rarely answer anything.
+func askPassages(segments []subtitle.Entry) []AskPassage {
+	var out []AskPassage
+	var cur *AskPassage
+	for _, s := range segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		if cur == nil || s.Start-cur.Seconds >= askPassageSeconds || len(cur.Text)+len(text) > askPassageChars {
+			out = append(out, AskPassage{Seconds: s.Start, EndSeconds: s.End, Time: search.FormatTime(s.Start), Text: text})
+			cur = &out[len(out)-1]
+			continue
+		}
+		cur.Text += " " + text
+		cur.EndSeconds = max(cur.EndSeconds, s.End)
+	}
+	return out
+}
+
+// keywordScores rates each passage by the share of the question's content
+// words it contains, rarer words counting more.
+func keywordScores(passages []AskPassage, question, lang string) []float64 {
+	pack := langpack.For(lang)
+	want := langpack.ContentWords(pack, question)
+	words := make([]map[string]bool, len(passages))
+	df := map[string]int{}
+	for i, p := range passages {
+		words[i] = map[string]bool{}
+		for _, w := range langpack.ContentWords(pack, p.Text) {
+			if !words[i][w] {
+				words[i][w] = true
+				df[w]++
+			}
+		}
+	}
+	scores := make([]float64, len(passages))
+	for i := range passages {
+		for _, w := range want {
+			if words[i][w] {
+				scores[i] += math.Log(1 + float64(len(passages))/float64(df[w]))
+			}
+		}
+	}
+	return scores
+}
+
+// maxEmbeddedVideos bounds the videos whose passage embeddings are kept in
+// memory; past it the cache is dropped and rebuilt as videos come back.
+const maxEmbeddedVideos = 64
+
+var (
+	embedMu    sync.Mutex
+	embedCache = map[string][][]float32{}
+)
+
+// embeddingScores rates each passage by the cosine similarity of its
+// embedding to the question's. A video's passage embeddings are computed
+// once per model and kept for follow-up questions.
+func embeddingScores(ctx context.Context, client *openai.Client, key string, passages []AskPassage, question string) ([]float64, error) {
+	model := askEmbeddingModel()
+	key = fmt.Sprintf("%s|%s|%d", key, model, len(passages))
+	embedMu.Lock()
+	vectors, ok := embedCache[key]
+	embedMu.Unlock()
+
+	inputs := []string{question}
+	if !ok {
+		for _, p := range passages {
+			inputs = append(inputs, p.Text)
+		}
+	}
+	var all [][]float32
+	// the API takes at most 2048 inputs a call
+	for start := 0; start < len(inputs); start += 2048 {
+		batch := inputs[start:min(start+2048, len(inputs))]
+		var resp openai.EmbeddingResponse
+		err := oai.Retry(ctx, string(model)+" embeddings", func() (err error) {
+			resp, err = client.CreateEmbeddings(ctx, openai.EmbeddingRequest{Input: batch, Model: model})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, fmt.Errorf("got %d embeddings for %d inputs", len(resp.Data), len(batch))
+		}
+		sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+		for _, d := range resp.Data {
+			all = append(all, d.Embedding)
+		}
+	}
+	if !ok {
+		vectors = all[1:]
+		embedMu.Lock()
+		if len(embedCache) >= maxEmbeddedVideos {
+			embedCache = map[string][][]float32{}
+		}
+		embedCache[key] = vectors
+		embedMu.Unlock()
+	}
+
+	scores := make([]float64, len(passages))
+	for i, v := range vectors {
+		scores[i] = cosine(all[0], v)
+	}
+	return scores, nil
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// fuseRanks merges the keyword and embedding rankings by reciprocal rank
+// fusion and returns the indexes of the best n passages, with every
+// passage's fused score. With no embeddings, only keyword matches are
+// returned, or the opening passages when nothing matches.
+func fuseRanks(keyword, semantic []float64, n int) ([]int, []float64) {
+	const k = 60
+	fused := make([]float64, len(keyword))
+	add := func(scores []float64) {
+		order := make([]int, 0, len(scores))
+		for i, s := range scores {
+			if s > 0 {
+				order = append(order, i)
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+		for rank, i := range order {
+			fused[i] += 1 / float64(k+rank+1)
+		}
+	}
+	add(keyword)
+	if semantic != nil {
+		add(semantic)
+	}
+	order := make([]int, len(fused))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return fused[order[a]] > fused[order[b]] })
+	out := order[:min(n, len(order))]
+	if semantic == nil {
+		hits := 0
+		for _, i := range out {
+			if fused[i] > 0 {
+				hits++
+			}
+		}
+		if hits > 0 {
+			out = out[:hits]
+		}
+	}
+	return out, fused
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+)
+
+// Attachments are what a webhook delivery carries per its
+// store.WebhookAttachments.
+type Attachments struct {
+	Transcript *TranscriptAttachment `json:"transcript,omitempty"`
+	Clip       *ClipAttachment       `json:"clip,omitempty"`
+}
+
+// TranscriptAttachment is the video's indexed transcript, inline or as a
+// link.
+type TranscriptAttachment struct {
+	VideoID  string           `json:"video_id"`
+	Language string           `json:"language,omitempty"`
+	Source   string           `json:"source"`
+	Segments []subtitle.Entry `json:"segments,omitempty"`
+	// URL fetches the transcript without an API key until ExpiresAt; when
+	// no signing secret is configured it is the authenticated API URL and
+	// doesn't expire
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ClipAttachment is the matched stretch of the video with the transcript
+// around it.
+type ClipAttachment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// URL opens the video at the match
+	URL  string `json:"url"`
+	Text string `json:"text,omitempty"`
+	// Context are the segments within WEBHOOK_CLIP_CONTEXT_SECONDS (default
+	// 15) of the match
+	Context []subtitle.Entry `json:"context,omitempty"`
+}
+
+// validAttachments checks the options a subscription or callback asked for.
+func validAttachments(a store.WebhookAttachments) error {
+	switch a.Transcript {
+	case "", store.AttachInline, store.AttachURL:
+		return nil
+	default:
+		return fmt.Errorf("attachments.transcript must be %q or %q", store.AttachInline, store.AttachURL)
+	}
+}
+
+// buildAttachments gathers what opts ask for about videoURL from the
+// index, and the clip around match when there is one. Nil means nothing to
+// attach; a video that isn't indexed has no transcript to attach.
+func (app *App) buildAttachments(ctx context.Context, opts store.WebhookAttachments, videoURL string, match *search.Response) *Attachments {
+	if opts.Transcript == "" && !opts.Clip {
+		return nil
+	}
+	var rec store.TranscriptRecord
+	var found bool
+	if app.store != nil {
+		var err error
+		rec, found, err = app.store.GetTranscript(ctx, store.VideoKey(videoURL))
+		if err != nil {
+			log.Printf("webhook attachments for %s: %v", videoURL, err)
+		}
+		rec.Segments = redactedSegments(rec.Segments)
+	}
+	att := &Attachments{}
+	if found && opts.Transcript != "" {
+		t := &TranscriptAttachment{VideoID: rec.VideoID, Language: rec.Language, Source: rec.Source}
+		if opts.Transcript == store.AttachInline {
+			t.Segments = rec.Segments
+		} else {
+			t.URL, t.ExpiresAt = signedTranscriptURL(rec.VideoID)
+		}
+		att.Transcript = t
+	}
+	if opts.Clip && match != nil && match.Found {
+		clip := &ClipAttachment{Start: match.Seconds, End: match.EndSeconds, URL: media.DeepLink(videoURL, match.Seconds)}
+		margin := env.Float("WEBHOOK_CLIP_CONTEXT_SECONDS", 15)
+		for _, e := range rec.Segments {
+			if e.End >= match.Seconds-margin && e.Start <= match.EndSeconds+margin {
+				clip.Context = append(clip.Context, e)
+			}
+			if e.Start <= match.Seconds && e.End >= match.Seconds && clip.Text == "" {
+				clip.Text = e.Text
+			}
+		}
+		att.Clip = clip
+	}
+	if att.Transcript == nil && att.Clip == nil {
+		return nil
+	}
+	return att
+}
+
+// transcriptURLSecret signs transcript links: TRANSCRIPT_URL_SECRET, or
+// WEBHOOK_SECRET when that is unset.
+func transcriptURLSecret() string {
+	if s := os.Getenv("TRANSCRIPT_URL_SECRET"); s != "" {
+		return s
+	}
+	return os.Getenv("WEBHOOK_SECRET")
+}
+
+func signTranscript(secret, videoID string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "transcript.%s.%d", videoID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedTranscriptURL links to GET /public/transcripts/:videoID, valid for
+// TRANSCRIPT_URL_TTL (default 24h). Without a secret it falls back to the
+// API URL, which needs a key.
+func signedTranscriptURL(videoID string) (string, *time.Time) {
+	secret := transcriptURLSecret()
+	if secret == "" {
+		return transcriptLocation(videoID), nil
+	}
+	expires := time.Now().Add(env.Duration("TRANSCRIPT_URL_TTL", 24*time.Hour)).UTC().Truncate(time.Second)
+	unix := expires.Unix()
+	q := url.Values{"expires": {strconv.FormatInt(unix, 10)}, "sig": {signTranscript(secret, videoID, unix)}}
+	base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	return base + "/public/transcripts/" + url.PathEscape(videoID) + "?" + q.Encode(), &expires
+}
+
+// signedTranscriptHandler serves GET /public/transcripts/:videoID to holders
+// of a link from signedTranscriptURL, without an API key.
+func (app *App) signedTranscriptHandler(c *web.Context) {
+	secret := transcriptURLSecret()
+	if app.store == nil || secret == "" {
+		c.JSON(404, ErrorResponse{Error: "signed transcript links are disabled"})
+		return
+	}
+	videoID := c.Param("videoID")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(c.Query("sig")), []byte(signTranscript(secret, videoID, expires))) {
+		c.JSON(403, ErrorResponse{Error: "invalid signature"})
+		return
+	}
+	if time.Now().Unix() > expires {
+		c.JSON(410, ErrorResponse{Error: "link expired"})
+		return
+	}
+	rec, found, err := app.store.GetTranscript(c.Request.Context(), videoID)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(404, ErrorResponse{Error: "transcript not found"})
+		return
+	}
+	c.JSON(200, TranscriptPayload{TranscriptRecord: rec, Segments: redactedSegments(rec.Segments)})
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"strings"
+
+	"searchme/internal/web"
+	"searchme/media"
+)
+
+// audioTracksHandler lists the audio tracks of ?video_url= so callers can pick audio_track.
+func (app *App) audioTracksHandler(c *web.Context) {
+	videoURL := strings.TrimSpace(c.Query("video_url"))
+	if videoURL == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url is required"})
+		return
+	}
+	if _, err := media.ResolveWebSource(app.downloader, videoURL); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	tracks, err := app.downloader.ListAudioTracks(videoURL)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(200, web.H{"tracks": tracks})
+}
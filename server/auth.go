@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"searchme/internal/web"
+)
+
+// KeyUsage is the per-key accounting kept in memory.
+type KeyUsage struct {
+	Name     string           `json:"name"`
+	Requests int64            `json:"requests"`
+	Rejected int64            `json:"rejected"`
+	ByRoute  map[string]int64 `json:"by_route"`
+	LastUsed time.Time        `json:"last_used"`
+}
+
+// APIKeyAuth checks X-API-Key on /api routes. It is disabled when no keys are configured.
+type APIKeyAuth struct {
+	mu    sync.Mutex
+	keys  map[[32]byte]*KeyUsage
+	names map[string]*KeyUsage
+}
+
+// apiKeyContextKey is where the authenticated key name is stored on the gin context.
+const apiKeyContextKey = "api_key_name"
+
+// NewAPIKeyAuthFromEnv loads keys from API_KEYS (comma separated) and/or
+// API_KEYS_FILE (one per line). Entries are "name:key" or a bare key.
+func NewAPIKeyAuthFromEnv() *APIKeyAuth {
+	a := &APIKeyAuth{}
+	if err := a.ReloadFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	return a
+}
+
+// ReloadFromEnv re-reads API_KEYS and API_KEYS_FILE. Keys that remain keep
+// their usage; requests already admitted are unaffected. On error the
+// current keys stay in place.
+func (a *APIKeyAuth) ReloadFromEnv() error {
+	next := &APIKeyAuth{keys: map[[32]byte]*KeyUsage{}, names: map[string]*KeyUsage{}}
+	for _, entry := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		next.add(entry)
+	}
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to read API_KEYS_FILE: %w", err)
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				next.add(line)
+			}
+		}
+		f.Close()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for digest, u := range next.keys {
+		if old, ok := a.names[u.Name]; ok {
+			next.keys[digest] = old
+			next.names[u.Name] = old
+		}
+	}
+	a.keys, a.names = next.keys, next.names
+	if len(a.keys) > 0 {
+		log.Printf("API key authentication enabled (%d keys)", len(a.keys))
+	}
+	return nil
+}
+
+func (a *APIKeyAuth) add(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return
+	}
+	name, key, ok := strings.Cut(entry, ":")
+	if !ok {
+		key = entry
+	}
+	name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+	if key == "" {
+		return
+	}
+	digest := sha256.Sum256([]byte(key))
+	if !ok {
+		// unnamed keys are reported by a short hash of their digest, which
+		// tells them apart without giving away any of the key
+		sum := sha256.Sum256(digest[:])
+		name = "key-" + hex.EncodeToString(sum[:4])
+	}
+	u := &KeyUsage{Name: name, ByRoute: map[string]int64{}}
+	a.keys[digest] = u
+	a.names[name] = u
+}
+
+// Enabled reports whether any keys are configured.
+func (a *APIKeyAuth) Enabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.keys) > 0
+}
+
+// Middleware rejects requests without a valid X-API-Key and records usage per key.
+// Keys are compared by SHA-256 digest so lookups don't leak key prefixes via timing.
+func (a *APIKeyAuth) Middleware() web.HandlerFunc {
+	return func(c *web.Context) {
+		if !a.Enabled() {
+			c.Next()
+			return
+		}
+		key := c.GetHeader("X-API-Key")
+		a.mu.Lock()
+		u := a.keys[sha256.Sum256([]byte(key))]
+		if key == "" || u == nil {
+			a.mu.Unlock()
+			c.AbortWithStatusJSON(401, ErrorResponse{Error: "missing or invalid API key"})
+			return
+		}
+		u.Requests++
+		u.ByRoute[c.Request.Method+" "+c.FullPath()]++
+		u.LastUsed = time.Now()
+		a.mu.Unlock()
+
+		c.Set(apiKeyContextKey, u.Name)
+		c.Next()
+
+		if c.Writer.Status() == 429 {
+			a.mu.Lock()
+			u.Rejected++
+			a.mu.Unlock()
+		}
+	}
+}
+
+// Usage returns a copy of the accounting for the named key.
+func (a *APIKeyAuth) Usage(name string) (KeyUsage, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u, ok := a.names[name]
+	if !ok {
+		return KeyUsage{}, false
+	}
+	cp := *u
+	cp.ByRoute = make(map[string]int64, len(u.ByRoute))
+	for k, v := range u.ByRoute {
+		cp.ByRoute[k] = v
+	}
+	return cp, true
+}
+
+// usageHandler returns the calling key's usage.
+func (app *App) usageHandler(c *web.Context) {
+	if !app.auth.Enabled() {
+		c.JSON(404, ErrorResponse{Error: "API key authentication is disabled"})
+		return
+	}
+	usage, _ := app.auth.Usage(c.GetString(apiKeyContextKey))
+	c.JSON(200, usage)
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+)
+
+// CachedVideo is what the caches hold for one video.
+type CachedVideo struct {
+	VideoID  string `json:"video_id"`
+	VideoURL string `json:"video_url,omitempty"`
+	Title    string `json:"title,omitempty"`
+	// Bytes totals the transcript, audio and chunk sizes
+	Bytes      int64                   `json:"size_bytes"`
+	Transcript *CachedTranscript       `json:"transcript,omitempty"`
+	Audio      []media.AudioCacheEntry `json:"audio,omitempty"`
+	Chunks     *search.CachedChunks    `json:"chunks,omitempty"`
+	Chapters   bool                    `json:"chapters,omitempty"`
+}
+
+// CachedTranscript is a video's transcript in the index.
+type CachedTranscript struct {
+	Language string `json:"language,omitempty"`
+	Source   string `json:"source"`
+	store.TranscriptSize
+	Partial    bool      `json:"partial,omitempty"`
+	IndexedAt  time.Time `json:"indexed_at"`
+	AgeSeconds float64   `json:"age_seconds"`
+}
+
+// CacheListing answers GET /api/admin/cache.
+type CacheListing struct {
+	Videos []CachedVideo `json:"videos"`
+	Bytes  int64         `json:"size_bytes"`
+}
+
+// CachePurge answers DELETE /api/admin/cache/:videoID with what was dropped.
+type CachePurge struct {
+	VideoID    string `json:"video_id"`
+	Transcript bool   `json:"transcript"`
+	AudioFiles int    `json:"audio_files"`
+	Chunks     int    `json:"chunks"`
+	Chapters   bool   `json:"chapters"`
+	FreedBytes int64  `json:"freed_bytes"`
+}
+
+// cacheVideoKey is the library key (see store.VideoKey) of a video named in
+// a cache: the caches hold YouTube IDs as they are and other videos by URL.
+func cacheVideoKey(video string) string {
+	if strings.Contains(video, "/") {
+		return store.VideoKey(video)
+	}
+	return video
+}
+
+// cacheHandler answers GET /api/admin/cache with every cached video, the
+// largest first: its indexed transcript, downloaded audio, transcribed
+// chunks and chapters.
+func (app *App) cacheHandler(c *web.Context) {
+	ctx := c.Request.Context()
+	videos := map[string]*CachedVideo{}
+	video := func(id string) *CachedVideo {
+		v, ok := videos[id]
+		if !ok {
+			v = &CachedVideo{VideoID: id}
+			videos[id] = v
+		}
+		return v
+	}
+
+	if app.store != nil {
+		recs, err := app.store.ListVideos(ctx)
+		if err != nil {
+			c.JSON(500, ErrorResponse{Error: err.Error()})
+			return
+		}
+		var sizes map[string]store.TranscriptSize
+		if cs, ok := app.store.(store.CacheStore); ok {
+			if sizes, err = cs.TranscriptSizes(ctx); err != nil {
+				c.JSON(500, ErrorResponse{Error: err.Error()})
+				return
+			}
+		}
+		now := time.Now()
+		for _, rec := range recs {
+			v := video(rec.VideoID)
+			v.VideoURL, v.Title = rec.VideoURL, rec.Title
+			v.Transcript = &CachedTranscript{
+				Language:       rec.Language,
+				Source:         rec.Source,
+				TranscriptSize: sizes[rec.VideoID],
+				Partial:        rec.Partial(),
+				IndexedAt:      rec.IndexedAt,
+				AgeSeconds:     now.Sub(rec.IndexedAt).Round(time.Second).Seconds(),
+			}
+			v.Bytes += v.Transcript.Bytes
+		}
+	}
+	for _, e := range media.SharedAudioCache().Entries() {
+		if e.Video == "" {
+			continue
+		}
+		v := video(cacheVideoKey(e.Video))
+		if v.VideoURL == "" && strings.Contains(e.Video, "/") {
+			v.VideoURL = e.Video
+		}
+		v.Audio = append(v.Audio, e)
+		v.Bytes += e.Bytes
+	}
+	for _, chunks := range app.pipeline.ChunkCache.Videos() {
+		v := video(cacheVideoKey(chunks.Video))
+		v.Chunks = &chunks
+		v.Bytes += chunks.Bytes
+	}
+	for _, key := range app.chapters.videos() {
+		video(key).Chapters = true
+	}
+
+	listing := CacheListing{Videos: make([]CachedVideo, 0, len(videos))}
+	for _, v := range videos {
+		listing.Videos = append(listing.Videos, *v)
+		listing.Bytes += v.Bytes
+	}
+	sort.Slice(listing.Videos, func(i, j int) bool {
+		a, b := listing.Videos[i], listing.Videos[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.VideoID < b.VideoID
+	})
+	c.JSON(200, listing)
+}
+
+// purgeCacheHandler answers DELETE /api/admin/cache/:videoID by dropping
+// everything cached for the video, so its next search starts afresh. The
+// ID is the one GET /api/admin/cache lists. 404 when nothing was cached.
+func (app *App) purgeCacheHandler(c *web.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("videoID")
+	purge := CachePurge{VideoID: id}
+
+	if cs, ok := app.store.(store.CacheStore); ok {
+		rec, indexed, err := app.store.GetTranscript(ctx, id)
+		if err != nil {
+			c.JSON(500, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if indexed {
+			if err := cs.DeleteTranscript(ctx, id); err != nil {
+				c.JSON(500, ErrorResponse{Error: err.Error()})
+				return
+			}
+			purge.Transcript = true
+			for _, seg := range rec.Segments {
+				purge.FreedBytes += int64(len(seg.Text))
+			}
+		}
+	}
+	match := func(video string) bool { return video != "" && cacheVideoKey(video) == id }
+	n, bytes := media.SharedAudioCache().Purge(match)
+	purge.AudioFiles = n
+	purge.FreedBytes += bytes
+	purge.Chunks = app.pipeline.ChunkCache.Purge(match)
+	purge.Chapters = app.chapters.forget(id)
+
+	if !purge.Transcript && purge.AudioFiles == 0 && purge.Chunks == 0 && !purge.Chapters {
+		c.JSON(404, ErrorResponse{Error: "nothing is cached for video " + id})
+		return
+	}
+	c.JSON(200, purge)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+)
+
+// ChapterSearchRequest searches only inside one chapter of a video, named by
+// SearchRequest's chapter or chapter_index.
+type ChapterSearchRequest struct {
+	SearchRequest
+}
+
+// chapterCache remembers each video's chapters, including videos without
+// any, so labelling results costs one yt-dlp call per video.
+type chapterCache struct {
+	mu       sync.Mutex
+	max      int
+	order    []string
+	chapters map[string][]media.Chapter
+}
+
+func newChapterCache() *chapterCache {
+	return &chapterCache{max: env.Int("CHAPTER_CACHE_MAX", 1000), chapters: map[string][]media.Chapter{}}
+}
+
+// videoChapters returns a video's chapters, or none for sources yt-dlp
+// doesn't handle and on errors.
+func (app *App) videoChapters(req search.Request) []media.Chapter {
+	key := store.VideoKey(req.VideoURL)
+	cache := app.chapters
+	cache.mu.Lock()
+	chapters, ok := cache.chapters[key]
+	cache.mu.Unlock()
+	if ok {
+		return chapters
+	}
+
+	dl, err := app.downloader.With(req.DownloadOptions)
+	if err != nil {
+		return nil
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil || !src.SupportsSubtitles() {
+		return nil
+	}
+	chapters, err = dl.Chapters(req.VideoURL)
+	if err != nil {
+		// not cached: the next search may have better luck
+		log.Printf("chapters for %s: %v", req.VideoURL, err)
+		return nil
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.chapters[key]; !ok {
+		if cache.max > 0 && len(cache.order) >= cache.max {
+			delete(cache.chapters, cache.order[0])
+			cache.order = cache.order[1:]
+		}
+		cache.order = append(cache.order, key)
+	}
+	cache.chapters[key] = chapters
+	return chapters
+}
+
+// chapterSearchHandler answers POST /api/search/chapter from the segments of
+// the named chapter only. The video's captions or full transcript are loaded
+// as for a ranked search.
+func (app *App) chapterSearchHandler(c *web.Context) {
+	var req ChapterSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" || req.Keyword == "" || (strings.TrimSpace(req.Chapter) == "" && req.ChapterIndex == 0) {
+		c.JSON(400, ErrorResponse{Error: "video_url, keyword and chapter or chapter_index are required"})
+		return
+	}
+	if _, err := search.ParseMatchMode(req.MatchMode); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	chapters, ok := app.chapterWindow(c, &req.SearchRequest)
+	if !ok {
+		return
+	}
+
+	req.OpenAIKey = callerKey(c)
+	subs, source, usedLang, err := app.pipeline.LoadSegments(c.Request.Context(), req.Request)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	resp := search.InSegments(req.Request, subs, source, usedLang)
+	search.LabelChapters(&resp, chapters)
+	app.calibrator.Calibrate(&resp)
+	c.JSON(200, resp)
+}
+
+// chapterWindow limits req to the chapter it names by title or 1-based
+// index, answering 404 with the video's chapter titles when there is no such
+// chapter. It returns the video's chapters; ok is false when it answered.
+func (app *App) chapterWindow(c *web.Context, req *SearchRequest) (chapters []media.Chapter, ok bool) {
+	chapters = app.videoChapters(req.Request)
+	if len(chapters) == 0 {
+		c.JSON(404, ErrorResponse{Error: "video has no chapters"})
+		return nil, false
+	}
+	var ch media.Chapter
+	if req.ChapterIndex != 0 {
+		ok = req.ChapterIndex > 0 && req.ChapterIndex <= len(chapters)
+		if ok {
+			ch = chapters[req.ChapterIndex-1]
+		}
+	} else {
+		ch, ok = search.FindChapter(chapters, req.Chapter)
+	}
+	if !ok {
+		titles := make([]string, len(chapters))
+		for i, ch := range chapters {
+			titles[i] = ch.Title
+		}
+		c.JSON(404, web.H{"error": "chapter not found", "chapters": titles})
+		return nil, false
+	}
+	req.Window = &search.TimeRange{Start: ch.Start, End: ch.End}
+	return chapters, true
+}
+
+// videos lists the video keys whose chapters are cached.
+func (cache *chapterCache) videos() []string {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return append([]string(nil), cache.order...)
+}
+
+// forget drops a video's chapters, reporting whether they were cached.
+func (cache *chapterCache) forget(key string) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.chapters[key]; !ok {
+		return false
+	}
+	delete(cache.chapters, key)
+	for i, k := range cache.order {
+		if k == key {
+			cache.order = append(cache.order[:i], cache.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"searchme/internal/oai"
+	"searchme/internal/web"
+)
+
+// openAIKeyHeader carries a caller's own OpenAI key, so their Whisper and
+// chat calls are billed to them rather than the operator.
+const openAIKeyHeader = "X-OpenAI-Key"
+
+// clientKeyContextKey is where the caller's OpenAI key is stored on the gin context.
+const clientKeyContextKey = "openai_key"
+
+// clientKeyMiddleware takes the caller's OpenAI key off the request so no
+// later logging or forwarding sees it, and keeps it for callerKey. Keys are
+// refused when CLIENT_OPENAI_KEYS is "off".
+func clientKeyMiddleware() web.HandlerFunc {
+	return func(c *web.Context) {
+		key := strings.TrimSpace(c.GetHeader(openAIKeyHeader))
+		c.Request.Header.Del(openAIKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if oai.ClientKeyMode() == oai.ClientKeysOff {
+			c.AbortWithStatusJSON(400, ErrorResponse{Error: "this server does not accept client OpenAI keys"})
+			return
+		}
+		if len(key) > 256 || strings.ContainsAny(key, " \t\r\n") {
+			c.AbortWithStatusJSON(400, ErrorResponse{Error: "invalid " + openAIKeyHeader + " header"})
+			return
+		}
+		c.Set(clientKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// callerKey is the caller's OpenAI key, or "" to use the server's.
+func callerKey(c *web.Context) string {
+	return c.GetString(clientKeyContextKey)
+}
+
+// callerContext is a context for work that outlives nothing but the
+// request's own handler, billing OpenAI calls to the caller's key if any.
+func callerContext(c *web.Context) context.Context {
+	return oai.WithKey(context.Background(), callerKey(c))
+}
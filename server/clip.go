@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"searchme/artifact"
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+)
+
+// ClipRequest asks for the stretch of a video around a moment, given as
+// seconds or found by searching for keyword like POST /api/search.
+type ClipRequest struct {
+	search.Request
+	Seconds *float64 `json:"seconds,omitempty"`
+	// Duration is the clip length; 0 reads CLIP_SECONDS (default 20), and
+	// CLIP_MAX_SECONDS (default 120) caps it. Without it, a clip of a keyword
+	// runs from the segment before the match to the one after, when those
+	// are known and fit under the cap.
+	Duration float64 `json:"duration,omitempty"`
+	// AudioOnly returns an mp3 instead of an mp4
+	AudioOnly bool `json:"audio_only,omitempty"`
+	// Upload puts the clip under CLIP_S3_URI, or in the blob store under
+	// clips/ (see artifact.SharedBlobStore), and answers with a presigned
+	// URL instead of the file
+	Upload bool `json:"upload,omitempty"`
+}
+
+// ClipResponse is the answer to an uploaded clip.
+type ClipResponse struct {
+	VideoURL  string    `json:"video_url"`
+	Start     float64   `json:"start"`
+	End       float64   `json:"end"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// clipHandler answers POST /api/clip with a clip of the video centred on the
+// moment: the file itself, or with upload a link to it in S3.
+func (app *App) clipHandler(c *web.Context) {
+	var req ClipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" || (req.Seconds == nil && req.Keyword == "") {
+		c.JSON(400, ErrorResponse{Error: "video_url and seconds or keyword are required"})
+		return
+	}
+	if req.Seconds != nil && *req.Seconds < 0 {
+		c.JSON(400, ErrorResponse{Error: "seconds must not be negative"})
+		return
+	}
+	fit := req.Duration == 0
+	if fit {
+		req.Duration = env.Float("CLIP_SECONDS", 20)
+	}
+	maxDuration := env.Float("CLIP_MAX_SECONDS", 120)
+	if req.Duration <= 0 || req.Duration > maxDuration {
+		c.JSON(400, ErrorResponse{Error: fmt.Sprintf("duration must be between 0 and %g seconds", maxDuration)})
+		return
+	}
+	bucket, blobs := os.Getenv("CLIP_S3_URI"), artifact.SharedBlobStore()
+	if req.Upload && !strings.HasPrefix(bucket, "s3://") && blobs == nil {
+		c.JSON(400, ErrorResponse{Error: "clip uploads are disabled (set CLIP_S3_URI or BLOB_STORE_URI)"})
+		return
+	}
+	if _, encrypted := blobs.(artifact.EncryptedBlobStore); req.Upload && !strings.HasPrefix(bucket, "s3://") && encrypted {
+		c.JSON(400, ErrorResponse{Error: "clip uploads need CLIP_S3_URI while the blob store is encrypted at rest"})
+		return
+	}
+	if _, err := search.ParseMatchMode(req.MatchMode); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	dl, err := app.downloader.With(req.DownloadOptions)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	moment := 0.0
+	var span *search.TimeRange
+	if req.Seconds != nil {
+		moment = *req.Seconds
+	} else {
+		req.Limit, req.Offset, req.Cursor = 0, 0, ""
+		req.OpenAIKey = callerKey(c)
+		resp, err := app.Search(ctx, SearchRequest{Request: req.Request})
+		if err != nil {
+			pipelineError(c, err)
+			return
+		}
+		if !resp.Found {
+			c.JSON(404, ErrorResponse{Error: fmt.Sprintf("%q was not found", req.Keyword)})
+			return
+		}
+		moment = (resp.Seconds + math.Max(resp.EndSeconds, resp.Seconds)) / 2
+		if fit {
+			span = matchSpan(resp, maxDuration)
+		}
+	}
+	start := math.Max(0, moment-req.Duration/2)
+	end := start + req.Duration
+	if span != nil {
+		start, end = span.Start, span.End
+	}
+
+	clip, err := media.Clip(ctx, src, start, end, req.AudioOnly)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer clip.Remove()
+	name := fmt.Sprintf("%s-%s%s", store.VideoKey(req.VideoURL), strings.ReplaceAll(search.FormatTime(start), ":", ""), filepath.Ext(clip.Path))
+
+	if req.Upload {
+		ttl := env.Duration("CLIP_URL_TTL", time.Hour)
+		var url string
+		if strings.HasPrefix(bucket, "s3://") {
+			url, err = media.UploadS3(ctx, clip.Path, strings.TrimRight(bucket, "/")+"/"+path.Base(clip.Path), ttl)
+		} else {
+			url, err = uploadBlob(ctx, blobs, "clips/"+path.Base(clip.Path), clip.Path, ttl)
+		}
+		if err != nil {
+			c.JSON(502, ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(200, ClipResponse{VideoURL: req.VideoURL, Start: start, End: end, URL: url, ExpiresAt: time.Now().Add(ttl).UTC()})
+		return
+	}
+	f, err := os.Open(clip.Path)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer f.Close()
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	http.ServeContent(c.Writer, c.Request, name, time.Time{}, f)
+}
+
+// matchSpan is a found match with the segments on either side, when they
+// are known and together last at most max seconds, so a clip of it starts
+// and ends between sentences.
+func matchSpan(resp search.Response, max float64) *search.TimeRange {
+	if resp.PreviousSegment == nil && resp.NextSegment == nil {
+		return nil
+	}
+	span := search.TimeRange{Start: resp.Seconds, End: math.Max(resp.EndSeconds, resp.Seconds)}
+	if p := resp.PreviousSegment; p != nil {
+		span.Start = p.Start
+	}
+	if n := resp.NextSegment; n != nil {
+		span.End = n.End
+	}
+	if d := span.End - span.Start; d <= 0 || d > max {
+		return nil
+	}
+	return &span
+}
+
+// uploadBlob puts the local file in the blob store under key and links to it.
+func uploadBlob(ctx context.Context, blobs artifact.BlobStore, key, local string, ttl time.Duration) (string, error) {
+	if err := blobs.Put(ctx, key, local); err != nil {
+		return "", err
+	}
+	return blobs.URL(ctx, key, ttl)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"searchme/internal/flight"
+	"searchme/search"
+)
+
+// pipelineSearches coalesces identical searches run on the local pipeline
+// at the same time, so a burst of clients asking about the same video and
+// keyword downloads and transcribes it once.
+var pipelineSearches flight.Group[search.Response]
+
+// searchPipeline runs req on the local pipeline, or shares the result of
+// an identical search already running. Only the search that started the
+// work reports progress.
+func (app *App) searchPipeline(ctx context.Context, req SearchRequest) (search.Response, error) {
+	key, ok := pipelineKey(req.Request)
+	if !ok {
+		return app.runPipeline(ctx, req.Request)
+	}
+	resp, shared, err := pipelineSearches.Do(ctx, key, func(ctx context.Context) (search.Response, error) {
+		return app.runPipeline(ctx, req.Request)
+	})
+	if shared && err == nil {
+		// the callers finish their responses in place
+		resp, err = copyResponse(resp)
+	}
+	return resp, err
+}
+
+// runPipeline searches the languages asked for, every ranked occurrence
+// or the first match, as req needs.
+func (app *App) runPipeline(ctx context.Context, req search.Request) (search.Response, error) {
+	if len(req.Languages) > 0 {
+		return app.pipeline.SearchLanguages(ctx, req)
+	}
+	if req.Limit > 0 {
+		// ranking needs every occurrence, so skip the early-exit search
+		subs, source, usedLang, err := app.pipeline.LoadSegments(ctx, req)
+		if err != nil {
+			return search.Response{}, err
+		}
+		return search.InSegments(req, subs, source, usedLang), nil
+	}
+	match, found, usedLang, err := app.pipeline.Search(ctx, req)
+	if err != nil {
+		return search.Response{}, err
+	}
+	return search.NewResponse(req.VideoURL, match, found, usedLang), nil
+}
+
+// pipelineKey identifies what req searches for. The caller's OpenAI key is
+// part of it, hashed, so nobody's search is billed to someone else.
+func pipelineKey(req search.Request) (string, bool) {
+	b, err := json.Marshal(struct {
+		search.Request
+		Translations map[string]string `json:"translations,omitempty"`
+		OpenAIKey    string            `json:"openai_key,omitempty"`
+	}{req, req.Translations, hashKey(req.OpenAIKey)})
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+func hashKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// copyResponse deep-copies a shared response.
+func copyResponse(resp search.Response) (search.Response, error) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return resp, err
+	}
+	var out search.Response
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
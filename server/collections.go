@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"searchme/events"
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/store"
+)
+
+// VideoIndexedPayload is the body POSTed to collection webhooks.
+type VideoIndexedPayload struct {
+	Event      events.Type            `json:"event"`
+	Collection string                 `json:"collection"`
+	Video      store.TranscriptRecord `json:"video"`
+	Segments   int                    `json:"segments"`
+	// TranscriptURL is where the full transcript can be fetched
+	TranscriptURL string `json:"transcript_url"`
+	// Attachments are what the subscription asked for; there is no match
+	// to clip here
+	Attachments *Attachments `json:"attachments,omitempty"`
+}
+
+func (app *App) collections() (store.CollectionStore, bool) {
+	cs, ok := app.store.(store.CollectionStore)
+	return cs, ok
+}
+
+// addToCollection files a freshly indexed video under collection and notifies
+// the collection's webhooks. Videos that were already indexed are filed but
+// not announced again.
+func (app *App) addToCollection(ctx context.Context, collection, videoURL string, existed bool) {
+	cs, ok := app.collections()
+	if !ok || collection == "" {
+		return
+	}
+	videoID := store.VideoKey(videoURL)
+	if err := cs.AssignCollection(ctx, videoID, collection); err != nil {
+		log.Printf("failed to add %s to collection %s: %v", videoURL, collection, err)
+		return
+	}
+	if existed {
+		return
+	}
+	rec, found, err := app.store.GetTranscript(ctx, videoID)
+	if err != nil || !found {
+		return
+	}
+	payload := VideoIndexedPayload{
+		Event:         events.VideoIndexed,
+		Collection:    collection,
+		Video:         rec,
+		Segments:      len(rec.Segments),
+		TranscriptURL: transcriptLocation(videoID),
+	}
+	events.Default().Emit(events.Event{Type: events.VideoIndexed, VideoURL: videoURL, Data: map[string]interface{}{
+		"collection": collection, "transcript_url": payload.TranscriptURL,
+	}})
+
+	hooks, err := cs.ListWebhooks(ctx, collection)
+	if err != nil {
+		log.Printf("failed to load webhooks for collection %s: %v", collection, err)
+		return
+	}
+	for _, h := range hooks {
+		p := payload
+		p.Attachments = app.buildAttachments(ctx, h.Attachments, videoURL, nil)
+		go deliverWebhook(h.URL, h.Secret, p)
+	}
+}
+
+// transcriptLocation is the transcript URL handed to webhooks, absolute when
+// PUBLIC_BASE_URL is set.
+func transcriptLocation(videoID string) string {
+	return strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/") + "/api/transcripts/" + url.PathEscape(videoID)
+}
+
+// addWebhookHandler subscribes a URL: POST /api/collections/:name/webhooks
+// {"url": ..., "secret": ..., "attachments": {"transcript": "inline"|"url", "clip": bool}}
+// Deliveries are signed with secret when it is given.
+func (app *App) addWebhookHandler(c *web.Context) {
+	cs, ok := app.collections()
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	var body struct {
+		URL         string                   `json:"url"`
+		Secret      string                   `json:"secret"`
+		Attachments store.WebhookAttachments `json:"attachments"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if err := checkWebhookURL(c.Request.Context(), body.URL); err != nil {
+		c.JSON(400, ErrorResponse{Error: "url: " + err.Error()})
+		return
+	}
+	if err := validAttachments(body.Attachments); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	hook := store.CollectionWebhook{
+		ID:          workfile.Name("hook"),
+		Collection:  c.Param("name"),
+		URL:         body.URL,
+		Secret:      body.Secret,
+		Attachments: body.Attachments,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := cs.AddWebhook(c.Request.Context(), hook); err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(201, hook)
+}
+
+// listWebhooksHandler lists a collection's subscriptions.
+func (app *App) listWebhooksHandler(c *web.Context) {
+	cs, ok := app.collections()
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	hooks, err := cs.ListWebhooks(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(200, web.H{"webhooks": hooks})
+}
+
+// deleteWebhookHandler unsubscribes: DELETE /api/collections/:name/webhooks/:id
+func (app *App) deleteWebhookHandler(c *web.Context) {
+	cs, ok := app.collections()
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	deleted, err := cs.DeleteWebhook(c.Request.Context(), c.Param("name"), c.Param("id"))
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(404, ErrorResponse{Error: "webhook not found"})
+		return
+	}
+	c.Status(204)
+}
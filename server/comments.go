@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+)
+
+// CommentIndexRequest names the video whose comments to scan.
+type CommentIndexRequest struct {
+	VideoURL string `json:"video_url"`
+	media.DownloadOptions
+}
+
+// CommentMarkersResponse lists a video's community markers.
+type CommentMarkersResponse struct {
+	VideoID string                  `json:"video_id"`
+	Markers []store.CommunityMarker `json:"markers"`
+}
+
+var errMarkersDisabled = errors.New("transcript index is disabled (set INDEX_DB)")
+
+func (app *App) communityMarkers() (store.CommunityMarkerStore, bool) {
+	ms, ok := app.store.(store.CommunityMarkerStore)
+	return ms, ok
+}
+
+// CommentMarkers turns comments into markers, one per timestamp, dropping
+// timestamps past the end of the video when its duration is known.
+func CommentMarkers(comments []media.Comment, duration float64) []store.CommunityMarker {
+	var markers []store.CommunityMarker
+	for _, cm := range comments {
+		for _, ts := range search.CommentTimestamps(cm.Text) {
+			if duration > 0 && ts.Seconds > duration {
+				continue
+			}
+			markers = append(markers, store.CommunityMarker{Seconds: ts.Seconds, Text: ts.Label, Likes: cm.Likes, CommentID: cm.ID})
+		}
+	}
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].Seconds < markers[j].Seconds })
+	return markers
+}
+
+// scanComments fetches up to COMMENT_SCAN_LIMIT (default 500) top comments
+// of the video and replaces its community markers with their timestamps.
+func (app *App) scanComments(ctx context.Context, videoURL string, opts media.DownloadOptions) ([]store.CommunityMarker, error) {
+	ms, ok := app.communityMarkers()
+	if !ok {
+		return nil, errMarkersDisabled
+	}
+	// only web videos have comments, and yt-dlp must not read the URL as an option
+	if err := media.CheckWebURL(videoURL); err != nil {
+		return nil, err
+	}
+	dl, err := app.downloader.With(opts)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := dl.Comments(ctx, videoURL, env.Int("COMMENT_SCAN_LIMIT", 500))
+	if err != nil {
+		return nil, err
+	}
+	videoID := store.VideoKey(videoURL)
+	var duration float64
+	if rec, found, err := app.store.GetTranscript(ctx, videoID); err == nil && found {
+		duration = rec.Duration
+	}
+	markers := CommentMarkers(comments, duration)
+	if err := ms.ReplaceMarkers(ctx, videoID, markers, time.Now()); err != nil {
+		return nil, err
+	}
+	return markers, nil
+}
+
+// communityHints returns the markers whose comment text matches the keyword,
+// most liked first, up to COMMUNITY_HINT_LIMIT (default 5). A video whose
+// comments were never scanned is scanned first. Failures only cost the
+// hints.
+func (app *App) communityHints(ctx context.Context, req search.Request) []search.CommunityHint {
+	ms, ok := app.communityMarkers()
+	if !ok || media.CheckWebURL(req.VideoURL) != nil {
+		// uploads, S3 and local files have no comments
+		return nil
+	}
+	videoID := store.VideoKey(req.VideoURL)
+	markers, scanned, err := ms.ListMarkers(ctx, videoID)
+	if err == nil && !scanned {
+		markers, err = app.scanComments(ctx, req.VideoURL, req.DownloadOptions)
+	}
+	if err != nil {
+		log.Printf("community hints for %s: %v", req.VideoURL, err)
+		return nil
+	}
+	matcher := req.Matcher(search.NormalizeLang(req.Language))
+	var hints []search.CommunityHint
+	for _, m := range markers {
+		if matcher.Match(m.Text) {
+			hints = append(hints, search.CommunityHint{
+				Time:    search.FormatTime(m.Seconds),
+				Seconds: m.Seconds,
+				URL:     media.DeepLink(req.VideoURL, m.Seconds),
+				Text:    m.Text,
+				Likes:   m.Likes,
+			})
+		}
+	}
+	sort.SliceStable(hints, func(i, j int) bool { return hints[i].Likes > hints[j].Likes })
+	if limit := env.Int("COMMUNITY_HINT_LIMIT", 5); len(hints) > limit {
+		hints = hints[:limit]
+	}
+	return hints
+}
+
+// commentIndexHandler answers POST /api/comments/index by (re)scanning a
+// video's comments for timestamps.
+func (app *App) commentIndexHandler(c *web.Context) {
+	var req CommentIndexRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url is required"})
+		return
+	}
+	if err := media.CheckWebURL(req.VideoURL); err != nil {
+		c.JSON(400, ErrorResponse{Error: "video_url: " + err.Error()})
+		return
+	}
+	if _, ok := app.communityMarkers(); !ok {
+		c.JSON(404, ErrorResponse{Error: errMarkersDisabled.Error()})
+		return
+	}
+	markers, err := app.scanComments(c.Request.Context(), req.VideoURL, req.DownloadOptions)
+	if err != nil {
+		c.JSON(502, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if markers == nil {
+		markers = []store.CommunityMarker{}
+	}
+	c.JSON(200, CommentMarkersResponse{VideoID: store.VideoKey(req.VideoURL), Markers: markers})
+}
+
+// commentMarkersHandler lists a scanned video's markers for
+// GET /api/comments/:videoID.
+func (app *App) commentMarkersHandler(c *web.Context) {
+	ms, ok := app.communityMarkers()
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: errMarkersDisabled.Error()})
+		return
+	}
+	markers, scanned, err := ms.ListMarkers(c.Request.Context(), c.Param("videoID"))
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !scanned {
+		c.JSON(404, ErrorResponse{Error: "comments not scanned yet"})
+		return
+	}
+	c.JSON(200, CommentMarkersResponse{VideoID: c.Param("videoID"), Markers: markers})
+}
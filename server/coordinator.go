@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"searchme/internal/workfile"
+	"searchme/search"
+)
+
+// Process roles, set by ROLE or the worker command
+const (
+	// RoleAll serves the API and runs queued jobs in one process
+	RoleAll = "all"
+	// RoleCoordinator serves the API and hands searches, sync ones too, to
+	// workers through the job queue
+	RoleCoordinator = "coordinator"
+	// RoleWorker runs queued jobs and serves only health checks
+	RoleWorker = "worker"
+)
+
+// coordinatorPoll is how often a coordinator checks on a sync search it
+// handed to a worker
+const coordinatorPoll = 250 * time.Millisecond
+
+// ErrNoJobBackend is returned by RunWorker without a job queue to work from.
+var ErrNoJobBackend = errors.New("worker mode needs a job queue: set QUEUE_BACKEND=redis")
+
+func (app *App) role() string {
+	if r := strings.ToLower(app.cfg.Role); r != "" {
+		return r
+	}
+	return RoleAll
+}
+
+// coordinating reports whether searches go to workers rather than run here.
+func (app *App) coordinating() bool {
+	return app.role() == RoleCoordinator && app.jobs.Backend() != nil
+}
+
+// RunWorker runs QUEUE_WORKERS queued jobs at a time until the process is
+// stopped, serving /healthz and /readyz on the configured port for
+// orchestrators. Start as many workers as the downloads and transcriptions
+// need boxes; they share the queue with each other and the coordinator.
+func (app *App) RunWorker() error {
+	if app.jobs.Backend() == nil {
+		return ErrNoJobBackend
+	}
+	app.reloadOnSIGHUP()
+	workfile.StartJanitor(janitorConfigFromEnv())
+	go app.warm()
+	app.startJobWorkers(queueWorkers())
+	r := newEngine()
+	r.GET("/healthz", app.healthzHandler)
+	r.GET("/readyz", app.readyzHandler)
+	log.Printf("Worker running, health checks on port %s...", app.cfg.Port)
+	return serve(app.cfg, r)
+}
+
+// queueWorkers is QUEUE_WORKERS, default 2; 0 runs none.
+func queueWorkers() int {
+	if v, err := strconv.Atoi(os.Getenv("QUEUE_WORKERS")); err == nil && v >= 0 {
+		return v
+	}
+	return 2
+}
+
+// searchViaQueue answers a sync search on a coordinator: it queues the
+// search like an async one and waits for a worker to finish it. If the
+// caller goes away the job is cancelled. Searches paid with the caller's
+// OpenAI key run here, as async ones do.
+func (app *App) searchViaQueue(ctx context.Context, req SearchRequest) (search.Response, error) {
+	job, err := app.enqueueSearchJob(req)
+	if err != nil {
+		return search.Response{}, err
+	}
+	backend := app.jobs.Backend()
+	t := time.NewTicker(coordinatorPoll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			stop, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := backend.RequestCancel(stop, job.ID); err != nil {
+				log.Printf("failed to cancel abandoned job %s: %v", job.ID, err)
+			}
+			cancel()
+			return search.Response{}, ctx.Err()
+		case <-t.C:
+		}
+		rec, found, err := backend.LoadJob(ctx, job.ID)
+		if err != nil {
+			log.Printf("coordinator: checking job %s: %v", job.ID, err)
+			continue
+		}
+		if !found {
+			return search.Response{}, errors.New("queued search disappeared from the job store")
+		}
+		switch rec.Job.Status {
+		case JobCompleted:
+			var resp search.Response
+			data, err := json.Marshal(rec.Job.Result)
+			if err == nil {
+				err = json.Unmarshal(data, &resp)
+			}
+			return resp, err
+		case JobFailed, JobCancelled:
+			return search.Response{}, remoteError{msg: rec.Job.Error, code: rec.Job.ErrorCode}
+		}
+	}
+}
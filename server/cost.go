@@ -0,0 +1,273 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+
+	"searchme/internal/env"
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/transcribe"
+)
+
+// CostConfig prices Whisper and caps what one request and one day may spend,
+// in USD.
+type CostConfig struct {
+	PerMinute     float64 // Whisper's price per audio minute
+	MaxPerRequest float64 // estimate above which a request is refused; 0 is no limit
+	DailyBudget   float64 // spend per UTC day above which requests are refused; 0 is no limit
+	// Confirmable lets a request over a limit through when it sets
+	// confirm_cost; otherwise it is refused outright
+	Confirmable bool
+}
+
+// costConfigFromEnv reads WHISPER_COST_PER_MINUTE (0.006),
+// MAX_COST_PER_REQUEST (0, off), DAILY_COST_BUDGET (0, off) and
+// COST_OVER_BUDGET ("confirm", or "reject").
+func costConfigFromEnv() CostConfig {
+	return CostConfig{
+		PerMinute:     env.Float("WHISPER_COST_PER_MINUTE", 0.006),
+		MaxPerRequest: env.Float("MAX_COST_PER_REQUEST", 0),
+		DailyBudget:   env.Float("DAILY_COST_BUDGET", 0),
+		Confirmable:   !strings.EqualFold(env.Or("COST_OVER_BUDGET", "confirm"), "reject"),
+	}
+}
+
+// CostEstimate is what a request is expected to cost against the limits.
+type CostEstimate struct {
+	AudioMinutes  float64 `json:"audio_minutes"`
+	USD           float64 `json:"usd"`
+	MaxPerRequest float64 `json:"max_per_request,omitempty"`
+	DailyBudget   float64 `json:"daily_budget,omitempty"`
+	SpentToday    float64 `json:"spent_today"`
+	// Reserved is held for requests still running
+	Reserved float64 `json:"reserved,omitempty"`
+}
+
+// CostError refuses a request whose estimate is over a limit.
+type CostError struct {
+	Estimate CostEstimate
+	Reason   string
+	// Confirmable is set when resending with confirm_cost would be accepted
+	Confirmable bool
+}
+
+func (e *CostError) Error() string {
+	if e.Confirmable {
+		return e.Reason + "; resend with confirm_cost to accept it"
+	}
+	return e.Reason
+}
+
+// CostResponse is the body of a 402 for a refused estimate.
+type CostResponse struct {
+	Error           string       `json:"error"`
+	Estimate        CostEstimate `json:"estimate"`
+	ConfirmRequired bool         `json:"confirm_required"`
+}
+
+// CostGuard checks Whisper cost estimates against the budgets. Spend is what
+// Whisper actually transcribed (see transcribe.Usage). Pipeline requests
+// also reserve their estimate until they end, so requests running at once
+// don't together go over the daily budget unseen; a request that stops early, e.g.
+// cancelled or answered by the first chunk, gives back what it didn't use.
+type CostGuard struct {
+	mu        sync.Mutex
+	cfg       CostConfig
+	refused   int
+	confirmed int
+	// reserved is the USD held by running requests
+	reserved float64
+}
+
+func NewCostGuard(cfg CostConfig) *CostGuard {
+	return &CostGuard{cfg: cfg}
+}
+
+// Reload applies new prices and limits.
+func (g *CostGuard) Reload(cfg CostConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cfg = cfg
+}
+
+func (g *CostGuard) config() CostConfig {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.cfg
+}
+
+// Estimate prices audioSeconds of Whisper against today's spend.
+func (g *CostGuard) Estimate(audioSeconds float64) CostEstimate {
+	cfg := g.config()
+	return CostEstimate{
+		AudioMinutes:  round2(audioSeconds / 60),
+		USD:           round2(audioSeconds / 60 * cfg.PerMinute),
+		MaxPerRequest: cfg.MaxPerRequest,
+		DailyBudget:   cfg.DailyBudget,
+		SpentToday:    round2(transcribe.UsageToday().Seconds / 60 * cfg.PerMinute),
+		Reserved:      round2(g.reservedUSD()),
+	}
+}
+
+func (g *CostGuard) reservedUSD() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.reserved
+}
+
+// Check refuses audioSeconds of Whisper with a *CostError when it would go
+// over the per-request limit or the daily budget, unless confirmed and the
+// config allows confirming. An unknown length (0) is refused the same way
+// whenever either limit is set, since its cost can't be bounded.
+func (g *CostGuard) Check(audioSeconds float64, confirmed bool) error {
+	cfg := g.config()
+	est := g.Estimate(audioSeconds)
+	var reason string
+	switch {
+	case audioSeconds <= 0 && (cfg.MaxPerRequest > 0 || cfg.DailyBudget > 0):
+		reason = "the media's length is unknown, so its transcription cost can't be checked against the cost limits"
+	case cfg.MaxPerRequest > 0 && est.USD > cfg.MaxPerRequest:
+		reason = fmt.Sprintf("estimated transcription cost $%.2f exceeds the per-request limit of $%.2f", est.USD, cfg.MaxPerRequest)
+	case cfg.DailyBudget > 0 && est.SpentToday+est.Reserved+est.USD > cfg.DailyBudget:
+		reason = fmt.Sprintf("estimated transcription cost $%.2f would take today's spend of $%.2f (plus $%.2f reserved by running requests) over the daily budget of $%.2f", est.USD, est.SpentToday, est.Reserved, cfg.DailyBudget)
+	default:
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if confirmed && cfg.Confirmable {
+		g.confirmed++
+		log.Printf("cost: confirmed over budget: %s", reason)
+		return nil
+	}
+	g.refused++
+	return &CostError{Estimate: est, Reason: reason, Confirmable: cfg.Confirmable}
+}
+
+// Reserve is Check, then holds the estimate against the daily budget until
+// refund is called. Reservations are not spend: they are conservative while
+// the request runs, since its chunks count as spent as they are transcribed
+// too, and refund gives the whole estimate back.
+func (g *CostGuard) Reserve(audioSeconds float64, confirmed bool) (refund func(), err error) {
+	if err := g.Check(audioSeconds, confirmed); err != nil {
+		return func() {}, err
+	}
+	usd := audioSeconds / 60 * g.config().PerMinute
+	g.mu.Lock()
+	g.reserved += usd
+	g.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			g.reserved = math.Max(0, g.reserved-usd)
+			g.mu.Unlock()
+		})
+	}, nil
+}
+
+// approve is the pipeline's ApproveCost hook. Requests paid with the
+// caller's own OpenAI key are not the operator's spend.
+func (g *CostGuard) approve(req search.Request, audioSeconds float64) (func(), error) {
+	if req.OpenAIKey != "" {
+		return func() {}, nil
+	}
+	return g.Reserve(audioSeconds, req.ConfirmCost)
+}
+
+// approveAudio checks the cost of transcribing a downloaded or uploaded
+// file, answering the request itself when the estimate is refused. Callers
+// paying with their own key are let through; without one, they are refused
+// when CLIENT_OPENAI_KEYS is "require".
+func (app *App) approveAudio(c *web.Context, audio *media.AudioFile, confirmed bool) bool {
+	if callerKey(c) != "" {
+		return true
+	}
+	if oai.ClientKeyMode() == oai.ClientKeysRequire {
+		c.JSON(401, ErrorResponse{Error: oai.ErrClientKeyRequired.Error()})
+		return false
+	}
+	d, err := media.ProbeDuration(audio.Path)
+	if err != nil {
+		log.Printf("cost estimate: %v", err)
+	}
+	var costErr *CostError
+	if err := app.costs.Check(d, confirmed); errors.As(err, &costErr) {
+		costError(c, costErr)
+		return false
+	}
+	return true
+}
+
+// CostStats reports spend against the budgets.
+type CostStats struct {
+	PerMinute     float64    `json:"per_minute"`
+	MaxPerRequest float64    `json:"max_per_request,omitempty"`
+	DailyBudget   float64    `json:"daily_budget,omitempty"`
+	Confirmable   bool       `json:"confirmable"`
+	Refused       int        `json:"refused"`
+	Confirmed     int        `json:"confirmed"`
+	Reserved      float64    `json:"reserved"`
+	Days          []DaySpend `json:"days"`
+	Today         DaySpend   `json:"today"`
+}
+
+// DaySpend is one UTC day's Whisper usage and what it cost.
+type DaySpend struct {
+	Date         string  `json:"date"`
+	AudioMinutes float64 `json:"audio_minutes"`
+	Chunks       int     `json:"chunks"`
+	USD          float64 `json:"usd"`
+}
+
+// Stats prices the Whisper usage recorded since startup at the current rate.
+func (g *CostGuard) Stats() CostStats {
+	g.mu.Lock()
+	st := CostStats{
+		PerMinute:     g.cfg.PerMinute,
+		MaxPerRequest: g.cfg.MaxPerRequest,
+		DailyBudget:   g.cfg.DailyBudget,
+		Confirmable:   g.cfg.Confirmable,
+		Refused:       g.refused,
+		Confirmed:     g.confirmed,
+		Reserved:      round2(g.reserved),
+	}
+	g.mu.Unlock()
+
+	spend := func(u transcribe.DayUsage) DaySpend {
+		return DaySpend{
+			Date:         u.Date,
+			AudioMinutes: round2(u.Seconds / 60),
+			Chunks:       u.Chunks,
+			USD:          round2(u.Seconds / 60 * st.PerMinute),
+		}
+	}
+	st.Today = spend(transcribe.UsageToday())
+	st.Days = []DaySpend{}
+	for _, u := range transcribe.Usage() {
+		st.Days = append(st.Days, spend(u))
+	}
+	return st
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// costHandler reports Whisper spend for GET /debug/costs.
+func (app *App) costHandler(c *web.Context) {
+	c.JSON(200, app.costs.Stats())
+}
+
+// costError answers a refused estimate with 402 and the estimate.
+func costError(c *web.Context, err *CostError) {
+	c.JSON(402, CostResponse{Error: err.Error(), Estimate: err.Estimate, ConfirmRequired: err.Confirmable})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+
+	"searchme/internal/deadline"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// Codes for failures clients handle apart from the rest, sent as
+// ErrorResponse.Code and a failed job's error_code.
+const (
+	// CodeVideoUnavailable: the video is removed, private, region or age
+	// locked, or not there at all; asking again won't help
+	CodeVideoUnavailable = "video_unavailable"
+	// CodeNoSubtitles: the video has no captions and the request could not
+	// be answered without them
+	CodeNoSubtitles = "no_subtitles"
+	// CodeTranscriptionFailed: Whisper failed on the video's audio
+	CodeTranscriptionFailed = "transcription_failed"
+	// CodeTimeout: a stage (download, segmentation, chunk transcription)
+	// ran past its time limit; the message names it
+	CodeTimeout = "timeout"
+	// CodeKeywordNotFound: the search ran but the keyword isn't in the
+	// video (only the versioned API fails not-found searches)
+	CodeKeywordNotFound = "keyword_not_found"
+)
+
+// typedErrors map the pipeline's typed errors to their status and code,
+// first match wins: a download that failed because the video is gone, or
+// took too long, also fails its transcription.
+var typedErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{media.ErrVideoUnavailable, 410, CodeVideoUnavailable},
+	{deadline.ErrTimeout, 504, CodeTimeout},
+	{subtitle.ErrNoSubtitles, 422, CodeNoSubtitles},
+	{transcribe.ErrTranscriptionFailed, 502, CodeTranscriptionFailed},
+	{search.ErrKeywordNotFound, 404, CodeKeywordNotFound},
+}
+
+// errorCode is err's status and code when it is one of the typedErrors.
+func errorCode(err error) (status int, code string) {
+	for _, t := range typedErrors {
+		if errors.Is(err, t.err) {
+			return t.status, t.code
+		}
+	}
+	return 0, ""
+}
+
+// typedError answers err with its status and code when it is one of the
+// typedErrors, reporting whether it did.
+func typedError(c *web.Context, err error) bool {
+	status, code := errorCode(err)
+	if code == "" {
+		return false
+	}
+	c.JSON(status, ErrorResponse{Error: err.Error(), Code: code})
+	return true
+}
+
+// remoteError is a failure reported by another process, e.g. a worker
+// through the job store, as its message and code. It is the typed error
+// its code names, so it is answered as if it had happened here.
+type remoteError struct {
+	msg  string
+	code string
+}
+
+func (e remoteError) Error() string { return e.msg }
+
+func (e remoteError) Is(target error) bool {
+	for _, t := range typedErrors {
+		if t.err == target {
+			return t.code == e.code
+		}
+	}
+	return false
+}
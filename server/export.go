@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/store"
+	"searchme/transcribe"
+)
+
+type KaraokeExportRequest struct {
+	VideoURL string `json:"video_url"`
+	// Format is "lrc" (enhanced LRC) or "vtt" (WebVTT with inline word timestamps)
+	Format string `json:"format"`
+	// ConfirmCost accepts a Whisper cost estimate over the server's budget
+	ConfirmCost bool `json:"confirm_cost,omitempty"`
+	media.DownloadOptions
+	transcribe.WhisperOptions
+}
+
+// karaokeExportHandler transcribes the video with word timestamps and returns
+// a word-timed caption file for karaoke-style highlighting.
+func (app *App) karaokeExportHandler(c *web.Context) {
+	var req KaraokeExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = "vtt"
+	}
+	if req.VideoURL == "" || (format != "lrc" && format != "vtt") {
+		c.JSON(400, ErrorResponse{Error: `video_url is required and format must be "lrc" or "vtt"`})
+		return
+	}
+
+	if err := req.WhisperOptions.WithDefaults().Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	dl, err := app.downloader.With(req.DownloadOptions)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	audio, err := src.DownloadAudio(c.Request.Context())
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer audio.Remove()
+	if !app.approveAudio(c, audio, req.ConfirmCost) {
+		return
+	}
+
+	release, err := app.limiter.AcquireTranscription(c.Request.Context())
+	if err != nil {
+		c.JSON(503, ErrorResponse{Error: err.Error()})
+		return
+	}
+	transcript, err := transcribe.Audio(transcribe.WithOptions(callerContext(c), req.WhisperOptions), audio, nil)
+	release()
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: fmt.Sprintf("failed to transcribe: %v", err)})
+		return
+	}
+	if len(transcript.Words) == 0 {
+		c.JSON(422, ErrorResponse{Error: "transcription returned no word timings"})
+		return
+	}
+
+	filename := store.VideoKey(req.VideoURL) + "." + format
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == "lrc" {
+		c.Data(200, "text/plain; charset=utf-8", []byte(BuildLRC(transcript)))
+		return
+	}
+	c.Data(200, "text/vtt; charset=utf-8", []byte(BuildWordVTT(transcript)))
+}
+
+// wordsBySegment assigns each word to the segment whose span contains its start.
+// Words outside every segment start a line of their own.
+func wordsBySegment(t transcribe.Transcript) [][]transcribe.Word {
+	var lines [][]transcribe.Word
+	wi := 0
+	for _, seg := range t.Segments {
+		var line []transcribe.Word
+		for wi < len(t.Words) && t.Words[wi].Start < seg.End {
+			line = append(line, t.Words[wi])
+			wi++
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if wi < len(t.Words) {
+		lines = append(lines, t.Words[wi:])
+	}
+	return lines
+}
+
+// BuildLRC renders enhanced LRC: a line timestamp followed by per-word <mm:ss.xx> tags.
+func BuildLRC(t transcribe.Transcript) string {
+	var b strings.Builder
+	for _, line := range wordsBySegment(t) {
+		fmt.Fprintf(&b, "[%s]", lrcTime(line[0].Start))
+		for _, w := range line {
+			fmt.Fprintf(&b, " <%s> %s", lrcTime(w.Start), strings.TrimSpace(w.Word))
+		}
+		fmt.Fprintf(&b, " <%s>\n", lrcTime(line[len(line)-1].End))
+	}
+	return b.String()
+}
+
+// BuildWordVTT renders one cue per segment with inline timestamp tags before each word,
+// which players use to highlight words as they are spoken.
+func BuildWordVTT(t transcribe.Transcript) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, line := range wordsBySegment(t) {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n", i+1, vttTime(line[0].Start), vttTime(line[len(line)-1].End))
+		for j, w := range line {
+			if j > 0 {
+				b.WriteByte(' ')
+				fmt.Fprintf(&b, "<%s>", vttTime(w.Start))
+			}
+			fmt.Fprintf(&b, "<c>%s</c>", strings.TrimSpace(w.Word))
+		}
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// lrcTime formats seconds as mm:ss.xx
+func lrcTime(seconds float64) string {
+	cs := int(seconds*100 + 0.5)
+	return fmt.Sprintf("%02d:%02d.%02d", cs/6000, (cs/100)%60, cs%100)
+}
+
+// vttTime formats seconds as HH:MM:SS.mmm
+func vttTime(seconds float64) string {
+	ms := int(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
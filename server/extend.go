@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/search"
+	"searchme/store"
+)
+
+// ExtendRequest asks for more of a partial transcript. Of the search fields
+// only video_url, language and the download options are used; video_url is
+// needed only for videos not in the index yet that aren't YouTube videos.
+type ExtendRequest struct {
+	search.Request
+	// Ranges are the parts to transcribe; empty continues from the first
+	// part not covered yet
+	Ranges []search.TimeRange `json:"ranges,omitempty"`
+	// Minutes caps the audio this call transcribes; 0 reads EXTEND_MINUTES
+	// (default 10)
+	Minutes float64 `json:"minutes,omitempty"`
+}
+
+// ExtendResponse reports what one extend call added.
+type ExtendResponse struct {
+	VideoID string `json:"video_id"`
+	// Added is how many segments were added to the transcript
+	Added       int                `json:"added"`
+	Transcribed []search.TimeRange `json:"transcribed"`
+	Coverage    *search.Coverage   `json:"coverage"`
+}
+
+var youTubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// extendTranscriptHandler answers POST /api/transcripts/:videoID/extend by
+// transcribing parts of the video its indexed transcript doesn't cover yet,
+// so a long video's transcript can be built up over several cheap requests.
+// The index keeps the partial transcript and what it covers; once all of
+// the video is covered it is a complete transcript like any other.
+func (app *App) extendTranscriptHandler(c *web.Context) {
+	if app.store == nil {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	var req ExtendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	for _, r := range req.Ranges {
+		if r.Start < 0 || r.End <= r.Start {
+			c.JSON(400, ErrorResponse{Error: "ranges need 0 <= start < end"})
+			return
+		}
+	}
+	if req.Minutes < 0 {
+		c.JSON(400, ErrorResponse{Error: "minutes must not be negative"})
+		return
+	}
+	if req.Minutes == 0 {
+		req.Minutes = env.Float("EXTEND_MINUTES", 10)
+	}
+	if err := app.downloader.AudioSettings().Merge(req.AudioSettings).Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := req.WhisperOptions.WithDefaults().Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	videoID := c.Param("videoID")
+	rec, found, err := app.store.GetTranscript(ctx, videoID)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	switch {
+	case found && !rec.Partial():
+		c.JSON(409, ErrorResponse{Error: "the transcript already covers the whole video"})
+		return
+	case found:
+		req.VideoURL, req.Language = rec.VideoURL, rec.Language
+	case req.VideoURL != "":
+		if store.VideoKey(req.VideoURL) != videoID {
+			c.JSON(400, ErrorResponse{Error: "video_url is not the video " + videoID})
+			return
+		}
+	case youTubeID.MatchString(videoID):
+		req.VideoURL = "https://www.youtube.com/watch?v=" + videoID
+	default:
+		c.JSON(400, ErrorResponse{Error: "video_url is required for videos not in the index"})
+		return
+	}
+	req.OpenAIKey = callerKey(c)
+
+	var covered []search.TimeRange
+	for _, sp := range rec.Covered {
+		covered = append(covered, search.TimeRange{Start: sp.Start, End: sp.End})
+	}
+	res, err := app.pipeline.Extend(ctx, req.Request, covered, req.Ranges, req.Minutes*60)
+	if errors.Is(err, search.ErrFullyCovered) {
+		c.JSON(409, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+
+	segments := append(rec.Segments, res.Entries...)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	next := store.TranscriptRecord{
+		VideoID:  videoID,
+		VideoURL: req.VideoURL,
+		Language: search.NormalizeLang(req.Language),
+		Source:   search.SourceChunkedTranscription,
+		Duration: res.Coverage.Duration,
+		Segments: segments,
+	}
+	quality := search.TranscriptQuality(segments, next.Source)
+	next.Quality, next.QualityFlags = quality.Score, quality.Flags
+	if !res.Coverage.Complete {
+		for _, r := range res.Coverage.Scanned {
+			next.Covered = append(next.Covered, store.Span{Start: r.Start, End: r.End})
+		}
+	}
+	if err := app.saveTranscript(ctx, next); err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(200, ExtendResponse{VideoID: videoID, Added: len(res.Entries), Transcribed: res.Transcribed, Coverage: res.Coverage})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"searchme/internal/web"
+	"searchme/search"
+	"searchme/store"
+)
+
+// FeedbackRequest confirms or rejects a published result's match.
+type FeedbackRequest struct {
+	ResultID string `json:"result_id"`
+	Correct  *bool  `json:"correct"`
+	// CorrectedSeconds optionally says where the keyword really is
+	CorrectedSeconds *float64 `json:"corrected_seconds,omitempty"`
+}
+
+// loadCalibrator replays the feedback kept in the store, when it keeps any.
+func loadCalibrator(st store.TranscriptStore) *search.Calibrator {
+	c := search.NewCalibratorFromEnv()
+	fs, ok := st.(store.FeedbackStore)
+	if !ok {
+		return c
+	}
+	counts, err := fs.CountFeedback(context.Background())
+	if err != nil {
+		log.Printf("failed to load feedback: %v", err)
+		return c
+	}
+	for _, fc := range counts {
+		c.Record(fc.Source, fc.Partial, fc.Correct, fc.Count)
+	}
+	return c
+}
+
+// feedbackHandler records whether a published result was right, and where the
+// keyword really is when the user knows. Verdicts on matches calibrate the
+// scores of later matches of the same kind.
+func (app *App) feedbackHandler(c *web.Context) {
+	var req FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.ResultID == "" || req.Correct == nil {
+		c.JSON(400, ErrorResponse{Error: "result_id and correct are required"})
+		return
+	}
+	if req.CorrectedSeconds != nil && *req.CorrectedSeconds < 0 {
+		c.JSON(400, ErrorResponse{Error: "corrected_seconds must not be negative"})
+		return
+	}
+	r, found, err := app.results.GetResult(c.Request.Context(), req.ResultID)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(404, ErrorResponse{Error: "result not found"})
+		return
+	}
+
+	fb := store.Feedback{
+		ResultID:         r.ID,
+		Source:           r.Source,
+		Partial:          r.Partial,
+		Seconds:          r.Seconds,
+		Correct:          *req.Correct,
+		CorrectedSeconds: req.CorrectedSeconds,
+		CreatedAt:        time.Now().UTC(),
+	}
+	if fs, ok := app.store.(store.FeedbackStore); ok {
+		if err := fs.SaveFeedback(c.Request.Context(), fb); err != nil {
+			c.JSON(500, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	if r.Found {
+		app.calibrator.Record(fb.Source, fb.Partial, fb.Correct, 1)
+	}
+	c.JSON(201, fb)
+}
+
+// listFeedbackHandler lists stored feedback, newest first, optionally for
+// one result (?result_id=) and capped by ?limit= (default 100).
+func (app *App) listFeedbackHandler(c *web.Context) {
+	fs, ok := app.store.(store.FeedbackStore)
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	list, err := fs.ListFeedback(c.Request.Context(), c.Query("result_id"), limit)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(200, web.H{"feedback": list})
+}
+
+// calibrationHandler shows the feedback the confidence scores are calibrated on.
+func (app *App) calibrationHandler(c *web.Context) {
+	c.JSON(200, web.H{"stats": app.calibrator.Stats(), "strict_words": app.calibrator.StrictWords()})
+}
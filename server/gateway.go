@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+)
+
+const (
+	ServedByCache    = "cache"
+	ServedByUpstream = "upstream"
+)
+
+// UpstreamError marks a failure of the delegated search, reported as 502.
+type UpstreamError struct {
+	Err error
+}
+
+func (e *UpstreamError) Error() string { return e.Err.Error() }
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+// Upstream is another instance this one delegates to when it has no cached
+// transcript, e.g. an edge cache in front of a central transcriber.
+type Upstream struct {
+	baseURL       string
+	apiKey        string
+	localFallback bool
+	client        *http.Client
+}
+
+// NewUpstreamFromEnv configures gateway mode from UPSTREAM_URL, UPSTREAM_API_KEY,
+// UPSTREAM_TIMEOUT (default 15m) and UPSTREAM_LOCAL_FALLBACK (run the local
+// pipeline when the upstream fails). Returns nil when UPSTREAM_URL is unset.
+func NewUpstreamFromEnv() *Upstream {
+	base := strings.TrimRight(os.Getenv("UPSTREAM_URL"), "/")
+	if base == "" {
+		return nil
+	}
+	timeout := 15 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("UPSTREAM_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	fallback, _ := strconv.ParseBool(os.Getenv("UPSTREAM_LOCAL_FALLBACK"))
+	log.Printf("Gateway mode: delegating cache misses to %s", base)
+	return &Upstream{
+		baseURL:       base,
+		apiKey:        os.Getenv("UPSTREAM_API_KEY"),
+		localFallback: fallback,
+		client:        &http.Client{Timeout: timeout},
+	}
+}
+
+// Search forwards a search request, and the caller's OpenAI key if any, to
+// the upstream instance.
+func (u *Upstream) Search(ctx context.Context, req SearchRequest) (search.Response, error) {
+	var resp search.Response
+	body, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	err = u.do(oai.WithKey(ctx, req.OpenAIKey), http.MethodPost, "/api/search", bytes.NewReader(body), &resp)
+	return resp, err
+}
+
+// Transcript fetches the upstream's stored transcript for a video.
+func (u *Upstream) Transcript(ctx context.Context, videoID string) (store.TranscriptRecord, error) {
+	var t TranscriptPayload
+	if err := u.do(ctx, http.MethodGet, "/api/transcripts/"+url.PathEscape(videoID), nil, &t); err != nil {
+		return store.TranscriptRecord{}, err
+	}
+	rec := t.TranscriptRecord
+	rec.Segments = t.Segments
+	return rec, nil
+}
+
+func (u *Upstream) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, u.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key := oai.Key(ctx); key != "" {
+		req.Header.Set(openAIKeyHeader, key)
+	}
+	if u.apiKey != "" {
+		req.Header.Set("X-API-Key", u.apiKey)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("upstream request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e ErrorResponse
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&e)
+		return fmt.Errorf("upstream returned %d: %s", resp.StatusCode, e.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// searchCached answers from the local transcript index. ok is false on a cache miss
+// or when the stored transcript is in a different language than requested.
+func (app *App) searchCached(ctx context.Context, req SearchRequest) (search.Response, bool) {
+	if app.store == nil || len(req.Languages) > 0 {
+		// the index keeps one track per video
+		return search.Response{}, false
+	}
+	rec, found, err := app.store.GetTranscript(ctx, store.VideoKey(req.VideoURL))
+	if err != nil {
+		log.Printf("cache lookup failed: %v", err)
+		return search.Response{}, false
+	}
+	if !found || len(rec.Segments) == 0 {
+		return search.Response{}, false
+	}
+	if req.Language != "" && !strings.EqualFold(search.NormalizeLang(req.Language), rec.Language) {
+		return search.Response{}, false
+	}
+	resp := search.InSegments(req.Request, rec.Segments, rec.Source, rec.Language)
+	if rec.Partial() && !(resp.Found && req.Limit == 0 && len(rec.Covered) > 0 && rec.Covered[0].Start <= 0.5 && resp.Seconds <= rec.Covered[0].End) {
+		// a partial transcript only answers for what it covers from the
+		// start: a miss, or a hit past a gap, may not be the earliest
+		return search.Response{}, false
+	}
+	if rec.Quality > 0 {
+		// decoder statistics aren't kept in the index; use the score taken at indexing time
+		resp.Quality = &search.Quality{Score: rec.Quality, Flags: rec.QualityFlags}
+	}
+	return resp, true
+}
+
+// trySearchUpstream delegates to the upstream when one is configured. delegated is
+// false when the local pipeline should run: no upstream, or it failed and
+// UPSTREAM_LOCAL_FALLBACK is set.
+func (app *App) trySearchUpstream(ctx context.Context, req SearchRequest) (resp search.Response, delegated bool, err error) {
+	if app.upstream == nil {
+		return resp, false, nil
+	}
+	resp, err = app.searchViaUpstream(ctx, req)
+	if err != nil && app.upstream.localFallback {
+		log.Printf("upstream search failed, running locally: %v", err)
+		return resp, false, nil
+	}
+	return resp, true, err
+}
+
+// searchViaUpstream delegates the search, then pulls the transcript the upstream
+// now holds into the local index so the next search for the video is local.
+func (app *App) searchViaUpstream(ctx context.Context, req SearchRequest) (search.Response, error) {
+	resp, err := app.upstream.Search(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	resp.ServedBy = ServedByUpstream
+
+	if app.store != nil {
+		rec, err := app.upstream.Transcript(ctx, store.VideoKey(req.VideoURL))
+		if err != nil {
+			log.Printf("could not cache upstream transcript: %v", err)
+		} else if err := app.saveTranscript(ctx, rec); err != nil {
+			log.Printf("could not cache upstream transcript: %v", err)
+		}
+	}
+	return resp, nil
+}
+
+// TranscriptPayload is the wire form of a stored transcript.
+type TranscriptPayload struct {
+	store.TranscriptRecord
+	Segments []subtitle.Entry `json:"segments"`
+}
+
+// transcriptHandler serves a stored transcript: GET /api/transcripts/:videoID.
+func (app *App) transcriptHandler(c *web.Context) {
+	if app.store == nil {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	rec, found, err := app.store.GetTranscript(c.Request.Context(), c.Param("videoID"))
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(404, ErrorResponse{Error: "transcript not found"})
+		return
+	}
+	c.JSON(200, TranscriptPayload{TranscriptRecord: rec, Segments: redactedSegments(rec.Segments)})
+}
@@ -0,0 +1,451 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"searchme/internal/web"
+	pb "searchme/proto/videosearchpb"
+	"searchme/search"
+	"searchme/transcribe"
+)
+
+// The gRPC API of proto/videosearch.proto, served on GRPC_PORT with the
+// generated types in proto/videosearchpb. Each call is answered by the
+// matching REST route in-process, so API keys, limits, warmup, validation
+// and the job queue behave exactly as they do over REST. Streams start or
+// look up their job that way once, then follow it directly.
+
+// grpcMaxMessage bounds a request message
+const grpcMaxMessage = 4 << 20
+
+// grpcWatchPoll is how often a stream checks on a job another replica runs,
+// in its job backend; jobs run here report their changes as they happen
+const grpcWatchPoll = 500 * time.Millisecond
+
+// grpcMetadata maps the metadata a call may carry to the REST headers it
+// stands for.
+var grpcMetadata = map[string]string{
+	"x-api-key":    "X-API-Key",
+	"x-openai-key": openAIKeyHeader,
+}
+
+// serveGRPC serves the gRPC API over plaintext HTTP/2 on port until it
+// fails, answering calls through rest, the REST router. Put it behind a
+// TLS-terminating proxy or mesh when it leaves a private network.
+func serveGRPC(port string, app *App, rest http.Handler) error {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(grpcMaxMessage))
+	pb.RegisterVideoSearchServer(srv, &grpcServer{app: app, rest: rest})
+	log.Printf("Serving gRPC on :%s", port)
+	return srv.Serve(lis)
+}
+
+type grpcServer struct {
+	pb.UnimplementedVideoSearchServer
+	app  *App
+	rest http.Handler
+}
+
+// grpcCodeForHTTP maps a REST status to the gRPC code that means the same.
+func grpcCodeForHTTP(status int) codes.Code {
+	switch status {
+	case 400, 413, 422:
+		return codes.InvalidArgument
+	case 401:
+		return codes.Unauthenticated
+	case 402, 409, 410:
+		return codes.FailedPrecondition
+	case 403:
+		return codes.PermissionDenied
+	case 404:
+		return codes.NotFound
+	case 429:
+		return codes.ResourceExhausted
+	case 502, 503:
+		return codes.Unavailable
+	case 504:
+		return codes.DeadlineExceeded
+	default:
+		if status >= 500 {
+			return codes.Internal
+		}
+		return codes.Unknown
+	}
+}
+
+// do sends a REST request in-process with the call's credentials and
+// decodes the JSON reply into out. Error replies become the gRPC status
+// matching their HTTP one.
+func (g *grpcServer) do(ctx context.Context, method, path string, body, out interface{}) error {
+	return g.doAs(ctx, ctx, method, path, body, out)
+}
+
+// doAs is do running under ctx with the credentials of call, the context
+// of the gRPC call, e.g. to clean up after a call that has ended.
+func (g *grpcServer) doAs(call, ctx context.Context, method, path string, body, out interface{}) error {
+	var rd io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, rd)
+	if err != nil {
+		return err
+	}
+	if p, ok := peer.FromContext(call); ok {
+		req.RemoteAddr = p.Addr.String()
+	}
+	req.Header.Set("Content-Type", web.MIMEJSON)
+	md, _ := metadata.FromIncomingContext(call)
+	for key, header := range grpcMetadata {
+		if v := md.Get(key); len(v) > 0 && v[0] != "" {
+			req.Header.Set(header, v[0])
+		}
+	}
+	rec := &restRecorder{header: http.Header{}, status: http.StatusOK}
+	g.rest.ServeHTTP(rec, req)
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if rec.status >= 300 {
+		var e ErrorResponse
+		if json.Unmarshal(rec.body.Bytes(), &e) != nil || e.Error == "" {
+			e.Error = http.StatusText(rec.status)
+		}
+		return status.Error(grpcCodeForHTTP(rec.status), e.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(rec.body.Bytes(), out)
+}
+
+// restRecorder captures an in-process REST reply.
+type restRecorder struct {
+	header http.Header
+	status int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func (r *restRecorder) Header() http.Header { return r.header }
+
+func (r *restRecorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status, r.wrote = status, true
+	}
+}
+
+func (r *restRecorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(b)
+}
+
+// Flush is a no-op: the reply is only read once the handler returns.
+func (r *restRecorder) Flush() {}
+
+// jobReply is a job as GET /api/jobs/:id returns it, keeping the result as
+// JSON.
+type jobReply struct {
+	JobSnapshot
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// replyOf is the jobReply REST would give for a snapshot.
+func replyOf(s JobSnapshot) (jobReply, error) {
+	job := jobReply{JobSnapshot: s}
+	if s.Result == nil {
+		return job, nil
+	}
+	raw, err := json.Marshal(s.Result)
+	if err != nil {
+		return job, err
+	}
+	job.Result = raw
+	return job, nil
+}
+
+func (j jobReply) finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+func (j jobReply) transcription() *transcribe.Progress {
+	if len(j.Items) == 0 {
+		return nil
+	}
+	return j.Items[0].Transcription
+}
+
+func (g *grpcServer) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	var raw json.RawMessage
+	if err := g.do(ctx, http.MethodPost, "/api/search", searchRequestOf(in), &raw); err != nil {
+		return nil, err
+	}
+	return searchResponseOf(raw)
+}
+
+// SearchStream runs the search as a job, streaming its Whisper progress
+// and then its result. The job is cancelled if the caller goes away.
+func (g *grpcServer) SearchStream(in *pb.SearchRequest, stream grpc.ServerStreamingServer[pb.SearchEvent]) error {
+	ctx := stream.Context()
+	req := searchRequestOf(in)
+	req.Async = true
+	var accepted JobAcceptedResponse
+	if err := g.do(ctx, http.MethodPost, "/api/search", req, &accepted); err != nil {
+		return err
+	}
+	var last *pb.Progress
+	err := g.follow(ctx, accepted.JobID, func(job jobReply) error {
+		if p := job.transcription(); p != nil {
+			if msg := progressOf(*p); !proto.Equal(msg, last) {
+				last = msg
+				if err := stream.Send(&pb.SearchEvent{Event: &pb.SearchEvent_Progress{Progress: msg}}); err != nil {
+					return err
+				}
+			}
+		}
+		switch job.Status {
+		case JobCompleted:
+			result, err := searchResponseOf(job.Result)
+			if err != nil {
+				return err
+			}
+			return stream.Send(&pb.SearchEvent{Event: &pb.SearchEvent_Result{Result: result}})
+		case JobFailed:
+			return status.Error(codes.Unknown, job.Error)
+		case JobCancelled:
+			return status.Error(codes.Canceled, "search was cancelled")
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		stop, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		body := map[string]string{"cancel_token": accepted.CancelToken}
+		if cerr := g.doAs(ctx, stop, http.MethodPost, "/api/jobs/"+url.PathEscape(accepted.JobID)+"/cancel", body, nil); cerr != nil {
+			log.Printf("failed to cancel abandoned job %s: %v", accepted.JobID, cerr)
+		}
+	}
+	return err
+}
+
+// follow passes the job to fn each time it changes until it finishes, fn
+// fails or the call ends. A job run here is followed as it is updated, one
+// run elsewhere by polling the job backend.
+func (g *grpcServer) follow(ctx context.Context, id string, fn func(jobReply) error) error {
+	if j, ok := g.app.jobs.Get(id); ok {
+		for {
+			s, changed := j.Watch()
+			job, err := replyOf(s)
+			if err != nil {
+				return err
+			}
+			if err := fn(job); err != nil || job.finished() {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			case <-changed:
+			}
+		}
+	}
+	t := time.NewTicker(grpcWatchPoll)
+	defer t.Stop()
+	for {
+		rec, ok, err := g.app.jobs.Lookup(ctx, id)
+		switch {
+		case err != nil:
+			return status.Error(codes.Unavailable, "job store unavailable: "+err.Error())
+		case !ok:
+			return status.Error(codes.NotFound, "job not found")
+		}
+		job, err := replyOf(rec.Job)
+		if err != nil {
+			return err
+		}
+		if err := fn(job); err != nil || job.finished() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (g *grpcServer) GetTranscript(ctx context.Context, in *pb.TranscriptRequest) (*pb.Transcript, error) {
+	if in.GetVideoId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "video_id is required")
+	}
+	var t TranscriptPayload
+	if err := g.do(ctx, http.MethodGet, "/api/transcripts/"+url.PathEscape(in.GetVideoId()), nil, &t); err != nil {
+		return nil, err
+	}
+	out := &pb.Transcript{
+		VideoId:  t.VideoID,
+		VideoUrl: t.VideoURL,
+		Language: t.Language,
+		Source:   t.Source,
+		Partial:  t.Partial(),
+	}
+	for _, s := range t.Segments {
+		out.Segments = append(out.Segments, &pb.Segment{Start: s.Start, End: s.End, Text: s.Text})
+	}
+	return out, nil
+}
+
+func (g *grpcServer) StartSearchJob(ctx context.Context, in *pb.SearchRequest) (*pb.JobAccepted, error) {
+	req := searchRequestOf(in)
+	req.Async = true
+	var accepted JobAcceptedResponse
+	if err := g.do(ctx, http.MethodPost, "/api/search", req, &accepted); err != nil {
+		return nil, err
+	}
+	return &pb.JobAccepted{JobId: accepted.JobID, CancelToken: accepted.CancelToken}, nil
+}
+
+func (g *grpcServer) GetJob(ctx context.Context, in *pb.JobRequest) (*pb.Job, error) {
+	if in.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	var job jobReply
+	if err := g.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(in.GetId()), nil, &job); err != nil {
+		return nil, err
+	}
+	return jobOf(job), nil
+}
+
+// WatchJob streams the job each time it changes, ending once it finishes.
+// The call is authorized by looking the job up over REST once.
+func (g *grpcServer) WatchJob(in *pb.JobRequest, stream grpc.ServerStreamingServer[pb.Job]) error {
+	first, err := g.GetJob(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(first); err != nil {
+		return err
+	}
+	last := first
+	return g.follow(stream.Context(), in.GetId(), func(job jobReply) error {
+		msg := jobOf(job)
+		if proto.Equal(msg, last) {
+			return nil
+		}
+		last = msg
+		return stream.Send(msg)
+	})
+}
+
+func (g *grpcServer) CancelJob(ctx context.Context, in *pb.CancelJobRequest) (*pb.Job, error) {
+	if in.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	body := map[string]string{"cancel_token": in.GetCancelToken()}
+	var job jobReply
+	if err := g.do(ctx, http.MethodPost, "/api/jobs/"+url.PathEscape(in.GetId())+"/cancel", body, &job); err != nil {
+		return nil, err
+	}
+	return jobOf(job), nil
+}
+
+func searchRequestOf(in *pb.SearchRequest) SearchRequest {
+	var req SearchRequest
+	req.VideoURL = in.GetVideoUrl()
+	req.Keyword = in.GetKeyword()
+	req.Language = in.GetLanguage()
+	req.Languages = in.GetLanguages()
+	req.Limit = int(in.GetLimit())
+	req.MatchMode = in.GetMatch()
+	req.Stem = in.GetStem()
+	req.BudgetMinutes = in.GetBudgetMinutes()
+	req.ConfirmCost = in.GetConfirmCost()
+	req.Chapter = in.GetChapter()
+	req.Translate = in.GetTranslate()
+	return req
+}
+
+// searchResponseOf turns a REST search reply into a SearchResponse,
+// keeping the reply itself in the json field.
+func searchResponseOf(raw json.RawMessage) (*pb.SearchResponse, error) {
+	var resp search.Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, status.Errorf(codes.Internal, "malformed search reply: %v", err)
+	}
+	out := &pb.SearchResponse{
+		Found:      resp.Found,
+		Seconds:    resp.Seconds,
+		EndSeconds: resp.EndSeconds,
+		Time:       resp.Time,
+		Url:        resp.URL,
+		Source:     resp.Source,
+		Confidence: resp.Confidence,
+		Language:   resp.Language,
+		Chapter:    resp.Chapter,
+		Score:      resp.Score,
+		Json:       string(raw),
+	}
+	for _, m := range resp.Matches {
+		out.Matches = append(out.Matches, &pb.Match{
+			Seconds:    m.Seconds,
+			EndSeconds: m.EndSeconds,
+			Time:       m.Time,
+			Url:        m.URL,
+			Text:       m.Text,
+			Score:      m.Score,
+			Language:   m.Language,
+		})
+	}
+	return out, nil
+}
+
+func progressOf(p transcribe.Progress) *pb.Progress {
+	return &pb.Progress{
+		ChunksDone:  int32(p.ChunksDone),
+		ChunksTotal: int32(p.ChunksTotal),
+		AudioDone:   p.AudioDone,
+		AudioTotal:  p.AudioTotal,
+		Elapsed:     p.Elapsed,
+		Eta:         p.ETA,
+	}
+}
+
+func jobOf(job jobReply) *pb.Job {
+	out := &pb.Job{
+		Id:         job.ID,
+		Kind:       job.Kind,
+		Status:     string(job.Status),
+		Error:      job.Error,
+		Total:      int32(job.Total),
+		Done:       int32(job.Done),
+		Failed:     int32(job.Failed),
+		Progress:   job.Progress,
+		ResultJson: string(job.Result),
+	}
+	if p := job.transcription(); p != nil {
+		out.Transcription = progressOf(*p)
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/search"
+)
+
+// ErrOverloaded is returned when the guard sheds a pipeline run instead of
+// risking the process running out of memory.
+var ErrOverloaded = errors.New("server overloaded, try again later")
+
+// GuardConfig sets the limits past which new pipeline runs are refused.
+type GuardConfig struct {
+	MaxPipelines  int    // pipeline runs at once, from any route or background job; 0 is no limit
+	MaxHeapBytes  uint64 // live heap above which new runs are refused; 0 is no limit
+	MaxGoroutines int    // goroutines above which new runs are refused; 0 is no limit
+	MaxSegments   int    // segments one transcript may load into memory
+	// Wait is how long a run may wait for a pipeline slot
+	Wait time.Duration
+}
+
+// guardConfigFromEnv reads MAX_PIPELINES (4), MAX_HEAP_MB (0, off),
+// MAX_GOROUTINES (10000), MAX_SEGMENTS (200000) and PIPELINE_WAIT (30s).
+func guardConfigFromEnv() GuardConfig {
+	return GuardConfig{
+		MaxPipelines:  env.Int("MAX_PIPELINES", 4),
+		MaxHeapBytes:  uint64(env.Int("MAX_HEAP_MB", 0)) << 20,
+		MaxGoroutines: env.Int("MAX_GOROUTINES", 10000),
+		MaxSegments:   env.Int("MAX_SEGMENTS", 200000),
+		Wait:          env.Duration("PIPELINE_WAIT", 30*time.Second),
+	}
+}
+
+// Guard caps concurrent pipeline runs and refuses new ones while the heap or
+// goroutine count is over its limit, so a burst of long videos is answered
+// with 503s rather than an OOM kill.
+type Guard struct {
+	mu        sync.Mutex
+	cfg       GuardConfig
+	pipelines chan struct{}
+}
+
+func NewGuard(cfg GuardConfig) *Guard {
+	return &Guard{cfg: cfg, pipelines: make(chan struct{}, cfg.MaxPipelines)}
+}
+
+// GuardStats is the guard's view of the process.
+type GuardStats struct {
+	Pipelines     int    `json:"pipelines"`
+	MaxPipelines  int    `json:"max_pipelines"`
+	HeapBytes     uint64 `json:"heap_bytes"`
+	MaxHeapBytes  uint64 `json:"max_heap_bytes,omitempty"`
+	Goroutines    int    `json:"goroutines"`
+	MaxGoroutines int    `json:"max_goroutines,omitempty"`
+	MaxSegments   int    `json:"max_segments"`
+}
+
+// AcquirePipeline waits for a pipeline slot, failing fast with ErrOverloaded
+// when memory or goroutines are already over their limits.
+func (g *Guard) AcquirePipeline(ctx context.Context) (func(), error) {
+	cfg, pipelines := g.state()
+	if heap := heapBytes(); cfg.MaxHeapBytes > 0 && heap > cfg.MaxHeapBytes {
+		return nil, fmt.Errorf("%w: heap at %d MB", ErrOverloaded, heap>>20)
+	}
+	if n := runtime.NumGoroutine(); cfg.MaxGoroutines > 0 && n > cfg.MaxGoroutines {
+		return nil, fmt.Errorf("%w: %d goroutines", ErrOverloaded, n)
+	}
+	if cfg.MaxPipelines <= 0 {
+		return func() {}, nil
+	}
+	timer := time.NewTimer(cfg.Wait)
+	defer timer.Stop()
+	select {
+	case pipelines <- struct{}{}:
+		return func() { <-pipelines }, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: all %d pipelines busy", ErrOverloaded, cfg.MaxPipelines)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a pipeline slot: %w", ctx.Err())
+	}
+}
+
+// Reload applies new limits; runs in flight keep their slots. MaxSegments
+// is read once at startup and is not reloaded.
+func (g *Guard) Reload(cfg GuardConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cfg.MaxPipelines != g.cfg.MaxPipelines {
+		g.pipelines = make(chan struct{}, cfg.MaxPipelines)
+	}
+	cfg.MaxSegments = g.cfg.MaxSegments
+	g.cfg = cfg
+}
+
+func (g *Guard) state() (GuardConfig, chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.cfg, g.pipelines
+}
+
+// Stats reports current usage against the limits.
+func (g *Guard) Stats() GuardStats {
+	cfg, pipelines := g.state()
+	return GuardStats{
+		Pipelines:     len(pipelines),
+		MaxPipelines:  cfg.MaxPipelines,
+		HeapBytes:     heapBytes(),
+		MaxHeapBytes:  cfg.MaxHeapBytes,
+		Goroutines:    runtime.NumGoroutine(),
+		MaxGoroutines: cfg.MaxGoroutines,
+		MaxSegments:   cfg.MaxSegments,
+	}
+}
+
+// heapBytes reads the live heap without stopping the world.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// guardHandler shows the guardrails for GET /debug/guard.
+func (app *App) guardHandler(c *web.Context) {
+	c.JSON(200, app.guard.Stats())
+}
+
+// pipelineError answers a failed pipeline run: 503 with Retry-After when the
+// guard shed it or the work directory is full, 401 when it needed the caller's own OpenAI key, 402 when
+// its cost estimate was refused, 413 when the transcript was too large to
+// hold, the status and code of a typed error (see typedErrors), else 500.
+func pipelineError(c *web.Context, err error) {
+	var costErr *CostError
+	switch {
+	case errors.Is(err, oai.ErrClientKeyRequired):
+		c.JSON(401, ErrorResponse{Error: err.Error()})
+	case errors.As(err, &costErr):
+		costError(c, costErr)
+	case errors.Is(err, ErrOverloaded), errors.Is(err, workfile.ErrQuotaExceeded):
+		c.Header("Retry-After", "30")
+		c.JSON(503, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, search.ErrTooManySegments):
+		c.JSON(413, ErrorResponse{Error: err.Error()})
+	case typedError(c, err):
+	default:
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/media"
+)
+
+// Health statuses
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// HealthResponse is the body of /healthz and /readyz.
+type HealthResponse struct {
+	Status    string                  `json:"status"`
+	Checks    []media.DependencyCheck `json:"checks"`
+	CheckedAt time.Time               `json:"checked_at"`
+}
+
+// healthChecker runs the dependency checks, caching the result for
+// HEALTH_CHECK_TTL (default 30s) so frequent probes don't fork a process each.
+type healthChecker struct {
+	downloader *media.Downloader
+	ttl        time.Duration
+
+	mu   sync.Mutex
+	last HealthResponse
+}
+
+func newHealthChecker(dl *media.Downloader) *healthChecker {
+	return &healthChecker{downloader: dl, ttl: env.Duration("HEALTH_CHECK_TTL", 30*time.Second)}
+}
+
+// Reset drops the cached result so the next probe checks again.
+func (h *healthChecker) Reset() {
+	h.mu.Lock()
+	h.last = HealthResponse{}
+	h.mu.Unlock()
+}
+
+func (h *healthChecker) Check(ctx context.Context) HealthResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.last.CheckedAt.IsZero() && time.Since(h.last.CheckedAt) < h.ttl {
+		return h.last
+	}
+
+	checks := []media.DependencyCheck{h.downloader.CheckYTDLP(ctx)}
+	checks = append(checks, media.CheckFFmpeg(ctx)...)
+	openai := media.DependencyCheck{Name: "openai", OK: true}
+	if _, err := oai.Client(); err != nil {
+		openai.OK, openai.Error = false, err.Error()
+	}
+	checks = append(checks, openai)
+
+	resp := HealthResponse{Status: HealthOK, Checks: checks, CheckedAt: time.Now().UTC()}
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = HealthDegraded
+		}
+	}
+	h.last = resp
+	return resp
+}
+
+// healthzHandler reports dependency status but always answers 200 while the
+// process can serve: restarting it won't install a missing ffmpeg.
+func (app *App) healthzHandler(c *web.Context) {
+	if !app.warmup.Ready() {
+		c.JSON(200, HealthResponse{Status: HealthWarmingUp, Checks: []media.DependencyCheck{}, CheckedAt: time.Now().UTC()})
+		return
+	}
+	c.JSON(200, app.health.Check(c.Request.Context()))
+}
+
+// readyzHandler answers 503 while any dependency is broken so orchestrators
+// stop routing searches here, and while the server is still warming up.
+func (app *App) readyzHandler(c *web.Context) {
+	if !app.warmup.Ready() {
+		c.JSON(503, HealthResponse{Status: HealthWarmingUp, Checks: []media.DependencyCheck{}, CheckedAt: time.Now().UTC()})
+		return
+	}
+	resp := app.health.Check(c.Request.Context())
+	if resp.Status != HealthOK {
+		c.JSON(503, resp)
+		return
+	}
+	c.JSON(200, resp)
+}
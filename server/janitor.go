@@ -0,0 +1,24 @@
+package server
+
+import (
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+)
+
+// janitorConfigFromEnv reads WORK_DIR_QUOTA (e.g. "20GB"; unset is no
+// quota), WORK_FILE_MAX_AGE (6h) and JANITOR_INTERVAL (1m).
+func janitorConfigFromEnv() workfile.JanitorConfig {
+	return workfile.JanitorConfig{
+		Quota:    env.Bytes("WORK_DIR_QUOTA", 0),
+		MaxAge:   env.Duration("WORK_FILE_MAX_AGE", 6*time.Hour),
+		Interval: env.Duration("JANITOR_INTERVAL", time.Minute),
+	}
+}
+
+// workDirHandler reports the janitor's last sweep for GET /debug/workdir.
+func workDirHandler(c *web.Context) {
+	c.JSON(200, workfile.LastSweep())
+}
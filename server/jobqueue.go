@@ -0,0 +1,388 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"searchme/internal/config"
+	"searchme/internal/env"
+	"searchme/internal/web"
+)
+
+// Job queue backends, set by QUEUE_BACKEND
+const (
+	// QueueBackendMemory runs async jobs in the process that accepted them
+	QueueBackendMemory = "memory"
+	// QueueBackendRedis keeps jobs and their results in Redis and queues
+	// async searches there for any replica's workers
+	QueueBackendRedis = "redis"
+)
+
+// JobRecord is a job as a JobBackend keeps it.
+type JobRecord struct {
+	Job            JobSnapshot `json:"job"`
+	CancelToken    string      `json:"cancel_token"`
+	CallbackURL    string      `json:"callback_url,omitempty"`
+	CallbackSecret string      `json:"callback_secret,omitempty"`
+}
+
+// JobBackend keeps jobs outside the process, so they survive restarts and
+// replicas share them: their records, for status, and a queue of the work
+// itself. Dequeued work holds a lease until acknowledged; work whose lease
+// lapses, because its replica died, goes back on the queue.
+type JobBackend interface {
+	SaveJob(ctx context.Context, rec JobRecord) error
+	LoadJob(ctx context.Context, id string) (JobRecord, bool, error)
+	// Enqueue saves rec and queues request, the work to run it
+	Enqueue(ctx context.Context, rec JobRecord, request []byte) error
+	// Dequeue waits a few seconds for work and leases it; "" when none came
+	Dequeue(ctx context.Context) (id string, request []byte, err error)
+	// Lease extends the lease on dequeued work and reports whether
+	// cancelling it was requested
+	Lease(ctx context.Context, id string) (cancelled bool, err error)
+	// Ack ends the lease on finished work
+	Ack(ctx context.Context, id string) error
+	// RequestCancel asks whichever replica runs the job to stop it
+	RequestCancel(ctx context.Context, id string) error
+	// Recover requeues work whose lease lapsed, returning how many
+	Recover(ctx context.Context) (int, error)
+}
+
+// jobLease is how long dequeued work stays claimed without a renewal; it is
+// renewed every jobLease/6, which also bounds how long a cancel takes
+const jobLease = 30 * time.Second
+
+// openJobBackend returns the backend cfg.QueueBackend names, nil for memory.
+func openJobBackend(cfg config.Config) JobBackend {
+	switch strings.ToLower(cfg.QueueBackend) {
+	case "", QueueBackendMemory:
+		return nil
+	case QueueBackendRedis:
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("REDIS_URL: %v", err)
+		}
+		b := &redisJobBackend{
+			client: redis.NewClient(opts),
+			prefix: env.Or("REDIS_PREFIX", "searchme:"),
+			ttl:    env.Duration("JOB_TTL", 7*24*time.Hour),
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := b.client.Ping(ctx).Err(); err != nil {
+			log.Fatalf("job queue: Redis at %s unreachable: %v", cfg.RedisURL, err)
+		}
+		log.Printf("Job queue and results in Redis (prefix %s)", b.prefix)
+		return b
+	default:
+		log.Fatalf("unknown QUEUE_BACKEND %q (want memory or redis)", cfg.QueueBackend)
+		return nil
+	}
+}
+
+// redisJobBackend keeps each job's record at <prefix>job:<id> for JOB_TTL
+// (default 7 days). Queued work waits in the <prefix>jobs:queue list, its
+// request at <prefix>jobreq:<id>; dequeued work moves to jobs:processing
+// and holds <prefix>joblease:<id> while it runs.
+type redisJobBackend struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// suspects are processing entries found without a lease last time
+	// Recover ran; only those still without one are requeued, so work
+	// dequeued just before its lease was taken isn't
+	suspects map[string]bool
+}
+
+func (b *redisJobBackend) key(parts ...string) string {
+	return b.prefix + strings.Join(parts, ":")
+}
+
+func (b *redisJobBackend) SaveJob(ctx context.Context, rec JobRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return b.client.Set(ctx, b.key("job", rec.Job.ID), data, b.ttl).Err()
+}
+
+func (b *redisJobBackend) LoadJob(ctx context.Context, id string) (JobRecord, bool, error) {
+	data, err := b.client.Get(ctx, b.key("job", id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return JobRecord{}, false, nil
+	}
+	if err != nil {
+		return JobRecord{}, false, err
+	}
+	var rec JobRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return JobRecord{}, false, fmt.Errorf("corrupt job record %s: %w", id, err)
+	}
+	return rec, true, nil
+}
+
+func (b *redisJobBackend) Enqueue(ctx context.Context, rec JobRecord, request []byte) error {
+	if err := b.SaveJob(ctx, rec); err != nil {
+		return err
+	}
+	id := rec.Job.ID
+	if err := b.client.Set(ctx, b.key("jobreq", id), request, b.ttl).Err(); err != nil {
+		return err
+	}
+	return b.client.LPush(ctx, b.key("jobs", "queue"), id).Err()
+}
+
+func (b *redisJobBackend) Dequeue(ctx context.Context) (string, []byte, error) {
+	id, err := b.client.BRPopLPush(ctx, b.key("jobs", "queue"), b.key("jobs", "processing"), 5*time.Second).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := b.Lease(ctx, id); err != nil {
+		return "", nil, err
+	}
+	req, err := b.client.Get(ctx, b.key("jobreq", id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// expired or already run: nothing to do
+		return id, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return id, req, nil
+}
+
+func (b *redisJobBackend) Lease(ctx context.Context, id string) (bool, error) {
+	if err := b.client.Set(ctx, b.key("joblease", id), "1", jobLease).Err(); err != nil {
+		return false, err
+	}
+	n, err := b.client.Exists(ctx, b.key("jobcancel", id)).Result()
+	return n > 0, err
+}
+
+func (b *redisJobBackend) Ack(ctx context.Context, id string) error {
+	if err := b.client.LRem(ctx, b.key("jobs", "processing"), 1, id).Err(); err != nil {
+		return err
+	}
+	return b.client.Del(ctx, b.key("joblease", id), b.key("jobreq", id)).Err()
+}
+
+func (b *redisJobBackend) RequestCancel(ctx context.Context, id string) error {
+	return b.client.Set(ctx, b.key("jobcancel", id), "1", b.ttl).Err()
+}
+
+func (b *redisJobBackend) Recover(ctx context.Context) (int, error) {
+	ids, err := b.client.LRange(ctx, b.key("jobs", "processing"), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	suspects := map[string]bool{}
+	requeued := 0
+	for _, id := range ids {
+		n, err := b.client.Exists(ctx, b.key("joblease", id)).Result()
+		if err != nil {
+			return requeued, err
+		}
+		if n > 0 {
+			continue
+		}
+		if !b.suspects[id] {
+			suspects[id] = true
+			continue
+		}
+		removed, err := b.client.LRem(ctx, b.key("jobs", "processing"), 1, id).Result()
+		if err != nil {
+			return requeued, err
+		}
+		if removed > 0 {
+			// RPUSH puts it next in line for BRPOPLPUSH
+			if err := b.client.RPush(ctx, b.key("jobs", "queue"), id).Err(); err != nil {
+				return requeued, err
+			}
+			requeued++
+		}
+	}
+	b.suspects = suspects
+	return requeued, nil
+}
+
+// enqueueSearchJob queues an async search for any replica's workers and
+// returns the job, which this process does not hold.
+func (app *App) enqueueSearchJob(req SearchRequest) (*Job, error) {
+	backend := app.jobs.Backend()
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	job := app.jobs.newJob("search")
+	job.SetCallback(req.CallbackURL, req.CallbackSecret)
+	job.Total = 1
+	job.Items = []JobItem{{VideoURL: req.VideoURL, Status: JobQueued}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := backend.Enqueue(ctx, job.record(), request); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// startJobWorkers runs n workers taking queued jobs from the backend, and
+// requeues work abandoned by replicas that died.
+func (app *App) startJobWorkers(n int) {
+	backend := app.jobs.Backend()
+	if backend == nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		go app.jobWorker(backend)
+	}
+	go func() {
+		for range time.Tick(jobLease / 2) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if n, err := backend.Recover(ctx); err != nil {
+				log.Printf("job queue recovery failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Requeued %d abandoned job(s)", n)
+			}
+			cancel()
+		}
+	}()
+	if n == 0 {
+		log.Printf("Queueing jobs for workers elsewhere, running none here")
+	} else {
+		log.Printf("Running %d job queue worker(s)", n)
+	}
+}
+
+func (app *App) jobWorker(backend JobBackend) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		id, request, err := backend.Dequeue(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("job queue: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if id != "" {
+			app.runQueuedJob(backend, id, request)
+		}
+	}
+}
+
+// runQueuedJob runs one dequeued search, renewing its lease and watching
+// for cancellation until it finishes.
+func (app *App) runQueuedJob(backend JobBackend, id string, request []byte) {
+	ack := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := backend.Ack(ctx, id); err != nil {
+			log.Printf("failed to acknowledge job %s: %v", id, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	rec, found, err := backend.LoadJob(ctx, id)
+	cancel()
+	if err != nil {
+		// leave it leased; Recover hands it out again
+		log.Printf("failed to load job %s: %v", id, err)
+		return
+	}
+	var req SearchRequest
+	if !found || request == nil || json.Unmarshal(request, &req) != nil {
+		log.Printf("dropping queued job %s: record or request missing", id)
+		ack()
+		return
+	}
+	switch rec.Job.Status {
+	case JobCompleted, JobFailed, JobCancelled:
+		ack()
+		return
+	}
+
+	job := app.jobs.adopt(rec, true)
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(jobLease / 6)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				cancelled, err := backend.Lease(ctx, id)
+				cancel()
+				if err != nil {
+					log.Printf("failed to renew the lease on job %s: %v", id, err)
+				} else if cancelled {
+					_ = job.Cancel(job.CancelToken())
+				}
+			}
+		}
+	}()
+	app.runSearchJob(job, req)
+	close(done)
+	ack()
+}
+
+// cancelRemoteJob cancels a job this process doesn't hold through the
+// backend. A queued job is cancelled at once; a running one is stopped by
+// the replica running it within a few seconds, so the answer is 202 with
+// the job as it stands.
+func (app *App) cancelRemoteJob(c *web.Context) {
+	backend := app.jobs.Backend()
+	ctx := c.Request.Context()
+	rec, found, err := backend.LoadJob(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(503, ErrorResponse{Error: "job store unavailable: " + err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(404, ErrorResponse{Error: "job not found"})
+		return
+	}
+	token := cancelToken(c)
+	if rec.Job.Status == JobRunning {
+		// the replica running it cancels it, and calls back
+		if subtle.ConstantTimeCompare([]byte(token), []byte(rec.CancelToken)) != 1 {
+			c.JSON(403, ErrorResponse{Error: ErrCancelToken.Error()})
+			return
+		}
+		if err := backend.RequestCancel(ctx, rec.Job.ID); err != nil {
+			c.JSON(503, ErrorResponse{Error: "job store unavailable: " + err.Error()})
+			return
+		}
+		c.JSON(202, rec.Job)
+		return
+	}
+	job := app.jobs.adopt(rec, false)
+	switch err := job.Cancel(token); {
+	case errors.Is(err, ErrCancelToken):
+		c.JSON(403, ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, ErrJobFinished):
+		c.JSON(409, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// in case a worker is taking it off the queue right now
+	if err := backend.RequestCancel(ctx, job.ID); err != nil {
+		log.Printf("failed to flag job %s cancelled: %v", job.ID, err)
+	}
+	c.JSON(200, job.Snapshot())
+}
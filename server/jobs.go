@@ -0,0 +1,402 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"searchme/events"
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/transcribe"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Errors from Job.Cancel
+var (
+	ErrCancelToken = errors.New("cancel token does not match")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// JobItem is one unit of work inside a job, e.g. one video of a playlist.
+type JobItem struct {
+	VideoURL string    `json:"video_url"`
+	Title    string    `json:"title,omitempty"`
+	Status   JobStatus `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	// Transcription is set while the item falls back to Whisper
+	Transcription *transcribe.Progress `json:"transcription,omitempty"`
+}
+
+// Job tracks a background operation and its progress.
+type Job struct {
+	mu     sync.Mutex
+	ID     string    `json:"id"`
+	Kind   string    `json:"kind"`
+	Status JobStatus `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	// ErrorCode is the code of a typed failure, see typedErrors
+	ErrorCode string    `json:"error_code,omitempty"`
+	Total     int       `json:"total"`
+	Done      int       `json:"done"`
+	Failed    int       `json:"failed"`
+	Items     []JobItem `json:"items,omitempty"`
+	// Result is what the job produced, e.g. an async search's response
+	Result    interface{} `json:"result,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	// CallbackURL, when set, is POSTed the final snapshot once the job
+	// completes or fails, signed with callbackSecret when there is one
+	CallbackURL    string `json:"callback_url,omitempty"`
+	callbackSecret string
+	// attachments ride along with the callback, see SetAttachments
+	attachments *Attachments
+	// backend, when set, keeps a copy of the job after every Update
+	backend JobBackend
+	// ctx is cancelled by Cancel; the job's work runs under it
+	ctx         context.Context
+	cancel      context.CancelFunc
+	cancelToken string
+	// changed is closed by the next Update, see Watch
+	changed chan struct{}
+}
+
+// JobSnapshot is a copy of a job that is safe to serialize.
+type JobSnapshot struct {
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind"`
+	Status    JobStatus   `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	ErrorCode string      `json:"error_code,omitempty"`
+	Total     int         `json:"total"`
+	Done      int         `json:"done"`
+	Failed    int         `json:"failed"`
+	Progress  float64     `json:"progress"`
+	Items     []JobItem   `json:"items,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// JobCallback is the body POSTed to a job's callback URL when it finishes.
+type JobCallback struct {
+	Event events.Type `json:"event"`
+	Job   JobSnapshot `json:"job"`
+	// Attachments are what the job's creator asked the callback to carry
+	Attachments *Attachments `json:"attachments,omitempty"`
+}
+
+// Snapshot copies the job under its lock.
+func (j *Job) Snapshot() JobSnapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.snapshot()
+}
+
+func (j *Job) snapshot() JobSnapshot {
+	s := JobSnapshot{
+		ID:        j.ID,
+		Kind:      j.Kind,
+		Status:    j.Status,
+		Error:     j.Error,
+		ErrorCode: j.ErrorCode,
+		Total:     j.Total,
+		Done:      j.Done,
+		Failed:    j.Failed,
+		Items:     append([]JobItem(nil), j.Items...),
+		Result:    j.Result,
+		CreatedAt: j.CreatedAt,
+		UpdatedAt: j.UpdatedAt,
+	}
+	if j.Total > 0 {
+		s.Progress = float64(j.Done+j.Failed) / float64(j.Total)
+	}
+	return s
+}
+
+// SetCallback has the job's final snapshot POSTed to target, signed with
+// secret unless it is empty. Callers choose target, so it is never signed
+// with a key of the server's own.
+func (j *Job) SetCallback(target, secret string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.CallbackURL = target
+	j.callbackSecret = secret
+}
+
+// SetAttachments has the callback carry att. Call it from the Update that
+// finishes the job.
+func (j *Job) SetAttachments(att *Attachments) {
+	j.attachments = att
+}
+
+// Context is cancelled when the job is; run the job's work under it.
+func (j *Job) Context() context.Context {
+	return j.ctx
+}
+
+// Watch copies the job like Snapshot, along with a channel the job's next
+// Update closes, so its progress can be followed without polling.
+func (j *Job) Watch() (JobSnapshot, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.changed == nil {
+		j.changed = make(chan struct{})
+	}
+	return j.snapshot(), j.changed
+}
+
+// CancelToken is the secret Cancel wants, handed to whoever created the job.
+func (j *Job) CancelToken() string {
+	return j.cancelToken
+}
+
+// Cancel stops the job if token is its cancel token. Work in progress sees
+// its context cancelled and stops at the next download or chunk boundary;
+// the job is reported cancelled right away.
+func (j *Job) Cancel(token string) error {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(j.cancelToken)) != 1 {
+		return ErrCancelToken
+	}
+	var err error
+	j.Update(func(j *Job) {
+		if j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCancelled {
+			err = ErrJobFinished
+			return
+		}
+		j.Status, j.Error = JobCancelled, "cancelled"
+		for i := range j.Items {
+			if s := j.Items[i].Status; s == JobQueued || s == JobRunning {
+				j.Items[i].Status = JobCancelled
+				j.Items[i].Transcription = nil
+			}
+		}
+	})
+	if err == nil {
+		j.cancel()
+	}
+	return err
+}
+
+// Update mutates the job under its lock and bumps UpdatedAt. A cancelled job
+// stays cancelled whatever fn sets. Moving the job to completed, failed or
+// cancelled emits a JobCompleted, JobFailed or JobCancelled event and calls
+// the job's callback URL, if any. With a job backend the new state is saved
+// there too.
+func (j *Job) Update(fn func(j *Job)) {
+	j.mu.Lock()
+	defer j.save()
+	defer j.mu.Unlock()
+	prev, prevErr := j.Status, j.Error
+	fn(j)
+	j.UpdatedAt = time.Now()
+	if prev == JobCancelled {
+		j.Status, j.Error = prev, prevErr
+	}
+	if j.changed != nil {
+		close(j.changed)
+		j.changed = nil
+	}
+	if j.Status == prev || (j.Status != JobCompleted && j.Status != JobFailed && j.Status != JobCancelled) {
+		return
+	}
+	typ := events.JobCompleted
+	data := map[string]interface{}{"kind": j.Kind}
+	switch j.Status {
+	case JobFailed:
+		typ = events.JobFailed
+		data["error"] = j.Error
+	case JobCancelled:
+		typ = events.JobCancelled
+	}
+	events.Default().Emit(events.Event{Type: typ, JobID: j.ID, Data: data})
+	if j.CallbackURL != "" {
+		go deliverWebhook(j.CallbackURL, j.callbackSecret, JobCallback{Event: typ, Job: j.snapshot(), Attachments: j.attachments})
+	}
+}
+
+// save copies the job's current state to its backend, if any.
+func (j *Job) save() {
+	if j.backend == nil {
+		return
+	}
+	j.mu.Lock()
+	rec := j.record()
+	j.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := j.backend.SaveJob(ctx, rec); err != nil {
+		log.Printf("failed to save job %s: %v", j.ID, err)
+	}
+}
+
+// record is the job as a JobBackend keeps it. Call it under the job's lock.
+func (j *Job) record() JobRecord {
+	return JobRecord{Job: j.snapshot(), CancelToken: j.cancelToken, CallbackURL: j.CallbackURL, CallbackSecret: j.callbackSecret}
+}
+
+// JobManager keeps background jobs in memory, and in its backend, when it
+// has one, so other replicas and later runs can see them.
+type JobManager struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	backend JobBackend
+}
+
+// NewJobManager keeps jobs in memory only when backend is nil.
+func NewJobManager(backend JobBackend) *JobManager {
+	return &JobManager{jobs: map[string]*Job{}, backend: backend}
+}
+
+// Backend is the manager's job backend, or nil.
+func (m *JobManager) Backend() JobBackend {
+	return m.backend
+}
+
+// Create registers a new queued job.
+func (m *JobManager) Create(kind string) *Job {
+	j := m.newJob(kind)
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	m.mu.Unlock()
+	return j
+}
+
+// newJob makes a queued job without registering it, for jobs another
+// replica may run.
+func (m *JobManager) newJob(kind string) *Job {
+	now := time.Now()
+	j := &Job{ID: workfile.Name("job"), Kind: kind, Status: JobQueued, CreatedAt: now, UpdatedAt: now, backend: m.backend}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	j.cancelToken = hex.EncodeToString(token)
+	events.Default().Emit(events.Event{Type: events.JobQueued, JobID: j.ID, Data: map[string]interface{}{"kind": kind}})
+	return j
+}
+
+// adopt rebuilds a job from the backend's record, to run or cancel it here.
+// register makes Get find it.
+func (m *JobManager) adopt(rec JobRecord, register bool) *Job {
+	s := rec.Job
+	j := &Job{
+		ID: s.ID, Kind: s.Kind, Status: s.Status, Error: s.Error,
+		Total: s.Total, Done: s.Done, Failed: s.Failed, Items: s.Items, Result: s.Result,
+		CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt,
+		CallbackURL: rec.CallbackURL, callbackSecret: rec.CallbackSecret,
+		cancelToken: rec.CancelToken, backend: m.backend,
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+	if register {
+		m.mu.Lock()
+		m.jobs[j.ID] = j
+		m.mu.Unlock()
+	}
+	return j
+}
+
+// Get looks up a job this process holds by ID.
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+// Lookup finds a job here or, failing that, in the backend.
+func (m *JobManager) Lookup(ctx context.Context, id string) (JobRecord, bool, error) {
+	if j, ok := m.Get(id); ok {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		return j.record(), true, nil
+	}
+	if m.backend == nil {
+		return JobRecord{}, false, nil
+	}
+	return m.backend.LoadJob(ctx, id)
+}
+
+// JobAcceptedResponse is the reply to a request that starts a job.
+type JobAcceptedResponse struct {
+	JobID       string `json:"job_id"`
+	StatusURL   string `json:"status_url"`
+	CancelURL   string `json:"cancel_url"`
+	CancelToken string `json:"cancel_token"`
+}
+
+// jobAccepted answers the request that started job with 202, its status URL
+// and the token that cancels it.
+func jobAccepted(c *web.Context, job *Job) {
+	c.JSON(202, JobAcceptedResponse{
+		JobID:       job.ID,
+		StatusURL:   "/api/jobs/" + job.ID,
+		CancelURL:   "/api/jobs/" + job.ID + "/cancel",
+		CancelToken: job.CancelToken(),
+	})
+}
+
+// jobCancelHandler stops a job for POST /api/jobs/:id/cancel. The token
+// returned when the job was created goes in X-Cancel-Token or a JSON body's
+// "cancel_token".
+func (app *App) jobCancelHandler(c *web.Context) {
+	job, ok := app.jobs.Get(c.Param("id"))
+	if !ok && app.jobs.Backend() != nil {
+		app.cancelRemoteJob(c)
+		return
+	}
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "job not found"})
+		return
+	}
+	token := cancelToken(c)
+	switch err := job.Cancel(token); {
+	case errors.Is(err, ErrCancelToken):
+		c.JSON(403, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrJobFinished):
+		c.JSON(409, ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(200, job.Snapshot())
+	}
+}
+
+// cancelToken reads the cancel token from X-Cancel-Token or the JSON body.
+func cancelToken(c *web.Context) string {
+	token := c.GetHeader("X-Cancel-Token")
+	if token == "" {
+		var body struct {
+			CancelToken string `json:"cancel_token"`
+		}
+		_ = c.ShouldBindJSON(&body)
+		token = body.CancelToken
+	}
+	return token
+}
+
+// jobStatusHandler reports progress for GET /api/jobs/:id, for jobs run
+// here or, with a job backend, anywhere.
+func (app *App) jobStatusHandler(c *web.Context) {
+	rec, ok, err := app.jobs.Lookup(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(503, ErrorResponse{Error: "job store unavailable: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "job not found"})
+		return
+	}
+	c.JSON(200, rec.Job)
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/search"
+	"searchme/subtitle"
+)
+
+// SlideSpan is a stretch of the video during which one slide is on screen.
+type SlideSpan struct {
+	Number int     `json:"number,omitempty"`
+	Title  string  `json:"title,omitempty"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Text   string  `json:"-"`
+}
+
+// Label renders the slide the way it is shown to users, e.g. "Slide 12".
+func (s SlideSpan) Label() string {
+	switch {
+	case s.Number > 0:
+		return fmt.Sprintf("Slide %d", s.Number)
+	case s.Title != "":
+		return s.Title
+	default:
+		return "Slide"
+	}
+}
+
+var (
+	// "Slide 12", "12 / 40", "12 of 40" or a lone page number on its own line
+	slideNumberRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bslide\s+(\d{1,3})\b`),
+		regexp.MustCompile(`(?m)^\s*(\d{1,3})\s*(?:/|of)\s*\d{1,3}\s*$`),
+		regexp.MustCompile(`(?m)^\s*(\d{1,3})\s*$`),
+	}
+)
+
+// lectureFrameInterval is the sampling period in seconds, LECTURE_FRAME_INTERVAL (default 10).
+func lectureFrameInterval() int {
+	if n, err := strconv.Atoi(os.Getenv("LECTURE_FRAME_INTERVAL")); err == nil && n > 0 {
+		return n
+	}
+	return 10
+}
+
+// BuildSlideIndex samples frames from the video, OCRs them with tesseract
+// (TESSERACT_PATH overrides the binary) and collapses consecutive frames showing
+// the same slide into spans.
+func BuildSlideIndex(ctx context.Context, media *media.AudioFile) ([]SlideSpan, error) {
+	framesDir, err := os.MkdirTemp(workfile.Dir(), "slides_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create frames dir: %w", err)
+	}
+	workfile.Track(framesDir)
+	defer os.RemoveAll(framesDir)
+
+	interval := lectureFrameInterval()
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-i", media.Path,
+		"-vf", fmt.Sprintf("fps=1/%d,scale=1280:-2", interval),
+		"-y", filepath.Join(framesDir, "frame_%05d.png"),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("ffmpeg frame extraction error: %s", string(out))
+		return nil, fmt.Errorf("failed to extract frames: %w", err)
+	}
+	frames, _ := filepath.Glob(filepath.Join(framesDir, "frame_*.png"))
+	sort.Strings(frames)
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames extracted (is this an audio-only source?)")
+	}
+
+	tesseract := os.Getenv("TESSERACT_PATH")
+	if tesseract == "" {
+		tesseract = "tesseract"
+	}
+
+	var spans []SlideSpan
+	for i, frame := range frames {
+		out, err := exec.CommandContext(ctx, tesseract, frame, "stdout").Output()
+		if err != nil {
+			return nil, fmt.Errorf("OCR failed: %w", err)
+		}
+		text := string(out)
+		slide := SlideSpan{
+			Number: slideNumber(text),
+			Title:  slideTitle(text),
+			Start:  float64(i * interval),
+			End:    float64((i + 1) * interval),
+			Text:   text,
+		}
+		if n := len(spans); n > 0 && sameSlide(spans[n-1], slide) {
+			spans[n-1].End = slide.End
+			continue
+		}
+		spans = append(spans, slide)
+	}
+	return spans, nil
+}
+
+func slideNumber(text string) int {
+	for _, re := range slideNumberRegexes {
+		if m := re.FindStringSubmatch(text); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	}
+	return 0
+}
+
+// slideTitle takes the first line with real words on it.
+func slideTitle(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(strings.Fields(line)) > 0 && strings.IndexFunc(line, func(r rune) bool { return r > '9' }) >= 0 {
+			return line
+		}
+	}
+	return ""
+}
+
+func sameSlide(a, b SlideSpan) bool {
+	if a.Number > 0 || b.Number > 0 {
+		return a.Number == b.Number
+	}
+	return strings.EqualFold(a.Title, b.Title)
+}
+
+// SlideAt returns the slide on screen at t.
+func SlideAt(spans []SlideSpan, t float64) (SlideSpan, bool) {
+	for _, s := range spans {
+		if t >= s.Start && t < s.End {
+			return s, true
+		}
+	}
+	return SlideSpan{}, false
+}
+
+type LectureSlideHit struct {
+	Slide SlideSpan `json:"slide"`
+	Label string    `json:"label"`
+}
+
+type LectureSearchResponse struct {
+	search.Response
+	Slide     *SlideSpan        `json:"slide,omitempty"`
+	Label     string            `json:"label,omitempty"`
+	SlideHits []LectureSlideHit `json:"slide_hits,omitempty"`
+}
+
+// lectureSearchHandler searches the spoken content like /api/search and correlates
+// the match with the slide on screen ("Slide 12, 34:10"). Slides whose text
+// contains the keyword are returned as slide_hits even when nobody says it.
+func (app *App) lectureSearchHandler(c *web.Context) {
+	var req SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" || req.Keyword == "" {
+		c.JSON(400, ErrorResponse{Error: "videourl and keyword are required"})
+		return
+	}
+	if _, err := search.ParseMatchMode(req.MatchMode); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if _, err := search.ParseOrder(req.Order, req.Hint); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	dl, err := app.downloader.With(req.DownloadOptions)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	media, err := media.DownloadMedia(c.Request.Context(), src)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	slides, err := BuildSlideIndex(c.Request.Context(), media)
+	media.Remove()
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	req.OpenAIKey = callerKey(c)
+	match, found, usedLang, err := app.pipeline.Search(c.Request.Context(), req.Request)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+
+	resp := LectureSearchResponse{Response: search.NewResponse(req.VideoURL, match, found, usedLang)}
+	if found {
+		resp.Label = resp.Time
+		if slide, ok := SlideAt(slides, match.Start); ok {
+			resp.Slide = &slide
+			resp.Label = fmt.Sprintf("%s, %s", slide.Label(), resp.Time)
+		}
+	}
+
+	matcher := req.Matcher(usedLang)
+	texts := make([]subtitle.Entry, len(slides))
+	for i, s := range slides {
+		texts[i] = subtitle.Entry{Start: s.Start, End: s.End, Text: s.Text}
+	}
+	// a slide is listed once, also for a phrase running on to the next one
+	last := -1
+	for _, h := range matcher.Hits(texts) {
+		if h.Index == last {
+			continue
+		}
+		last = h.Index
+		s := slides[h.Index]
+		resp.SlideHits = append(resp.SlideHits, LectureSlideHit{
+			Slide: s,
+			Label: fmt.Sprintf("%s, %s", s.Label(), search.FormatTime(s.Start)),
+		})
+	}
+	c.JSON(200, resp)
+}
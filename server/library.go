@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"searchme/artifact"
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// openStore opens the transcript index at path (INDEX_DB), or returns nil
+// when it is empty.
+func openStore(path string) store.TranscriptStore {
+	if path == "" {
+		return nil
+	}
+	store, err := store.OpenSQLiteStore(path)
+	if err != nil {
+		log.Fatalf("failed to open transcript index %s: %v", path, err)
+	}
+	if enc, _ := artifact.EncryptorFromEnv(); enc != nil {
+		log.Printf("Warning: the transcript index at %s is searchable plaintext and is not covered by encryption at rest", path)
+	}
+	log.Printf("Transcript index enabled at %s", path)
+	return store
+}
+
+// indexTranscript stores segments in the library. Indexing is best-effort and
+// never fails the request that produced the transcript. Published results for
+// the video are then checked against the new segments in the background.
+func (app *App) indexTranscript(videoURL, lang, source string, subs []subtitle.Entry) {
+	if app.store == nil || len(subs) == 0 {
+		return
+	}
+	quality := search.TranscriptQuality(subs, source)
+	err := app.saveTranscript(context.Background(), store.TranscriptRecord{
+		VideoID:      store.VideoKey(videoURL),
+		VideoURL:     videoURL,
+		Language:     lang,
+		Source:       source,
+		Quality:      quality.Score,
+		QualityFlags: quality.Flags,
+		Segments:     subs,
+	})
+	if err != nil {
+		log.Printf("failed to index transcript for %s: %v", videoURL, err)
+		return
+	}
+	go app.reportResultChanges(context.Background(), videoURL, lang, source, subs)
+}
+
+// saveTranscript stores rec in the library with its segments redacted when
+// REDACT_PII is set (see transcribe.NewRedactorFromEnv), whatever they came
+// from: captions, chunked transcription or an upstream instance.
+func (app *App) saveTranscript(ctx context.Context, rec store.TranscriptRecord) error {
+	client, _ := oai.ClientFor(ctx)
+	if redactor := transcribe.NewRedactorFromEnv(client); redactor != nil {
+		rec.Segments = append([]subtitle.Entry(nil), rec.Segments...)
+		redactor.RedactEntries(ctx, rec.Segments)
+	}
+	return app.store.SaveTranscript(ctx, rec)
+}
+
+// redactedSegments are a stored transcript's segments as they may leave the
+// server: through the regex pass of REDACT_PII, for transcripts stored
+// before redaction was turned on. segs is left alone.
+func redactedSegments(segs []subtitle.Entry) []subtitle.Entry {
+	redactor := transcribe.PatternRedactorFromEnv()
+	if redactor == nil {
+		return segs
+	}
+	segs = append([]subtitle.Entry(nil), segs...)
+	redactor.RedactEntries(context.Background(), segs)
+	return segs
+}
+
+type IndexVideoHits struct {
+	VideoID  string            `json:"video_id"`
+	VideoURL string            `json:"video_url"`
+	Title    string            `json:"title,omitempty"`
+	Hits     []search.Response `json:"hits"`
+}
+
+// indexSearchHandler answers "which of my indexed videos mention X, and where":
+// GET /api/index/search?q=...&limit=... Hits come a page at a time; pass the
+// page's next_cursor back as cursor (or set offset) for the next.
+func (app *App) indexSearchHandler(c *web.Context) {
+	if app.store == nil {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(400, ErrorResponse{Error: "q is required"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		if offset, err = search.DecodeCursor(q, cursor); err != nil {
+			c.JSON(400, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	offset = max(offset, 0)
+
+	// one extra hit tells whether there is another page
+	hits, err := app.store.Search(c.Request.Context(), store.LibraryQuery{Query: q, Limit: limit + 1, Offset: offset})
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	page := search.Page{Offset: offset, Limit: limit}
+	if len(hits) > limit {
+		hits = hits[:limit]
+		page.NextCursor = search.EncodeCursor(q, offset+limit)
+	}
+
+	// group by video, keeping the rank order of each video's best hit
+	videos := []*IndexVideoHits{}
+	byID := map[string]*IndexVideoHits{}
+	for _, h := range hits {
+		v := byID[h.VideoID]
+		if v == nil {
+			v = &IndexVideoHits{VideoID: h.VideoID, VideoURL: h.VideoURL, Title: h.Title}
+			byID[h.VideoID] = v
+			videos = append(videos, v)
+		}
+		m := search.Match{Start: h.Start, End: h.End, Text: h.Text, Source: search.SourceIndex}
+		v.Hits = append(v.Hits, search.NewResponse(h.VideoURL, m, true, ""))
+	}
+	c.JSON(200, web.H{"query": q, "videos": videos, "page": page})
+}
+
+// LibrarySearchRequest searches every indexed video for a keyword.
+type LibrarySearchRequest struct {
+	Keyword string `json:"keyword"`
+	// Limit defaults to 50; Offset or Cursor, a page's next_cursor, skip
+	// hits already seen
+	Limit  int    `json:"limit,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	// Collection and Language, when set, only search those videos and
+	// segments
+	Collection string `json:"collection,omitempty"`
+	Language   string `json:"language,omitempty"`
+}
+
+// LibraryResult is one place in the library the keyword is said.
+type LibraryResult struct {
+	VideoID    string  `json:"video_id"`
+	VideoURL   string  `json:"video_url"`
+	Title      string  `json:"title,omitempty"`
+	Time       string  `json:"time"`
+	Seconds    float64 `json:"seconds"`
+	EndSeconds float64 `json:"end_seconds"`
+	// URL opens the video at the hit
+	URL      string  `json:"url"`
+	Snippet  string  `json:"snippet"`
+	Text     string  `json:"text"`
+	Language string  `json:"language,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// librarySearchHandler answers POST /api/library/search, "grep my video
+// library": every indexed segment mentioning the keyword as a flat list of
+// (video, timestamp, snippet), best first, a page at a time. Unlike GET
+// /api/index/search it doesn't group by video, so the ranking is global.
+func (app *App) librarySearchHandler(c *web.Context) {
+	if app.store == nil {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	var req LibrarySearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	req.Keyword = strings.TrimSpace(req.Keyword)
+	if req.Keyword == "" {
+		c.JSON(400, ErrorResponse{Error: "keyword is required"})
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Language != "" {
+		req.Language = search.NormalizeLang(req.Language)
+	}
+	// a cursor is only good for the search it came from
+	scope := strings.Join([]string{req.Keyword, req.Collection, req.Language}, "\x00")
+	offset := max(req.Offset, 0)
+	if req.Cursor != "" {
+		var err error
+		if offset, err = search.DecodeCursor(scope, req.Cursor); err != nil {
+			c.JSON(400, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	hits, err := app.store.Search(c.Request.Context(), store.LibraryQuery{
+		Query: req.Keyword, Limit: req.Limit + 1, Offset: offset,
+		Collection: req.Collection, Language: req.Language,
+	})
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	page := search.Page{Offset: offset, Limit: req.Limit}
+	if len(hits) > req.Limit {
+		hits = hits[:req.Limit]
+		page.NextCursor = search.EncodeCursor(scope, offset+req.Limit)
+	}
+	results := make([]LibraryResult, len(hits))
+	for i, h := range hits {
+		results[i] = LibraryResult{
+			VideoID: h.VideoID, VideoURL: h.VideoURL, Title: h.Title,
+			Time: search.FormatTime(h.Start), Seconds: h.Start, EndSeconds: h.End,
+			URL: media.DeepLink(h.VideoURL, h.Start), Snippet: h.Snippet, Text: h.Text,
+			Language: h.Language, Score: h.Score,
+		}
+	}
+	c.JSON(200, web.H{"keyword": req.Keyword, "results": results, "page": page})
+}
+
+// indexVideosHandler lists every indexed video.
+func (app *App) indexVideosHandler(c *web.Context) {
+	if app.store == nil {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	videos, err := app.store.ListVideos(c.Request.Context())
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(200, web.H{"videos": videos})
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+)
+
+// LimitConfig bounds how much work the server takes on at once.
+type LimitConfig struct {
+	MaxConcurrent  int           // requests doing work at once, across all clients
+	MaxPerIP       int           // requests doing work at once per client IP
+	MaxQueue       int           // requests allowed to wait for a global slot
+	QueueTimeout   time.Duration // how long a queued request waits before 429
+	MaxTranscribes int           // Whisper pipelines in flight
+}
+
+// limitConfigFromEnv reads MAX_CONCURRENT_REQUESTS (8), MAX_CONCURRENT_PER_IP (2),
+// MAX_QUEUE (32), QUEUE_TIMEOUT (30s) and MAX_TRANSCRIPTIONS (2).
+func limitConfigFromEnv() LimitConfig {
+	cfg := LimitConfig{
+		MaxConcurrent:  env.Int("MAX_CONCURRENT_REQUESTS", 8),
+		MaxPerIP:       env.Int("MAX_CONCURRENT_PER_IP", 2),
+		MaxQueue:       env.Int("MAX_QUEUE", 32),
+		QueueTimeout:   30 * time.Second,
+		MaxTranscribes: env.Int("MAX_TRANSCRIPTIONS", 2),
+	}
+	if d, err := time.ParseDuration(os.Getenv("QUEUE_TIMEOUT")); err == nil && d >= 0 {
+		cfg.QueueTimeout = d
+	}
+	return cfg
+}
+
+// atLeastOne raises the concurrency limits to 1: a zero one would make an
+// unbuffered semaphore that nothing can acquire. Config.Validate rejects
+// such settings at startup.
+func (cfg LimitConfig) atLeastOne() LimitConfig {
+	cfg.MaxConcurrent = max(cfg.MaxConcurrent, 1)
+	cfg.MaxPerIP = max(cfg.MaxPerIP, 1)
+	cfg.MaxTranscribes = max(cfg.MaxTranscribes, 1)
+	return cfg
+}
+
+// Limiter enforces global and per-IP concurrency for request handlers and a
+// separate semaphore for transcriptions, which are the expensive part.
+type Limiter struct {
+	mu         sync.Mutex
+	cfg        LimitConfig
+	slots      chan struct{}
+	transcribe chan struct{}
+	perIP      map[string]int
+	waiting    int
+}
+
+func NewLimiter(cfg LimitConfig) *Limiter {
+	cfg = cfg.atLeastOne()
+	return &Limiter{
+		cfg:        cfg,
+		slots:      make(chan struct{}, cfg.MaxConcurrent),
+		transcribe: make(chan struct{}, cfg.MaxTranscribes),
+		perIP:      map[string]int{},
+	}
+}
+
+// Middleware admits a request when both a per-IP and a global slot are free.
+// Requests over the per-IP limit or beyond the queue are rejected with 429;
+// others wait up to QueueTimeout for a global slot.
+func (l *Limiter) Middleware() web.HandlerFunc {
+	return func(c *web.Context) {
+		ip := c.ClientIP()
+
+		l.mu.Lock()
+		// a request keeps the limits it was admitted under across a Reload
+		cfg, slots := l.cfg, l.slots
+		if l.perIP[ip] >= cfg.MaxPerIP {
+			l.mu.Unlock()
+			tooManyRequests(c, "too many concurrent requests from this client")
+			return
+		}
+		l.perIP[ip]++
+		l.mu.Unlock()
+		defer l.releaseIP(ip)
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			if !l.enqueue() {
+				tooManyRequests(c, "server is busy, queue is full")
+				return
+			}
+			timer := time.NewTimer(cfg.QueueTimeout)
+			select {
+			case slots <- struct{}{}:
+				timer.Stop()
+				l.dequeue()
+			case <-timer.C:
+				l.dequeue()
+				tooManyRequests(c, "server is busy, timed out waiting in queue")
+				return
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				l.dequeue()
+				c.Abort()
+				return
+			}
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
+
+// AcquireTranscription blocks until a transcription slot is free or ctx ends.
+func (l *Limiter) AcquireTranscription(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	sem := l.transcribe
+	l.mu.Unlock()
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a transcription slot: %w", ctx.Err())
+	}
+}
+
+// Reload applies new limits without dropping work in flight. Requests and
+// transcriptions already running release their slots against the old
+// limits, so until they finish up to old+new of them may run at once.
+func (l *Limiter) Reload(cfg LimitConfig) {
+	cfg = cfg.atLeastOne()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cfg.MaxConcurrent != l.cfg.MaxConcurrent {
+		l.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	if cfg.MaxTranscribes != l.cfg.MaxTranscribes {
+		l.transcribe = make(chan struct{}, cfg.MaxTranscribes)
+	}
+	l.cfg = cfg
+}
+
+func (l *Limiter) releaseIP(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+func (l *Limiter) enqueue() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.waiting >= l.cfg.MaxQueue {
+		return false
+	}
+	l.waiting++
+	return true
+}
+
+func (l *Limiter) dequeue() {
+	l.mu.Lock()
+	l.waiting--
+	l.mu.Unlock()
+}
+
+func tooManyRequests(c *web.Context, msg string) {
+	c.Header("Retry-After", "5")
+	c.AbortWithStatusJSON(429, ErrorResponse{Error: msg})
+}
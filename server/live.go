@@ -0,0 +1,400 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"searchme/events"
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+)
+
+// LiveConfig bounds live stream watches.
+type LiveConfig struct {
+	// Window is the default length of the stretches transcribed at a time
+	Window time.Duration
+	// MaxDuration caps, and is the default for, how long one watch runs
+	MaxDuration time.Duration
+	// MaxWatches caps the watches running at once
+	MaxWatches int
+}
+
+// liveConfigFromEnv reads LIVE_WINDOW_SECONDS (30), LIVE_MAX_MINUTES (240)
+// and LIVE_MAX_WATCHES (4).
+func liveConfigFromEnv() LiveConfig {
+	return LiveConfig{
+		Window:      time.Duration(env.Int("LIVE_WINDOW_SECONDS", 30)) * time.Second,
+		MaxDuration: time.Duration(env.Int("LIVE_MAX_MINUTES", 240)) * time.Minute,
+		MaxWatches:  env.Int("LIVE_MAX_WATCHES", 4),
+	}
+}
+
+// Live windows shorter than this cost a Whisper request every few seconds
+// and cut most words in half; longer ones report matches too late to act on.
+const (
+	minLiveWindow = 5
+	maxLiveWindow = 300
+)
+
+// liveRetention is how long a finished watch's events can still be replayed.
+const liveRetention = time.Hour
+
+// LiveRequest starts watching a live stream for a keyword.
+type LiveRequest struct {
+	search.Request
+	// WindowSeconds is how much of the stream is transcribed at a time,
+	// 5 to 300; 0 uses LIVE_WINDOW_SECONDS
+	WindowSeconds int `json:"window_seconds,omitempty"`
+	// MaxMinutes ends the watch; 0 or more than LIVE_MAX_MINUTES is
+	// LIVE_MAX_MINUTES
+	MaxMinutes float64 `json:"max_minutes,omitempty"`
+	// CallbackURL is POSTed each match as it is said, as a LiveMatchCallback,
+	// and the finished job, signed with CallbackSecret when it is set
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
+}
+
+// LiveAcceptedResponse is the reply to POST /api/live.
+type LiveAcceptedResponse struct {
+	JobAcceptedResponse
+	// EventsURL streams the watch's matches as server-sent events
+	EventsURL string `json:"events_url"`
+}
+
+// LiveResult is a live watch job's result, filled in as the stream plays.
+type LiveResult struct {
+	Matches []search.LiveMatch `json:"matches"`
+	// Windows counts the stretches searched and FailedWindows those that
+	// couldn't be transcribed
+	Windows       int     `json:"windows"`
+	FailedWindows int     `json:"failed_windows,omitempty"`
+	Seconds       float64 `json:"seconds"`
+}
+
+// LiveMatchCallback is POSTed to a watch's callback URL for every match.
+type LiveMatchCallback struct {
+	Event    events.Type      `json:"event"`
+	JobID    string           `json:"job_id"`
+	VideoURL string           `json:"video_url"`
+	Keyword  string           `json:"keyword"`
+	Match    search.LiveMatch `json:"match"`
+}
+
+// liveEvent is one server-sent event of a watch: "match", "window" or,
+// last, "end" with the job's final snapshot.
+type liveEvent struct {
+	id   int
+	name string
+	data interface{}
+}
+
+// liveWatch fans a running watch's events out to its event streams. Match
+// and end events are kept so late subscribers catch up.
+type liveWatch struct {
+	mu      sync.Mutex
+	nextID  int
+	history []liveEvent
+	subs    map[chan liveEvent]struct{}
+	done    bool
+}
+
+func (w *liveWatch) publish(name string, data interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextID++
+	ev := liveEvent{id: w.nextID, name: name, data: data}
+	if name != "window" {
+		w.history = append(w.history, ev)
+	}
+	for ch := range w.subs {
+		select {
+		case ch <- ev:
+		default:
+			// too slow to keep up; it can reconnect with Last-Event-ID
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// finish publishes the end event and closes every stream.
+func (w *liveWatch) finish(job JobSnapshot) {
+	w.publish("end", job)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+	for ch := range w.subs {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+// subscribe returns the kept events after lastID and a channel of the ones
+// to come, closed when the watch ends. Call unsubscribe when done.
+func (w *liveWatch) subscribe(lastID int) (past []liveEvent, ch chan liveEvent, unsubscribe func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, ev := range w.history {
+		if ev.id > lastID {
+			past = append(past, ev)
+		}
+	}
+	ch = make(chan liveEvent, 64)
+	if w.done {
+		close(ch)
+		return past, ch, func() {}
+	}
+	if w.subs == nil {
+		w.subs = map[chan liveEvent]struct{}{}
+	}
+	w.subs[ch] = struct{}{}
+	return past, ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// liveWatches holds the watches running here and those recently finished.
+type liveWatches struct {
+	cfg     LiveConfig
+	mu      sync.Mutex
+	running int
+	watches map[string]*liveWatch
+}
+
+func newLiveWatches(cfg LiveConfig) *liveWatches {
+	return &liveWatches{cfg: cfg, watches: map[string]*liveWatch{}}
+}
+
+// reserve takes a slot for a new watch, or reports false when MaxWatches
+// are already running.
+func (l *liveWatches) reserve() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cfg.MaxWatches > 0 && l.running >= l.cfg.MaxWatches {
+		return false
+	}
+	l.running++
+	return true
+}
+
+// add registers the watch run by the job, in a reserved slot.
+func (l *liveWatches) add(jobID string) *liveWatch {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w := &liveWatch{}
+	l.watches[jobID] = w
+	return w
+}
+
+// stop frees the watch's slot; its events stay for liveRetention.
+func (l *liveWatches) stop(jobID string) {
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+	time.AfterFunc(liveRetention, func() {
+		l.mu.Lock()
+		delete(l.watches, jobID)
+		l.mu.Unlock()
+	})
+}
+
+func (l *liveWatches) get(jobID string) (*liveWatch, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w, ok := l.watches[jobID]
+	return w, ok
+}
+
+// liveHandler answers POST /api/live by starting a "live" job that tails
+// the stream until it ends, max_minutes pass or the job is cancelled. Its
+// matches arrive on the events URL, at the callback URL and in the job's
+// result as they are said. Watches run on the replica that accepted them.
+func (app *App) liveHandler(c *web.Context) {
+	var req LiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" || req.Keyword == "" {
+		c.JSON(400, ErrorResponse{Error: "videourl and keyword are required"})
+		return
+	}
+	if err := media.CheckWebURL(req.VideoURL); err != nil {
+		c.JSON(400, ErrorResponse{Error: "video_url: " + err.Error()})
+		return
+	}
+	if _, err := search.ParseMatchMode(req.MatchMode); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.WindowSeconds != 0 && (req.WindowSeconds < minLiveWindow || req.WindowSeconds > maxLiveWindow) {
+		c.JSON(400, ErrorResponse{Error: fmt.Sprintf("window_seconds must be between %d and %d", minLiveWindow, maxLiveWindow)})
+		return
+	}
+	if req.MaxMinutes < 0 {
+		c.JSON(400, ErrorResponse{Error: "max_minutes can't be negative"})
+		return
+	}
+	if req.CallbackURL != "" {
+		if err := checkWebhookURL(c.Request.Context(), req.CallbackURL); err != nil {
+			c.JSON(400, ErrorResponse{Error: "callback_url: " + err.Error()})
+			return
+		}
+	}
+	if err := app.downloader.AudioSettings().Merge(req.AudioSettings).Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := req.WhisperOptions.WithDefaults().Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	req.OpenAIKey = callerKey(c)
+
+	opts := search.LiveOptions{Window: app.live.cfg.Window, MaxDuration: app.live.cfg.MaxDuration}
+	if req.WindowSeconds > 0 {
+		opts.Window = time.Duration(req.WindowSeconds) * time.Second
+	}
+	if d := time.Duration(req.MaxMinutes * float64(time.Minute)); d > 0 && (opts.MaxDuration <= 0 || d < opts.MaxDuration) {
+		opts.MaxDuration = d
+	}
+	if !app.live.reserve() {
+		c.Header("Retry-After", "60")
+		c.JSON(503, ErrorResponse{Error: "too many live streams are being watched, try again later"})
+		return
+	}
+	job := app.jobs.Create("live")
+	watch := app.live.add(job.ID)
+	job.SetCallback(req.CallbackURL, req.CallbackSecret)
+	go app.runLiveWatch(job, watch, req, opts)
+	c.JSON(202, LiveAcceptedResponse{
+		JobAcceptedResponse: JobAcceptedResponse{
+			JobID:       job.ID,
+			StatusURL:   "/api/jobs/" + job.ID,
+			CancelURL:   "/api/jobs/" + job.ID + "/cancel",
+			CancelToken: job.CancelToken(),
+		},
+		EventsURL: "/api/live/" + job.ID + "/events",
+	})
+}
+
+// runLiveWatch runs the watch until it ends, keeping the job's result and
+// the event streams up to date.
+func (app *App) runLiveWatch(job *Job, watch *liveWatch, req LiveRequest, opts search.LiveOptions) {
+	defer app.live.stop(job.ID)
+	result := LiveResult{Matches: []search.LiveMatch{}}
+	job.Update(func(j *Job) {
+		j.Status = JobRunning
+		j.Items = []JobItem{{VideoURL: req.VideoURL, Status: JobRunning}}
+		j.Result = result
+	})
+	opts.OnMatch = func(m search.LiveMatch) {
+		job.Update(func(j *Job) {
+			result.Matches = append(result.Matches, m)
+			j.Result = result
+		})
+		watch.publish("match", m)
+		if req.CallbackURL != "" {
+			go deliverWebhook(req.CallbackURL, req.CallbackSecret, LiveMatchCallback{
+				Event: events.MatchFound, JobID: job.ID, VideoURL: req.VideoURL, Keyword: req.Keyword, Match: m,
+			})
+		}
+	}
+	opts.OnWindow = func(w search.LiveWindow) {
+		job.Update(func(j *Job) {
+			result.Windows++
+			if w.Error != "" {
+				result.FailedWindows++
+			}
+			result.Seconds = w.End
+			j.Done = result.Windows
+			j.Result = result
+		})
+		watch.publish("window", w)
+	}
+
+	err := app.pipeline.WatchLive(job.Context(), req.Request, opts)
+	job.Update(func(j *Job) {
+		if j.Status == JobCancelled {
+			return
+		}
+		if err != nil {
+			j.Status, j.Error = JobFailed, err.Error()
+			_, j.ErrorCode = errorCode(err)
+			j.Items[0].Status, j.Items[0].Error = JobFailed, err.Error()
+			return
+		}
+		j.Status = JobCompleted
+		j.Items[0].Status = JobCompleted
+	})
+	watch.finish(job.Snapshot())
+}
+
+// liveEventsHandler streams GET /api/live/:id/events as server-sent events:
+// "match" for every match, kept ones first, "window" as each stretch is
+// searched and "end" with the finished job. Reconnecting with Last-Event-ID
+// skips the events already seen.
+func (app *App) liveEventsHandler(c *web.Context) {
+	watch, ok := app.live.get(c.Param("id"))
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "live watch not found"})
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(500, ErrorResponse{Error: "streaming is not supported"})
+		return
+	}
+	lastID, _ := strconv.Atoi(c.GetHeader("Last-Event-ID"))
+	past, ch, unsubscribe := watch.subscribe(lastID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+	write := func(ev liveEvent) bool {
+		data, err := json.Marshal(ev.data)
+		if err != nil {
+			return false
+		}
+		_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", ev.id, ev.name, data)
+		flusher.Flush()
+		return err == nil
+	}
+	for _, ev := range past {
+		if !write(ev) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok || !write(ev) {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/search"
+	"searchme/store"
+)
+
+// Marker export formats
+const (
+	MarkersEDL      = "edl"
+	MarkersCSV      = "csv"
+	MarkersChapters = "chapters"
+)
+
+type MarkerExportRequest struct {
+	search.Request
+	// Format is "edl" (CMX 3600 with marker comments, for Premiere and
+	// Resolve), "csv" or "chapters" (YouTube description chapters)
+	Format string `json:"format"`
+	// FPS sets EDL and CSV timecodes; default 30
+	FPS int `json:"fps,omitempty"`
+	// PadSeconds widens each marker's range on both sides
+	PadSeconds float64 `json:"pad_seconds,omitempty"`
+}
+
+// Marker is one occurrence of the keyword, in video time.
+type Marker struct {
+	Start, End float64
+	Text, URL  string
+}
+
+// markerExportHandler answers POST /api/export/markers: every occurrence of
+// the keyword as a marker file an editor can import, so each one shows up
+// on the timeline. The search itself runs like POST /api/search with a
+// limit of MARKER_EXPORT_LIMIT (default 500) occurrences.
+func (app *App) markerExportHandler(c *web.Context) {
+	var req MarkerExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = MarkersEDL
+	}
+	if format != MarkersEDL && format != MarkersCSV && format != MarkersChapters {
+		c.JSON(400, ErrorResponse{Error: `format must be "edl", "csv" or "chapters"`})
+		return
+	}
+	if req.FPS == 0 {
+		req.FPS = 30
+	}
+	if req.FPS < 1 || req.FPS > 120 || req.PadSeconds < 0 {
+		c.JSON(400, ErrorResponse{Error: "fps must be 1-120 and pad_seconds not negative"})
+		return
+	}
+	if req.VideoURL == "" || req.Keyword == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url and keyword are required"})
+		return
+	}
+	if _, err := search.ParseMatchMode(req.MatchMode); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = env.Int("MARKER_EXPORT_LIMIT", 500)
+	}
+	req.Offset, req.Cursor = 0, ""
+	req.OpenAIKey = callerKey(c)
+
+	resp, err := app.Search(c.Request.Context(), SearchRequest{Request: req.Request})
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	markers := responseMarkers(resp, req.PadSeconds)
+	if len(markers) == 0 {
+		c.JSON(404, ErrorResponse{Error: fmt.Sprintf("%q was not found", req.Keyword)})
+		return
+	}
+
+	name := store.VideoKey(req.VideoURL) + "-markers"
+	switch format {
+	case MarkersEDL:
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.edl"`, name))
+		c.Data(200, "text/plain; charset=utf-8", []byte(BuildEDL(req.Keyword, markers, req.FPS)))
+	case MarkersCSV:
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		c.Data(200, "text/csv; charset=utf-8", BuildMarkerCSV(req.Keyword, markers, req.FPS))
+	default:
+		c.Data(200, "text/plain; charset=utf-8", []byte(BuildYouTubeChapters(req.Keyword, markers)))
+	}
+}
+
+// responseMarkers lists a search's occurrences in time order.
+func responseMarkers(resp search.Response, pad float64) []Marker {
+	var markers []Marker
+	for _, m := range resp.Matches {
+		markers = append(markers, Marker{Start: m.Seconds, End: m.EndSeconds, Text: m.Text, URL: m.URL})
+	}
+	if len(markers) == 0 && resp.Found {
+		markers = append(markers, Marker{Start: resp.Seconds, End: resp.EndSeconds, URL: resp.URL})
+	}
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].Start < markers[j].Start })
+	for i := range markers {
+		markers[i].Start = math.Max(0, markers[i].Start-pad)
+		markers[i].End = math.Max(markers[i].End, markers[i].Start) + pad
+	}
+	return markers
+}
+
+// BuildEDL renders a CMX 3600 edit decision list with one event per
+// marker. Premiere reads the "* LOC:" comments and Resolve the "|C: |M:"
+// ones as timeline markers; both can also cut the events as clips.
+func BuildEDL(title string, markers []Marker, fps int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TITLE: %s\nFCM: NON-DROP FRAME\n\n", edlText(title))
+	for i, m := range markers {
+		in, out := timecode(m.Start, fps), timecode(m.End, fps)
+		if out == in {
+			out = timecode(m.Start+1/float64(fps), fps)
+		}
+		name := edlText(markerName(title, m))
+		fmt.Fprintf(&b, "%03d  AX       V     C        %s %s %s %s\n", i+1, in, out, in, out)
+		fmt.Fprintf(&b, "* FROM CLIP NAME: %s\n", name)
+		fmt.Fprintf(&b, "* LOC: %s YELLOW %s\n", in, name)
+		fmt.Fprintf(&b, " |C:ResolveColorYellow |M:%s |D:%d\n\n", name, max(1, int(math.Round((m.End-m.Start)*float64(fps)))))
+	}
+	return b.String()
+}
+
+// BuildMarkerCSV renders one row per marker with seconds and timecodes.
+func BuildMarkerCSV(title string, markers []Marker, fps int) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"Marker Name", "Description", "In", "Out", "Start Seconds", "End Seconds", "URL"})
+	for _, m := range markers {
+		_ = w.Write([]string{
+			markerName(title, m), m.Text, timecode(m.Start, fps), timecode(m.End, fps),
+			strconv.FormatFloat(m.Start, 'f', 3, 64), strconv.FormatFloat(m.End, 'f', 3, 64), m.URL,
+		})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// BuildYouTubeChapters renders "m:ss title" lines for a video description.
+// YouTube wants the first chapter at 0:00 and chapters at least 10 seconds
+// apart, so one is added at the start and markers closer than that are
+// folded into the one before.
+func BuildYouTubeChapters(title string, markers []Marker) string {
+	if len(markers) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	last := math.Inf(-1)
+	if markers[0].Start >= 10 {
+		b.WriteString("0:00 Start\n")
+		last = 0
+	}
+	for _, m := range markers {
+		start := m.Start
+		if last < 0 {
+			start = 0
+		}
+		if start-last < 10 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s\n", chapterTime(start), markerName(title, m))
+		last = start
+	}
+	return b.String()
+}
+
+// markerName labels a marker with the keyword and what was said.
+func markerName(keyword string, m Marker) string {
+	text := strings.Join(strings.Fields(m.Text), " ")
+	if text == "" {
+		return keyword
+	}
+	if r := []rune(text); len(r) > 60 {
+		text = string(r[:57]) + "..."
+	}
+	return keyword + ": " + text
+}
+
+// edlText keeps a name on one EDL line.
+func edlText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ", "|", "/").Replace(s)
+}
+
+// timecode formats seconds as non-drop HH:MM:SS:FF.
+func timecode(seconds float64, fps int) string {
+	frames := int(math.Round(seconds * float64(fps)))
+	f := frames % fps
+	s := frames / fps
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", s/3600, (s/60)%60, s%60, f)
+}
+
+// chapterTime formats seconds as YouTube writes timestamps: m:ss or h:mm:ss.
+func chapterTime(seconds float64) string {
+	s := int(seconds)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, (s/60)%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
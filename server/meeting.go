@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// MeetingSegment is a transcript segment attributed to a speaker.
+type MeetingSegment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker,omitempty"`
+	Text    string  `json:"text"`
+}
+
+// ActionItem is a follow-up extracted from the meeting.
+type ActionItem struct {
+	Text  string  `json:"text"`
+	Owner string  `json:"owner,omitempty"`
+	Time  float64 `json:"time"`
+}
+
+// MeetingMatch is one keyword hit inside the meeting.
+type MeetingMatch struct {
+	Seconds float64 `json:"seconds"`
+	Time    string  `json:"time"`
+	Speaker string  `json:"speaker,omitempty"`
+	Text    string  `json:"text"`
+}
+
+type MeetingResponse struct {
+	Duration    float64          `json:"duration"`
+	Segments    []MeetingSegment `json:"segments"`
+	ActionItems []ActionItem     `json:"action_items,omitempty"`
+	Matches     []MeetingMatch   `json:"matches,omitempty"`
+	// Gaps are parts of the recording that could not be transcribed
+	Gaps []transcribe.Gap `json:"gaps,omitempty"`
+}
+
+// meetingHandler processes a meeting recording, either uploaded as multipart
+// "file" or referenced by "video_url" (Zoom/Teams share links, direct MP4s).
+// Form fields: keyword with match and stem, diarize (default true),
+// action_items (default true), confirm_cost, the audio settings chunk_seconds, sample_rate, channels,
+// bitrate_kbps and the Whisper options (see formWhisperOptions).
+func (app *App) meetingHandler(c *web.Context) {
+	if !limitUpload(c, maxUploadBytes()) {
+		return
+	}
+	mode, err := search.ParseMatchMode(c.PostForm("match"))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	settings, err := app.formAudioSettings(c)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	whisper, err := formWhisperOptions(c)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	var audio *media.AudioFile
+	if _, err := c.FormFile("file"); err == nil {
+		audio, err = saveUpload(c, "file")
+		if err != nil {
+			uploadFailed(c, err)
+			return
+		}
+		audio.Settings = settings
+	} else if videoURL := c.PostForm("video_url"); videoURL != "" {
+		dl, err := app.downloader.With(media.DownloadOptions{
+			CookiesFile:   c.PostForm("cookies_file"),
+			Proxy:         c.PostForm("proxy"),
+			AudioSettings: settings,
+		})
+		if err != nil {
+			c.JSON(400, ErrorResponse{Error: err.Error()})
+			return
+		}
+		src, err := media.ResolveSource(dl, videoURL)
+		if err != nil {
+			c.JSON(400, ErrorResponse{Error: err.Error()})
+			return
+		}
+		audio, err = src.DownloadAudio(c.Request.Context())
+		if err != nil {
+			c.JSON(500, ErrorResponse{Error: err.Error()})
+			return
+		}
+	} else {
+		c.JSON(400, ErrorResponse{Error: "file or video_url is required"})
+		return
+	}
+	defer audio.Remove()
+	if !app.approveAudio(c, audio, formBool(c, "confirm_cost", false)) {
+		return
+	}
+
+	release, err := app.limiter.AcquireTranscription(c.Request.Context())
+	if err != nil {
+		c.JSON(503, ErrorResponse{Error: err.Error()})
+		return
+	}
+	transcript, err := transcribe.Audio(transcribe.WithOptions(callerContext(c), whisper), audio, nil)
+	release()
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: fmt.Sprintf("failed to transcribe meeting: %v", err)})
+		return
+	}
+
+	resp := MeetingResponse{Duration: transcript.Duration, Gaps: transcript.Gaps}
+	for _, s := range transcript.Segments {
+		resp.Segments = append(resp.Segments, MeetingSegment{Start: s.Start, End: s.End, Text: strings.TrimSpace(s.Text)})
+	}
+
+	ctx := oai.WithKey(c.Request.Context(), callerKey(c))
+	client, err := oai.ClientFor(ctx)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if formBool(c, "diarize", true) {
+		labelSpeakers(ctx, client, resp.Segments)
+	}
+	if formBool(c, "action_items", true) {
+		resp.ActionItems = extractActionItems(ctx, client, resp.Segments)
+	}
+	if keyword := strings.TrimSpace(c.PostForm("keyword")); keyword != "" {
+		matcher := search.NewMatcherWithOptions(transcript.Language, keyword, matchOptions(c, mode))
+		entries := make([]subtitle.Entry, len(resp.Segments))
+		for i, s := range resp.Segments {
+			entries[i] = subtitle.Entry{Start: s.Start, End: s.End, Text: s.Text}
+		}
+		// a phrase split across segments goes to whoever started it
+		for _, h := range matcher.Hits(entries) {
+			resp.Matches = append(resp.Matches, MeetingMatch{
+				Seconds: h.Start,
+				Time:    search.FormatTime(h.Start),
+				Speaker: resp.Segments[h.Index].Speaker,
+				Text:    h.Text,
+			})
+		}
+	}
+	c.JSON(200, resp)
+}
+
+func formBool(c *web.Context, field string, def bool) bool {
+	v, err := strconv.ParseBool(c.PostForm(field))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// meetingModel is the chat model used for diarization and action items, MEETING_MODEL.
+func meetingModel() string {
+	if m := os.Getenv("MEETING_MODEL"); m != "" {
+		return m
+	}
+	return openai.GPT4oMini
+}
+
+// meetingSegmentsPerCall bounds how many segments go into one LLM prompt.
+const meetingSegmentsPerCall = 200
+
+// meetingSpeakerContext is how many labelled segments of the previous batch
+// labelSpeakers repeats ahead of the next one.
+const meetingSpeakerContext = 20
+
+// labelSpeakers assigns speaker labels to segments. Whisper does not diarize
+// and no voice is compared, so this is not diarization: the LLM guesses
+// speaker turns from the text alone. Labels are best-effort and left empty
+// from the first failed model call on. Every batch after the first is given
+// the roster of speakers so far, with the last line each said, and the end of
+// the batch before, so a label means the same person throughout.
+func labelSpeakers(ctx context.Context, client *openai.Client, segs []MeetingSegment) {
+	var roster []string
+	lastSaid := make(map[string]string)
+	for start := 0; start < len(segs); start += meetingSegmentsPerCall {
+		end := min(start+meetingSegmentsPerCall, len(segs))
+		var prompt strings.Builder
+		if len(roster) > 0 {
+			prompt.WriteString("Speakers so far, each with the last line they said:\n")
+			for _, label := range roster {
+				fmt.Fprintf(&prompt, "%s: %s\n", label, lastSaid[label])
+			}
+			prompt.WriteString("\nThe lines just before, already labelled:\n")
+			for _, s := range segs[max(0, start-meetingSpeakerContext):start] {
+				if s.Speaker != "" {
+					fmt.Fprintf(&prompt, "%s: %s\n", s.Speaker, s.Text)
+				}
+			}
+			prompt.WriteString("\nLines to label:\n")
+		}
+		for i := start; i < end; i++ {
+			fmt.Fprintf(&prompt, "%d: %s\n", i, segs[i].Text)
+		}
+		var labels map[string]string
+		err := chatJSON(ctx, client, meetingModel(),
+			`You label speaker turns in a meeting transcript. Each line to label is "<index>: <text>". `+
+				`Reply with a JSON object mapping every index to a speaker label like "Speaker 1". `+
+				`Use a participant's name instead when the transcript makes it clear. Keep labels consistent: `+
+				`when speakers so far are listed, give the same people the same labels and only add labels for new speakers.`,
+			prompt.String(), &labels)
+		if err != nil {
+			return
+		}
+		for k, v := range labels {
+			if i, err := strconv.Atoi(k); err == nil && i >= start && i < end {
+				segs[i].Speaker = v
+			}
+		}
+		for _, s := range segs[start:end] {
+			if s.Speaker == "" {
+				continue
+			}
+			if _, ok := lastSaid[s.Speaker]; !ok {
+				roster = append(roster, s.Speaker)
+			}
+			lastSaid[s.Speaker] = s.Text
+		}
+	}
+}
+
+// extractActionItems asks the LLM for follow-ups, with the time each was raised.
+func extractActionItems(ctx context.Context, client *openai.Client, segs []MeetingSegment) []ActionItem {
+	var items []ActionItem
+	for start := 0; start < len(segs); start += meetingSegmentsPerCall {
+		end := min(start+meetingSegmentsPerCall, len(segs))
+		var prompt strings.Builder
+		for _, s := range segs[start:end] {
+			if s.Speaker != "" {
+				fmt.Fprintf(&prompt, "[%.1f] %s: %s\n", s.Start, s.Speaker, s.Text)
+			} else {
+				fmt.Fprintf(&prompt, "[%.1f] %s\n", s.Start, s.Text)
+			}
+		}
+		var found []ActionItem
+		err := chatJSON(ctx, client, meetingModel(),
+			`Extract action items from this meeting transcript. Lines start with the time in seconds. `+
+				`Reply with a JSON array of objects {"text": string, "owner": string, "time": number} `+
+				`where time is the second the item was raised. Reply [] if there are none.`,
+			prompt.String(), &found)
+		if err != nil {
+			continue
+		}
+		items = append(items, found...)
+	}
+	return items
+}
+
+// chatJSON runs a deterministic chat completion with model and decodes the
+// JSON reply into out.
+func chatJSON(ctx context.Context, client *openai.Client, model, system, user string, out interface{}) error {
+	var resp openai.ChatCompletionResponse
+	err := oai.Retry(ctx, model+" chat completion", func() (err error) {
+		resp, err = client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model:       model,
+			Temperature: 0,
+			Messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleSystem, Content: system},
+				{Role: openai.ChatMessageRoleUser, Content: user},
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 {
+		return fmt.Errorf("empty model response")
+	}
+	content := strings.TrimSpace(resp.Choices[0].Message.Content)
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```json"), "```")
+	return json.Unmarshal([]byte(strings.TrimSpace(content)), out)
+}
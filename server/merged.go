@@ -0,0 +1,44 @@
+package server
+
+import (
+	"searchme/internal/web"
+	"searchme/search"
+)
+
+// MergedTranscriptRequest names the video to merge; of the search fields
+// only video_url, language and the download options are used.
+type MergedTranscriptRequest struct {
+	search.Request
+}
+
+// mergedTranscriptHandler answers POST /api/transcripts/merged with the
+// video's captions and Whisper transcript merged into one timeline, each
+// segment timed by whichever source is more trustworthy there and saying
+// which one that was. It transcribes the whole video, so it is priced like
+// a full transcription.
+func (app *App) mergedTranscriptHandler(c *web.Context) {
+	var req MergedTranscriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url is required"})
+		return
+	}
+	if err := app.downloader.AudioSettings().Merge(req.AudioSettings).Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := req.WhisperOptions.WithDefaults().Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	req.OpenAIKey = callerKey(c)
+	merged, err := app.pipeline.MergedTranscript(c.Request.Context(), req.Request)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(200, merged)
+}
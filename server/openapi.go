@@ -0,0 +1,470 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+)
+
+// apiOperation annotates one route for the OpenAPI document. Bodies,
+// responses and query structs are Go values whose types are described by
+// reflection, following their JSON tags, so the document tracks the types
+// the handlers actually bind and return.
+type apiOperation struct {
+	Method  string
+	Path    string // with OpenAPI parameters, e.g. /api/jobs/{id}
+	Tag     string
+	Summary string
+	// Query is a struct read with bindQuery; Params are other query
+	// parameters
+	Query  interface{}
+	Params []apiParam
+	// Body is the JSON request body; Form lists multipart fields instead
+	Body interface{}
+	Form []apiParam
+	// Status (default 200) answers with Response as JSON, or as
+	// ContentTypes when the route returns files or text
+	Status       int
+	Response     interface{}
+	ContentTypes []string
+	// Access is apiKeyAccess (default), adminAccess or publicAccess
+	Access string
+	// Work routes are concurrency limited and take the caller's OpenAI key
+	Work bool
+}
+
+type apiParam struct {
+	Name        string
+	Type        string // string (default), integer, number, boolean or file
+	Description string
+}
+
+const (
+	apiKeyAccess = "api_key"
+	adminAccess  = "admin"
+	publicAccess = "public"
+)
+
+// apiOperations lists the routes Router registers, in the same order, but
+// for pprof and the docs themselves. Add new routes here too.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/public/results/{id}", Tag: "public", Summary: "Read a published search result", Response: PublicResult{}, Access: publicAccess},
+	{Method: "GET", Path: "/public/transcripts/{videoID}", Tag: "public", Summary: "Read a transcript through a signed link", Params: []apiParam{{Name: "expires", Type: "integer"}, {Name: "sig"}}, Response: TranscriptPayload{}, Access: publicAccess},
+	{Method: "GET", Path: "/public/previews/{name}", Tag: "public", Summary: "Fetch a match preview thumbnail or GIF", ContentTypes: []string{"image/jpeg", "image/gif"}, Access: publicAccess},
+	{Method: "GET", Path: "/healthz", Tag: "health", Summary: "Check the server and its dependencies", Response: HealthResponse{}, Access: publicAccess},
+	{Method: "GET", Path: "/readyz", Tag: "health", Summary: "Report readiness, 503 while warming up or degraded", Response: HealthResponse{}, Access: publicAccess},
+
+	{Method: "GET", Path: "/debug/guard", Tag: "admin", Summary: "Show resource guard state", Response: GuardStats{}, Access: adminAccess},
+	{Method: "GET", Path: "/debug/costs", Tag: "admin", Summary: "Show OpenAI spend against the budgets", Response: CostStats{}, Access: adminAccess},
+	{Method: "GET", Path: "/debug/workdir", Tag: "admin", Summary: "Show the work directory's size and the janitor's last sweep", Response: workfile.SweepStats{}, Access: adminAccess},
+	{Method: "POST", Path: "/debug/reload", Tag: "admin", Summary: "Reload keys, limits and policies from the environment", Response: ReloadResult{}, Access: adminAccess},
+	{Method: "POST", Path: "/debug/captions/resync", Tag: "admin", Summary: "Re-check indexed captions against the platform", Params: []apiParam{{Name: "all", Type: "boolean", Description: "check every video, not just stale ones"}}, Response: ResyncResult{}, Access: adminAccess},
+	{Method: "GET", Path: "/api/admin/cache", Tag: "admin", Summary: "List cached videos with their transcript, audio and chunk sizes and ages", Response: CacheListing{}, Access: adminAccess},
+	{Method: "DELETE", Path: "/api/admin/cache/{videoID}", Tag: "admin", Summary: "Purge everything cached for a video", Response: CachePurge{}, Access: adminAccess},
+
+	{Method: "GET", Path: "/api/usage", Tag: "account", Summary: "Show the calling API key's usage", Response: KeyUsage{}},
+	{Method: "GET", Path: "/api/index/search", Tag: "library", Summary: "Full-text search of indexed transcripts", Params: []apiParam{{Name: "q"}, {Name: "limit", Type: "integer"}, {Name: "offset", Type: "integer"}, {Name: "cursor"}}, Response: struct {
+		Query  string           `json:"query"`
+		Videos []IndexVideoHits `json:"videos"`
+		Page   search.Page      `json:"page"`
+	}{}},
+	{Method: "GET", Path: "/api/index/videos", Tag: "library", Summary: "List indexed videos", Response: struct {
+		Videos []store.TranscriptRecord `json:"videos"`
+	}{}},
+	{Method: "POST", Path: "/api/library/search", Tag: "library", Summary: "Search a collection of indexed videos", Body: LibrarySearchRequest{}, Response: struct {
+		Keyword string          `json:"keyword"`
+		Results []LibraryResult `json:"results"`
+		Page    search.Page     `json:"page"`
+	}{}},
+	{Method: "GET", Path: "/api/jobs/{id}", Tag: "jobs", Summary: "Report a background job's progress", Response: JobSnapshot{}},
+	{Method: "POST", Path: "/api/jobs/{id}/cancel", Tag: "jobs", Summary: "Cancel a background job", Body: struct {
+		CancelToken string `json:"cancel_token"`
+	}{}, Response: JobSnapshot{}},
+	{Method: "GET", Path: "/api/transcripts/{videoID}", Tag: "transcripts", Summary: "Read an indexed transcript", Response: TranscriptPayload{}},
+	{Method: "GET", Path: "/api/comments/{videoID}", Tag: "comments", Summary: "Read timestamp markers indexed from a video's comments", Response: CommentMarkersResponse{}},
+	{Method: "GET", Path: "/api/policy", Tag: "search", Summary: "Show the search policy and caption statistics", Response: map[string]interface{}{}},
+	{Method: "POST", Path: "/api/feedback", Tag: "feedback", Summary: "Rate a search result", Body: FeedbackRequest{}, Status: 201, Response: store.Feedback{}},
+	{Method: "GET", Path: "/api/feedback", Tag: "feedback", Summary: "List feedback", Params: []apiParam{{Name: "result_id"}, {Name: "limit", Type: "integer"}}, Response: struct {
+		Feedback []store.Feedback `json:"feedback"`
+	}{}},
+	{Method: "GET", Path: "/api/calibration", Tag: "feedback", Summary: "Show match calibration learned from feedback", Response: struct {
+		Stats       []search.CalibrationStats `json:"stats"`
+		StrictWords bool                      `json:"strict_words"`
+	}{}},
+	{Method: "POST", Path: "/api/collections/{name}/webhooks", Tag: "collections", Summary: "Subscribe a webhook to a collection", Body: struct {
+		URL         string                   `json:"url"`
+		Secret      string                   `json:"secret,omitempty"`
+		Attachments store.WebhookAttachments `json:"attachments"`
+	}{}, Status: 201, Response: store.CollectionWebhook{}},
+	{Method: "GET", Path: "/api/collections/{name}/webhooks", Tag: "collections", Summary: "List a collection's webhooks", Response: struct {
+		Webhooks []store.CollectionWebhook `json:"webhooks"`
+	}{}},
+	{Method: "DELETE", Path: "/api/collections/{name}/webhooks/{id}", Tag: "collections", Summary: "Remove a webhook", Status: 204},
+	{Method: "GET", Path: "/api/live/{id}/events", Tag: "live", Summary: "Stream a live watch's matches as server-sent events", ContentTypes: []string{"text/event-stream"}},
+
+	{Method: "POST", Path: "/api/search", Tag: "search", Summary: "Find a keyword in a video; with async or callback_url, start a job instead (202)", Body: SearchRequest{}, Response: search.Response{}, Work: true},
+	{Method: "GET", Path: "/api/search", Tag: "search", Summary: "Find a keyword in a video, parameters in the query", Query: SearchRequest{}, Response: search.Response{}, Work: true},
+	{Method: "POST", Path: "/api/search/chapter", Tag: "search", Summary: "Search within one chapter", Body: ChapterSearchRequest{}, Response: search.Response{}, Work: true},
+	{Method: "POST", Path: "/api/prefetch", Tag: "jobs", Summary: "Transcribe a video ahead of searches", Body: PrefetchRequest{}, Status: 202, Response: JobAcceptedResponse{}, Work: true},
+	{Method: "POST", Path: "/api/transcripts/{videoID}/extend", Tag: "transcripts", Summary: "Transcribe more of a partial transcript", Body: ExtendRequest{}, Response: ExtendResponse{}, Work: true},
+	{Method: "POST", Path: "/api/transcripts/merged", Tag: "transcripts", Summary: "Merge captions and transcription into one transcript", Body: MergedTranscriptRequest{}, Response: search.MergedTranscript{}, Work: true},
+	{Method: "POST", Path: "/api/search/upload", Tag: "search", Summary: "Search an uploaded caption or transcript file", Form: []apiParam{{Name: "file", Type: "file"}, {Name: "keyword"}, {Name: "language"}, {Name: "match"}, {Name: "stem", Type: "boolean"}}, Response: search.Response{}, Work: true},
+	{Method: "POST", Path: "/api/search/media", Tag: "search", Summary: "Transcribe an uploaded recording and search it", Form: append([]apiParam{{Name: "file", Type: "file"}, {Name: "keyword"}, {Name: "language"}, {Name: "match"}, {Name: "stem", Type: "boolean"}, {Name: "confirm_cost", Type: "boolean"}}, append(audioSettingParams, whisperParams...)...), Response: MediaSearchResponse{}, Work: true},
+	{Method: "POST", Path: "/api/meetings", Tag: "meetings", Summary: "Transcribe a meeting with speakers and action items", Form: append([]apiParam{{Name: "file", Type: "file"}, {Name: "video_url"}, {Name: "keyword"}, {Name: "match"}, {Name: "stem", Type: "boolean"}, {Name: "diarize", Type: "boolean"}, {Name: "action_items", Type: "boolean"}, {Name: "confirm_cost", Type: "boolean"}, {Name: "cookies_file"}, {Name: "proxy"}}, append(audioSettingParams, whisperParams...)...), Response: MeetingResponse{}, Work: true},
+	{Method: "POST", Path: "/api/lecture/search", Tag: "search", Summary: "Search a lecture's speech and slides", Body: SearchRequest{}, Response: LectureSearchResponse{}, Work: true},
+	{Method: "POST", Path: "/api/timeline", Tag: "analysis", Summary: "Chart keyword mentions over a video", Body: TimelineRequest{}, Response: TimelineResponse{}, Work: true},
+	{Method: "GET", Path: "/api/audio-tracks", Tag: "media", Summary: "List a video's audio tracks", Params: []apiParam{{Name: "video_url"}}, Response: struct {
+		Tracks []media.AudioTrack `json:"tracks"`
+	}{}, Work: true},
+	{Method: "POST", Path: "/api/export/karaoke", Tag: "export", Summary: "Export word-timed lyrics (LRC) or WebVTT", Body: KaraokeExportRequest{}, ContentTypes: []string{"text/vtt", "text/plain"}, Work: true},
+	{Method: "POST", Path: "/api/export/markers", Tag: "export", Summary: "Export matches as editor markers (EDL, CSV or YouTube chapters)", Body: MarkerExportRequest{}, ContentTypes: []string{"text/plain", "text/csv"}, Work: true},
+	{Method: "POST", Path: "/api/clip", Tag: "media", Summary: "Cut a clip around a moment", Body: ClipRequest{}, Response: ClipResponse{}, Work: true},
+	{Method: "POST", Path: "/api/comments/index", Tag: "comments", Summary: "Index timestamp markers from a video's comments", Body: CommentIndexRequest{}, Response: CommentMarkersResponse{}, Work: true},
+	{Method: "POST", Path: "/api/summarize", Tag: "analysis", Summary: "Summarize a video into timestamped topics", Body: SummarizeRequest{}, Response: SummarizeResponse{}, Work: true},
+	{Method: "POST", Path: "/api/ask", Tag: "analysis", Summary: "Answer a question from a video's transcript, citing timestamps", Body: AskRequest{}, Response: AskResponse{}, Work: true},
+	{Method: "GET", Path: "/api/topics", Tag: "analysis", Summary: "Extract keyphrases and where they are discussed", Query: TopicsRequest{}, Response: TopicsResponse{}, Work: true},
+	{Method: "POST", Path: "/api/index/playlist", Tag: "library", Summary: "Index every video of a playlist or channel", Body: PlaylistIndexRequest{}, Status: 202, Response: JobAcceptedResponse{}, Work: true},
+	{Method: "POST", Path: "/api/live", Tag: "live", Summary: "Watch a live stream and report the keyword as it is said", Body: LiveRequest{}, Status: 202, Response: LiveAcceptedResponse{}, Work: true},
+}
+
+var audioSettingParams = []apiParam{
+	{Name: "chunk_seconds", Type: "integer"},
+	{Name: "sample_rate", Type: "integer"},
+	{Name: "channels", Type: "integer"},
+	{Name: "bitrate_kbps", Type: "integer"},
+}
+
+var whisperParams = []apiParam{
+	{Name: "whisper_model"},
+	{Name: "whisper_language"},
+	{Name: "whisper_temperature", Type: "number"},
+	{Name: "whisper_prompt"},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// openAPIHandler serves the OpenAPI 3 document: GET /openapi.json.
+func openAPIHandler(c *web.Context) {
+	openAPIOnce.Do(func() {
+		var err error
+		if openAPIDoc, err = json.Marshal(buildOpenAPI(apiOperations)); err != nil {
+			panic("openapi: " + err.Error())
+		}
+	})
+	c.Data(200, web.MIMEJSON, openAPIDoc)
+}
+
+// docsHandler serves Swagger UI for the document: GET /docs. The UI's
+// assets come from the swagger-ui-dist package on unpkg.
+func docsHandler(c *web.Context) {
+	c.Data(200, "text/html; charset=utf-8", []byte(swaggerPage))
+}
+
+const swaggerPage = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>searchme API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+var routeParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// buildOpenAPI describes ops as an OpenAPI 3.0 document.
+func buildOpenAPI(ops []apiOperation) map[string]interface{} {
+	g := &schemaGen{components: map[string]interface{}{}, names: map[reflect.Type]string{
+		// plain "Response" says nothing to SDK users
+		reflect.TypeOf(search.Response{}): "SearchResponse",
+	}}
+	errorRef := g.schema(reflect.TypeOf(ErrorResponse{}))
+	paths := map[string]map[string]interface{}{}
+	for _, op := range ops {
+		var params []interface{}
+		for _, m := range routeParam.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]interface{}{"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+		}
+		if op.Query != nil {
+			params = append(params, g.queryParams(reflect.TypeOf(op.Query))...)
+		}
+		for _, p := range op.Params {
+			params = append(params, map[string]interface{}{"name": p.Name, "in": "query", "description": p.Description, "schema": paramSchema(p.Type)})
+		}
+		if op.Work {
+			params = append(params, map[string]interface{}{"name": openAIKeyHeader, "in": "header", "description": "bill OpenAI calls to this key instead of the server's", "schema": map[string]interface{}{"type": "string"}})
+		}
+
+		o := map[string]interface{}{
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"operationId": operationID(op),
+		}
+		if len(params) > 0 {
+			o["parameters"] = params
+		}
+		switch {
+		case op.Body != nil:
+			o["requestBody"] = map[string]interface{}{"required": true, "content": map[string]interface{}{
+				web.MIMEJSON: map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.Body))},
+			}}
+		case len(op.Form) > 0:
+			props := map[string]interface{}{}
+			for _, p := range op.Form {
+				props[p.Name] = paramSchema(p.Type)
+			}
+			o["requestBody"] = map[string]interface{}{"required": true, "content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{"schema": map[string]interface{}{"type": "object", "properties": props}},
+			}}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = 200
+		}
+		ok := map[string]interface{}{"description": http.StatusText(status)}
+		content := map[string]interface{}{}
+		if op.Response != nil {
+			content[web.MIMEJSON] = map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.Response))}
+		}
+		for _, ct := range op.ContentTypes {
+			content[ct] = map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}
+		}
+		if len(content) > 0 {
+			ok["content"] = content
+		}
+		o["responses"] = map[string]interface{}{
+			strconv.Itoa(status): ok,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     map[string]interface{}{web.MIMEJSON: map[string]interface{}{"schema": errorRef}},
+			},
+		}
+		switch op.Access {
+		case publicAccess:
+			o["security"] = []interface{}{}
+		case adminAccess:
+			o["security"] = []interface{}{map[string]interface{}{"AdminKey": []string{}}}
+		}
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = o
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "searchme API",
+			"version":     "1",
+			"description": "Find where a keyword is said in a video, from its captions or a Whisper transcription. Send an API key in X-API-Key when the server has keys configured. Every /api route is also served under /api/v1, its JSON replies wrapped in a stable {data, error, meta} envelope; prefer it for new clients. Failures clients may want to handle apart carry a code: video_unavailable (410), no_subtitles (422), transcription_failed (502) and, under /api/v1 only, keyword_not_found (404) for a search that came up empty.",
+		},
+		"paths":    paths,
+		"security": []interface{}{map[string]interface{}{"ApiKey": []string{}}},
+		"components": map[string]interface{}{
+			"schemas": g.components,
+			"securitySchemes": map[string]interface{}{
+				"ApiKey":   map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"AdminKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-Admin-Key"},
+			},
+		},
+	}
+}
+
+// operationID names an operation for SDK generators, e.g. "postApiSearch"
+// or "getApiJobsById".
+func operationID(op apiOperation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '-' || r == '_' }) {
+		if strings.HasPrefix(part, "{") {
+			b.WriteString("By")
+			part = strings.Trim(part, "{}")
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func paramSchema(typ string) map[string]interface{} {
+	switch typ {
+	case "":
+		return map[string]interface{}{"type": "string"}
+	case "file":
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	default:
+		return map[string]interface{}{"type": typ}
+	}
+}
+
+// schemaGen describes Go types as JSON Schema, collecting named structs
+// under components/schemas.
+type schemaGen struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]interface{}{"type": "integer", "description": "nanoseconds"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return map[string]interface{}{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if _, described := g.components[name]; !ok || !described {
+			if !ok {
+				name = g.componentName(t)
+				g.names[t] = name
+			}
+			g.components[name] = map[string]interface{}{} // placeholder for recursive types
+			g.components[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		// interface{} and anything else take any JSON value
+		return map[string]interface{}{}
+	}
+}
+
+// componentName is the type's name, qualified by its package when another
+// package's type already has it.
+func (g *schemaGen) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+// object describes a struct's JSON fields, embedded structs' fields
+// included as encoding/json flattens them.
+func (g *schemaGen) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	g.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]interface{}) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+	}
+	// fields of the outer struct win over embedded ones of the same name
+	for _, et := range embedded {
+		inner := map[string]interface{}{}
+		g.fields(et, inner)
+		for name, s := range inner {
+			if _, ok := props[name]; !ok {
+				props[name] = s
+			}
+		}
+	}
+}
+
+// queryParams lists the query parameters bindQuery reads into t.
+func (g *schemaGen) queryParams(t reflect.Type) []interface{} {
+	kinds := map[string]reflect.Type{}
+	jsonFields(t, kinds)
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var params []interface{}
+	for _, name := range names {
+		var s map[string]interface{}
+		switch kinds[name].Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+			s = g.schema(kinds[name])
+		case reflect.Slice:
+			s = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+		default:
+			continue
+		}
+		p := map[string]interface{}{"name": name, "in": "query", "schema": s}
+		if s["type"] == "array" {
+			p["style"], p["explode"] = "form", false
+		}
+		params = append(params, p)
+	}
+	return params
+}
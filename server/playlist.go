@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/transcribe"
+)
+
+type PlaylistIndexRequest struct {
+	PlaylistURL string `json:"playlist_url"`
+	Language    string `json:"language,omitempty"`
+	// Limit caps how many videos are indexed, 0 means all
+	Limit int `json:"limit,omitempty"`
+	// Collection files the videos under a name whose webhooks hear about new ones
+	Collection string `json:"collection,omitempty"`
+	// CallbackURL is POSTed the finished job, signed with CallbackSecret when
+	// it is set
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
+	media.DownloadOptions
+	// OpenAIKey is the caller's X-OpenAI-Key, which pays for the whole job
+	OpenAIKey string `json:"-"`
+}
+
+// indexPlaylistHandler enumerates a playlist/channel and indexes every video in the
+// background. Progress is available at GET /api/jobs/:id.
+func (app *App) indexPlaylistHandler(c *web.Context) {
+	if app.store == nil {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	var req PlaylistIndexRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if strings.TrimSpace(req.PlaylistURL) == "" {
+		c.JSON(400, ErrorResponse{Error: "playlist_url is required"})
+		return
+	}
+	if err := media.CheckWebURL(req.PlaylistURL); err != nil {
+		c.JSON(400, ErrorResponse{Error: "playlist_url: " + err.Error()})
+		return
+	}
+	if req.CallbackURL != "" {
+		if err := checkWebhookURL(c.Request.Context(), req.CallbackURL); err != nil {
+			c.JSON(400, ErrorResponse{Error: "callback_url: " + err.Error()})
+			return
+		}
+	}
+	dl, err := app.downloader.With(req.DownloadOptions)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	req.OpenAIKey = callerKey(c)
+	job := app.jobs.Create("index_playlist")
+	job.SetCallback(req.CallbackURL, req.CallbackSecret)
+	go app.runPlaylistIndex(job, dl, req)
+	jobAccepted(c, job)
+}
+
+func (app *App) runPlaylistIndex(job *Job, dl *media.Downloader, req PlaylistIndexRequest) {
+	job.Update(func(j *Job) { j.Status = JobRunning })
+
+	entries, err := dl.ListPlaylist(req.PlaylistURL)
+	if err != nil {
+		job.Update(func(j *Job) { j.Status = JobFailed; j.Error = err.Error() })
+		return
+	}
+	if req.Limit > 0 && len(entries) > req.Limit {
+		entries = entries[:req.Limit]
+	}
+	job.Update(func(j *Job) {
+		j.Total = len(entries)
+		for _, e := range entries {
+			j.Items = append(j.Items, JobItem{VideoURL: e.URL, Title: e.Title, Status: JobQueued})
+		}
+	})
+
+	ctx := job.Context()
+	for i, e := range entries {
+		if ctx.Err() != nil {
+			// cancelled: Cancel already marked the remaining items
+			break
+		}
+		job.Update(func(j *Job) { j.Items[i].Status = JobRunning })
+		_, existed, _ := app.store.GetTranscript(context.Background(), store.VideoKey(e.URL))
+		_, _, _, err := app.pipeline.LoadSegments(ctx, search.Request{
+			VideoURL:        e.URL,
+			Language:        req.Language,
+			DownloadOptions: req.DownloadOptions,
+			OpenAIKey:       req.OpenAIKey,
+			Progress: func(p transcribe.Progress) {
+				job.Update(func(j *Job) {
+					if j.Items[i].Status == JobRunning {
+						j.Items[i].Transcription = &p
+					}
+				})
+			},
+		})
+		job.Update(func(j *Job) {
+			if ctx.Err() != nil {
+				j.Items[i].Status = JobCancelled
+				return
+			}
+			if err != nil {
+				j.Items[i].Status = JobFailed
+				j.Items[i].Error = err.Error()
+				j.Failed++
+				return
+			}
+			j.Items[i].Status = JobCompleted
+			j.Done++
+		})
+		if err != nil {
+			log.Printf("playlist %s: failed to index %s: %v", job.ID, e.URL, err)
+			continue
+		}
+		app.addToCollection(context.Background(), req.Collection, e.URL, existed)
+	}
+
+	job.Update(func(j *Job) {
+		j.Status = JobCompleted
+		if j.Total > 0 && j.Failed == j.Total {
+			j.Status = JobFailed
+			j.Error = "every video failed to index"
+		}
+	})
+}
@@ -0,0 +1,13 @@
+package server
+
+import (
+	"searchme/internal/web"
+	"searchme/subtitle"
+)
+
+// policyHandler shows the search policy and the hit rates it is deciding on,
+// along with how often each caption variant had captions.
+func (app *App) policyHandler(c *web.Context) {
+	policy := app.pipeline.Policy
+	c.JSON(200, web.H{"config": policy.Config(), "stats": policy.Stats(), "caption_variants": subtitle.Stats()})
+}
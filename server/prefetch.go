@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"searchme/internal/web"
+	"searchme/search"
+	"searchme/store"
+	"searchme/transcribe"
+)
+
+// PrefetchRequest hints at the searches a client expects to run next on a
+// video; see search.PrefetchHints. Of the search fields only video_url,
+// language and the download options are used, and those must match the
+// later searches' for the warmed chunks to be reused.
+type PrefetchRequest struct {
+	search.Request
+	search.PrefetchHints
+}
+
+// prefetchHandler answers POST /api/prefetch with 202 and warms the video
+// in the background as a "prefetch" job: captions are indexed, or the
+// chunks the hints point at are transcribed, so the follow-up search
+// doesn't wait on a download or Whisper. The job's result is a
+// search.PrefetchResult.
+func (app *App) prefetchHandler(c *web.Context) {
+	var req PrefetchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if strings.TrimSpace(req.VideoURL) == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url is required"})
+		return
+	}
+	for _, r := range req.Ranges {
+		if r.Start < 0 || r.End <= r.Start {
+			c.JSON(400, ErrorResponse{Error: "ranges need 0 <= start < end"})
+			return
+		}
+	}
+	if err := app.downloader.AudioSettings().Merge(req.AudioSettings).Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := req.WhisperOptions.WithDefaults().Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	req.OpenAIKey = callerKey(c)
+	jobAccepted(c, app.startPrefetchJob(req.Request, req.PrefetchHints))
+}
+
+// startPrefetchJob runs Pipeline.Prefetch as a cancellable job. Videos
+// already in the index have nothing to warm.
+func (app *App) startPrefetchJob(req search.Request, hints search.PrefetchHints) *Job {
+	job := app.jobs.Create("prefetch")
+	req.Progress = func(p transcribe.Progress) {
+		job.Update(func(j *Job) {
+			if j.Items[0].Status == JobRunning {
+				j.Items[0].Transcription = &p
+			}
+		})
+	}
+	job.Update(func(j *Job) {
+		j.Status = JobRunning
+		j.Total = 1
+		j.Items = []JobItem{{VideoURL: req.VideoURL, Status: JobRunning}}
+	})
+	go func() {
+		res, err := app.prefetch(job.Context(), req, hints)
+		job.Update(func(j *Job) {
+			if j.Status == JobCancelled {
+				return
+			}
+			j.Items[0].Transcription = nil
+			if err != nil {
+				log.Printf("prefetch %s: %v", req.VideoURL, err)
+				j.Status, j.Error = JobFailed, err.Error()
+				j.Items[0].Status, j.Items[0].Error = JobFailed, err.Error()
+				j.Failed = 1
+				return
+			}
+			j.Status, j.Result = JobCompleted, res
+			j.Items[0].Status = JobCompleted
+			j.Done = 1
+		})
+	}()
+	return job
+}
+
+func (app *App) prefetch(ctx context.Context, req search.Request, hints search.PrefetchHints) (search.PrefetchResult, error) {
+	if app.store != nil {
+		rec, found, err := app.store.GetTranscript(ctx, store.VideoKey(req.VideoURL))
+		if err != nil {
+			log.Printf("prefetch: index lookup failed: %v", err)
+		} else if found && len(rec.Segments) > 0 && !rec.Partial() && (req.Language == "" || strings.EqualFold(search.NormalizeLang(req.Language), rec.Language)) {
+			return search.PrefetchResult{Source: rec.Source}, nil
+		}
+	}
+	return app.pipeline.Prefetch(ctx, req, hints)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/search"
+)
+
+// previewDir is where rendered previews are kept, PREVIEW_DIR or "previews"
+// in the work directory.
+func previewDir() string {
+	if d := os.Getenv("PREVIEW_DIR"); d != "" {
+		return d
+	}
+	return filepath.Join(workfile.Dir(), "previews")
+}
+
+// addPreviews renders a preview of the kind the request asked for at each
+// match, up to PREVIEW_MAX (default 5), and sets the matches' preview URLs.
+// Previews are public at /public/previews/ under random names and kept for
+// PREVIEW_TTL (default 24h). A preview that fails only leaves its URL out.
+func (app *App) addPreviews(ctx context.Context, req SearchRequest, resp *search.Response) {
+	if !resp.Found {
+		return
+	}
+	dl, err := app.downloader.With(req.DownloadOptions)
+	if err != nil {
+		return
+	}
+	src, err := media.ResolveSource(dl, req.VideoURL)
+	if err != nil {
+		return
+	}
+	dir := previewDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("previews: %v", err)
+		return
+	}
+	prunePreviews(dir, env.Duration("PREVIEW_TTL", 24*time.Hour))
+
+	ext := ".jpg"
+	if req.Previews == media.PreviewGIF {
+		ext = ".gif"
+	}
+	render := func(at float64) string {
+		name := workfile.Name("preview") + ext
+		if err := media.Preview(ctx, src, at, req.Previews, filepath.Join(dir, name)); err != nil {
+			log.Printf("preview of %s at %.1fs: %v", req.VideoURL, at, err)
+			return ""
+		}
+		return strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/") + "/public/previews/" + name
+	}
+	budget := env.Int("PREVIEW_MAX", 5)
+	if len(resp.Matches) == 0 {
+		resp.PreviewURL = render(resp.Seconds)
+		return
+	}
+	for i := range resp.Matches {
+		if i >= budget {
+			break
+		}
+		resp.Matches[i].PreviewURL = render(resp.Matches[i].Seconds)
+		if resp.Matches[i].Seconds == resp.Seconds && resp.PreviewURL == "" {
+			resp.PreviewURL = resp.Matches[i].PreviewURL
+		}
+	}
+}
+
+// prunePreviews deletes previews older than ttl.
+func prunePreviews(dir string, ttl time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > ttl {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// validPreviews checks a request's previews option.
+func validPreviews(kind string) error {
+	switch kind {
+	case "", media.PreviewThumbnail, media.PreviewGIF:
+		return nil
+	default:
+		return fmt.Errorf("previews must be %q or %q", media.PreviewThumbnail, media.PreviewGIF)
+	}
+}
+
+// previewHandler serves GET /public/previews/:name.
+func (app *App) previewHandler(c *web.Context) {
+	name := c.Param("name")
+	if name != filepath.Base(name) || !strings.HasPrefix(name, "preview_") {
+		c.JSON(404, ErrorResponse{Error: "preview not found"})
+		return
+	}
+	path := filepath.Join(previewDir(), name)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(404, ErrorResponse{Error: "preview not found"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	http.ServeFile(c.Writer, c.Request, path)
+}
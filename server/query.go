@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// bindQuery fills dst, a pointer to a JSON request struct, from query
+// parameters named like its JSON fields, so a GET takes the same fields as
+// the matching POST. Parameters the struct doesn't have are ignored, e.g.
+// tracking parameters added by link shorteners.
+func bindQuery(values url.Values, dst interface{}) error {
+	kinds := map[string]reflect.Type{}
+	jsonFields(reflect.TypeOf(dst).Elem(), kinds)
+
+	body := map[string]interface{}{}
+	for name, vs := range values {
+		t, ok := kinds[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		v := vs[len(vs)-1]
+		switch t.Kind() {
+		case reflect.String:
+			body[name] = v
+		case reflect.Bool:
+			if v == "" {
+				// ?stem alone switches it on
+				v = "true"
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false", name)
+			}
+			body[name] = b
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number", name)
+			}
+			body[name] = n
+		case reflect.Slice:
+			var list []string
+			for _, v := range vs {
+				for _, p := range strings.Split(v, ",") {
+					if p = strings.TrimSpace(p); p != "" {
+						list = append(list, p)
+					}
+				}
+			}
+			body[name] = list
+		}
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	return nil
+}
+
+// jsonFields maps the JSON names of t's fields, including those of embedded
+// structs, to their types.
+func jsonFields(t reflect.Type, out map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			jsonFields(f.Type, out)
+			continue
+		}
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out[name] = f.Type
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"searchme/internal/dotenv"
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/langpack"
+)
+
+// ReloadResult lists what a reload refreshed and what it could not.
+type ReloadResult struct {
+	Reloaded []string `json:"reloaded"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Reload re-reads .env and applies the settings that can change without a
+// restart: request and pipeline limits, cost budgets, API and admin keys, the OpenAI key,
+// language pack rules, yt-dlp proxy/cookie/rate settings and audio encoding.
+// Work in flight finishes under the settings it started with. Structural
+// settings (PORT, TLS, INDEX_DB, UPSTREAM_URL, MAX_SEGMENTS) still need a
+// restart.
+func (app *App) Reload() ReloadResult {
+	var res ReloadResult
+	fail := func(what string, err error) {
+		log.Printf("reload: %s: %v", what, err)
+		res.Errors = append(res.Errors, what+": "+err.Error())
+	}
+
+	// variables dropped from .env keep their old values until restart
+	if _, err := os.Stat(".env"); err == nil {
+		if err := dotenv.Overload(); err != nil {
+			fail(".env", err)
+		} else {
+			res.Reloaded = append(res.Reloaded, ".env")
+		}
+	}
+
+	app.limiter.Reload(limitConfigFromEnv())
+	app.guard.Reload(guardConfigFromEnv())
+	app.costs.Reload(costConfigFromEnv())
+	res.Reloaded = append(res.Reloaded, "limits")
+
+	if err := app.auth.ReloadFromEnv(); err != nil {
+		fail("api_keys", err)
+	} else {
+		res.Reloaded = append(res.Reloaded, "api_keys")
+	}
+	app.admin.ReloadFromEnv()
+	res.Reloaded = append(res.Reloaded, "admin_keys")
+
+	if err := oai.Reload(); err != nil {
+		fail("openai", err)
+	} else {
+		res.Reloaded = append(res.Reloaded, "openai")
+	}
+
+	langpack.Reload()
+	res.Reloaded = append(res.Reloaded, "language_packs")
+	if err := app.downloader.ReloadFromEnv(); err != nil {
+		fail("downloader", err)
+	} else {
+		res.Reloaded = append(res.Reloaded, "downloader")
+	}
+	app.health.Reset()
+
+	log.Printf("reload: refreshed %v", res.Reloaded)
+	return res
+}
+
+// reloadOnSIGHUP reloads the config each time the process receives SIGHUP.
+func (app *App) reloadOnSIGHUP() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for range sig {
+			log.Printf("SIGHUP received, reloading config")
+			app.Reload()
+		}
+	}()
+}
+
+// reloadHandler reloads the config for POST /debug/reload.
+func (app *App) reloadHandler(c *web.Context) {
+	res := app.Reload()
+	if len(res.Errors) > 0 {
+		c.JSON(500, res)
+		return
+	}
+	c.JSON(200, res)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"log"
+	"math"
+
+	"searchme/events"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+)
+
+// Kinds of change between a published result and the re-processed transcript
+const (
+	ResultMoved   = "moved"
+	ResultRemoved = "removed"
+	ResultAdded   = "added"
+)
+
+// resultMoveTolerance is how far (seconds) a match may shift before it is reported as moved
+const resultMoveTolerance = 1.0
+
+// ResultChange is one published result whose answer changed.
+type ResultChange struct {
+	ResultID string          `json:"result_id"`
+	Keyword  string          `json:"keyword"`
+	Change   string          `json:"change"`
+	Before   search.Response `json:"before"`
+	After    search.Response `json:"after"`
+}
+
+// ResultDiffReport is the body POSTed to collection webhooks when re-processing
+// a video changes matches that were already published.
+type ResultDiffReport struct {
+	Event    events.Type    `json:"event"`
+	VideoID  string         `json:"video_id"`
+	VideoURL string         `json:"video_url"`
+	Source   string         `json:"source"`
+	Changes  []ResultChange `json:"changes"`
+}
+
+// diffResults re-runs each published result's keyword against the new
+// segments and returns the ones whose answer changed.
+func diffResults(results []PublicResult, lang, source string, subs []subtitle.Entry) []ResultChange {
+	var changes []ResultChange
+	for _, r := range results {
+		rLang := r.Language
+		if rLang == "" {
+			rLang = lang
+		}
+		mode, _ := search.ParseMatchMode(r.MatchMode)
+		m := search.NewMatcherWithOptions(rLang, r.Keyword, search.MatchOptions{Mode: mode, Stem: r.Stem})
+		var after search.Response
+		if sub, ok := search.FindInSubtitles(subs, m); ok {
+			after = search.NewResponse(r.VideoURL, search.Match{Start: sub.Start, End: sub.End, Text: sub.Text, Source: source}, true, rLang)
+		} else {
+			after = search.NewResponse(r.VideoURL, search.Match{}, false, rLang)
+		}
+
+		change := ""
+		switch {
+		case r.Found && !after.Found:
+			change = ResultRemoved
+		case !r.Found && after.Found:
+			change = ResultAdded
+		case r.Found && math.Abs(after.Seconds-r.Seconds) > resultMoveTolerance:
+			change = ResultMoved
+		}
+		if change != "" {
+			changes = append(changes, ResultChange{ResultID: r.ID, Keyword: r.Keyword, Change: change, Before: r.Response, After: after})
+		}
+	}
+	return changes
+}
+
+// reportResultChanges compares the results published for a video against its
+// freshly stored transcript and notifies the video's collection webhooks of
+// any differences, so saved links and alerts can be corrected.
+func (app *App) reportResultChanges(ctx context.Context, videoURL, lang, source string, subs []subtitle.Entry) {
+	videoID := store.VideoKey(videoURL)
+	results, err := app.results.ListResults(ctx, videoID)
+	if err != nil {
+		log.Printf("failed to load published results for %s: %v", videoURL, err)
+		return
+	}
+	if len(results) == 0 {
+		return
+	}
+	changes := diffResults(results, lang, source, subs)
+	if len(changes) == 0 {
+		return
+	}
+	log.Printf("re-processing %s changed %d published result(s)", videoURL, len(changes))
+
+	report := ResultDiffReport{
+		Event:    events.ResultsChanged,
+		VideoID:  videoID,
+		VideoURL: videoURL,
+		Source:   source,
+		Changes:  changes,
+	}
+	ids := make([]string, len(changes))
+	for i, ch := range changes {
+		ids[i] = ch.ResultID
+	}
+	events.Emit(events.ResultsChanged, videoURL, map[string]interface{}{"result_ids": ids, "source": source})
+
+	cs, ok := app.collections()
+	if !ok {
+		return
+	}
+	rec, found, err := app.store.GetTranscript(ctx, videoID)
+	if err != nil || !found || rec.Collection == "" {
+		return
+	}
+	hooks, err := cs.ListWebhooks(ctx, rec.Collection)
+	if err != nil {
+		log.Printf("failed to load webhooks for collection %s: %v", rec.Collection, err)
+		return
+	}
+	for _, h := range hooks {
+		go deliverWebhook(h.URL, h.Secret, report)
+	}
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/transcribe"
+)
+
+// PublicResult is a search result published for unauthenticated, cacheable reads.
+type PublicResult struct {
+	ID        string    `json:"id"`
+	VideoURL  string    `json:"video_url"`
+	Keyword   string    `json:"keyword"`
+	MatchMode string    `json:"match,omitempty"`
+	Stem      bool      `json:"stem,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	search.Response
+}
+
+// ResultStore keeps published results. Results are write-once: the first
+// result stored under an ID is the one served forever, which is what lets
+// /public/results send immutable caching headers.
+type ResultStore interface {
+	SaveResult(ctx context.Context, r PublicResult) error
+	GetResult(ctx context.Context, id string) (PublicResult, bool, error)
+	// ListResults returns the results published for a video (by store.VideoKey).
+	ListResults(ctx context.Context, videoID string) ([]PublicResult, error)
+}
+
+// PublicResultID is deterministic so repeated searches share one cacheable URL.
+// Options left at their defaults (substring matching, no stemming, sequential
+// order, see also resultVariant) stay out of the key so IDs published before
+// they existed stay the same.
+func PublicResultID(req SearchRequest) string {
+	key := store.VideoKey(req.VideoURL) + "\x00" + search.NormalizeLang(req.Language) + "\x00" + strings.ToLower(strings.TrimSpace(req.Keyword))
+	if mode, _ := search.ParseMatchMode(req.MatchMode); mode != search.MatchSubstring {
+		key += "\x00" + string(mode)
+	}
+	if req.Stem {
+		key += "\x00stem"
+	}
+	// a priority search may settle on a later match than a sequential one
+	if order, _ := search.ParseOrder(req.Order, req.Hint); order == search.OrderPriority {
+		key += "\x00" + order
+	}
+	if v, _ := json.Marshal(variantOf(req)); string(v) != "{}" {
+		key += "\x00" + string(v)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:12])
+}
+
+// resultVariant holds the other options that change a search's response,
+// down to the page of ranked matches; those left at their defaults are
+// omitted.
+type resultVariant struct {
+	Window          *search.TimeRange `json:"window,omitempty"`
+	Chapter         string            `json:"chapter,omitempty"`
+	ChapterIndex    int               `json:"chapter_index,omitempty"`
+	Languages       []string          `json:"languages,omitempty"`
+	Translate       bool              `json:"translate,omitempty"`
+	KeywordLanguage string            `json:"keyword_language,omitempty"`
+	Limit           int               `json:"limit,omitempty"`
+	Offset          int               `json:"offset,omitempty"`
+	Cursor          string            `json:"cursor,omitempty"`
+	Hint            string            `json:"hint,omitempty"`
+	BudgetMinutes   float64           `json:"budget_minutes,omitempty"`
+	AudioTrack      string            `json:"audio_track,omitempty"`
+	media.AudioSettings
+	transcribe.WhisperOptions
+	CommunityHints bool   `json:"community_hints,omitempty"`
+	Previews       string `json:"previews,omitempty"`
+	Voice          bool   `json:"voice,omitempty"`
+}
+
+func variantOf(req SearchRequest) resultVariant {
+	v := resultVariant{
+		Window:         req.Window,
+		Chapter:        strings.ToLower(strings.TrimSpace(req.Chapter)),
+		ChapterIndex:   req.ChapterIndex,
+		Translate:      req.Translate,
+		Limit:          req.Limit,
+		Offset:         req.Offset,
+		Cursor:         req.Cursor,
+		Hint:           strings.ToLower(strings.TrimSpace(req.Hint)),
+		BudgetMinutes:  req.BudgetMinutes,
+		AudioTrack:     req.AudioTrack,
+		AudioSettings:  req.AudioSettings,
+		WhisperOptions: req.WhisperOptions,
+		CommunityHints: req.CommunityHints,
+		Previews:       req.Previews,
+		Voice:          req.Voice,
+	}
+	if req.KeywordLanguage != "" {
+		v.KeywordLanguage = search.NormalizeLang(req.KeywordLanguage)
+	}
+	for _, lang := range req.Languages {
+		v.Languages = append(v.Languages, search.NormalizeLang(lang))
+	}
+	return v
+}
+
+// resultDocStore is implemented by transcript stores that can also keep
+// published results as JSON documents.
+type resultDocStore interface {
+	SaveResult(ctx context.Context, id, videoID string, data []byte, createdAt time.Time) error
+	GetResult(ctx context.Context, id string) ([]byte, bool, error)
+	ListResults(ctx context.Context, videoID string) ([][]byte, error)
+}
+
+// newResultStore persists results next to the transcript index when there is
+// one, otherwise keeps them in memory.
+func newResultStore(st store.TranscriptStore) ResultStore {
+	if ds, ok := st.(resultDocStore); ok {
+		return docResultStore{ds}
+	}
+	return &memoryResultStore{max: env.Int("PUBLIC_RESULTS_MAX", 10000), results: map[string]PublicResult{}}
+}
+
+// publishResult stores a search result and stamps its public ID on resp,
+// unless the ID already serves an earlier result: results are write-once.
+// Publishing is best-effort and never fails the search.
+func (app *App) publishResult(ctx context.Context, req SearchRequest, resp *search.Response) {
+	id := PublicResultID(req)
+	created := time.Now().UTC()
+	err := app.results.SaveResult(ctx, PublicResult{
+		ID:        id,
+		VideoURL:  req.VideoURL,
+		Keyword:   req.Keyword,
+		MatchMode: req.MatchMode,
+		Stem:      req.Stem,
+		CreatedAt: created,
+		Response:  *resp,
+	})
+	if err != nil {
+		return
+	}
+	if stored, found, err := app.results.GetResult(ctx, id); err != nil || !found || !stored.CreatedAt.Equal(created) {
+		return
+	}
+	resp.ResultID = id
+}
+
+// publicResultHandler serves GET /public/results/:id without authentication.
+// Responses are immutable so a CDN in front of the service absorbs the traffic.
+func (app *App) publicResultHandler(c *web.Context) {
+	id := c.Param("id")
+	etag := `"` + id + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.Status(http.StatusNotModified)
+		return
+	}
+	r, found, err := app.results.GetResult(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !found {
+		// short negative caching: the result may be published later
+		c.Header("Cache-Control", "public, max-age=60")
+		c.JSON(404, ErrorResponse{Error: "result not found"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", r.CreatedAt.Format(http.TimeFormat))
+	c.JSON(200, r)
+}
+
+// docResultStore stores results as JSON in a resultDocStore.
+type docResultStore struct {
+	docs resultDocStore
+}
+
+func (d docResultStore) SaveResult(ctx context.Context, r PublicResult) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return d.docs.SaveResult(ctx, r.ID, store.VideoKey(r.VideoURL), data, r.CreatedAt)
+}
+
+func (d docResultStore) GetResult(ctx context.Context, id string) (PublicResult, bool, error) {
+	var r PublicResult
+	data, found, err := d.docs.GetResult(ctx, id)
+	if err != nil || !found {
+		return r, found, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, false, err
+	}
+	return r, true, nil
+}
+
+func (d docResultStore) ListResults(ctx context.Context, videoID string) ([]PublicResult, error) {
+	docs, err := d.docs.ListResults(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PublicResult, 0, len(docs))
+	for _, data := range docs {
+		var r PublicResult
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+type memoryResultStore struct {
+	mu      sync.Mutex
+	max     int
+	order   []string
+	results map[string]PublicResult
+}
+
+func (m *memoryResultStore) SaveResult(_ context.Context, r PublicResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.results[r.ID]; ok {
+		return nil
+	}
+	if m.max > 0 && len(m.order) >= m.max {
+		delete(m.results, m.order[0])
+		m.order = m.order[1:]
+	}
+	m.results[r.ID] = r
+	m.order = append(m.order, r.ID)
+	return nil
+}
+
+func (m *memoryResultStore) GetResult(_ context.Context, id string) (PublicResult, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.results[id]
+	return r, ok, nil
+}
+
+func (m *memoryResultStore) ListResults(_ context.Context, videoID string) ([]PublicResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []PublicResult
+	for _, id := range m.order {
+		if r := m.results[id]; store.VideoKey(r.VideoURL) == videoID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"searchme/events"
+	"searchme/internal/env"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+)
+
+// ResyncConfig sets how often cached caption tracks are compared with the
+// platform's.
+type ResyncConfig struct {
+	// Interval between resync passes; 0 disables the background loop
+	Interval time.Duration
+	// MaxAge is how long a track is trusted before it is checked again
+	MaxAge time.Duration
+	// Batch caps the tracks checked per pass
+	Batch int
+}
+
+// resyncConfigFromEnv reads CAPTION_RESYNC_INTERVAL (6h, 0 off),
+// CAPTION_RESYNC_AGE (168h) and CAPTION_RESYNC_BATCH (20).
+func resyncConfigFromEnv() ResyncConfig {
+	return ResyncConfig{
+		Interval: env.Duration("CAPTION_RESYNC_INTERVAL", 6*time.Hour),
+		MaxAge:   env.Duration("CAPTION_RESYNC_AGE", 7*24*time.Hour),
+		Batch:    env.Int("CAPTION_RESYNC_BATCH", 20),
+	}
+}
+
+// ResyncResult counts what one resync pass found.
+type ResyncResult struct {
+	Checked   int `json:"checked"`
+	Unchanged int `json:"unchanged"`
+	Changed   int `json:"changed"`
+	Removed   int `json:"removed"`
+	Failed    int `json:"failed"`
+}
+
+// resyncCaptions re-fetches the least recently checked caption tracks and
+// compares their fingerprints with the index. Edited tracks are re-indexed,
+// which also re-checks published results; tracks the uploader removed are
+// dropped so the next search runs the pipeline again. Fetch errors leave the
+// track to be retried next pass.
+func (app *App) resyncCaptions(ctx context.Context, cfg ResyncConfig) (ResyncResult, error) {
+	var res ResyncResult
+	cs, ok := app.store.(store.CaptionSyncStore)
+	if !ok {
+		return res, nil
+	}
+	stale, err := cs.StaleCaptions(ctx, time.Now().Add(-cfg.MaxAge), cfg.Batch)
+	if err != nil {
+		return res, err
+	}
+	for _, rec := range stale {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		res.Checked++
+		src, err := media.ResolveSource(app.downloader, rec.VideoURL)
+		if err != nil {
+			log.Printf("caption resync: %s: %v", rec.VideoURL, err)
+			res.Failed++
+			continue
+		}
+		track, found, err := subtitle.FetchTrack(app.downloader, src, rec.VideoURL, rec.Language)
+		if !found {
+			if err != nil {
+				log.Printf("caption resync: %s: %v", rec.VideoURL, err)
+				res.Failed++
+				continue
+			}
+			log.Printf("caption resync: captions for %s are gone, dropping it from the index", rec.VideoURL)
+			if err := cs.DeleteTranscript(ctx, rec.VideoID); err != nil {
+				log.Printf("caption resync: %s: %v", rec.VideoURL, err)
+				res.Failed++
+				continue
+			}
+			events.Emit(events.CaptionsChanged, rec.VideoURL, map[string]interface{}{"video_id": rec.VideoID, "removed": true})
+			res.Removed++
+			continue
+		}
+		subs, err := track.Entries()
+		if err != nil {
+			log.Printf("caption resync: %s: %v", rec.VideoURL, err)
+			res.Failed++
+			continue
+		}
+		search.TagLanguages(subs, rec.Language)
+		if rec.Fingerprint == "" {
+			// indexed before fingerprints were kept: nothing to compare with,
+			// so take the current track as the baseline
+			app.indexTranscript(rec.VideoURL, rec.Language, track.Source, subs)
+			res.Unchanged++
+			continue
+		}
+		if fp := subtitle.Fingerprint(subs); fp == rec.Fingerprint {
+			if err := cs.MarkChecked(ctx, rec.VideoID, time.Now()); err != nil {
+				log.Printf("caption resync: %s: %v", rec.VideoURL, err)
+			}
+			res.Unchanged++
+			continue
+		}
+		log.Printf("caption resync: captions for %s changed, re-indexing", rec.VideoURL)
+		app.indexTranscript(rec.VideoURL, rec.Language, track.Source, subs)
+		events.Emit(events.CaptionsChanged, rec.VideoURL, map[string]interface{}{"video_id": rec.VideoID, "source": track.Source})
+		res.Changed++
+	}
+	return res, nil
+}
+
+// startCaptionResync runs resync passes in the background every
+// CAPTION_RESYNC_INTERVAL while the index is enabled.
+func (app *App) startCaptionResync() {
+	cfg := resyncConfigFromEnv()
+	if _, ok := app.store.(store.CaptionSyncStore); !ok || cfg.Interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			res, err := app.resyncCaptions(context.Background(), resyncConfigFromEnv())
+			if err != nil {
+				log.Printf("caption resync failed: %v", err)
+				continue
+			}
+			if res.Checked > 0 {
+				log.Printf("caption resync: checked %d, %d changed, %d removed, %d failed", res.Checked, res.Changed, res.Removed, res.Failed)
+			}
+		}
+	}()
+}
+
+// resyncHandler runs a resync pass now for POST /debug/captions/resync.
+// ?all=true checks every caption track regardless of age, up to the batch size.
+func (app *App) resyncHandler(c *web.Context) {
+	if _, ok := app.store.(store.CaptionSyncStore); !ok {
+		c.JSON(404, ErrorResponse{Error: "transcript index is disabled (set INDEX_DB)"})
+		return
+	}
+	cfg := resyncConfigFromEnv()
+	if all, _ := strconv.ParseBool(c.Query("all")); all {
+		cfg.MaxAge = 0
+	}
+	res, err := app.resyncCaptions(c.Request.Context(), cfg)
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(200, res)
+}
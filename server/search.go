@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"searchme/events"
+	"searchme/internal/web"
+	"searchme/search"
+	"searchme/store"
+	"searchme/transcribe"
+)
+
+// HTTP Handlers
+type SearchRequest struct {
+	search.Request
+	// Public publishes the result for unauthenticated reads at /public/results/:id
+	Public bool `json:"public,omitempty"`
+	// Voice adds a spoken-style answer and SSML for voice assistants
+	Voice bool `json:"voice,omitempty"`
+	// Async runs the search as a background job and answers 202 with its ID
+	Async bool `json:"async,omitempty"`
+	// CallbackURL, which implies Async, is POSTed the finished job
+	CallbackURL string `json:"callback_url,omitempty"`
+	// CallbackSecret, when set, signs the callback
+	CallbackSecret string `json:"callback_secret,omitempty"`
+	// CallbackAttachments adds the transcript and the matched clip to the
+	// callback
+	CallbackAttachments store.WebhookAttachments `json:"callback_attachments,omitempty"`
+	// CommunityHints falls back to timestamps in the video's comments when
+	// the transcript has no match
+	CommunityHints bool `json:"community_hints,omitempty"`
+	// Previews renders a "thumbnail" or "gif" at each match
+	Previews string `json:"previews,omitempty"`
+	// Chapter limits the search, and any transcription, to the chapter with
+	// this title, matched ignoring case; a unique part of it will do.
+	// ChapterIndex picks the chapter by position instead, from 1.
+	Chapter      string `json:"chapter,omitempty"`
+	ChapterIndex int    `json:"chapter_index,omitempty"`
+	// Translate looks for the keyword translated into each searched
+	// language, so an English keyword finds Arabic captions. KeywordLanguage
+	// is the keyword's own language, when known.
+	Translate       bool   `json:"translate,omitempty"`
+	KeywordLanguage string `json:"keyword_language,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code, when set, is one of the Code* constants
+	Code string `json:"code,omitempty"`
+}
+
+// searchHandler answers POST /api/search. Clients that send
+// Accept: application/ssml+xml get the voice answer's SSML alone.
+func (app *App) searchHandler(c *web.Context) {
+
+	var req SearchRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	app.runSearchRequest(c, req)
+}
+
+// searchQueryHandler answers GET /api/search, which takes the POST body's
+// fields as query parameters (?video_url=...&keyword=...&language=...), so
+// a search fits in a link. Lists such as languages are comma separated.
+func (app *App) searchQueryHandler(c *web.Context) {
+	var req SearchRequest
+	if err := bindQuery(c.Request.URL.Query(), &req); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	app.runSearchRequest(c, req)
+}
+
+// runSearchRequest validates and answers a search however it arrived.
+func (app *App) runSearchRequest(c *web.Context, req SearchRequest) {
+	if req.VideoURL == "" || req.Keyword == "" {
+		c.JSON(400, ErrorResponse{Error: "videourl and keyword are required"})
+		return
+	}
+	if _, err := search.ParseMatchMode(req.MatchMode); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if _, err := search.ParseOrder(req.Order, req.Hint); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if _, err := req.PageOffset(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validPreviews(req.Previews); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if w := req.Window; w != nil && (w.Start < 0 || w.End <= w.Start) {
+		c.JSON(400, ErrorResponse{Error: "window needs 0 <= start < end"})
+		return
+	}
+	if req.Chapter != "" || req.ChapterIndex != 0 {
+		if len(req.Languages) > 0 {
+			c.JSON(400, ErrorResponse{Error: "chapter can't be combined with languages"})
+			return
+		}
+		if _, ok := app.chapterWindow(c, &req); !ok {
+			return
+		}
+	}
+	if err := app.downloader.AudioSettings().Merge(req.AudioSettings).Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := req.WhisperOptions.WithDefaults().Validate(); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	req.OpenAIKey = callerKey(c)
+	if req.Async || req.CallbackURL != "" {
+		if req.CallbackURL != "" {
+			if err := checkWebhookURL(c.Request.Context(), req.CallbackURL); err != nil {
+				c.JSON(400, ErrorResponse{Error: "callback_url: " + err.Error()})
+				return
+			}
+		}
+		if err := validAttachments(req.CallbackAttachments); err != nil {
+			c.JSON(400, ErrorResponse{Error: "callback_" + err.Error()})
+			return
+		}
+		jobAccepted(c, app.startSearchJob(req))
+		return
+	}
+	ssml := c.NegotiateFormat(web.MIMEJSON, mimeSSML) == mimeSSML
+	req.Voice = req.Voice || ssml
+	var resp search.Response
+	var err error
+	if app.coordinating() && req.OpenAIKey == "" {
+		resp, err = app.searchViaQueue(c.Request.Context(), req)
+	} else {
+		resp, err = app.Search(c.Request.Context(), req)
+	}
+	if err != nil {
+		var upErr *UpstreamError
+		if errors.As(err, &upErr) {
+			c.JSON(502, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if typedError(c, err) {
+			return
+		}
+		if errors.Is(err, search.ErrNoStrategy) {
+			c.JSON(422, ErrorResponse{Error: err.Error()})
+			return
+		}
+		pipelineError(c, err)
+		return
+	}
+	if ssml {
+		c.Data(200, mimeSSML+"; charset=utf-8", []byte(resp.Voice.SSML))
+		return
+	}
+	if !resp.Found && apiVersion(c) != "" {
+		// the versioned API fails a search that came up empty; the result
+		// still says what was covered
+		c.JSON(404, keywordNotFound{ErrorResponse: ErrorResponse{Error: search.ErrKeywordNotFound.Error(), Code: CodeKeywordNotFound}, Result: resp})
+		return
+	}
+	c.JSON(200, resp)
+}
+
+// keywordNotFound is the versioned API's answer to a search that didn't
+// find the keyword.
+type keywordNotFound struct {
+	ErrorResponse
+	Result search.Response `json:"result"`
+}
+
+const mimeSSML = "application/ssml+xml"
+
+// Search answers a request from the transcript cache, the upstream instance
+// or the local pipeline, in that order. Shared by the HTTP API and the CLI.
+func (app *App) Search(ctx context.Context, req SearchRequest) (search.Response, error) {
+	if mode, _ := search.ParseMatchMode(req.MatchMode); mode == search.MatchSubstring && !req.Stem && app.calibrator.StrictWords() {
+		// feedback says partial-word hits are mostly wrong
+		req.MatchMode = string(search.MatchWord)
+	}
+	if req.Translate && req.Translations == nil {
+		app.translateKeyword(ctx, &req)
+	}
+
+	var resp search.Response
+	if r, ok := app.searchCached(ctx, req); ok {
+		resp = r
+		resp.ServedBy = ServedByCache
+	} else if r, delegated, err := app.trySearchUpstream(ctx, req); delegated {
+		if err != nil {
+			return resp, &UpstreamError{Err: err}
+		}
+		resp = r
+	} else {
+		r, err := app.searchPipeline(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		resp = r
+	}
+
+	if len(req.Translations) > 0 {
+		reportTranslation(req, &resp)
+	}
+	if !resp.Found && req.CommunityHints {
+		resp.CommunityHints = app.communityHints(ctx, req.Request)
+	}
+	if resp.Found && resp.Chapter == "" {
+		search.LabelChapters(&resp, app.videoChapters(req.Request))
+	}
+	app.calibrator.Calibrate(&resp)
+	if req.Previews != "" {
+		app.addPreviews(ctx, req, &resp)
+	}
+	if req.Voice {
+		v := search.NewVoiceAnswer(req.Keyword, resp)
+		resp.Voice = &v
+	}
+	if req.Public {
+		app.publishResult(ctx, req, &resp)
+	}
+	if resp.Found {
+		events.Emit(events.MatchFound, req.VideoURL, map[string]interface{}{
+			"keyword": req.Keyword, "seconds": resp.Seconds, "source": resp.Source,
+		})
+	}
+	return resp, nil
+}
+
+// startSearchJob runs req in the background as a "search" job whose result
+// is the search response. Poll it at GET /api/jobs/:id or wait for its
+// callback; cancelling it stops the download or transcription.
+//
+// With a job backend the search is queued for any replica, unless the
+// caller's OpenAI key pays for it: keys are never written to the backend,
+// so such jobs run here.
+func (app *App) startSearchJob(req SearchRequest) *Job {
+	if app.jobs.Backend() != nil && req.OpenAIKey == "" {
+		job, err := app.enqueueSearchJob(req)
+		if err == nil {
+			return job
+		}
+		log.Printf("failed to queue search job, running it here: %v", err)
+	}
+	job := app.jobs.Create("search")
+	job.SetCallback(req.CallbackURL, req.CallbackSecret)
+	go app.runSearchJob(job, req)
+	return job
+}
+
+// runSearchJob runs the search job until it finishes or is cancelled.
+func (app *App) runSearchJob(job *Job, req SearchRequest) {
+	attach := req.CallbackAttachments
+	if req.CallbackURL == "" {
+		attach = store.WebhookAttachments{}
+	}
+	// the job itself is async; whoever runs the search next must not be
+	req.Async, req.CallbackURL, req.CallbackSecret = false, "", ""
+	req.Progress = func(p transcribe.Progress) {
+		job.Update(func(j *Job) {
+			if j.Items[0].Status == JobRunning {
+				j.Items[0].Transcription = &p
+			}
+		})
+	}
+	job.Update(func(j *Job) {
+		j.Status = JobRunning
+		j.Total = 1
+		j.Items = []JobItem{{VideoURL: req.VideoURL, Status: JobRunning}}
+	})
+	resp, err := app.Search(job.Context(), req)
+	var att *Attachments
+	if err == nil {
+		att = app.buildAttachments(job.Context(), attach, req.VideoURL, &resp)
+	}
+	job.Update(func(j *Job) {
+		if j.Status == JobCancelled {
+			return
+		}
+		j.Items[0].Transcription = nil
+		if err != nil {
+			j.Status, j.Error = JobFailed, err.Error()
+			_, j.ErrorCode = errorCode(err)
+			j.Items[0].Status, j.Items[0].Error = JobFailed, err.Error()
+			j.Failed = 1
+			return
+		}
+		j.Status, j.Result = JobCompleted, resp
+		j.SetAttachments(att)
+		j.Items[0].Status = JobCompleted
+		j.Done = 1
+	})
+}
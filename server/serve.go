@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"searchme/internal/config"
+)
+
+// TLS modes selected by TLS_MODE
+const (
+	TLSModeOff      = "off"      // plain HTTP, e.g. behind a TLS-terminating proxy
+	TLSModeFile     = "file"     // TLS_CERT_FILE / TLS_KEY_FILE
+	TLSModeAutocert = "autocert" // Let's Encrypt for AUTOCERT_DOMAINS
+)
+
+// serve runs the HTTP server on the configured port according to TLS_MODE.
+// With no TLS_MODE set it keeps the old behavior of serving cert.pem/key.pem,
+// but falls back to plain HTTP instead of crashing when those files don't
+// exist.
+func serve(cfg config.Config, handler http.Handler) error {
+	addr := ":" + cfg.Port
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+
+	mode := strings.ToLower(strings.TrimSpace(cfg.TLSMode))
+	if mode == "" {
+		mode = TLSModeFile
+		if !fileExists(certFile) || !fileExists(keyFile) {
+			log.Printf("No TLS certificate at %s, serving plain HTTP (set TLS_MODE to configure)", certFile)
+			mode = TLSModeOff
+		}
+	}
+
+	srv := &http.Server{Addr: addr, Handler: handler}
+	switch mode {
+	case TLSModeOff:
+		log.Printf("Serving HTTP on %s", addr)
+		return srv.ListenAndServe()
+	case TLSModeFile:
+		log.Printf("Serving HTTPS on %s with %s", addr, certFile)
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	case TLSModeAutocert:
+		domains := cfg.AutocertDomains
+		if len(domains) == 0 {
+			return fmt.Errorf("TLS_MODE=autocert requires AUTOCERT_DOMAINS")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		// HTTP-01 challenges must be answered on port 80
+		challengeAddr := cfg.AutocertHTTPAddr
+		go func() {
+			if err := http.ListenAndServe(challengeAddr, m.HTTPHandler(nil)); err != nil {
+				log.Printf("ACME challenge listener on %s stopped: %v", challengeAddr, err)
+			}
+		}()
+		srv.TLSConfig = m.TLSConfig()
+		log.Printf("Serving HTTPS on %s with Let's Encrypt certificates for %s", addr, strings.Join(domains, ", "))
+		return srv.ListenAndServeTLS("", "")
+	default:
+		return fmt.Errorf("unknown TLS_MODE %q (want off, file or autocert)", mode)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
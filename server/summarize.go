@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/env"
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+)
+
+// SummarizeRequest names the video to summarize; of the search fields only
+// video_url, language and the download options are used.
+type SummarizeRequest struct {
+	search.Request
+	// MaxTopics caps the key topics returned; default 8
+	MaxTopics int `json:"max_topics,omitempty"`
+}
+
+// SummaryTopic is a key topic of the video and where it starts.
+type SummaryTopic struct {
+	Title   string  `json:"title"`
+	Summary string  `json:"summary,omitempty"`
+	Seconds float64 `json:"seconds"`
+	Time    string  `json:"time"`
+	URL     string  `json:"url,omitempty"`
+}
+
+type SummarizeResponse struct {
+	VideoID  string         `json:"video_id"`
+	Source   string         `json:"source"`
+	Language string         `json:"language,omitempty"`
+	Model    string         `json:"model"`
+	Summary  string         `json:"summary"`
+	Topics   []SummaryTopic `json:"topics"`
+}
+
+// summaryModel is the chat model summaries are written with, SUMMARY_MODEL.
+func summaryModel() string {
+	if m := os.Getenv("SUMMARY_MODEL"); m != "" {
+		return m
+	}
+	return openai.GPT4oMini
+}
+
+// videoTranscript returns the video's transcript from the index when it
+// holds a complete one in the language asked for, and otherwise loads it
+// like a ranked search, which indexes it for next time.
+func (app *App) videoTranscript(ctx context.Context, req search.Request) ([]subtitle.Entry, string, string, error) {
+	if app.store != nil {
+		rec, found, err := app.store.GetTranscript(ctx, store.VideoKey(req.VideoURL))
+		if err != nil {
+			log.Printf("transcript lookup failed: %v", err)
+		}
+		lang := search.NormalizeLang(req.Language)
+		if found && !rec.Partial() && len(rec.Segments) > 0 && (req.Language == "" || strings.EqualFold(lang, rec.Language)) {
+			return rec.Segments, rec.Source, rec.Language, nil
+		}
+	}
+	return app.pipeline.LoadSegments(ctx, req)
+}
+
+// summarizeHandler answers POST /api/summarize with a summary of the video
+// and its key topics, each with the time it starts. The transcript comes
+// from the index when possible, so summarizing an indexed video costs no
+// transcription. Long transcripts are summarized part by part, then the
+// parts' summaries are combined.
+func (app *App) summarizeHandler(c *web.Context) {
+	var req SummarizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url is required"})
+		return
+	}
+	if req.MaxTopics <= 0 {
+		req.MaxTopics = 8
+	}
+	req.OpenAIKey = callerKey(c)
+	req.Window = nil
+	ctx := c.Request.Context()
+	segments, source, lang, err := app.videoTranscript(ctx, req.Request)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	if len(segments) == 0 {
+		c.JSON(422, ErrorResponse{Error: "the transcript is empty"})
+		return
+	}
+
+	ctx = oai.WithKey(ctx, req.OpenAIKey)
+	client, err := oai.ClientFor(ctx)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+	summary, topics, err := summarize(ctx, client, segments, req.MaxTopics)
+	if err != nil {
+		c.JSON(502, ErrorResponse{Error: fmt.Sprintf("summarization failed: %v", err)})
+		return
+	}
+	resp := SummarizeResponse{
+		VideoID:  store.VideoKey(req.VideoURL),
+		Source:   source,
+		Language: lang,
+		Model:    summaryModel(),
+		Summary:  summary,
+		Topics:   []SummaryTopic{},
+	}
+	for _, t := range topics {
+		t.Time = search.FormatTime(t.Seconds)
+		t.URL = media.DeepLink(req.VideoURL, t.Seconds)
+		resp.Topics = append(resp.Topics, t)
+	}
+	c.JSON(200, resp)
+}
+
+// summaryPart is what the model returns for a part of the transcript.
+type summaryPart struct {
+	Summary string         `json:"summary"`
+	Topics  []SummaryTopic `json:"topics"`
+}
+
+// summarize writes the summary and up to maxTopics topics. Transcripts over
+// SUMMARY_PART_CHARS (default 24000) characters are split into parts
+// summarized on their own first.
+func summarize(ctx context.Context, client *openai.Client, segments []subtitle.Entry, maxTopics int) (string, []SummaryTopic, error) {
+	limit := env.Int("SUMMARY_PART_CHARS", 24000)
+	var parts []string
+	var b strings.Builder
+	for _, s := range segments {
+		line := fmt.Sprintf("[%.0f] %s\n", s.Start, strings.TrimSpace(s.Text))
+		if b.Len() > 0 && b.Len()+len(line) > limit {
+			parts = append(parts, b.String())
+			b.Reset()
+		}
+		b.WriteString(line)
+	}
+	parts = append(parts, b.String())
+
+	instructions := fmt.Sprintf(`Summarize this video transcript. Lines start with the time in seconds. `+
+		`Reply with a JSON object {"summary": string, "topics": [{"title": string, "summary": string, "seconds": number}]} `+
+		`with a summary of a few sentences and up to %d key topics in time order, where seconds is when the topic starts.`, maxTopics)
+	if len(parts) == 1 {
+		var out summaryPart
+		if err := chatJSON(ctx, client, summaryModel(), instructions, parts[0], &out); err != nil {
+			return "", nil, err
+		}
+		return out.Summary, capTopics(out.Topics, maxTopics), nil
+	}
+
+	var combined strings.Builder
+	var topics []SummaryTopic
+	for i, part := range parts {
+		var out summaryPart
+		if err := chatJSON(ctx, client, summaryModel(), instructions, part, &out); err != nil {
+			return "", nil, fmt.Errorf("part %d of %d: %w", i+1, len(parts), err)
+		}
+		fmt.Fprintf(&combined, "Part %d: %s\n", i+1, out.Summary)
+		topics = append(topics, out.Topics...)
+	}
+	var out summaryPart
+	err := chatJSON(ctx, client, summaryModel(),
+		`These are summaries of consecutive parts of one video. Reply with a JSON object {"summary": string} `+
+			`summarizing the whole video in a few sentences.`,
+		combined.String(), &out)
+	if err != nil {
+		return "", nil, err
+	}
+	return out.Summary, capTopics(topics, maxTopics), nil
+}
+
+// capTopics keeps max topics, spread over the video, in time order.
+func capTopics(topics []SummaryTopic, max int) []SummaryTopic {
+	sort.SliceStable(topics, func(i, j int) bool { return topics[i].Seconds < topics[j].Seconds })
+	if len(topics) <= max {
+		return topics
+	}
+	out := make([]SummaryTopic, 0, max)
+	for i := 0; i < max; i++ {
+		out = append(out, topics[i*len(topics)/max])
+	}
+	return out
+}
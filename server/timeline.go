@@ -0,0 +1,207 @@
+package server
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/subtitle"
+)
+
+// defaultEventWords cover common sports and esports call-outs.
+var defaultEventWords = []string{
+	"goal", "score", "penalty", "red card", "yellow card", "free kick", "save",
+	"touchdown", "home run", "wicket", "knockout", "ace", "break point", "match point",
+	"kill", "double kill", "triple kill", "headshot", "clutch", "first blood",
+}
+
+type TimelineRequest struct {
+	VideoURL string `json:"video_url"`
+	Language string `json:"language,omitempty"`
+	// Events replaces the default event word list
+	Events []string `json:"events,omitempty"`
+	// BucketSeconds is the histogram resolution, default 60
+	BucketSeconds int `json:"bucket_seconds,omitempty"`
+	// Highlights is how many highlight candidates to return, default 5
+	Highlights int `json:"highlights,omitempty"`
+	media.DownloadOptions
+}
+
+type TimelineOccurrence struct {
+	Event   string  `json:"event"`
+	Seconds float64 `json:"seconds"`
+	Time    string  `json:"time"`
+	URL     string  `json:"url,omitempty"`
+	Text    string  `json:"text"`
+}
+
+type TimelineBucket struct {
+	Start  float64        `json:"start"`
+	End    float64        `json:"end"`
+	Time   string         `json:"time"`
+	Counts map[string]int `json:"counts"`
+	Total  int            `json:"total"`
+}
+
+type TimelineResponse struct {
+	Source      string               `json:"source"`
+	Language    string               `json:"language,omitempty"`
+	Totals      map[string]int       `json:"totals"`
+	Occurrences []TimelineOccurrence `json:"occurrences"`
+	Buckets     []TimelineBucket     `json:"buckets"`
+	Highlights  []TimelineBucket     `json:"highlights"`
+}
+
+// timelineHandler plots every occurrence of the event words over time, bucketed
+// (per minute by default), and suggests the busiest buckets as highlight candidates.
+func (app *App) timelineHandler(c *web.Context) {
+	var req TimelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "Invalid JSON request"})
+		return
+	}
+	if req.VideoURL == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url is required"})
+		return
+	}
+
+	subs, source, usedLang, err := app.pipeline.LoadSegments(c.Request.Context(), search.Request{
+		VideoURL:        req.VideoURL,
+		Language:        req.Language,
+		DownloadOptions: req.DownloadOptions,
+		OpenAIKey:       callerKey(c),
+	})
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+
+	resp := BuildTimeline(subs, req.Events, req.BucketSeconds, req.Highlights)
+	resp.Source = source
+	resp.Language = usedLang
+	for i := range resp.Occurrences {
+		resp.Occurrences[i].URL = media.DeepLink(req.VideoURL, resp.Occurrences[i].Seconds)
+	}
+	c.JSON(200, resp)
+}
+
+// BuildTimeline counts event words per segment and per bucket.
+func BuildTimeline(subs []subtitle.Entry, events []string, bucketSeconds, highlights int) TimelineResponse {
+	if len(events) == 0 {
+		events = defaultEventWords
+	}
+	if bucketSeconds <= 0 {
+		bucketSeconds = 60
+	}
+	if highlights <= 0 {
+		highlights = 5
+	}
+
+	var words []string
+	seen := map[string]bool{}
+	for _, e := range events {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != "" && !seen[e] {
+			seen[e] = true
+			words = append(words, e)
+		}
+	}
+
+	resp := TimelineResponse{Totals: map[string]int{}}
+	buckets := map[int]*TimelineBucket{}
+	maxBucket := -1
+	for _, sub := range subs {
+		text := " " + strings.ToLower(sub.Text) + " "
+		for _, w := range words {
+			n := countWordOccurrences(text, w)
+			if n == 0 {
+				continue
+			}
+			resp.Totals[w] += n
+			resp.Occurrences = append(resp.Occurrences, TimelineOccurrence{
+				Event:   w,
+				Seconds: sub.Start,
+				Time:    search.FormatTime(sub.Start),
+				Text:    strings.TrimSpace(sub.Text),
+			})
+
+			idx := int(sub.Start) / bucketSeconds
+			b := buckets[idx]
+			if b == nil {
+				start := float64(idx * bucketSeconds)
+				b = &TimelineBucket{Start: start, End: start + float64(bucketSeconds), Time: search.FormatTime(start), Counts: map[string]int{}}
+				buckets[idx] = b
+			}
+			b.Counts[w] += n
+			b.Total += n
+			if idx > maxBucket {
+				maxBucket = idx
+			}
+		}
+	}
+
+	// dense histogram so clients can plot it directly
+	resp.Buckets = make([]TimelineBucket, 0, maxBucket+1)
+	for i := 0; i <= maxBucket; i++ {
+		if b := buckets[i]; b != nil {
+			resp.Buckets = append(resp.Buckets, *b)
+			continue
+		}
+		start := float64(i * bucketSeconds)
+		resp.Buckets = append(resp.Buckets, TimelineBucket{Start: start, End: start + float64(bucketSeconds), Time: search.FormatTime(start), Counts: map[string]int{}})
+	}
+	resp.Highlights = highlightBuckets(resp.Buckets, highlights)
+	return resp
+}
+
+// highlightBuckets returns up to n buckets noticeably busier than average
+// (at least mean + one standard deviation), busiest first.
+func highlightBuckets(buckets []TimelineBucket, n int) []TimelineBucket {
+	if len(buckets) == 0 {
+		return nil
+	}
+	var sum, sumSq float64
+	for _, b := range buckets {
+		sum += float64(b.Total)
+		sumSq += float64(b.Total * b.Total)
+	}
+	mean := sum / float64(len(buckets))
+	std := math.Sqrt(math.Max(sumSq/float64(len(buckets))-mean*mean, 0))
+
+	var out []TimelineBucket
+	for _, b := range buckets {
+		if b.Total > 0 && float64(b.Total) >= mean+std {
+			out = append(out, b)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Total > out[j].Total })
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// countWordOccurrences counts whole-word occurrences of w in a lowercased,
+// space-padded text, so "ace" does not match "place".
+func countWordOccurrences(text, w string) int {
+	count := 0
+	for i := 0; ; {
+		j := strings.Index(text[i:], w)
+		if j < 0 {
+			return count
+		}
+		start := i + j
+		end := start + len(w)
+		if !isWordByte(text[start-1]) && (end >= len(text) || !isWordByte(text[end])) {
+			count++
+		}
+		i = start + 1
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '_' || b >= 0x80
+}
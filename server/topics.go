@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/oai"
+	"searchme/internal/web"
+	"searchme/media"
+	"searchme/search"
+	"searchme/store"
+	"searchme/subtitle"
+)
+
+// Topic extraction methods
+const (
+	TopicsLocal = "local"
+	TopicsLLM   = "llm"
+)
+
+// TopicsRequest asks for a video's key topics. Limit is the number of
+// topics, default 10; of the other search fields only video_url, language
+// and the download options are used.
+type TopicsRequest struct {
+	search.Request
+	// Method is TopicsLocal (default), TF-IDF keyphrases computed here, or
+	// TopicsLLM, keyphrases picked by SUMMARY_MODEL
+	Method string `json:"method,omitempty"`
+}
+
+// TopicRange is one stretch of the video a topic is discussed in.
+type TopicRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Time  string  `json:"time"`
+	URL   string  `json:"url,omitempty"`
+}
+
+type TopicResult struct {
+	Phrase string       `json:"phrase"`
+	Score  float64      `json:"score,omitempty"`
+	Count  int          `json:"count"`
+	Ranges []TopicRange `json:"ranges"`
+}
+
+type TopicsResponse struct {
+	VideoID  string        `json:"video_id"`
+	Method   string        `json:"method"`
+	Source   string        `json:"source"`
+	Language string        `json:"language,omitempty"`
+	Topics   []TopicResult `json:"topics"`
+}
+
+// topicsHandler answers GET /api/topics?video_url=... with the video's key
+// topics, each with the time ranges it is discussed in, as a starting point
+// for chaptering. The transcript comes from the index when possible.
+func (app *App) topicsHandler(c *web.Context) {
+	var req TopicsRequest
+	if err := bindQuery(c.Request.URL.Query(), &req); err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.VideoURL == "" {
+		c.JSON(400, ErrorResponse{Error: "video_url is required"})
+		return
+	}
+	if req.Method == "" {
+		req.Method = TopicsLocal
+	}
+	if req.Method != TopicsLocal && req.Method != TopicsLLM {
+		c.JSON(400, ErrorResponse{Error: fmt.Sprintf("unknown method %q (want local or llm)", req.Method)})
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+	req.Limit = min(req.Limit, 50)
+	req.OpenAIKey = callerKey(c)
+	req.Window = nil
+	ctx := c.Request.Context()
+	segments, source, lang, err := app.videoTranscript(ctx, req.Request)
+	if err != nil {
+		pipelineError(c, err)
+		return
+	}
+
+	var topics []search.Topic
+	if req.Method == TopicsLLM {
+		ctx = oai.WithKey(ctx, req.OpenAIKey)
+		client, err := oai.ClientFor(ctx)
+		if err != nil {
+			pipelineError(c, err)
+			return
+		}
+		topics, err = llmTopics(ctx, client, segments, lang, req.Limit)
+		if err != nil {
+			c.JSON(502, ErrorResponse{Error: fmt.Sprintf("topic extraction failed: %v", err)})
+			return
+		}
+	} else {
+		topics = search.ExtractTopics(segments, lang, req.Limit)
+	}
+
+	resp := TopicsResponse{VideoID: store.VideoKey(req.VideoURL), Method: req.Method, Source: source, Language: lang, Topics: []TopicResult{}}
+	for _, t := range topics {
+		out := TopicResult{Phrase: t.Phrase, Score: t.Score, Count: t.Count}
+		for _, r := range t.Ranges {
+			out.Ranges = append(out.Ranges, TopicRange{
+				Start: r.Start, End: r.End,
+				Time: search.FormatTime(r.Start),
+				URL:  media.DeepLink(req.VideoURL, r.Start),
+			})
+		}
+		resp.Topics = append(resp.Topics, out)
+	}
+	c.JSON(200, resp)
+}
+
+// llmTopics has the model name the transcript's key topics as phrases said
+// in it, then finds where each is said. Phrases it invented that the
+// transcript never says are dropped.
+func llmTopics(ctx context.Context, client *openai.Client, segments []subtitle.Entry, lang string, limit int) ([]search.Topic, error) {
+	var text strings.Builder
+	for _, s := range segments {
+		text.WriteString(strings.TrimSpace(s.Text))
+		text.WriteByte('\n')
+		if text.Len() > 48000 {
+			break
+		}
+	}
+	var out struct {
+		Topics []string `json:"topics"`
+	}
+	err := chatJSON(ctx, client, summaryModel(),
+		fmt.Sprintf(`List the %d most important topics of this video transcript, most important first. `+
+			`Each topic must be a short keyphrase of one to three words that appears verbatim in the transcript. `+
+			`Reply with a JSON object {"topics": [string]}.`, limit),
+		text.String(), &out)
+	if err != nil {
+		return nil, err
+	}
+	var topics []search.Topic
+	for _, phrase := range out.Topics {
+		m := search.NewMatcherWithOptions(lang, phrase, search.MatchOptions{Mode: search.MatchPhrase, Stem: true})
+		var spans []search.TimeRange
+		for _, h := range m.Hits(segments) {
+			spans = append(spans, search.TimeRange{Start: h.Start, End: h.End})
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		topics = append(topics, search.Topic{Phrase: phrase, Count: len(spans), Ranges: search.TopicRanges(spans)})
+		if len(topics) == limit {
+			break
+		}
+	}
+	return topics, nil
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/oai"
+	"searchme/search"
+)
+
+// translateModel is the chat model keywords are translated with,
+// TRANSLATE_MODEL.
+func translateModel() string {
+	if m := os.Getenv("TRANSLATE_MODEL"); m != "" {
+		return m
+	}
+	return openai.GPT4oMini
+}
+
+// maxTranslations bounds the keyword translations kept in memory; past it
+// the cache is dropped and rebuilt as keywords come back.
+const maxTranslations = 1024
+
+var (
+	translateMu    sync.Mutex
+	translateCache = map[string]string{}
+)
+
+// translateKeyword fills req.Translations with the keyword in each language
+// the request searches: Language, or every code in Languages ("all" can't be
+// known beforehand and is searched untranslated). Languages the keyword is
+// already in are left out. A failed translation is logged and that language
+// searched with the keyword as given.
+func (app *App) translateKeyword(ctx context.Context, req *SearchRequest) {
+	targets := req.Languages
+	if len(targets) == 0 {
+		targets = []string{req.Language}
+	}
+	from := strings.ToLower(strings.TrimSpace(req.KeywordLanguage))
+	var want []string
+	for _, l := range targets {
+		l = search.NormalizeLang(l)
+		if l == search.AllLanguages || l == from {
+			continue
+		}
+		if t, ok := cachedTranslation(req.Keyword, l); ok {
+			req.setTranslation(l, t)
+			continue
+		}
+		want = append(want, l)
+	}
+	if len(want) == 0 {
+		return
+	}
+
+	ctx = oai.WithKey(ctx, req.OpenAIKey)
+	client, err := oai.ClientFor(ctx)
+	if err != nil {
+		log.Printf("keyword translation unavailable: %v", err)
+		return
+	}
+	var out map[string]string
+	hint := ""
+	if from != "" {
+		hint = " from " + from
+	}
+	err = chatJSON(ctx, client, translateModel(),
+		`Translate a search keyword`+hint+` into each language requested by its ISO 639-1 code, `+
+			`as the word or phrase a speaker of that language would say, the way it would appear in subtitles. `+
+			`Reply with a JSON object mapping each language code to the translation; `+
+			`if the keyword is already in that language, map it to the keyword unchanged.`,
+		"Keyword: "+req.Keyword+"\nLanguages: "+strings.Join(want, ", "), &out)
+	if err != nil {
+		log.Printf("failed to translate %q: %v", req.Keyword, err)
+		return
+	}
+	translateMu.Lock()
+	defer translateMu.Unlock()
+	if len(translateCache)+len(want) > maxTranslations {
+		translateCache = map[string]string{}
+	}
+	for _, l := range want {
+		t := strings.TrimSpace(out[l])
+		if t == "" {
+			continue
+		}
+		translateCache[l+"\x00"+req.Keyword] = t
+		req.setTranslation(l, t)
+	}
+}
+
+func cachedTranslation(keyword, lang string) (string, bool) {
+	translateMu.Lock()
+	defer translateMu.Unlock()
+	t, ok := translateCache[lang+"\x00"+keyword]
+	return t, ok
+}
+
+// setTranslation records t as the keyword for lang, unless it is the keyword
+// itself.
+func (req *SearchRequest) setTranslation(lang, t string) {
+	if strings.EqualFold(t, req.Keyword) {
+		return
+	}
+	if req.Translations == nil {
+		req.Translations = map[string]string{}
+	}
+	req.Translations[lang] = t
+}
+
+// reportTranslation notes on resp which translated keyword each answer
+// matched.
+func reportTranslation(req SearchRequest, resp *search.Response) {
+	if kw := req.KeywordFor(resp.Language); kw != req.Keyword {
+		resp.Keyword, resp.TranslatedKeyword = req.Keyword, kw
+	}
+}
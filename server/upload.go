@@ -0,0 +1,316 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"searchme/internal/web"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/search"
+	"searchme/subtitle"
+	"searchme/transcribe"
+)
+
+// maxTranscriptUploadBytes caps uploaded caption/transcript files.
+const maxTranscriptUploadBytes = 20 << 20
+
+// uploadSearchHandler searches an uploaded SRT, VTT, ASS/SSA, TTML/DFXP or Whisper JSON file
+// (multipart field "file") for "keyword" without downloading anything.
+func (app *App) uploadSearchHandler(c *web.Context) {
+	if !limitUpload(c, maxTranscriptUploadBytes) {
+		return
+	}
+	keyword := c.PostForm("keyword")
+	if strings.TrimSpace(keyword) == "" {
+		c.JSON(400, ErrorResponse{Error: "file and keyword are required"})
+		return
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "file and keyword are required"})
+		return
+	}
+	if fh.Size > maxTranscriptUploadBytes {
+		c.JSON(413, ErrorResponse{Error: fmt.Sprintf("file exceeds %d MB limit", maxTranscriptUploadBytes>>20)})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "failed to read uploaded file"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxTranscriptUploadBytes))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "failed to read uploaded file"})
+		return
+	}
+
+	mode, err := search.ParseMatchMode(c.PostForm("match"))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	match, found, err := app.searchUploadedTranscript(fh.Filename, data, search.NewMatcherWithOptions(c.PostForm("language"), keyword, matchOptions(c, mode)))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(200, search.NewResponse("", match, found, c.PostForm("language")))
+}
+
+// searchUploadedTranscript detects the format from the extension (falling back
+// to sniffing the content) and returns the first match.
+func (app *App) searchUploadedTranscript(filename string, data []byte, matcher *search.Matcher) (search.Match, bool, error) {
+	format, ok := subtitle.FormatForExt(filepath.Ext(filename))
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		format, ok = "json", true
+	}
+	if !ok {
+		trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+		if sniffed, sniffOK := subtitle.Sniff(trimmed); sniffOK {
+			format = sniffed
+		} else if bytes.HasPrefix(trimmed, []byte("{")) {
+			format = "json"
+		} else {
+			format = subtitle.FormatSRT
+		}
+	}
+
+	if format == "json" {
+		m, ok, err := search.InTranscriptJSON(data, matcher)
+		if err != nil {
+			return search.Match{}, false, fmt.Errorf("failed to parse JSON transcript: %w", err)
+		}
+		if ok && !m.Estimated {
+			m.Source = search.SourceUploadedTranscript
+		}
+		return m, ok, nil
+	}
+
+	subs, err := subtitle.Parse(data, format)
+	if err != nil {
+		return search.Match{}, false, fmt.Errorf("failed to parse %s subtitles: %w", strings.ToUpper(format), err)
+	}
+
+	if sub, ok := search.FindInSubtitles(subs, matcher); ok {
+		m := search.Match{Start: sub.Start, End: sub.End, Text: sub.Text, Source: search.SourceUploadedSubtitles}
+		m.Previous, m.Next = search.SegmentNeighbors(subs, sub)
+		return m, true, nil
+	}
+	return search.Match{}, false, nil
+}
+
+// matchOptions combines the validated "match" form field with "stem".
+func matchOptions(c *web.Context, mode search.MatchMode) search.MatchOptions {
+	stem, _ := strconv.ParseBool(c.PostForm("stem"))
+	return search.MatchOptions{Mode: mode, Stem: stem}
+}
+
+// maxUploadBytes is the upload size limit, MAX_UPLOAD_MB (default 500).
+func maxUploadBytes() int64 {
+	if mb, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_MB"), 10, 64); err == nil && mb > 0 {
+		return mb << 20
+	}
+	return 500 << 20
+}
+
+// formAudioSettings reads the chunk_seconds, sample_rate, channels and
+// bitrate_kbps form fields over the server's audio settings.
+func (app *App) formAudioSettings(c *web.Context) (media.AudioSettings, error) {
+	var override media.AudioSettings
+	for field, dst := range map[string]*int{
+		"chunk_seconds": &override.ChunkSeconds,
+		"sample_rate":   &override.SampleRate,
+		"channels":      &override.Channels,
+		"bitrate_kbps":  &override.BitrateKbps,
+	} {
+		v := strings.TrimSpace(c.PostForm(field))
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return media.AudioSettings{}, fmt.Errorf("%s must be an integer", field)
+		}
+		*dst = n
+	}
+	settings := app.downloader.AudioSettings().Merge(override)
+	return settings, settings.Validate()
+}
+
+// formWhisperOptions reads the whisper_model, whisper_language,
+// whisper_temperature and whisper_prompt form fields, the overrides of the
+// server's transcription options. Without whisper_language, the language
+// field is Whisper's hint.
+func formWhisperOptions(c *web.Context) (transcribe.WhisperOptions, error) {
+	override := transcribe.WhisperOptions{
+		Model:    strings.TrimSpace(c.PostForm("whisper_model")),
+		Language: strings.TrimSpace(c.PostForm("whisper_language")),
+		Prompt:   c.PostForm("whisper_prompt"),
+	}
+	if override.Language == "" {
+		override.Language = transcribe.LanguageHint(c.PostForm("language"))
+	}
+	if v := strings.TrimSpace(c.PostForm("whisper_temperature")); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return override, fmt.Errorf("whisper_temperature must be a number")
+		}
+		override.Temperature = t
+	}
+	return override, override.WithDefaults().Validate()
+}
+
+// uploadFailed answers a saveUpload error: 503 while the work directory is
+// full, else 400.
+func uploadFailed(c *web.Context, err error) {
+	if errors.Is(err, workfile.ErrQuotaExceeded) {
+		pipelineError(c, err)
+		return
+	}
+	c.JSON(400, ErrorResponse{Error: err.Error()})
+}
+
+// uploadFormSlack is room for the multipart framing and the form fields next
+// to the file in limitUpload's body limit.
+const uploadFormSlack = 1 << 20
+
+// limitUpload caps the request body at limit bytes (plus uploadFormSlack) and
+// parses the form, answering 413 when the body is larger and 400 when it is
+// not a valid form. It must run before any PostForm or FormFile, since the
+// first of those reads the whole body, spooling files to disk.
+func limitUpload(c *web.Context, limit int64) bool {
+	if c.Request.ContentLength > limit+uploadFormSlack {
+		c.JSON(413, ErrorResponse{Error: fmt.Sprintf("upload exceeds %d MB limit", limit>>20)})
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+uploadFormSlack)
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, ErrorResponse{Error: fmt.Sprintf("upload exceeds %d MB limit", limit>>20)})
+		} else {
+			c.JSON(400, ErrorResponse{Error: fmt.Sprintf("invalid upload: %v", err)})
+		}
+		return false
+	}
+	return true
+}
+
+// saveUpload stores a multipart file in a temp file, enforcing the upload
+// size limit; the body must already be limited by limitUpload.
+func saveUpload(c *web.Context, field string) (*media.AudioFile, error) {
+	fh, err := c.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload: %w", err)
+	}
+	if fh.Size > maxUploadBytes() {
+		return nil, fmt.Errorf("upload exceeds %d MB limit", maxUploadBytes()>>20)
+	}
+	in, err := fh.Open()
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload: %w", err)
+	}
+	defer in.Close()
+
+	if err := workfile.CheckQuota(); err != nil {
+		return nil, err
+	}
+	out, err := os.CreateTemp(workfile.Dir(), "upload_*"+strings.ToLower(filepath.Ext(fh.Filename)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	workfile.Track(out.Name())
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(out.Name())
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(out.Name())
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	return &media.AudioFile{Path: out.Name(), Temp: true}, nil
+}
+
+// mediaSearchHandler transcribes an uploaded audio/video file (multipart "file")
+// with the chunked Whisper pipeline and returns every segment containing "keyword".
+// The audio settings form fields (chunk_seconds, sample_rate, channels,
+// bitrate_kbps) and the Whisper ones (see formWhisperOptions) override the
+// server defaults; confirm_cost accepts a cost estimate over budget.
+func (app *App) mediaSearchHandler(c *web.Context) {
+	if !limitUpload(c, maxUploadBytes()) {
+		return
+	}
+	keyword := strings.TrimSpace(c.PostForm("keyword"))
+	if keyword == "" {
+		c.JSON(400, ErrorResponse{Error: "file and keyword are required"})
+		return
+	}
+	mode, err := search.ParseMatchMode(c.PostForm("match"))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	settings, err := app.formAudioSettings(c)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	whisper, err := formWhisperOptions(c)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: err.Error()})
+		return
+	}
+	audio, err := saveUpload(c, "file")
+	if err != nil {
+		uploadFailed(c, err)
+		return
+	}
+	defer audio.Remove()
+	audio.Settings = settings
+	if !app.approveAudio(c, audio, formBool(c, "confirm_cost", false)) {
+		return
+	}
+
+	release, err := app.limiter.AcquireTranscription(c.Request.Context())
+	if err != nil {
+		c.JSON(503, ErrorResponse{Error: err.Error()})
+		return
+	}
+	transcript, err := transcribe.Audio(transcribe.WithOptions(callerContext(c), whisper), audio, nil)
+	release()
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: fmt.Sprintf("failed to transcribe upload: %v", err)})
+		return
+	}
+
+	matcher := search.NewMatcherWithOptions(transcript.Language, keyword, matchOptions(c, mode))
+	resp := MediaSearchResponse{Duration: transcript.Duration, Language: transcript.Language, Matches: []search.Response{}, Gaps: transcript.Gaps}
+	entries := transcribe.Entries(transcript)
+	for _, h := range matcher.Hits(entries) {
+		m := search.Match{Start: h.Start, End: h.End, Text: h.Text, Source: search.SourceUploadedMedia, Speech: search.EntryConfidence(h.Entry), Partial: !h.Whole}
+		m.Previous, m.Next = search.SegmentNeighbors(entries, h.Entry)
+		resp.Matches = append(resp.Matches, search.NewResponse("", m, true, transcript.Language))
+	}
+	resp.Found = len(resp.Matches) > 0
+	c.JSON(200, resp)
+}
+
+type MediaSearchResponse struct {
+	Found    bool              `json:"found"`
+	Duration float64           `json:"duration"`
+	Language string            `json:"language,omitempty"`
+	Matches  []search.Response `json:"matches"`
+	// Gaps are parts of the upload that could not be transcribed
+	Gaps []transcribe.Gap `json:"gaps,omitempty"`
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"searchme/internal/web"
+)
+
+// APIVersion is the current versioned API, served under /api/v1.
+const APIVersion = "v1"
+
+// Envelope wraps every JSON reply of the versioned API. Exactly one of
+// Data and Error is set. Fields are only ever added to Data in a version;
+// changes that would break its consumers get a new version instead.
+type Envelope struct {
+	Data  json.RawMessage `json:"data"`
+	Error *EnvelopeError  `json:"error"`
+	Meta  EnvelopeMeta    `json:"meta"`
+}
+
+// EnvelopeError describes a failed request. Code is stable for clients to
+// branch on; Message is for people. Details carries the rest of the
+// unversioned error body when it has more than a message, e.g. a cost
+// estimate to confirm.
+type EnvelopeError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// EnvelopeMeta is about the reply rather than its subject.
+type EnvelopeMeta struct {
+	APIVersion string `json:"api_version"`
+	Status     int    `json:"status"`
+}
+
+// errorCodes name the HTTP statuses the API fails with.
+var errorCodes = map[int]string{
+	400: "invalid_request",
+	401: "unauthenticated",
+	402: "cost_confirmation_required",
+	403: "forbidden",
+	404: "not_found",
+	409: "conflict",
+	410: "gone",
+	413: "too_large",
+	422: "unprocessable",
+	429: "rate_limited",
+	500: "internal",
+	502: "upstream_failed",
+	503: "unavailable",
+	504: "timeout",
+}
+
+type apiVersionKey struct{}
+
+// apiVersion is the versioned API the request came in through, or "" for
+// the unversioned one.
+func apiVersion(c *web.Context) string {
+	v, _ := c.Request.Context().Value(apiVersionKey{}).(string)
+	return v
+}
+
+// versionedAPI serves /api/v1/... with the unversioned /api/... route
+// behind it, putting JSON replies and all errors in an Envelope. Other
+// paths, successful replies that aren't JSON such as exports, and event
+// streams, which can't wait for the reply to end, pass through unchanged.
+type versionedAPI struct {
+	next http.Handler
+}
+
+func (v versionedAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, "/api/"+APIVersion+"/")
+	if !ok {
+		v.next.ServeHTTP(w, r)
+		return
+	}
+	inner := r.Clone(context.WithValue(r.Context(), apiVersionKey{}, APIVersion))
+	inner.URL.Path, inner.URL.RawPath = "/api/"+rest, ""
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("API-Version", APIVersion)
+		v.next.ServeHTTP(w, inner)
+		return
+	}
+	rec := &restRecorder{header: http.Header{}, status: http.StatusOK}
+	v.next.ServeHTTP(rec, inner)
+
+	for k, vs := range rec.header {
+		w.Header()[k] = vs
+	}
+	w.Header().Set("API-Version", APIVersion)
+	mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	if (mediaType != "application/json" && rec.status < 400) || rec.status == http.StatusNoContent || rec.status == http.StatusNotModified {
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+		return
+	}
+
+	env := Envelope{Meta: EnvelopeMeta{APIVersion: APIVersion, Status: rec.status}}
+	if rec.status < 400 {
+		env.Data = rec.body.Bytes()
+		if len(env.Data) == 0 {
+			env.Data = json.RawMessage("null")
+		}
+	} else {
+		env.Error = envelopeError(rec.status, rec.body.Bytes())
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		// the handler wrote invalid JSON; hand it over as it is
+		body = rec.body.Bytes()
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(rec.status)
+	_, _ = w.Write(body)
+}
+
+func envelopeError(status int, body []byte) *EnvelopeError {
+	e := &EnvelopeError{Code: errorCodes[status]}
+	if e.Code == "" {
+		e.Code = "error"
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil {
+		_ = json.Unmarshal(fields["error"], &e.Message)
+		// a typed error's code is more telling than its status's
+		if code := fields["code"]; code != nil {
+			_ = json.Unmarshal(code, &e.Code)
+		}
+		delete(fields, "error")
+		delete(fields, "code")
+		if len(fields) > 0 {
+			e.Details, _ = json.Marshal(fields)
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(status)
+	}
+	return e
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"searchme/internal/env"
+	"searchme/internal/web"
+)
+
+// HealthWarmingUp is the status reported until startup warmup finishes.
+const HealthWarmingUp = "warming_up"
+
+// WarmupResponse is the body of requests turned away during warmup.
+type WarmupResponse struct {
+	Error  string `json:"error"`
+	Status string `json:"status"`
+}
+
+// warmup holds API requests that arrive while the server is still verifying
+// its dependencies at startup, so a fresh deploy answers them late rather
+// than failing them. Up to WARMUP_MAX_QUEUE (64) requests wait, each for at
+// most WARMUP_QUEUE_TIMEOUT (60s); the rest get 503 with status warming_up.
+type warmup struct {
+	ready    chan struct{}
+	once     sync.Once
+	maxQueue int
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	waiting int
+}
+
+func newWarmup() *warmup {
+	return &warmup{
+		ready:    make(chan struct{}),
+		maxQueue: env.Int("WARMUP_MAX_QUEUE", 64),
+		timeout:  env.Duration("WARMUP_QUEUE_TIMEOUT", 60*time.Second),
+	}
+}
+
+// Done ends warmup and releases the queued requests.
+func (w *warmup) Done() {
+	w.once.Do(func() { close(w.ready) })
+}
+
+func (w *warmup) Ready() bool {
+	select {
+	case <-w.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// Middleware passes requests straight through once warm, and until then
+// queues them while there's room.
+func (w *warmup) Middleware() web.HandlerFunc {
+	return func(c *web.Context) {
+		if w.Ready() {
+			c.Next()
+			return
+		}
+		w.mu.Lock()
+		if w.waiting >= w.maxQueue {
+			w.mu.Unlock()
+			warmingUp(c, "server is warming up, queue is full")
+			return
+		}
+		w.waiting++
+		w.mu.Unlock()
+		defer func() {
+			w.mu.Lock()
+			w.waiting--
+			w.mu.Unlock()
+		}()
+
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+		select {
+		case <-w.ready:
+			c.Next()
+		case <-timer.C:
+			warmingUp(c, "server is warming up, timed out waiting in queue")
+		case <-c.Request.Context().Done():
+			c.Abort()
+		}
+	}
+}
+
+func warmingUp(c *web.Context, msg string) {
+	c.Header("Retry-After", "5")
+	c.AbortWithStatusJSON(503, WarmupResponse{Error: msg, Status: HealthWarmingUp})
+}
+
+// warm verifies the dependencies before the server takes API requests,
+// giving up after WARMUP_TIMEOUT (2m). A failed check doesn't keep the
+// server cold: /readyz goes on reporting it.
+func (app *App) warm() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), env.Duration("WARMUP_TIMEOUT", 2*time.Minute))
+	defer cancel()
+	health := app.health.Check(ctx)
+	app.warmup.Done()
+	log.Printf("Warmup finished in %s, dependencies %s", time.Since(start).Round(time.Millisecond), health.Status)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"searchme/internal/safehttp"
+)
+
+// Webhook deliveries are signed with the secret their subscriber gave, so
+// receivers can tell them from forgeries:
+// X-Webhook-Signature is "sha256=" and the hex HMAC-SHA256 of
+// "<X-Webhook-Timestamp>.<body>". Receivers should also reject old timestamps.
+const (
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// signWebhook returns the X-Webhook-Signature value for body sent at ts.
+func signWebhook(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkWebhookURL accepts absolute http(s) URLs whose host resolves to
+// public addresses only. Deliveries check again as they connect, since DNS
+// can change in between.
+func checkWebhookURL(ctx context.Context, raw string) error {
+	return safehttp.CheckURL(ctx, raw)
+}
+
+// deliverWebhook POSTs the payload, signed with secret when there is one,
+// retrying a few times on failure. Each attempt is signed afresh.
+func deliverWebhook(target, secret string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	client := safehttp.Client(10 * time.Second)
+	for attempt, backoff := 1, time.Second; attempt <= 3; attempt, backoff = attempt+1, backoff*4 {
+		err := postWebhook(client, target, secret, body)
+		if err == nil {
+			return
+		}
+		log.Printf("webhook %s (attempt %d): %v", target, attempt, err)
+		time.Sleep(backoff)
+	}
+}
+
+func postWebhook(client *http.Client, target, secret string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, ts)
+		req.Header.Set(webhookSignatureHeader, signWebhook(secret, ts, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// CollectionWebhook is a URL notified whenever a new video lands in a collection.
+type CollectionWebhook struct {
+	ID         string `json:"id"`
+	Collection string `json:"collection"`
+	URL        string `json:"url"`
+	// Secret signs deliveries; it is never shown back
+	Secret      string             `json:"-"`
+	Attachments WebhookAttachments `json:"attachments"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// Transcript attachment modes
+const (
+	// AttachInline puts the segments in the delivery
+	AttachInline = "inline"
+	// AttachURL sends a signed, expiring link to the transcript
+	AttachURL = "url"
+)
+
+// WebhookAttachments choose what a webhook delivery carries besides the
+// event itself.
+type WebhookAttachments struct {
+	// Transcript is AttachInline, AttachURL or empty for neither
+	Transcript string `json:"transcript,omitempty"`
+	// Clip adds the matched segment and the transcript around it, for
+	// deliveries about a match
+	Clip bool `json:"clip,omitempty"`
+}
+
+// CollectionStore is implemented by stores that group videos into collections.
+type CollectionStore interface {
+	AssignCollection(ctx context.Context, videoID, collection string) error
+	AddWebhook(ctx context.Context, hook CollectionWebhook) error
+	ListWebhooks(ctx context.Context, collection string) ([]CollectionWebhook, error)
+	DeleteWebhook(ctx context.Context, collection, id string) (bool, error)
+}
+
+func (s *SQLiteStore) AssignCollection(ctx context.Context, videoID, collection string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE videos SET collection = ? WHERE video_id = ?`, collection, videoID)
+	return err
+}
+
+func (s *SQLiteStore) AddWebhook(ctx context.Context, h CollectionWebhook) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO collection_webhooks (id, collection, url, created_at, attach_transcript, attach_clip, secret) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		h.ID, h.Collection, h.URL, h.CreatedAt.Unix(), h.Attachments.Transcript, h.Attachments.Clip, h.Secret)
+	return err
+}
+
+func (s *SQLiteStore) ListWebhooks(ctx context.Context, collection string) ([]CollectionWebhook, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, collection, url, created_at, attach_transcript, attach_clip, secret FROM collection_webhooks WHERE collection = ? ORDER BY created_at`,
+		collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []CollectionWebhook{}
+	for rows.Next() {
+		var h CollectionWebhook
+		var created int64
+		if err := rows.Scan(&h.ID, &h.Collection, &h.URL, &created, &h.Attachments.Transcript, &h.Attachments.Clip, &h.Secret); err != nil {
+			return nil, err
+		}
+		h.CreatedAt = time.Unix(created, 0).UTC()
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteWebhook(ctx context.Context, collection, id string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM collection_webhooks WHERE collection = ? AND id = ?`, collection, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Feedback is a user's verdict on a published result.
+type Feedback struct {
+	ResultID string `json:"result_id"`
+	// Source, Partial and Seconds describe the match the verdict is about
+	Source  string  `json:"source"`
+	Partial bool    `json:"partial_word,omitempty"`
+	Seconds float64 `json:"seconds"`
+	Correct bool    `json:"correct"`
+	// CorrectedSeconds is where the user says the keyword really is
+	CorrectedSeconds *float64  `json:"corrected_seconds,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// FeedbackCount is how many verdicts of one kind were given for one kind of match.
+type FeedbackCount struct {
+	Source  string
+	Partial bool
+	Correct bool
+	Count   int
+}
+
+// FeedbackStore is implemented by stores that keep feedback on results.
+type FeedbackStore interface {
+	SaveFeedback(ctx context.Context, f Feedback) error
+	CountFeedback(ctx context.Context) ([]FeedbackCount, error)
+	// ListFeedback returns the newest feedback first, for one result when
+	// resultID is set.
+	ListFeedback(ctx context.Context, resultID string, limit int) ([]Feedback, error)
+}
+
+func (s *SQLiteStore) SaveFeedback(ctx context.Context, f Feedback) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO feedback (result_id, source, partial, seconds, correct, corrected_seconds, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		f.ResultID, f.Source, f.Partial, f.Seconds, f.Correct, f.CorrectedSeconds, f.CreatedAt.Unix())
+	return err
+}
+
+func (s *SQLiteStore) CountFeedback(ctx context.Context) ([]FeedbackCount, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT source, partial, correct, COUNT(*) FROM feedback GROUP BY source, partial, correct`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counts []FeedbackCount
+	for rows.Next() {
+		var fc FeedbackCount
+		if err := rows.Scan(&fc.Source, &fc.Partial, &fc.Correct, &fc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, fc)
+	}
+	return counts, rows.Err()
+}
+
+func (s *SQLiteStore) ListFeedback(ctx context.Context, resultID string, limit int) ([]Feedback, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT result_id, source, partial, seconds, correct, corrected_seconds, created_at FROM feedback
+		WHERE ? = '' OR result_id = ? ORDER BY id DESC LIMIT ?`,
+		resultID, resultID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Feedback{}
+	for rows.Next() {
+		var f Feedback
+		var corrected sql.NullFloat64
+		var created int64
+		if err := rows.Scan(&f.ResultID, &f.Source, &f.Partial, &f.Seconds, &f.Correct, &corrected, &created); err != nil {
+			return nil, err
+		}
+		if corrected.Valid {
+			f.CorrectedSeconds = &corrected.Float64
+		}
+		f.CreatedAt = time.Unix(created, 0).UTC()
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
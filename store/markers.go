@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CommunityMarker is a moment a viewer pointed at in a comment, e.g.
+// "12:34 best part".
+type CommunityMarker struct {
+	Seconds   float64 `json:"seconds"`
+	Text      string  `json:"text"`
+	Likes     int     `json:"likes"`
+	CommentID string  `json:"comment_id,omitempty"`
+}
+
+// CommunityMarkerStore is implemented by stores that keep the timestamps
+// found in videos' comments.
+type CommunityMarkerStore interface {
+	// ReplaceMarkers records a fresh scan of the video's comments.
+	ReplaceMarkers(ctx context.Context, videoID string, markers []CommunityMarker, scannedAt time.Time) error
+	// ListMarkers returns the video's markers by time; scanned is false when
+	// its comments were never scanned.
+	ListMarkers(ctx context.Context, videoID string) (markers []CommunityMarker, scanned bool, err error)
+}
+
+func (s *SQLiteStore) ReplaceMarkers(ctx context.Context, videoID string, markers []CommunityMarker, scannedAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM community_markers WHERE video_id = ?`, videoID); err != nil {
+		return err
+	}
+	for _, m := range markers {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO community_markers (video_id, seconds, text, likes, comment_id) VALUES (?, ?, ?, ?, ?)`,
+			videoID, m.Seconds, m.Text, m.Likes, m.CommentID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO comment_scans (video_id, scanned_at) VALUES (?, ?)
+		ON CONFLICT(video_id) DO UPDATE SET scanned_at = excluded.scanned_at`,
+		videoID, scannedAt.Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ListMarkers(ctx context.Context, videoID string) ([]CommunityMarker, bool, error) {
+	var scannedAt int64
+	err := s.db.QueryRowContext(ctx, `SELECT scanned_at FROM comment_scans WHERE video_id = ?`, videoID).Scan(&scannedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT seconds, text, likes, comment_id FROM community_markers WHERE video_id = ? ORDER BY seconds, likes DESC`,
+		videoID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	markers := []CommunityMarker{}
+	for rows.Next() {
+		var m CommunityMarker
+		if err := rows.Scan(&m.Seconds, &m.Text, &m.Likes, &m.CommentID); err != nil {
+			return nil, false, err
+		}
+		markers = append(markers, m)
+	}
+	return markers, true, rows.Err()
+}
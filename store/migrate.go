@@ -0,0 +1,152 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// sqliteMigrations upgrade the index schema one version at a time. The schema
+// version is kept in PRAGMA user_version; entry i moves the database from
+// version i to i+1. Append new migrations, never edit released ones.
+var sqliteMigrations = []string{
+	// 1: videos and full-text segments
+	`
+CREATE TABLE IF NOT EXISTS videos (
+	video_id   TEXT PRIMARY KEY,
+	video_url  TEXT NOT NULL,
+	title      TEXT NOT NULL DEFAULT '',
+	language   TEXT NOT NULL DEFAULT '',
+	source     TEXT NOT NULL DEFAULT '',
+	duration   REAL NOT NULL DEFAULT 0,
+	indexed_at INTEGER NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS segments_fts USING fts5(
+	text,
+	video_id UNINDEXED,
+	start UNINDEXED,
+	end UNINDEXED,
+	tokenize = 'unicode61 remove_diacritics 2'
+);`,
+	// 2: published results
+	`
+CREATE TABLE IF NOT EXISTS public_results (
+	id         TEXT PRIMARY KEY,
+	data       TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);`,
+	// 3: collections and their webhooks
+	`
+ALTER TABLE videos ADD COLUMN collection TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS collection_webhooks (
+	id         TEXT PRIMARY KEY,
+	collection TEXT NOT NULL,
+	url        TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS collection_webhooks_collection ON collection_webhooks (collection);`,
+	// 4: published results by video, for diffing after re-processing.
+	// Results published before this version keep an empty video_id.
+	`
+ALTER TABLE public_results ADD COLUMN video_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS public_results_video ON public_results (video_id);`,
+	// 5: per-segment language. FTS5 tables can't add columns, so rebuild it.
+	`
+CREATE VIRTUAL TABLE segments_fts_v5 USING fts5(
+	text,
+	video_id UNINDEXED,
+	start UNINDEXED,
+	end UNINDEXED,
+	lang UNINDEXED,
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+INSERT INTO segments_fts_v5 (text, video_id, start, end, lang)
+	SELECT text, video_id, start, end, '' FROM segments_fts;
+DROP TABLE segments_fts;
+ALTER TABLE segments_fts_v5 RENAME TO segments_fts;`,
+	// 6: transcript quality
+	`
+ALTER TABLE videos ADD COLUMN quality REAL NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN quality_flags TEXT NOT NULL DEFAULT '';`,
+	// 7: user feedback on published results
+	`
+CREATE TABLE IF NOT EXISTS feedback (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	result_id  TEXT NOT NULL,
+	source     TEXT NOT NULL DEFAULT '',
+	partial    INTEGER NOT NULL DEFAULT 0,
+	correct    INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS feedback_result ON feedback (result_id);`,
+	// 8: the reported and corrected timestamps, for analytics
+	`
+ALTER TABLE feedback ADD COLUMN seconds REAL NOT NULL DEFAULT 0;
+ALTER TABLE feedback ADD COLUMN corrected_seconds REAL;`,
+	// 9: caption fingerprints, re-checked against the platform
+	`
+ALTER TABLE videos ADD COLUMN fingerprint TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN checked_at INTEGER NOT NULL DEFAULT 0;
+UPDATE videos SET checked_at = indexed_at;
+CREATE INDEX IF NOT EXISTS videos_checked ON videos (source, checked_at);`,
+	// 10: what partial transcripts cover, as "start-end,..."; empty is all
+	`
+ALTER TABLE videos ADD COLUMN covered TEXT NOT NULL DEFAULT '';`,
+	// 11: what collection webhook deliveries carry
+	`
+ALTER TABLE collection_webhooks ADD COLUMN attach_transcript TEXT NOT NULL DEFAULT '';
+ALTER TABLE collection_webhooks ADD COLUMN attach_clip INTEGER NOT NULL DEFAULT 0;`,
+	// 12: timestamps viewers mention in comments
+	`
+CREATE TABLE IF NOT EXISTS community_markers (
+	video_id   TEXT NOT NULL,
+	seconds    REAL NOT NULL,
+	text       TEXT NOT NULL DEFAULT '',
+	likes      INTEGER NOT NULL DEFAULT 0,
+	comment_id TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS community_markers_video ON community_markers (video_id, seconds);
+CREATE TABLE IF NOT EXISTS comment_scans (
+	video_id   TEXT PRIMARY KEY,
+	scanned_at INTEGER NOT NULL
+);`,
+	// 13: per-webhook signing secrets; older webhooks go unsigned
+	`
+ALTER TABLE collection_webhooks ADD COLUMN secret TEXT NOT NULL DEFAULT '';`,
+}
+
+// migrateSQLite brings the index up to the current schema. Each migration runs
+// in its own transaction so a failure leaves the database at the last good
+// version. A database written by a newer release is refused rather than
+// touched, since this binary can't know what its schema means.
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read index schema version: %w", err)
+	}
+	latest := len(sqliteMigrations)
+	if version > latest {
+		return fmt.Errorf("index schema version %d is newer than this release supports (%d); upgrade the server or point INDEX_DB at another file", version, latest)
+	}
+
+	for v := version; v < latest; v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[v]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("index migration to version %d failed: %w", v+1, err)
+		}
+		// PRAGMA doesn't take bind parameters
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, v+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("index migration to version %d failed: %w", v+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("index migration to version %d failed: %w", v+1, err)
+		}
+		log.Printf("Migrated transcript index to schema version %d", v+1)
+	}
+	return nil
+}
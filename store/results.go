@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SaveResult stores a published result document, keeping the first one
+// stored under an ID so cached copies never go stale.
+func (s *SQLiteStore) SaveResult(ctx context.Context, id, videoID string, data []byte, createdAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO public_results (id, video_id, data, created_at) VALUES (?, ?, ?, ?)`,
+		id, videoID, string(data), createdAt.Unix())
+	return err
+}
+
+// GetResult loads a published result document.
+func (s *SQLiteStore) GetResult(ctx context.Context, id string) ([]byte, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM public_results WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(data), true, nil
+}
+
+// ListResults loads every result document published for a video, oldest first.
+func (s *SQLiteStore) ListResults(ctx context.Context, videoID string) ([][]byte, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT data FROM public_results WHERE video_id = ? ORDER BY created_at`, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs [][]byte
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		docs = append(docs, []byte(data))
+	}
+	return docs, rows.Err()
+}
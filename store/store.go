@@ -0,0 +1,413 @@
+// Package store persists transcripts in a SQLite full-text index so every
+// processed video is searchable afterwards.
+package store
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"searchme/media"
+	"searchme/subtitle"
+)
+
+// TranscriptRecord is one video's timed text as stored in the library.
+type TranscriptRecord struct {
+	VideoID   string    `json:"video_id"`
+	VideoURL  string    `json:"video_url"`
+	Title     string    `json:"title,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	Source    string    `json:"source"`
+	Duration  float64   `json:"duration"`
+	IndexedAt time.Time `json:"indexed_at"`
+	// Collection groups videos indexed together, e.g. by one playlist job
+	Collection string `json:"collection,omitempty"`
+	// Quality is the transcript quality score (0 when unknown) and its flags
+	Quality      float64  `json:"quality,omitempty"`
+	QualityFlags []string `json:"quality_flags,omitempty"`
+	// Fingerprint is the segments' subtitle.Fingerprint, set on save
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// CheckedAt is when the segments were last known to match the platform's
+	// captions: indexing time, or the last resync that found them unchanged
+	CheckedAt time.Time `json:"checked_at"`
+	// Covered lists what a partial transcript has been transcribed for so
+	// far; empty means the whole video
+	Covered  []Span           `json:"covered,omitempty"`
+	Segments []subtitle.Entry `json:"-"`
+}
+
+// Partial reports whether the transcript covers only parts of the video.
+func (r TranscriptRecord) Partial() bool {
+	return len(r.Covered) > 0
+}
+
+// Span is a stretch of a video in seconds.
+type Span struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// LibraryHit is one matching segment in an indexed video.
+type LibraryHit struct {
+	VideoID  string  `json:"video_id"`
+	VideoURL string  `json:"video_url"`
+	Title    string  `json:"title,omitempty"`
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Text     string  `json:"text"`
+	Snippet  string  `json:"snippet"`
+	// Language is the segment's language, or the video's for segments
+	// indexed before languages were kept per segment
+	Language string `json:"language,omitempty"`
+	// Score is the full-text rank; higher is more relevant
+	Score float64 `json:"score"`
+}
+
+// LibraryQuery is a phrase search across the library. Empty filters match
+// everything.
+type LibraryQuery struct {
+	Query string
+	// Limit defaults to 50
+	Limit, Offset int
+	Collection    string
+	Language      string
+}
+
+// TranscriptStore persists transcripts and searches across all of them.
+type TranscriptStore interface {
+	SaveTranscript(ctx context.Context, rec TranscriptRecord) error
+	// Search returns up to q.Limit hits, best first, after skipping q.Offset.
+	Search(ctx context.Context, q LibraryQuery) ([]LibraryHit, error)
+	ListVideos(ctx context.Context) ([]TranscriptRecord, error)
+	// GetTranscript loads a stored transcript with its segments; found is false when absent.
+	GetTranscript(ctx context.Context, videoID string) (rec TranscriptRecord, found bool, err error)
+	Close() error
+}
+
+// CaptionSyncStore is implemented by stores that can re-check cached caption
+// tracks against the platform.
+type CaptionSyncStore interface {
+	// StaleCaptions lists videos indexed from platform captions and last
+	// checked at or before before, least recently checked first.
+	StaleCaptions(ctx context.Context, before time.Time, limit int) ([]TranscriptRecord, error)
+	// MarkChecked records that the video's captions were found unchanged.
+	MarkChecked(ctx context.Context, videoID string, at time.Time) error
+	// DeleteTranscript drops a video and its segments from the index.
+	DeleteTranscript(ctx context.Context, videoID string) error
+}
+
+// CacheStore is implemented by stores whose transcripts operators can
+// size up and purge per video.
+type CacheStore interface {
+	// TranscriptSizes returns each video's segment count and text size.
+	TranscriptSizes(ctx context.Context) (map[string]TranscriptSize, error)
+	// DeleteTranscript drops a video and its segments from the index.
+	DeleteTranscript(ctx context.Context, videoID string) error
+}
+
+// TranscriptSize is how much of the index a transcript takes.
+type TranscriptSize struct {
+	Segments int   `json:"segments"`
+	Bytes    int64 `json:"size_bytes"`
+}
+
+// VideoKey is the stable library key for a video: the YouTube ID when there is one,
+// otherwise a hash of the URL.
+func VideoKey(videoURL string) string {
+	if id := media.YouTubeVideoID(videoURL); id != "" {
+		return id
+	}
+	sum := sha1.Sum([]byte(strings.TrimSpace(videoURL)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// SQLiteStore keeps segments in an FTS5 table for ranked full-text search.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens (creating if needed) the index database at path.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	// SQLite allows one writer; serialize through a single connection
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// SaveTranscript replaces any previously stored transcript for the video.
+func (s *SQLiteStore) SaveTranscript(ctx context.Context, rec TranscriptRecord) error {
+	if rec.VideoID == "" {
+		rec.VideoID = VideoKey(rec.VideoURL)
+	}
+	if rec.IndexedAt.IsZero() {
+		rec.IndexedAt = time.Now()
+	}
+	if rec.Duration == 0 && len(rec.Segments) > 0 {
+		rec.Duration = rec.Segments[len(rec.Segments)-1].End
+	}
+	if rec.Fingerprint == "" && len(rec.Segments) > 0 {
+		rec.Fingerprint = subtitle.Fingerprint(rec.Segments)
+	}
+	if rec.CheckedAt.IsZero() {
+		rec.CheckedAt = rec.IndexedAt
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM segments_fts WHERE video_id = ?`, rec.VideoID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO videos (video_id, video_url, title, language, source, duration, indexed_at, collection, quality, quality_flags, fingerprint, checked_at, covered)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(video_id) DO UPDATE SET
+			video_url = excluded.video_url,
+			title = CASE WHEN excluded.title != '' THEN excluded.title ELSE videos.title END,
+			language = excluded.language,
+			source = excluded.source,
+			duration = excluded.duration,
+			indexed_at = excluded.indexed_at,
+			collection = CASE WHEN excluded.collection != '' THEN excluded.collection ELSE videos.collection END,
+			quality = excluded.quality,
+			quality_flags = excluded.quality_flags,
+			fingerprint = excluded.fingerprint,
+			checked_at = excluded.checked_at,
+			covered = excluded.covered`,
+		rec.VideoID, rec.VideoURL, rec.Title, rec.Language, rec.Source, rec.Duration, rec.IndexedAt.Unix(), rec.Collection,
+		rec.Quality, strings.Join(rec.QualityFlags, ","), rec.Fingerprint, rec.CheckedAt.Unix(), formatSpans(rec.Covered)); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO segments_fts (text, video_id, start, end, lang) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, seg := range rec.Segments {
+		if _, err := stmt.ExecContext(ctx, seg.Text, rec.VideoID, seg.Start, seg.End, seg.Lang); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Search runs a phrase query across every indexed segment, best matches first.
+func (s *SQLiteStore) Search(ctx context.Context, q LibraryQuery) ([]LibraryHit, error) {
+	query := strings.TrimSpace(q.Query)
+	if query == "" {
+		return nil, nil
+	}
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT f.video_id, v.video_url, v.title, f.start, f.end, f.text,
+			snippet(segments_fts, 0, '[', ']', '…', 16), bm25(segments_fts),
+			CASE WHEN f.lang != '' THEN f.lang ELSE v.language END
+		FROM segments_fts f
+		JOIN videos v ON v.video_id = f.video_id
+		WHERE segments_fts MATCH ?
+			AND (? = '' OR v.collection = ?)
+			AND (? = '' OR f.lang = ? OR (f.lang = '' AND v.language = ?))
+		ORDER BY bm25(segments_fts), f.video_id, f.start
+		LIMIT ? OFFSET ?`, ftsPhrase(query), q.Collection, q.Collection, q.Language, q.Language, q.Language, q.Limit, max(q.Offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("index search failed: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []LibraryHit
+	for rows.Next() {
+		var h LibraryHit
+		var rank float64
+		if err := rows.Scan(&h.VideoID, &h.VideoURL, &h.Title, &h.Start, &h.End, &h.Text, &h.Snippet, &rank, &h.Language); err != nil {
+			return nil, err
+		}
+		// bm25 is negative, lower is better
+		h.Score = -rank
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// ListVideos returns every indexed video, most recently indexed first.
+func (s *SQLiteStore) ListVideos(ctx context.Context) ([]TranscriptRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+videoColumns+`
+		FROM videos ORDER BY indexed_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return scanVideos(rows)
+}
+
+// videoColumns are the videos columns read by scanVideo, in order.
+const videoColumns = `video_id, video_url, title, language, source, duration, indexed_at, collection, quality, quality_flags, fingerprint, checked_at, covered`
+
+// scanVideo reads one row of videoColumns.
+func scanVideo(row interface{ Scan(...interface{}) error }) (TranscriptRecord, error) {
+	var r TranscriptRecord
+	var indexedAt, checkedAt int64
+	var flags, covered string
+	if err := row.Scan(&r.VideoID, &r.VideoURL, &r.Title, &r.Language, &r.Source, &r.Duration, &indexedAt, &r.Collection, &r.Quality, &flags, &r.Fingerprint, &checkedAt, &covered); err != nil {
+		return r, err
+	}
+	r.IndexedAt = time.Unix(indexedAt, 0)
+	r.CheckedAt = time.Unix(checkedAt, 0)
+	r.QualityFlags = splitFlags(flags)
+	r.Covered = parseSpans(covered)
+	return r, nil
+}
+
+func scanVideos(rows *sql.Rows) ([]TranscriptRecord, error) {
+	defer rows.Close()
+	var out []TranscriptRecord
+	for rows.Next() {
+		r, err := scanVideo(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// GetTranscript loads one video's record and its segments in time order.
+func (s *SQLiteStore) GetTranscript(ctx context.Context, videoID string) (TranscriptRecord, bool, error) {
+	r, err := scanVideo(s.db.QueryRowContext(ctx, `
+		SELECT `+videoColumns+`
+		FROM videos WHERE video_id = ?`, videoID))
+	if err == sql.ErrNoRows {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT start, end, text, lang FROM segments_fts
+		WHERE video_id = ? ORDER BY CAST(start AS REAL)`, videoID)
+	if err != nil {
+		return r, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e subtitle.Entry
+		if err := rows.Scan(&e.Start, &e.End, &e.Text, &e.Lang); err != nil {
+			return r, false, err
+		}
+		r.Segments = append(r.Segments, e)
+	}
+	return r, true, rows.Err()
+}
+
+// StaleCaptions lists caption-sourced videos last checked at or before before.
+func (s *SQLiteStore) StaleCaptions(ctx context.Context, before time.Time, limit int) ([]TranscriptRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+videoColumns+`
+		FROM videos WHERE source IN (?, ?) AND checked_at <= ?
+		ORDER BY checked_at LIMIT ?`, subtitle.SourceManual, subtitle.SourceAuto, before.Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanVideos(rows)
+}
+
+func (s *SQLiteStore) MarkChecked(ctx context.Context, videoID string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE videos SET checked_at = ? WHERE video_id = ?`, at.Unix(), videoID)
+	return err
+}
+
+func (s *SQLiteStore) TranscriptSizes(ctx context.Context) (map[string]TranscriptSize, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT video_id, COUNT(*), COALESCE(SUM(LENGTH(CAST(text AS BLOB))), 0)
+		FROM segments_fts GROUP BY video_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sizes := map[string]TranscriptSize{}
+	for rows.Next() {
+		var id string
+		var size TranscriptSize
+		if err := rows.Scan(&id, &size.Segments, &size.Bytes); err != nil {
+			return nil, err
+		}
+		sizes[id] = size
+	}
+	return sizes, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteTranscript(ctx context.Context, videoID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM segments_fts WHERE video_id = ?`, videoID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM videos WHERE video_id = ?`, videoID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func splitFlags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// formatSpans stores spans as "start-end,start-end".
+func formatSpans(spans []Span) string {
+	parts := make([]string, len(spans))
+	for i, sp := range spans {
+		parts[i] = strconv.FormatFloat(sp.Start, 'f', 3, 64) + "-" + strconv.FormatFloat(sp.End, 'f', 3, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseSpans(s string) []Span {
+	var spans []Span
+	for _, part := range splitFlags(s) {
+		start, end, ok := strings.Cut(part, "-")
+		if !ok {
+			continue
+		}
+		a, err1 := strconv.ParseFloat(start, 64)
+		b, err2 := strconv.ParseFloat(end, 64)
+		if err1 == nil && err2 == nil {
+			spans = append(spans, Span{Start: a, End: b})
+		}
+	}
+	return spans
+}
+
+// ftsPhrase quotes user input as a single FTS5 phrase so operators in it are literal.
+func ftsPhrase(q string) string {
+	return `"` + strings.ReplaceAll(q, `"`, `""`) + `"`
+}
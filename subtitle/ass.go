@@ -0,0 +1,90 @@
+package subtitle
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// ASS timings are H:MM:SS.cc, in centiseconds
+	assTimeRegex     = regexp.MustCompile(`^(\d+):(\d{1,2}):(\d{1,2})[.,](\d{1,3})$`)
+	assOverrideRegex = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// assDefaultFields is the [Events] column order when the file has no Format line.
+var assDefaultFields = []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+
+// ParseASS parses the Dialogue lines of an ASS or SSA file, dropping override
+// tags like {\i1}. Comment lines and the other sections are ignored.
+func ParseASS(content string) ([]Entry, error) {
+	var entries []Entry
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	fields := assDefaultFields
+	inEvents := false
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "format":
+			fields = nil
+			for _, f := range strings.Split(value, ",") {
+				fields = append(fields, strings.ToLower(strings.TrimSpace(f)))
+			}
+		case "dialogue":
+			// Text is the last column and may itself contain commas
+			cols := strings.SplitN(value, ",", len(fields))
+			if len(cols) != len(fields) {
+				continue
+			}
+			var start, end float64
+			var startOK, endOK bool
+			var text string
+			for i, f := range fields {
+				switch f {
+				case "start":
+					start, startOK = parseASSTime(cols[i])
+				case "end":
+					end, endOK = parseASSTime(cols[i])
+				case "text":
+					text = assText(cols[i])
+				}
+			}
+			if startOK && endOK && text != "" {
+				entries = append(entries, Entry{Start: start, End: end, Text: text})
+			}
+		}
+	}
+
+	// events may be listed in any order, e.g. grouped by style
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Start < entries[j].Start })
+	return entries, nil
+}
+
+func parseASSTime(s string) (float64, bool) {
+	m := assTimeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, false
+	}
+	// pad the fraction to milliseconds: "5" and "50" are both half a second
+	ms := (m[4] + "00")[:3]
+	return parseTime(m[1], m[2], m[3], ms), true
+}
+
+// assText strips override tags and turns ASS line breaks into spaces.
+func assText(s string) string {
+	s = assOverrideRegex.ReplaceAllString(s, "")
+	s = strings.NewReplacer(`\N`, " ", `\n`, " ", `\h`, " ").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,218 @@
+package subtitle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"searchme/internal/deadline"
+	"searchme/internal/env"
+	"searchme/internal/faults"
+	"searchme/internal/workfile"
+	"searchme/media"
+)
+
+// Where fetched captions came from
+const (
+	SourceManual = "manual_subtitles"
+	SourceAuto   = "auto_subtitles"
+)
+
+// ErrNoSubtitles is wrapped by errors for videos without captions where a
+// search needed them.
+var ErrNoSubtitles = errors.New("the video has no captions")
+
+// Caption variants, tried in this order before anyone pays for Whisper.
+// CAPTION_VARIANTS, a comma list of these names, narrows or reorders them.
+const (
+	// VariantManual is the uploader's captions in the requested language
+	VariantManual = "manual"
+	// VariantManualRegion is the uploader's captions in a regional form of
+	// the language, e.g. en-GB for en
+	VariantManualRegion = "manual_region"
+	// VariantAuto is the platform's auto captions in the requested language,
+	// which may be machine translated from the original
+	VariantAuto = "auto"
+	// VariantAutoRegion is auto captions in a regional form of the language
+	VariantAutoRegion = "auto_region"
+	// VariantAutoOriginal is auto captions in the video's original language,
+	// whatever that is
+	VariantAutoOriginal = "auto_original"
+)
+
+type captionVariant struct {
+	name, flag, source string
+	// langs builds yt-dlp's --sub-langs pattern for the requested language
+	langs func(lang string) string
+}
+
+var captionVariants = []captionVariant{
+	{VariantManual, "--write-subs", SourceManual, func(l string) string { return l }},
+	{VariantManualRegion, "--write-subs", SourceManual, func(l string) string { return l + "-.*" }},
+	{VariantAuto, "--write-auto-subs", SourceAuto, func(l string) string { return l }},
+	{VariantAutoRegion, "--write-auto-subs", SourceAuto, func(l string) string { return l + "-.*" }},
+	{VariantAutoOriginal, "--write-auto-subs", SourceAuto, func(string) string { return ".*-orig" }},
+}
+
+// Track is a caption track fetched for a video.
+type Track struct {
+	Content []byte
+	// Format is the caption file format, e.g. FormatSRT
+	Format string
+	// Source is SourceManual or SourceAuto
+	Source string
+	// Variant names the caption variant that had the track
+	Variant string
+	// Lang is the track's language as the platform labels it, e.g. "en-GB"
+	Lang string
+}
+
+// VariantStats counts how often a caption variant was tried and had captions.
+type VariantStats struct {
+	Attempts int `json:"attempts"`
+	Hits     int `json:"hits"`
+}
+
+var (
+	variantMu    sync.Mutex
+	variantStats = map[string]*VariantStats{}
+)
+
+// Stats returns a copy of the per-variant counts since startup.
+func Stats() map[string]VariantStats {
+	variantMu.Lock()
+	defer variantMu.Unlock()
+	out := make(map[string]VariantStats, len(variantStats))
+	for name, st := range variantStats {
+		out[name] = *st
+	}
+	return out
+}
+
+func recordVariant(name string, hit bool) {
+	variantMu.Lock()
+	defer variantMu.Unlock()
+	st := variantStats[name]
+	if st == nil {
+		st = &VariantStats{}
+		variantStats[name] = st
+	}
+	st.Attempts++
+	if hit {
+		st.Hits++
+	}
+}
+
+// enabledVariants applies CAPTION_VARIANTS; unknown names are ignored.
+func enabledVariants() []captionVariant {
+	names := env.List("CAPTION_VARIANTS")
+	if len(names) == 0 {
+		return captionVariants
+	}
+	var out []captionVariant
+	for _, n := range names {
+		for _, v := range captionVariants {
+			if v.name == strings.ToLower(n) {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+// Entries parses the track in its format.
+func (t Track) Entries() ([]Entry, error) {
+	return Parse(t.Content, t.Format)
+}
+
+// FetchTrack tries each caption variant in turn and returns the first track
+// found, so a missing exact-language track doesn't send the search straight
+// to Whisper. ok is false when no variant has captions; err is the last
+// yt-dlp error, if any. A Fatal error stops the search straight away.
+func FetchTrack(dl *media.Downloader, src media.VideoSource, videoURL, langCode string) (track Track, ok bool, err error) {
+	if !src.SupportsSubtitles() {
+		return Track{}, false, nil
+	}
+
+	for _, v := range enabledVariants() {
+		// Use a unique output template to avoid file conflicts
+		outputTemplate := workfile.Path("temp_subs")
+		var output []byte
+		output, err = dl.CombinedOutput(context.Background(),
+			"--skip-download",
+			v.flag,
+			"--sub-langs", v.langs(langCode),
+			// SRT when the platform has it or yt-dlp can convert to it;
+			// otherwise whichever format we can parse
+			"--sub-format", "srt/vtt/ass/ssa/ttml/dfxp/best",
+			"--convert-subs", "srt",
+			"-o", outputTemplate,
+			videoURL,
+		)
+		log.Printf("commandt: %s", string(output))
+
+		track, ok = readTrack(outputTemplate)
+		recordVariant(v.name, ok)
+		if ok {
+			track.Source, track.Variant = v.source, v.name
+			if v.name != VariantManual {
+				log.Printf("captions for %s found as %s (%s)", videoURL, v.name, track.Lang)
+			}
+			return track, true, err
+		}
+		if Fatal(err) {
+			break
+		}
+	}
+	return Track{}, false, err
+}
+
+// Fatal reports whether a FetchTrack error means the video can't be had
+// at all, so falling back to transcribing it would fail too: it is
+// unavailable, or yt-dlp ran out of time reaching it.
+func Fatal(err error) bool {
+	return errors.Is(err, media.ErrVideoUnavailable) || errors.Is(err, deadline.ErrTimeout)
+}
+
+// readTrack picks up the caption files yt-dlp wrote for outputTemplate,
+// keeping the first by language in a format we can parse, and removes them
+// all. yt-dlp names them <template>.<lang>.<ext>; when conversion to SRT
+// fails it leaves the original format behind.
+func readTrack(outputTemplate string) (Track, bool) {
+	files, _ := filepath.Glob(outputTemplate + ".*")
+	defer func() {
+		// clean up the subtitle files after reading
+		for _, f := range files {
+			_ = os.Remove(f)
+		}
+	}()
+	sort.Strings(files)
+
+	for _, f := range files {
+		name := strings.TrimPrefix(f, outputTemplate+".")
+		ext := filepath.Ext(name)
+		lang := strings.TrimSuffix(name, ext)
+		if lang == "" {
+			continue
+		}
+		content, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		format, ok := FormatForExt(ext)
+		if !ok {
+			// e.g. ".best": trust the content over the name
+			if format, ok = Sniff(content); !ok {
+				log.Printf("ignoring captions in unrecognized format: %s", filepath.Base(f))
+				continue
+			}
+		}
+		return Track{Content: faults.SRT(content), Format: format, Lang: lang}, true
+	}
+	return Track{}, false
+}
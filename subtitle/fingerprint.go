@@ -0,0 +1,20 @@
+package subtitle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Fingerprint identifies a track's content: its cue times (to the
+// millisecond) and whitespace-normalized text. Platforms expose no caption
+// version, so comparing fingerprints is how an edit by the uploader shows.
+// The format a track was fetched in doesn't affect it.
+func Fingerprint(entries []Entry) string {
+	h := sha256.New()
+	for _, e := range entries {
+		fmt.Fprintf(h, "%d %d %s\n", int64(e.Start*1000), int64(e.End*1000), strings.Join(strings.Fields(e.Text), " "))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,87 @@
+package subtitle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Caption file formats
+const (
+	FormatSRT = "srt"
+	FormatVTT = "vtt"
+	// FormatASS covers Advanced SubStation Alpha and its SSA predecessor
+	FormatASS = "ass"
+	// FormatTTML covers TTML and DFXP, its older name
+	FormatTTML = "ttml"
+)
+
+// ErrUnknownFormat is returned when caption content matches no supported format.
+var ErrUnknownFormat = errors.New("unrecognized caption format")
+
+var (
+	srtSniffRegex = regexp.MustCompile(`\d{2}:\d{2}:\d{2},\d{3}\s*-->`)
+	vttSniffRegex = regexp.MustCompile(`\d{2}:\d{2}\.\d{3}\s*-->`)
+	assSniffRegex = regexp.MustCompile(`(?mi)^\s*(\[script info\]|\[events\]|dialogue:)`)
+)
+
+// FormatForExt maps a file extension, with or without the dot, to its format.
+func FormatForExt(ext string) (string, bool) {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "srt":
+		return FormatSRT, true
+	case "vtt":
+		return FormatVTT, true
+	case "ass", "ssa":
+		return FormatASS, true
+	case "ttml", "dfxp", "xml":
+		return FormatTTML, true
+	}
+	return "", false
+}
+
+// Sniff guesses a caption file's format from its content. ok is false when it
+// looks like none of them.
+func Sniff(content []byte) (format string, ok bool) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")))
+	// the first few KB are enough to tell the formats apart
+	head := trimmed[:min(len(trimmed), 4096)]
+	switch {
+	case bytes.HasPrefix(head, []byte("WEBVTT")):
+		return FormatVTT, true
+	case bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head, []byte("<tt")):
+		return FormatTTML, true
+	case assSniffRegex.Match(head):
+		return FormatASS, true
+	case srtSniffRegex.Match(head):
+		return FormatSRT, true
+	case vttSniffRegex.Match(head):
+		// WebVTT without its header, as some exporters write it
+		return FormatVTT, true
+	}
+	return "", false
+}
+
+// Parse parses caption content in format, sniffing the format when it is empty.
+func Parse(content []byte, format string) ([]Entry, error) {
+	if format == "" {
+		f, ok := Sniff(content)
+		if !ok {
+			return nil, ErrUnknownFormat
+		}
+		format = f
+	}
+	switch format {
+	case FormatSRT:
+		return ParseSRT(strings.ReplaceAll(string(content), "\r\n", "\n"))
+	case FormatVTT:
+		return ParseVTT(string(content))
+	case FormatASS:
+		return ParseASS(string(content))
+	case FormatTTML:
+		return ParseTTML(content)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
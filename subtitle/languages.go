@@ -0,0 +1,51 @@
+package subtitle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"searchme/media"
+)
+
+// Languages lists the caption tracks a video has without downloading any:
+// the uploader's (manual) and the platform's auto captions. On YouTube auto
+// includes machine translations into most languages; the original-language
+// track is the one labelled "<lang>-orig".
+func Languages(dl *media.Downloader, src media.VideoSource, videoURL string) (manual, auto []string, err error) {
+	if !src.SupportsSubtitles() {
+		return nil, nil, nil
+	}
+	out, err := dl.Output(context.Background(), "--skip-download",
+		"--print", "%(subtitles)j", "--print", "%(automatic_captions)j", "--", videoURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list caption tracks: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) > 0 {
+		manual = trackLanguages(lines[0])
+	}
+	if len(lines) > 1 {
+		auto = trackLanguages(lines[1])
+	}
+	return manual, auto, nil
+}
+
+// trackLanguages reads the language codes out of yt-dlp's subtitles map.
+func trackLanguages(line string) []string {
+	var tracks map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &tracks); err != nil {
+		return nil
+	}
+	var langs []string
+	for lang := range tracks {
+		if lang == "live_chat" {
+			continue
+		}
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
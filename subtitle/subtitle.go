@@ -0,0 +1,84 @@
+// Package subtitle parses timed captions (SRT, WebVTT, ASS/SSA, TTML/DFXP)
+// and fetches platform captions with yt-dlp.
+package subtitle
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Entry is one timed caption.
+type Entry struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+	// Lang is the segment's own language when it differs from the track's
+	// (code-switching), or the language Whisper heard in a transcribed
+	// chunk; empty means the track language.
+	Lang string `json:"lang,omitempty"`
+	// NoSpeechProb is Whisper's estimate that the segment is not speech; 0 for captions
+	NoSpeechProb float64 `json:"no_speech_prob,omitempty"`
+	// AvgLogprob and CompressionRatio are Whisper's decoder statistics; 0 for captions
+	AvgLogprob       float64 `json:"avg_logprob,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+}
+
+func parseTime(hours, minutes, seconds, milliseconds string) float64 {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.Atoi(seconds)
+	ms, _ := strconv.Atoi(milliseconds)
+	return float64(h*3600+m*60+s) + float64(ms)/1000.0
+}
+
+// ParseSRT parses SubRip cues, dropping formatting tags.
+func ParseSRT(content string) ([]Entry, error) {
+	var entries []Entry
+	blocks := strings.Split(content, "\n\n")
+	timeRegex := regexp.MustCompile(`(\d{2}):(\d{2}):(\d{2}),(\d{3})\s*-->\s*(\d{2}):(\d{2}):(\d{2}),(\d{3})`)
+
+	for _, block := range blocks {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		if len(lines) < 2 {
+			continue
+		}
+
+		timelineIdx := -1
+		for i, line := range lines {
+			if timeRegex.MatchString(line) {
+				timelineIdx = i
+				break
+			}
+		}
+		if timelineIdx == -1 {
+			continue
+		}
+
+		matches := timeRegex.FindStringSubmatch(lines[timelineIdx])
+		if len(matches) != 9 {
+			continue
+		}
+
+		start := parseTime(matches[1], matches[2], matches[3], matches[4])
+		end := parseTime(matches[5], matches[6], matches[7], matches[8])
+
+		var textParts []string
+		for i := timelineIdx + 1; i < len(lines); i++ {
+			if text := strings.TrimSpace(lines[i]); text != "" {
+				text = regexp.MustCompile(`<[^>]*>`).ReplaceAllString(text, "")
+				textParts = append(textParts, text)
+			}
+		}
+
+		if len(textParts) > 0 {
+			entries = append(entries, Entry{
+				Start: start,
+				End:   end,
+				Text:  strings.Join(textParts, " "),
+			})
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,186 @@
+package subtitle
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// clock time: hh:mm:ss, hh:mm:ss.fraction or hh:mm:ss:frames
+	ttmlClockRegex = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2}(?:\.\d+)?)(?::(\d+(?:\.\d+)?))?$`)
+	// offset time: a number and a unit, e.g. 12.5s or 900ms
+	ttmlOffsetRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|m|s|ms|f|t)$`)
+)
+
+// ttmlRates are the tt element's timing parameters.
+type ttmlRates struct {
+	frameRate, tickRate float64
+}
+
+// ParseTTML parses the <p> elements of a TTML or DFXP document. Times on
+// enclosing <body> and <div> elements offset their children, <br/> becomes a
+// space and styling is dropped.
+func ParseTTML(content []byte) ([]Entry, error) {
+	var entries []Entry
+	rates := ttmlRates{frameRate: 30, tickRate: 1}
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	// DFXP files from some platforms declare encodings other than UTF-8 but
+	// are UTF-8 in practice
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	// offsets holds the begin time of each open container
+	offsets := []float64{0}
+	var cue *Entry
+	var text strings.Builder
+	depth := 0 // element depth inside the current <p>
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if cue != nil {
+				depth++
+				if t.Name.Local == "br" {
+					text.WriteByte(' ')
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "tt":
+				for _, a := range t.Attr {
+					v, err := strconv.ParseFloat(a.Value, 64)
+					if err != nil || v <= 0 {
+						continue
+					}
+					switch a.Name.Local {
+					case "frameRate":
+						rates.frameRate = v
+					case "tickRate":
+						rates.tickRate = v
+					}
+				}
+				offsets = append(offsets, offsets[len(offsets)-1])
+			case "p":
+				base := offsets[len(offsets)-1]
+				begin, end, ok := ttmlTiming(t.Attr, base, rates)
+				if !ok {
+					// untimed paragraphs cannot be searched by time
+					if err := dec.Skip(); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				cue = &Entry{Start: begin, End: end}
+				text.Reset()
+				depth = 0
+			default:
+				begin := offsets[len(offsets)-1]
+				if b, ok := ttmlAttr(t.Attr, "begin"); ok {
+					if v, ok := parseTTMLTime(b, rates); ok {
+						begin += v
+					}
+				}
+				offsets = append(offsets, begin)
+			}
+		case xml.EndElement:
+			if cue != nil {
+				if depth > 0 {
+					depth--
+					continue
+				}
+				if s := strings.Join(strings.Fields(text.String()), " "); s != "" {
+					cue.Text = s
+					entries = append(entries, *cue)
+				}
+				cue = nil
+				continue
+			}
+			if len(offsets) > 1 {
+				offsets = offsets[:len(offsets)-1]
+			}
+		case xml.CharData:
+			if cue != nil {
+				text.Write(t)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Start < entries[j].Start })
+	return entries, nil
+}
+
+// ttmlTiming reads a <p>'s begin and end (or dur), relative to base.
+func ttmlTiming(attrs []xml.Attr, base float64, rates ttmlRates) (begin, end float64, ok bool) {
+	b, ok := ttmlAttr(attrs, "begin")
+	if !ok {
+		return 0, 0, false
+	}
+	begin, ok = parseTTMLTime(b, rates)
+	if !ok {
+		return 0, 0, false
+	}
+	if e, has := ttmlAttr(attrs, "end"); has {
+		end, ok = parseTTMLTime(e, rates)
+	} else if d, has := ttmlAttr(attrs, "dur"); has {
+		var dur float64
+		dur, ok = parseTTMLTime(d, rates)
+		end = begin + dur
+	} else {
+		end, ok = begin, true
+	}
+	return base + begin, base + end, ok
+}
+
+func ttmlAttr(attrs []xml.Attr, name string) (string, bool) {
+	for _, a := range attrs {
+		if a.Name.Local == name {
+			return strings.TrimSpace(a.Value), true
+		}
+	}
+	return "", false
+}
+
+// parseTTMLTime reads a TTML clock or offset time expression as seconds.
+func parseTTMLTime(s string, rates ttmlRates) (float64, bool) {
+	if m := ttmlClockRegex.FindStringSubmatch(s); m != nil {
+		h, _ := strconv.ParseFloat(m[1], 64)
+		mins, _ := strconv.ParseFloat(m[2], 64)
+		sec, _ := strconv.ParseFloat(m[3], 64)
+		secs := h*3600 + mins*60 + sec
+		if m[4] != "" {
+			frames, _ := strconv.ParseFloat(m[4], 64)
+			secs += frames / rates.frameRate
+		}
+		return secs, true
+	}
+	if m := ttmlOffsetRegex.FindStringSubmatch(s); m != nil {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "h":
+			return v * 3600, true
+		case "m":
+			return v * 60, true
+		case "s":
+			return v, true
+		case "ms":
+			return v / 1000, true
+		case "f":
+			return v / rates.frameRate, true
+		case "t":
+			return v / rates.tickRate, true
+		}
+	}
+	return 0, false
+}
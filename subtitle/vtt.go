@@ -0,0 +1,52 @@
+package subtitle
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// WebVTT cue timings; the hours field is optional
+	vttTimeRegex = regexp.MustCompile(`(?:(\d{2,}):)?(\d{2}):(\d{2})\.(\d{3})\s*-->\s*(?:(\d{2,}):)?(\d{2}):(\d{2})\.(\d{3})`)
+	cueTagRegex  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ParseVTT parses WebVTT cues. NOTE/STYLE/REGION blocks and cue settings are ignored.
+func ParseVTT(content string) ([]Entry, error) {
+	var entries []Entry
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	for _, block := range strings.Split(content, "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		timelineIdx := -1
+		for i, line := range lines {
+			if vttTimeRegex.MatchString(line) {
+				timelineIdx = i
+				break
+			}
+		}
+		if timelineIdx == -1 {
+			continue
+		}
+
+		m := vttTimeRegex.FindStringSubmatch(lines[timelineIdx])
+		start := parseTime(m[1], m[2], m[3], m[4])
+		end := parseTime(m[5], m[6], m[7], m[8])
+
+		var textParts []string
+		for _, line := range lines[timelineIdx+1:] {
+			if text := strings.TrimSpace(cueTagRegex.ReplaceAllString(line, "")); text != "" {
+				textParts = append(textParts, text)
+			}
+		}
+		if len(textParts) > 0 {
+			entries = append(entries, Entry{
+				Start: start,
+				End:   end,
+				Text:  strings.Join(textParts, " "),
+			})
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,266 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/deadline"
+	"searchme/internal/env"
+	"searchme/internal/faults"
+	"searchme/internal/oai"
+	"searchme/media"
+)
+
+// DefaultChunkSeconds is the chunk length used when neither the audio's
+// settings nor CHUNK_SECONDS give one: 5 minutes keeps each upload well
+// under Whisper's 25 MB limit at 32 kbit/s.
+const DefaultChunkSeconds = media.DefaultChunkSeconds
+
+// Chunk is one piece of a longer recording. Offset is where it starts in the
+// original and Duration its length, in seconds.
+type Chunk struct {
+	Index    int
+	Path     string
+	Offset   float64
+	Duration float64
+}
+
+// Split cuts audio into mp3 chunks of chunkSeconds in dir, which must exist,
+// encoded per audio.Settings. chunkSeconds 0 uses the settings' chunk length.
+// The caller owns dir and removes it when done. ffmpeg is killed when ctx
+// ends or after SEGMENT_TIMEOUT (see deadline.Segment). Audio no longer than
+// one chunk that Whisper takes as it is (media.FitsWhisper) isn't cut: its
+// one chunk is the audio file itself, which Split never removes. With
+// SKIP_SILENCE on, silences are left out (see VADConfig): chunks then hold
+// only speech, each starting at its Offset in the audio. Audio from the
+// AudioCache keeps its chunks there, so it is only cut once per length.
+func Split(ctx context.Context, audio *media.AudioFile, dir string, chunkSeconds int) ([]Chunk, error) {
+	settings := audio.Settings.WithDefaults()
+	if chunkSeconds <= 0 {
+		chunkSeconds = settings.ChunkSeconds
+	}
+	if chunkSeconds <= 0 {
+		chunkSeconds = DefaultChunkSeconds
+	}
+	// a failed probe only costs the shortcut
+	total, probeErr := media.ProbeDuration(audio.Path)
+	if probeErr == nil && total > 0 && total <= float64(chunkSeconds) && media.FitsWhisper(audio.Path) {
+		return []Chunk{{Index: 0, Path: audio.Path, Duration: total}}, nil
+	}
+
+	ctx, cancel := deadline.Segment.Context(ctx)
+	defer cancel()
+	if vad := VADConfigFromEnv(); vad.Enabled && probeErr == nil && total > 0 {
+		chunks, ok, err := splitSpeech(ctx, audio, dir, total, chunkSeconds, settings, vad)
+		if err != nil {
+			return nil, deadline.Err(ctx, err)
+		}
+		if ok {
+			return chunks, nil
+		}
+	}
+
+	if audio.CacheDir != "" {
+		if files, _ := filepath.Glob(filepath.Join(cachedChunkDir(audio, chunkSeconds), "chunk_*.mp3")); len(files) > 0 {
+			sort.Strings(files)
+			return chunksFromFiles(files, chunkSeconds, total, probeErr), nil
+		}
+	}
+
+	chunkPattern := filepath.Join(dir, "chunk_%03d.mp3")
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", audio.Path}
+	args = append(args, settings.FFmpegArgs()...)
+	args = append(args,
+		"-f", "segment",
+		"-segment_time", fmt.Sprintf("%d", chunkSeconds),
+		"-reset_timestamps", "1",
+		"-y", chunkPattern,
+	)
+	segCmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if out, err := segCmd.CombinedOutput(); err != nil {
+		log.Printf("ffmpeg segment error: %s", string(out))
+		return nil, fmt.Errorf("failed to segment audio: %w", deadline.Err(ctx, err))
+	}
+
+	chunkFiles, err := filepath.Glob(filepath.Join(dir, "chunk_*.mp3"))
+	if err != nil || len(chunkFiles) == 0 {
+		return nil, fmt.Errorf("no chunks produced: %w", err)
+	}
+	sort.Strings(chunkFiles)
+	if audio.CacheDir != "" {
+		keepChunks(cachedChunkDir(audio, chunkSeconds), chunkFiles)
+	}
+	return chunksFromFiles(chunkFiles, chunkSeconds, total, probeErr), nil
+}
+
+// chunksFromFiles times the chunk files ffmpeg cut at chunkSeconds from
+// audio of length total, when probing it didn't fail.
+func chunksFromFiles(chunkFiles []string, chunkSeconds int, total float64, probeErr error) []Chunk {
+	chunks := make([]Chunk, len(chunkFiles))
+	for i, f := range chunkFiles {
+		chunks[i] = Chunk{Index: i, Path: f, Offset: float64(i * chunkSeconds), Duration: float64(chunkSeconds)}
+	}
+	// only the last chunk can be shorter
+	last := &chunks[len(chunks)-1]
+	if probeErr == nil && total > last.Offset && total-last.Offset < last.Duration {
+		last.Duration = total - last.Offset
+	} else if d, err := media.ProbeDuration(last.Path); err == nil && d > 0 && d < last.Duration {
+		last.Duration = d
+	}
+	return chunks
+}
+
+// cachedChunkDir is where cached audio keeps its chunks of chunkSeconds.
+func cachedChunkDir(audio *media.AudioFile, chunkSeconds int) string {
+	return filepath.Join(audio.CacheDir, fmt.Sprintf("chunks_%d", chunkSeconds))
+}
+
+// keepChunks links a finished set of chunk files into dir, all at once so
+// a concurrent Split never sees half of them. Failing only costs the cache.
+func keepChunks(dir string, files []string) {
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".chunks_")
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if err := os.Link(f, filepath.Join(tmp, filepath.Base(f))); err != nil {
+			_ = os.RemoveAll(tmp)
+			return
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// another search kept the same chunks first
+		_ = os.RemoveAll(tmp)
+	}
+}
+
+// Whisper transcribes audio files with the OpenAI API.
+type Whisper struct {
+	client *openai.Client
+	// callerKey is set when a caller's own key pays, so the server's
+	// Usage doesn't count it
+	callerKey bool
+	opts      WhisperOptions
+}
+
+// NewWhisperFromEnv returns a Whisper on the shared OpenAI client (see oai.Client).
+func NewWhisperFromEnv() (*Whisper, error) {
+	return NewWhisper(context.Background())
+}
+
+// NewWhisper returns a Whisper billing the caller's key in ctx, if any
+// (see oai.WithKey), else the shared client's, with the options ctx carries
+// over the server's (see WithOptions).
+func NewWhisper(ctx context.Context) (*Whisper, error) {
+	client, err := oai.ClientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Whisper{client: client, callerKey: oai.Key(ctx) != "", opts: Options(ctx).WithDefaults()}, nil
+}
+
+var (
+	workersOnce sync.Once
+	workers     chan struct{}
+)
+
+// whisperWorkers bounds the Whisper requests in flight across the whole
+// process to WHISPER_WORKERS (default 8), however many searches run at once.
+func whisperWorkers() chan struct{} {
+	workersOnce.Do(func() { workers = make(chan struct{}, env.Int("WHISPER_WORKERS", 8)) })
+	return workers
+}
+
+// Warm creates the shared OpenAI client and the Whisper worker pool up front,
+// so the first search doesn't pay for it and a missing key or invalid
+// WhisperOptionsFromEnv show at startup.
+func Warm() error {
+	whisperWorkers()
+	if err := WhisperOptionsFromEnv().Validate(); err != nil {
+		return fmt.Errorf("invalid Whisper options: %w", err)
+	}
+	_, err := oai.Client()
+	return err
+}
+
+// Chunk transcribes one chunk and shifts its timestamps by the chunk offset.
+// Word timings are requested only when words is set, since they slow Whisper down.
+// Rate limits and server errors are retried per OPENAI_RETRY_*, all within
+// CHUNK_TRANSCRIBE_TIMEOUT (see deadline.Chunk); successful chunks count
+// towards Usage unless a caller's key paid for them. Models that don't time
+// their segments (see WhisperOptions.Model) return the chunk as one segment.
+func (w *Whisper) Chunk(ctx context.Context, c Chunk, words bool) (Transcript, error) {
+	if err := faults.Chunk(c.Index); err != nil {
+		return Transcript{}, err
+	}
+	pool := whisperWorkers()
+	select {
+	case pool <- struct{}{}:
+		defer func() { <-pool }()
+	case <-ctx.Done():
+		return Transcript{}, ctx.Err()
+	}
+
+	areq := openai.AudioRequest{
+		Model:       w.opts.Model,
+		FilePath:    c.Path,
+		Prompt:      w.opts.Prompt,
+		Temperature: float32(w.opts.Temperature),
+		Language:    w.opts.Language,
+		Format:      openai.AudioResponseFormatJSON,
+	}
+	if w.opts.timed() {
+		areq.Format = openai.AudioResponseFormatVerboseJSON
+		areq.TimestampGranularities = []openai.TranscriptionTimestampGranularity{openai.TranscriptionTimestampGranularitySegment}
+		if words {
+			areq.TimestampGranularities = append(areq.TimestampGranularities, openai.TranscriptionTimestampGranularityWord)
+		}
+	}
+	ctx, cancel := deadline.Chunk.Context(ctx)
+	defer cancel()
+	var resp openai.AudioResponse
+	err := oai.Retry(ctx, fmt.Sprintf("whisper chunk %d", c.Index), func() (err error) {
+		resp, err = w.client.CreateTranscription(ctx, areq)
+		return err
+	})
+	if err != nil {
+		return Transcript{}, deadline.Err(ctx, err)
+	}
+	if !w.callerKey {
+		recordUsage(c.Duration)
+	}
+
+	t := Transcript{Text: resp.Text, Language: LanguageCode(resp.Language), Duration: resp.Duration}
+	if t.Language == "" {
+		t.Language = w.opts.Language
+	}
+	for idx, s := range resp.Segments {
+		t.Segments = append(t.Segments, Segment{
+			ID:               idx,
+			Start:            s.Start + c.Offset,
+			End:              s.End + c.Offset,
+			Text:             s.Text,
+			Temperature:      s.Temperature,
+			AvgLogprob:       s.AvgLogprob,
+			CompressionRatio: s.CompressionRatio,
+			NoSpeechProb:     s.NoSpeechProb,
+		})
+	}
+	for _, wd := range resp.Words {
+		t.Words = append(t.Words, Word{Word: wd.Word, Start: wd.Start + c.Offset, End: wd.End + c.Offset})
+	}
+	if !w.opts.timed() && strings.TrimSpace(resp.Text) != "" {
+		t.Duration = c.Duration
+		t.Segments = []Segment{{Start: c.Offset, End: c.Offset + c.Duration, Text: resp.Text}}
+	}
+	return t, nil
+}
@@ -0,0 +1,61 @@
+package transcribe
+
+import "strings"
+
+// whisperLanguages maps the ISO 639-1 codes Whisper knows (a few, like
+// "haw" and "yue", are longer) to the names its verbose JSON reports the
+// detected language as.
+var whisperLanguages = map[string]string{
+	"af": "afrikaans", "am": "amharic", "ar": "arabic", "as": "assamese", "az": "azerbaijani",
+	"ba": "bashkir", "be": "belarusian", "bg": "bulgarian", "bn": "bengali", "bo": "tibetan",
+	"br": "breton", "bs": "bosnian", "ca": "catalan", "cs": "czech", "cy": "welsh",
+	"da": "danish", "de": "german", "el": "greek", "en": "english", "es": "spanish",
+	"et": "estonian", "eu": "basque", "fa": "persian", "fi": "finnish", "fo": "faroese",
+	"fr": "french", "gl": "galician", "gu": "gujarati", "ha": "hausa", "haw": "hawaiian",
+	"he": "hebrew", "hi": "hindi", "hr": "croatian", "ht": "haitian creole", "hu": "hungarian",
+	"hy": "armenian", "id": "indonesian", "is": "icelandic", "it": "italian", "ja": "japanese",
+	"jw": "javanese", "ka": "georgian", "kk": "kazakh", "km": "khmer", "kn": "kannada",
+	"ko": "korean", "la": "latin", "lb": "luxembourgish", "ln": "lingala", "lo": "lao",
+	"lt": "lithuanian", "lv": "latvian", "mg": "malagasy", "mi": "maori", "mk": "macedonian",
+	"ml": "malayalam", "mn": "mongolian", "mr": "marathi", "ms": "malay", "mt": "maltese",
+	"my": "myanmar", "ne": "nepali", "nl": "dutch", "nn": "nynorsk", "no": "norwegian",
+	"oc": "occitan", "pa": "punjabi", "pl": "polish", "ps": "pashto", "pt": "portuguese",
+	"ro": "romanian", "ru": "russian", "sa": "sanskrit", "sd": "sindhi", "si": "sinhala",
+	"sk": "slovak", "sl": "slovenian", "sn": "shona", "so": "somali", "sq": "albanian",
+	"sr": "serbian", "su": "sundanese", "sv": "swedish", "sw": "swahili", "ta": "tamil",
+	"te": "telugu", "tg": "tajik", "th": "thai", "tk": "turkmen", "tl": "tagalog",
+	"tr": "turkish", "tt": "tatar", "uk": "ukrainian", "ur": "urdu", "uz": "uzbek",
+	"vi": "vietnamese", "yi": "yiddish", "yo": "yoruba", "yue": "cantonese", "zh": "chinese",
+}
+
+// whisperLanguageCodes is whisperLanguages the other way round.
+var whisperLanguageCodes = func() map[string]string {
+	codes := make(map[string]string, len(whisperLanguages))
+	for code, name := range whisperLanguages {
+		codes[name] = code
+	}
+	return codes
+}()
+
+// LanguageHint is the code Whisper takes for a language tag such as "pt-BR"
+// or "es", or "" when Whisper doesn't know the language and should detect it.
+func LanguageHint(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := whisperLanguages[tag]; ok {
+		return tag
+	}
+	return ""
+}
+
+// LanguageCode is the code of a language Whisper reported, by name
+// ("english") or already as a code; "" when it is unknown.
+func LanguageCode(reported string) string {
+	reported = strings.ToLower(strings.TrimSpace(reported))
+	if code, ok := whisperLanguageCodes[reported]; ok {
+		return code
+	}
+	return LanguageHint(reported)
+}
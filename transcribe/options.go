@@ -0,0 +1,128 @@
+package transcribe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/env"
+)
+
+// maxPromptLength bounds WhisperOptions.Prompt. Whisper only reads the last
+// 224 tokens of a prompt, which this comfortably covers.
+const maxPromptLength = 1000
+
+// WhisperOptions tune the transcription requests: the model, a language hint,
+// the sampling temperature and an initial prompt, e.g. product names and
+// other vocabulary the model would otherwise mishear. Zero fields mean the
+// server's default.
+type WhisperOptions struct {
+	// Model is "whisper-1", "gpt-4o-transcribe", "gpt-4o-mini-transcribe" or
+	// another model allowed by WHISPER_MODELS. Only Whisper models time their
+	// segments; with the others each chunk is one segment, so matches are
+	// only placed to the chunk.
+	Model string `json:"whisper_model,omitempty"`
+	// Language is the spoken language as an ISO 639-1 code; "" lets the model
+	// detect it
+	Language string `json:"whisper_language,omitempty"`
+	// Temperature is between 0 and 1; 0 lets Whisper raise it by itself when
+	// a chunk comes out garbled
+	Temperature float64 `json:"whisper_temperature,omitempty"`
+	Prompt      string  `json:"whisper_prompt,omitempty"`
+}
+
+// DefaultWhisperModels are the models WHISPER_MODELS allows by default.
+var DefaultWhisperModels = []string{openai.Whisper1, "gpt-4o-transcribe", "gpt-4o-mini-transcribe"}
+
+// WhisperOptionsFromEnv reads WHISPER_MODEL ("whisper-1"), WHISPER_LANGUAGE,
+// WHISPER_TEMPERATURE (0) and WHISPER_PROMPT.
+func WhisperOptionsFromEnv() WhisperOptions {
+	return WhisperOptions{
+		Model:       env.Or("WHISPER_MODEL", openai.Whisper1),
+		Language:    os.Getenv("WHISPER_LANGUAGE"),
+		Temperature: env.Float("WHISPER_TEMPERATURE", 0),
+		Prompt:      os.Getenv("WHISPER_PROMPT"),
+	}
+}
+
+// Merge returns o with the non-zero fields of override applied.
+func (o WhisperOptions) Merge(override WhisperOptions) WhisperOptions {
+	if override.Model != "" {
+		o.Model = override.Model
+	}
+	if override.Language != "" {
+		o.Language = override.Language
+	}
+	if override.Temperature != 0 {
+		o.Temperature = override.Temperature
+	}
+	if override.Prompt != "" {
+		o.Prompt = override.Prompt
+	}
+	return o
+}
+
+// WithDefaults fills the zero fields from WhisperOptionsFromEnv.
+func (o WhisperOptions) WithDefaults() WhisperOptions {
+	return WhisperOptionsFromEnv().Merge(o)
+}
+
+// Validate checks the model is one WHISPER_MODELS (default
+// DefaultWhisperModels) allows and the other options are in range.
+func (o WhisperOptions) Validate() error {
+	allowed := env.List("WHISPER_MODELS")
+	if len(allowed) == 0 {
+		allowed = DefaultWhisperModels
+	}
+	switch {
+	case !slices.Contains(allowed, o.Model):
+		return fmt.Errorf("whisper_model %q is not allowed (want %s)", o.Model, strings.Join(allowed, ", "))
+	case o.Language != "" && LanguageHint(o.Language) != o.Language:
+		return fmt.Errorf("whisper_language must be the ISO 639-1 code of a language Whisper knows, like \"en\", got %q", o.Language)
+	case o.Temperature < 0 || o.Temperature > 1:
+		return fmt.Errorf("whisper_temperature must be between 0 and 1, got %g", o.Temperature)
+	case len(o.Prompt) > maxPromptLength:
+		return fmt.Errorf("whisper_prompt must be at most %d characters", maxPromptLength)
+	}
+	return nil
+}
+
+// CacheKey tells transcripts made with these overrides apart from the
+// server's defaults in caches; it is "" when nothing is overridden.
+func (o WhisperOptions) CacheKey() string {
+	if o == (WhisperOptions{}) {
+		return ""
+	}
+	data, _ := json.Marshal(o)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// timed reports whether the model returns timed segments.
+func (o WhisperOptions) timed() bool {
+	return strings.HasPrefix(o.Model, "whisper")
+}
+
+type optionsContext struct{}
+
+// WithOptions returns a context whose transcriptions use o over the
+// server's defaults.
+func WithOptions(ctx context.Context, o WhisperOptions) context.Context {
+	if o == (WhisperOptions{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, optionsContext{}, o)
+}
+
+// Options are the overrides ctx carries (see WithOptions).
+func Options(ctx context.Context) WhisperOptions {
+	o, _ := ctx.Value(optionsContext{}).(WhisperOptions)
+	return o
+}
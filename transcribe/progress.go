@@ -0,0 +1,73 @@
+package transcribe
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Progress is how far a transcription has got. Times are in seconds; the ETA
+// extrapolates the rate so far over the audio still to transcribe.
+type Progress struct {
+	ChunksDone  int     `json:"chunks_done"`
+	ChunksTotal int     `json:"chunks_total"`
+	AudioDone   float64 `json:"audio_done"`
+	AudioTotal  float64 `json:"audio_total"`
+	Elapsed     float64 `json:"elapsed"`
+	ETA         float64 `json:"eta,omitempty"`
+}
+
+// ProgressFunc receives progress updates; it may be called from several
+// goroutines, one call at a time.
+type ProgressFunc func(Progress)
+
+// ProgressTracker counts finished chunks, logging each step and passing it
+// on to a ProgressFunc. It is safe for concurrent use.
+type ProgressTracker struct {
+	label string
+	fn    ProgressFunc
+	start time.Time
+
+	mu sync.Mutex
+	p  Progress
+}
+
+// NewProgressTracker starts tracking a transcription of chunks; fn may be nil.
+// label names the transcription in logs.
+func NewProgressTracker(label string, chunks []Chunk, fn ProgressFunc) *ProgressTracker {
+	t := &ProgressTracker{label: label, fn: fn, start: time.Now()}
+	t.p.ChunksTotal = len(chunks)
+	for _, c := range chunks {
+		t.p.AudioTotal += c.Duration
+	}
+	if fn != nil {
+		fn(t.p)
+	}
+	return t
+}
+
+// ChunkDone records a transcribed chunk.
+func (t *ProgressTracker) ChunkDone(c Chunk) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p.ChunksDone++
+	t.p.AudioDone += c.Duration
+	t.p.Elapsed = round1(time.Since(t.start).Seconds())
+	t.p.ETA = 0
+	if t.p.AudioDone > 0 && t.p.AudioDone < t.p.AudioTotal {
+		t.p.ETA = round1(t.p.Elapsed * (t.p.AudioTotal - t.p.AudioDone) / t.p.AudioDone)
+	}
+	log.Printf("%s: chunk %d/%d transcribed (%.0fs of %.0fs audio), elapsed %s, ETA %s",
+		t.label, t.p.ChunksDone, t.p.ChunksTotal, t.p.AudioDone, t.p.AudioTotal,
+		secondsString(t.p.Elapsed), secondsString(t.p.ETA))
+	if t.fn != nil {
+		t.fn(t.p)
+	}
+}
+
+func round1(f float64) float64 { return math.Round(f*10) / 10 }
+
+func secondsString(s float64) string {
+	return (time.Duration(s) * time.Second).String()
+}
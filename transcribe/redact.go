@@ -0,0 +1,208 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"searchme/internal/oai"
+	"searchme/subtitle"
+)
+
+const (
+	redactedEmail = "[EMAIL]"
+	redactedPhone = "[PHONE]"
+	redactedName  = "[NAME]"
+
+	// keep LLM prompts well under model context limits
+	redactLLMChunkChars = 8000
+)
+
+var (
+	emailRegex = regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`)
+	// international or local numbers with optional separators, at least 7 digits
+	phoneRegex = regexp.MustCompile(`\+?\d[\d\s().\-]{5,}\d`)
+)
+
+// Redactor masks PII (emails, phone numbers and, with an LLM, person names)
+// in transcripts before they are stored or exported.
+type Redactor struct {
+	client *openai.Client
+	model  string
+}
+
+// NewRedactorFromEnv returns a redactor configured by REDACT_PII
+// ("regex" or "llm"), or nil when redaction is disabled.
+// The LLM pass reuses the given client; REDACT_MODEL overrides the chat model.
+func NewRedactorFromEnv(client *openai.Client) *Redactor {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("REDACT_PII")))
+	switch mode {
+	case "regex":
+		return &Redactor{}
+	case "llm":
+		model := os.Getenv("REDACT_MODEL")
+		if model == "" {
+			model = openai.GPT4oMini
+		}
+		return &Redactor{client: client, model: model}
+	default:
+		return nil
+	}
+}
+
+// PatternRedactorFromEnv is the regex pass of REDACT_PII without the LLM's
+// name detection, or nil when redaction is disabled. It is cheap enough for
+// transcripts on their way out, to cover ones stored before redaction was
+// turned on.
+func PatternRedactorFromEnv() *Redactor {
+	if NewRedactorFromEnv(nil) == nil {
+		return nil
+	}
+	return &Redactor{}
+}
+
+// Redactor is the redactor REDACT_PII configures on w's client, or nil when
+// redaction is disabled.
+func (w *Whisper) Redactor() *Redactor {
+	return NewRedactorFromEnv(w.client)
+}
+
+// Redact masks emails and phone numbers, then the given names.
+func (r *Redactor) Redact(text string, names []string) string {
+	text = emailRegex.ReplaceAllString(text, redactedEmail)
+	text = phoneRegex.ReplaceAllStringFunc(text, func(s string) string {
+		digits := 0
+		for _, c := range s {
+			if c >= '0' && c <= '9' {
+				digits++
+			}
+		}
+		if digits < 7 {
+			return s
+		}
+		return redactedPhone
+	})
+	for _, name := range names {
+		re, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`)
+		if err != nil {
+			continue
+		}
+		text = re.ReplaceAllString(text, redactedName)
+	}
+	return text
+}
+
+// RedactTranscript masks PII in the transcript text and every segment in place.
+func (r *Redactor) RedactTranscript(ctx context.Context, t *Transcript) {
+	names := r.detectNames(ctx, t.Text)
+	t.Text = r.Redact(t.Text, names)
+	for i := range t.Segments {
+		t.Segments[i].Text = r.Redact(t.Segments[i].Text, names)
+	}
+	// names span several words; mask any word that is part of a detected name
+	nameParts := map[string]bool{}
+	for _, n := range names {
+		for _, p := range strings.Fields(strings.ToLower(n)) {
+			nameParts[p] = true
+		}
+	}
+	for i := range t.Words {
+		w := r.Redact(t.Words[i].Word, names)
+		if nameParts[strings.ToLower(strings.Trim(w, ".,!?;:'\"()"))] {
+			w = redactedName
+		}
+		t.Words[i].Word = w
+	}
+}
+
+// RedactEntries masks PII in the text of every entry in place.
+func (r *Redactor) RedactEntries(ctx context.Context, entries []subtitle.Entry) {
+	texts := make([]string, len(entries))
+	for i, e := range entries {
+		texts[i] = e.Text
+	}
+	names := r.detectNames(ctx, strings.Join(texts, " "))
+	for i := range entries {
+		entries[i].Text = r.Redact(entries[i].Text, names)
+	}
+}
+
+// detectNames asks the LLM for person names mentioned in text.
+// Failures are logged and treated as "no names" so regex redaction still applies.
+func (r *Redactor) detectNames(ctx context.Context, text string) []string {
+	if r.client == nil || strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for _, chunk := range splitForLLM(text, redactLLMChunkChars) {
+		var resp openai.ChatCompletionResponse
+		err := oai.Retry(ctx, "name redaction", func() (err error) {
+			resp, err = r.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+				Model:       r.model,
+				Temperature: 0,
+				Messages: []openai.ChatCompletionMessage{
+					{
+						Role:    openai.ChatMessageRoleSystem,
+						Content: `List every person name mentioned in the user's text. Reply with a JSON array of strings only, e.g. ["Jane Doe"]. Reply [] if there are none.`,
+					},
+					{Role: openai.ChatMessageRoleUser, Content: chunk},
+				},
+			})
+			return err
+		})
+		if err != nil {
+			log.Printf("name redaction failed: %v", err)
+			continue
+		}
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		var found []string
+		content := strings.TrimSpace(resp.Choices[0].Message.Content)
+		content = strings.TrimSuffix(strings.TrimPrefix(content, "```json"), "```")
+		if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &found); err != nil {
+			log.Printf("name redaction: unexpected model output: %v", err)
+			continue
+		}
+		for _, n := range found {
+			if n = strings.TrimSpace(n); len(n) > 1 {
+				seen[n] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	// replace longer names first so "Jane Doe" wins over "Jane"
+	sort.Slice(names, func(a, b int) bool { return len(names[a]) > len(names[b]) })
+	return names
+}
+
+// splitForLLM splits text on whitespace into pieces of at most max bytes.
+func splitForLLM(text string, max int) []string {
+	var chunks []string
+	var b strings.Builder
+	for _, w := range strings.Fields(text) {
+		if b.Len()+len(w)+1 > max && b.Len() > 0 {
+			chunks = append(chunks, b.String())
+			b.Reset()
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(w)
+	}
+	if b.Len() > 0 {
+		chunks = append(chunks, b.String())
+	}
+	return chunks
+}
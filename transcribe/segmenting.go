@@ -0,0 +1,127 @@
+package transcribe
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SegmentRules bound segment durations in seconds; zero disables a bound.
+type SegmentRules struct {
+	MinDuration float64
+	MaxDuration float64
+	// MaxMergeGap is the longest pause that may be bridged when merging
+	MaxMergeGap float64
+}
+
+// SegmentRulesFromEnv reads SEGMENT_MIN_SECONDS (default 1) and
+// SEGMENT_MAX_SECONDS (default 20); set either to 0 to disable it.
+func SegmentRulesFromEnv() SegmentRules {
+	rules := SegmentRules{MinDuration: 1, MaxDuration: 20, MaxMergeGap: 1.5}
+	if v, err := strconv.ParseFloat(os.Getenv("SEGMENT_MIN_SECONDS"), 64); err == nil && v >= 0 {
+		rules.MinDuration = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("SEGMENT_MAX_SECONDS"), 64); err == nil && v >= 0 {
+		rules.MaxDuration = v
+	}
+	return rules
+}
+
+// NormalizeSegments splits segments longer than MaxDuration at the longest pause
+// between words and merges segments shorter than MinDuration into a neighbour.
+// IDs are renumbered.
+func NormalizeSegments(segs []Segment, words []Word, rules SegmentRules) []Segment {
+	var out []Segment
+	if rules.MaxDuration > 0 {
+		wi := 0
+		for _, s := range segs {
+			// words are sorted, so each segment takes the run that starts inside it
+			var segWords []Word
+			for wi < len(words) && words[wi].Start < s.Start {
+				wi++
+			}
+			for wi < len(words) && words[wi].Start < s.End {
+				segWords = append(segWords, words[wi])
+				wi++
+			}
+			out = append(out, splitSegment(s, segWords, rules.MaxDuration)...)
+		}
+	} else {
+		out = append(out, segs...)
+	}
+
+	if rules.MinDuration > 0 {
+		out = mergeShortSegments(out, rules)
+	}
+	for i := range out {
+		out[i].ID = i
+	}
+	return out
+}
+
+// splitSegment recursively cuts s at the largest gap between its words until every
+// piece fits in max. Segments without word timings are left alone.
+func splitSegment(s Segment, words []Word, max float64) []Segment {
+	if s.End-s.Start <= max || len(words) < 2 {
+		return []Segment{s}
+	}
+
+	// prefer the segment's own tokens so punctuation survives the split
+	tokens := strings.Fields(s.Text)
+	if len(tokens) != len(words) {
+		tokens = make([]string, len(words))
+		for i, w := range words {
+			tokens[i] = strings.TrimSpace(w.Word)
+		}
+	}
+
+	cut, bestGap := 1, -1.0
+	mid := (s.Start + s.End) / 2
+	for i := 1; i < len(words); i++ {
+		gap := words[i].Start - words[i-1].End
+		// break ties toward the middle so pieces stay balanced
+		if gap > bestGap || (gap == bestGap && math.Abs(words[i].Start-mid) < math.Abs(words[cut].Start-mid)) {
+			cut, bestGap = i, gap
+		}
+	}
+
+	left, right := s, s
+	left.End = words[cut-1].End
+	left.Text = strings.Join(tokens[:cut], " ")
+	right.Start = words[cut].Start
+	right.Text = strings.Join(tokens[cut:], " ")
+	return append(splitSegment(left, words[:cut], max), splitSegment(right, words[cut:], max)...)
+}
+
+// mergeShortSegments folds too-short segments into the previous one when the pause
+// between them is small and the result stays within MaxDuration.
+func mergeShortSegments(segs []Segment, rules SegmentRules) []Segment {
+	var out []Segment
+	for _, s := range segs {
+		if n := len(out); n > 0 {
+			last := &out[n-1]
+			short := last.End-last.Start < rules.MinDuration || s.End-s.Start < rules.MinDuration
+			fits := rules.MaxDuration <= 0 || s.End-last.Start <= rules.MaxDuration
+			if short && fits && s.Start-last.End <= rules.MaxMergeGap {
+				mergeInto(last, s)
+				continue
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// mergeInto appends s to dst, combining quality stats weighted by duration.
+func mergeInto(dst *Segment, s Segment) {
+	dw, sw := dst.End-dst.Start, s.End-s.Start
+	if total := dw + sw; total > 0 {
+		dst.AvgLogprob = (dst.AvgLogprob*dw + s.AvgLogprob*sw) / total
+		dst.CompressionRatio = (dst.CompressionRatio*dw + s.CompressionRatio*sw) / total
+	}
+	dst.NoSpeechProb = math.Max(dst.NoSpeechProb, s.NoSpeechProb)
+	dst.Text = strings.TrimSpace(dst.Text) + " " + strings.TrimSpace(s.Text)
+	dst.Tokens = append(dst.Tokens, s.Tokens...)
+	dst.End = s.End
+}
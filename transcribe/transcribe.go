@@ -0,0 +1,238 @@
+// Package transcribe turns media audio into timed transcripts with Whisper:
+// chunking with ffmpeg, concurrent transcription, segment normalization and
+// optional PII redaction.
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"searchme/artifact"
+	"searchme/events"
+	"searchme/internal/env"
+	"searchme/internal/workfile"
+	"searchme/media"
+	"searchme/subtitle"
+)
+
+// JSON Transcript structure
+type Segment struct {
+	ID               int     `json:"id"`
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
+	Text             string  `json:"text"`
+	Tokens           []int   `json:"tokens"`
+	Temperature      float64 `json:"temperature"`
+	AvgLogprob       float64 `json:"avg_logprob"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	NoSpeechProb     float64 `json:"no_speech_prob"`
+}
+
+// Word-level timing from Whisper
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Transcript is a Whisper verbose JSON transcript with absolute timestamps.
+type Transcript struct {
+	Text string `json:"text"`
+	// Language is the ISO 639-1 code of the language heard (see LanguageCode)
+	Language string    `json:"language"`
+	Duration float64   `json:"duration"`
+	Segments []Segment `json:"segments"`
+	Words    []Word    `json:"words,omitempty"`
+	// Gaps are stretches whose chunks failed to transcribe even after retries
+	Gaps []Gap `json:"gaps,omitempty"`
+}
+
+// Gap is audio missing from a transcript, in seconds.
+type Gap struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Error string  `json:"error"`
+}
+
+// ErrTranscriptionFailed is wrapped by errors of searches and transcripts
+// whose Whisper transcription failed.
+var ErrTranscriptionFailed = errors.New("transcription failed")
+
+// maxFailedChunkFraction is the share of chunks, MAX_FAILED_CHUNK_FRACTION
+// (default 0.1), that may fail before a whole transcription is given up.
+func maxFailedChunkFraction() float64 {
+	return env.Float("MAX_FAILED_CHUNK_FRACTION", 0.1)
+}
+
+// ToFile transcribes the source's audio and saves the full transcript as a
+// (possibly encrypted) JSON artifact, returning its path. progress may be nil.
+// ctx may carry a caller's OpenAI key (see oai.WithKey).
+func ToFile(ctx context.Context, src media.VideoSource, progress ProgressFunc) (string, error) {
+	log.Println("Downloading compressed audio...")
+	audio, err := src.DownloadAudio(ctx)
+	if err != nil {
+		return "", err
+	}
+	log.Println("Audio downloaded:", audio.Path)
+	defer audio.Remove()
+
+	merged, err := Audio(ctx, audio, progress)
+	if err != nil {
+		return "", err
+	}
+
+	// 4️⃣ احفظ النتيجة كاملة (فيها text + segments)
+	transcriptFile := workfile.Path("transcript_segments") + ".json"
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal transcription: %w", err)
+	}
+	if err := artifact.Write(transcriptFile, data); err != nil {
+		return "", fmt.Errorf("failed to save transcript: %w", err)
+	}
+
+	return transcriptFile, nil
+}
+
+// Audio chunks the audio with ffmpeg, transcribes the chunks concurrently
+// with Whisper and merges them into one transcript with absolute timestamps.
+// Progress is logged per chunk and passed to progress, which may be nil.
+// Whisper bills the caller's key in ctx, if any (see oai.WithKey). Chunks that still fail after retries become Gaps in the transcript, as long
+// as they are at most MAX_FAILED_CHUNK_FRACTION of the audio; past that, or
+// when every chunk fails, the transcription fails. The transcript's Language
+// is the one heard longest.
+func Audio(ctx context.Context, audio *media.AudioFile, progress ProgressFunc) (Transcript, error) {
+	chunksDir := workfile.Path("chunks")
+	_ = os.RemoveAll(chunksDir)
+	if err := os.MkdirAll(chunksDir, 0755); err != nil {
+		return Transcript{}, fmt.Errorf("failed to create chunks dir: %w", err)
+	}
+	defer os.RemoveAll(chunksDir)
+
+	chunks, err := Split(ctx, audio, chunksDir, 0)
+	if err != nil {
+		return Transcript{}, err
+	}
+
+	whisper, err := NewWhisper(ctx)
+	if err != nil {
+		return Transcript{}, err
+	}
+
+	type chunkResult struct {
+		index      int
+		transcript Transcript
+		err        error
+	}
+
+	tracker := NewProgressTracker("transcribe "+audio.Path, chunks, progress)
+	results := make([]chunkResult, len(chunks))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 4) // limit concurrency
+
+	for i, chunk := range chunks {
+		i, chunk := i, chunk
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t, err := whisper.Chunk(ctx, chunk, true)
+			results[i] = chunkResult{index: i, transcript: t, err: err}
+			if err == nil {
+				tracker.ChunkDone(chunk)
+				events.Emit(events.ChunkTranscribed, "", map[string]interface{}{"chunk": i, "chunks": len(chunks), "offset": chunk.Offset})
+			}
+		}()
+	}
+	wg.Wait()
+
+	var merged Transcript
+	var firstErr error
+	for _, r := range results {
+		if r.err != nil {
+			c := chunks[r.index]
+			log.Printf("chunk %d transcription failed, leaving a gap at %.0fs: %v", r.index, c.Offset, r.err)
+			merged.Gaps = append(merged.Gaps, Gap{Start: c.Offset, End: c.Offset + c.Duration, Error: r.err.Error()})
+			if firstErr == nil {
+				firstErr = fmt.Errorf("chunk %d transcription failed: %w", r.index, r.err)
+			}
+		}
+	}
+	if failed := len(merged.Gaps); failed == len(chunks) || float64(failed) > maxFailedChunkFraction()*float64(len(chunks)) {
+		if failed > 1 {
+			return Transcript{}, fmt.Errorf("%d of %d chunks failed: %w", failed, len(chunks), firstErr)
+		}
+		return Transcript{}, firstErr
+	}
+
+	sort.Slice(results, func(a, b int) bool { return results[a].index < results[b].index })
+	var mergedTextParts []string
+	heard := map[string]float64{}
+	for _, r := range results {
+		if r.err == nil && r.transcript.Language != "" {
+			heard[r.transcript.Language] += chunks[r.index].Duration
+		}
+		merged.Segments = append(merged.Segments, r.transcript.Segments...)
+		merged.Words = append(merged.Words, r.transcript.Words...)
+		if r.transcript.Text != "" {
+			mergedTextParts = append(mergedTextParts, r.transcript.Text)
+		}
+	}
+	merged.Text = strings.Join(mergedTextParts, " ")
+	// the language heard longest speaks for the whole recording
+	for lang, seconds := range heard {
+		if seconds > heard[merged.Language] || (seconds == heard[merged.Language] && lang < merged.Language) {
+			merged.Language = lang
+		}
+	}
+	if len(merged.Segments) > 0 {
+		merged.Duration = merged.Segments[len(merged.Segments)-1].End
+	}
+	if len(merged.Gaps) > 0 {
+		// the last chunk may be the one missing
+		last := chunks[len(chunks)-1]
+		merged.Duration = max(merged.Duration, last.Offset+last.Duration)
+	}
+	merged.Segments = NormalizeSegments(merged.Segments, merged.Words, SegmentRulesFromEnv())
+
+	if redactor := NewRedactorFromEnv(whisper.client); redactor != nil {
+		redactor.RedactTranscript(ctx, &merged)
+	}
+
+	return merged, nil
+}
+
+// ReadFile loads a stored (possibly encrypted) transcript JSON file.
+func ReadFile(path string) (Transcript, error) {
+	var t Transcript
+	data, err := artifact.Read(path)
+	if err != nil {
+		return t, fmt.Errorf("failed to read transcript file: %w", err)
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("failed to parse JSON transcript: %w", err)
+	}
+	// older transcripts name the language as Whisper did
+	t.Language = LanguageCode(t.Language)
+	return t, nil
+}
+
+// Entries flattens Whisper segments into subtitle entries.
+func Entries(t Transcript) []subtitle.Entry {
+	entries := make([]subtitle.Entry, 0, len(t.Segments))
+	for _, s := range t.Segments {
+		entries = append(entries, subtitle.Entry{
+			Start: s.Start, End: s.End, Text: s.Text,
+			NoSpeechProb: s.NoSpeechProb, AvgLogprob: s.AvgLogprob, CompressionRatio: s.CompressionRatio,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,65 @@
+package transcribe
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// usageDays is how many days of Whisper usage are kept.
+const usageDays = 30
+
+// DayUsage is the audio sent to Whisper on one UTC day.
+type DayUsage struct {
+	Date    string  `json:"date"`
+	Seconds float64 `json:"seconds"`
+	Chunks  int     `json:"chunks"`
+}
+
+var (
+	usageMu sync.Mutex
+	usage   = map[string]*DayUsage{}
+)
+
+// recordUsage counts a chunk Whisper transcribed, whoever asked for it.
+func recordUsage(seconds float64) {
+	date := time.Now().UTC().Format(time.DateOnly)
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	d := usage[date]
+	if d == nil {
+		d = &DayUsage{Date: date}
+		usage[date] = d
+		cutoff := time.Now().UTC().AddDate(0, 0, -usageDays).Format(time.DateOnly)
+		for day := range usage {
+			if day < cutoff {
+				delete(usage, day)
+			}
+		}
+	}
+	d.Seconds += seconds
+	d.Chunks++
+}
+
+// UsageToday is the audio transcribed so far on the current UTC day.
+func UsageToday() DayUsage {
+	date := time.Now().UTC().Format(time.DateOnly)
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	if d := usage[date]; d != nil {
+		return *d
+	}
+	return DayUsage{Date: date}
+}
+
+// Usage returns the per-day usage since startup (at most 30 days), newest first.
+func Usage() []DayUsage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	out := make([]DayUsage, 0, len(usage))
+	for _, d := range usage {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date > out[j].Date })
+	return out
+}
@@ -0,0 +1,152 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"searchme/internal/env"
+	"searchme/media"
+)
+
+// VADConfig sets how silence is found and left out before transcription.
+type VADConfig struct {
+	// Enabled turns silence skipping on
+	Enabled bool
+	// NoiseDB is the level, in dB, below which audio counts as silence
+	NoiseDB float64
+	// MinSilence is the shortest silence, in seconds, that is skipped;
+	// shorter pauses stay inside the speech around them
+	MinSilence float64
+}
+
+// VADConfigFromEnv reads SKIP_SILENCE (off; "1" or "true" turns it on),
+// SILENCE_NOISE_DB (-35) and SILENCE_MIN_SECONDS (2).
+func VADConfigFromEnv() VADConfig {
+	enabled, _ := strconv.ParseBool(os.Getenv("SKIP_SILENCE"))
+	return VADConfig{
+		Enabled:    enabled,
+		NoiseDB:    env.Float("SILENCE_NOISE_DB", -35),
+		MinSilence: env.Float("SILENCE_MIN_SECONDS", 2),
+	}
+}
+
+// speechPadding is kept either side of speech, in seconds, so words
+// trailing into a silence aren't clipped.
+const speechPadding = 0.25
+
+// minSkippedFraction is how much of the audio must be silence for skipping
+// it to be worth cutting the audio up by speech.
+const minSkippedFraction = 0.05
+
+// Span is a stretch of audio in seconds.
+type Span struct {
+	Start float64
+	End   float64
+}
+
+var (
+	silenceStart = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEnd   = regexp.MustCompile(`silence_end: (-?[0-9.]+)`)
+)
+
+// DetectSilence finds the silences of at least cfg.MinSilence in the audio
+// file with ffmpeg's silencedetect filter. total is the audio's length,
+// closing a silence that runs to the end.
+func DetectSilence(ctx context.Context, path string, total float64, cfg VADConfig) ([]Span, error) {
+	filter := fmt.Sprintf("silencedetect=noise=%gdB:d=%g", cfg.NoiseDB, cfg.MinSilence)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-nostats", "-i", path, "-af", filter, "-f", "null", "-")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("silence detection failed: %w", err)
+	}
+	var silences []Span
+	open := -1.0
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := silenceStart.FindStringSubmatch(line); m != nil {
+			open, _ = strconv.ParseFloat(m[1], 64)
+			open = max(open, 0)
+		} else if m := silenceEnd.FindStringSubmatch(line); m != nil && open >= 0 {
+			end, _ := strconv.ParseFloat(m[1], 64)
+			silences = append(silences, Span{Start: open, End: end})
+			open = -1
+		}
+	}
+	if open >= 0 && total > open {
+		silences = append(silences, Span{Start: open, End: total})
+	}
+	return silences, nil
+}
+
+// speechSpans is what of 0..total isn't silence, each span padded by
+// speechPadding and cut to at most chunkSeconds.
+func speechSpans(silences []Span, total float64, chunkSeconds int) []Span {
+	var spans []Span
+	at := 0.0
+	add := func(start, end float64) {
+		start, end = max(start-speechPadding, 0), min(end+speechPadding, total)
+		if n := len(spans); n > 0 && start < spans[n-1].End {
+			start = spans[n-1].End
+		}
+		for start < end {
+			stop := min(end, start+float64(chunkSeconds))
+			spans = append(spans, Span{Start: start, End: stop})
+			start = stop
+		}
+	}
+	for _, s := range silences {
+		if s.Start > at {
+			add(at, s.Start)
+		}
+		at = max(at, s.End)
+	}
+	if at < total {
+		add(at, total)
+	}
+	return spans
+}
+
+// splitSpeech cuts the speech of audio into chunks in dir, leaving out its
+// silences, or reports false when there is too little silence to bother,
+// nothing but silence, or it can't be found. Each chunk's Offset is where
+// its speech starts in the audio, so timestamps stay absolute.
+func splitSpeech(ctx context.Context, audio *media.AudioFile, dir string, total float64, chunkSeconds int, settings media.AudioSettings, cfg VADConfig) ([]Chunk, bool, error) {
+	silences, err := DetectSilence(ctx, audio.Path, total, cfg)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, err
+		}
+		log.Printf("skipping silence: %v; transcribing all of %s", err, audio.Path)
+		return nil, false, nil
+	}
+	spans := speechSpans(silences, total, chunkSeconds)
+	var speech float64
+	for _, s := range spans {
+		speech += s.End - s.Start
+	}
+	if len(spans) == 0 || total-speech < minSkippedFraction*total {
+		return nil, false, nil
+	}
+	log.Printf("skipping %.0fs of silence in %.0fs of %s (%d speech chunks)", total-speech, total, audio.Path, len(spans))
+
+	chunks := make([]Chunk, 0, len(spans))
+	for i, s := range spans {
+		path := filepath.Join(dir, fmt.Sprintf("speech_%03d.mp3", i))
+		args := []string{"-hide_banner", "-loglevel", "error",
+			"-ss", strconv.FormatFloat(s.Start, 'f', 3, 64), "-t", strconv.FormatFloat(s.End-s.Start, 'f', 3, 64),
+			"-i", audio.Path}
+		args = append(args, settings.FFmpegArgs()...)
+		if out, err := exec.CommandContext(ctx, "ffmpeg", append(args, "-y", path)...).CombinedOutput(); err != nil {
+			log.Printf("ffmpeg speech chunk error: %s", string(out))
+			return nil, false, fmt.Errorf("failed to cut speech chunk %d: %w", i, err)
+		}
+		chunks = append(chunks, Chunk{Index: i, Path: path, Offset: s.Start, Duration: s.End - s.Start})
+	}
+	return chunks, true, nil
+}
@@ -0,0 +1,68 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"searchme/internal/deadline"
+	"searchme/internal/env"
+	"searchme/internal/workfile"
+)
+
+// WhisperCpp transcribes locally with a whisper.cpp build and a small ggml
+// model: free and quick, too rough to answer a search, but good enough to
+// tell which chunks are worth sending to the API.
+type WhisperCpp struct {
+	// Binary is the whisper.cpp command line tool
+	Binary string
+	// Model is the path of a ggml model, e.g. ggml-tiny.bin
+	Model string
+	// Threads is how many CPU threads one draft uses
+	Threads int
+}
+
+// WhisperCppFromEnv reads WHISPER_CPP_MODEL (unset turns local drafts off),
+// WHISPER_CPP_PATH ("whisper-cli") and WHISPER_CPP_THREADS (2). It is nil
+// when there is no model.
+func WhisperCppFromEnv() *WhisperCpp {
+	model := os.Getenv("WHISPER_CPP_MODEL")
+	if model == "" {
+		return nil
+	}
+	return &WhisperCpp{
+		Binary:  env.Or("WHISPER_CPP_PATH", "whisper-cli"),
+		Model:   model,
+		Threads: env.Int("WHISPER_CPP_THREADS", 2),
+	}
+}
+
+// Draft transcribes chunk roughly as plain text. lang "" lets the model
+// detect the language. whisper.cpp only reads 16 kHz WAV, so the chunk is
+// converted first.
+func (w *WhisperCpp) Draft(ctx context.Context, chunk Chunk, lang string) (string, error) {
+	ctx, cancel := deadline.Chunk.Context(ctx)
+	defer cancel()
+	wav := workfile.Path("draft") + ".wav"
+	defer os.Remove(wav)
+	conv := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-i", chunk.Path, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", "-y", wav)
+	if out, err := conv.CombinedOutput(); err != nil {
+		log.Printf("ffmpeg draft conversion error: %s", string(out))
+		return "", fmt.Errorf("failed to convert chunk %d for drafting: %w", chunk.Index, deadline.Err(ctx, err))
+	}
+	if lang == "" {
+		lang = "auto"
+	}
+	cmd := exec.CommandContext(ctx, w.Binary, "-m", w.Model, "-f", wav,
+		"-l", lang, "-t", strconv.Itoa(max(w.Threads, 1)), "-nt", "-np")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("whisper.cpp failed on chunk %d: %w", chunk.Index, deadline.Err(ctx, err))
+	}
+	return strings.Join(strings.Fields(string(out)), " "), nil
+}